@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// clientFlags 表示各子命令共用的 registry 配置和凭据参数
+type clientFlags struct {
+	registriesConfig string
+	credentials      repeatedFlag
+	bearerTokens     repeatedFlag
+	vault            vaultFlags
+	log              logFlags
+	tls              tlsFlags
+}
+
+// register 在 fs 中注册 registry 配置、凭据和 TLS 参数
+func (f *clientFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.registriesConfig, "registries-config", "", "registry 配置文件 (JSON，可选)")
+	fs.Var(&f.credentials, "credentials", "凭据 (可重复使用)，格式: registry:username:token")
+	fs.Var(&f.bearerTokens, "bearer-token", "预先获取的 bearer token (可重复使用)，格式: registry=token")
+	f.vault.register(fs)
+	f.log.register(fs)
+	f.tls.register(fs)
+	registerExitPolicy(fs)
+}
+
+// newClient 加载 registry 配置文件，创建配置了凭据的客户端
+// 凭据的优先级为 -credentials 等参数、环境变量、login 保存的凭据、Vault
+// 环境变量 DOCKER_MANIFEST_READ_ONLY 要求只读模式时启用只读模式
+func (f *clientFlags) newClient(timeout time.Duration) (*registry.Client, error) {
+	if f.registriesConfig != "" {
+		if err := registry.LoadRegistriesFile(f.registriesConfig); err != nil {
+			return nil, err
+		}
+	}
+	client := registry.NewClient().WithTimeout(timeout)
+	if err := f.log.configure(client); err != nil {
+		return nil, err
+	}
+	if err := f.tls.configure(client); err != nil {
+		return nil, err
+	}
+	if envReadOnly() {
+		client.WithReadOnly(true)
+	}
+	addStoredCredentials(client)
+	addEnvCredentials(client)
+	addFlagCredentials(client, f.credentials, f.bearerTokens)
+	if err := f.vault.configure(client); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// addFlagCredentials 为客户端添加 -credentials 和 -bearer-token 指定的凭据，格式错误的值输出警告后跳过
+func addFlagCredentials(client *registry.Client, credentialsList, bearerTokenList repeatedFlag) {
+	for _, cred := range credentialsList {
+		parts := strings.SplitN(cred, ":", 3)
+		if len(parts) != 3 {
+			fmt.Fprintf(os.Stderr, "警告: 凭据格式错误，应为 registry:username:token，跳过: %s\n", cred)
+			continue
+		}
+		client.AddCredential(parts[0], parts[1], parts[2])
+	}
+	for _, value := range bearerTokenList {
+		registryKey, token, ok := strings.Cut(value, "=")
+		if !ok || registryKey == "" || token == "" {
+			fmt.Fprintf(os.Stderr, "警告: bearer token 格式错误，应为 registry=token，已跳过\n")
+			continue
+		}
+		client.AddBearerToken(registryKey, token)
+	}
+}
+
+// envCredentialPrefix 为通过环境变量传递凭据时变量名的前缀，格式: DOCKER_MANIFEST_CRED_<KEY>=username:token
+const envCredentialPrefix = "DOCKER_MANIFEST_CRED_"
+
+// addEnvCredentials 为客户端添加环境变量中的凭据，返回添加了凭据的 registry key，避免 token 出现在 shell 历史和进程列表中
+// 支持 DOCKER_MANIFEST_CRED_<KEY>=username:token，以及 DOCKERHUB_USERNAME/DOCKERHUB_TOKEN、GHCR_USERNAME/GHCR_TOKEN
+// 应在加载 registry 配置文件之后调用，<KEY> 按 envCredentialKey 的规则对应 registry key；格式错误的值输出警告后跳过
+func addEnvCredentials(client *registry.Client) []string {
+	var keys []string
+	for _, pair := range []struct{ key, prefix string }{
+		{registry.DockerHubKey, "DOCKERHUB"},
+		{registry.GHCRKey, "GHCR"},
+	} {
+		username, token := os.Getenv(pair.prefix+"_USERNAME"), os.Getenv(pair.prefix+"_TOKEN")
+		if username != "" && token != "" {
+			client.AddCredential(pair.key, username, token)
+			keys = append(keys, pair.key)
+		}
+	}
+
+	environ := os.Environ()
+	sort.Strings(environ)
+	for _, env := range environ {
+		name, value, _ := strings.Cut(env, "=")
+		suffix, ok := strings.CutPrefix(name, envCredentialPrefix)
+		if !ok || suffix == "" {
+			continue
+		}
+		username, token, ok := strings.Cut(value, ":")
+		if !ok || username == "" || token == "" {
+			fmt.Fprintf(os.Stderr, "警告: 环境变量 %s 格式错误，应为 username:token，已跳过\n", name)
+			continue
+		}
+		key := envCredentialKey(suffix)
+		client.AddCredential(key, username, token)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// envCredentialKey 返回 DOCKER_MANIFEST_CRED_<KEY> 中 <KEY> 对应的 registry key
+// 环境变量名只能包含字母、数字和下划线，已注册的 registry key 中的其他字符（如 - 和 .）对应下划线，不区分大小写，
+// 如 MY_HARBOR 对应 my-harbor；没有对应的已注册 registry 时使用 <KEY> 的小写形式
+func envCredentialKey(suffix string) string {
+	for key := range registry.ListRegistries() {
+		if strings.EqualFold(envName(key), suffix) {
+			return key
+		}
+	}
+	return strings.ToLower(suffix)
+}
+
+// envName 将 registry key 中字母、数字以外的字符替换为下划线
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// parseImageAndTag 解析镜像名称和标签
+// 如果镜像名中包含标签（如 nginx:1.19）或 digest（如 nginx@sha256:...），使用镜像中的标签或 digest
+// 否则使用默认标签；域名中的端口（如 harbor.example.com:8443/team/app）不视为标签
+func parseImageAndTag(image string, defaultTag string) (string, string) {
+	if name, digest, ok := strings.Cut(image, "@"); ok {
+		return name, digest
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		// 镜像名中包含标签
+		return image[:i], image[i+1:]
+	}
+	// 使用默认标签
+	return image, defaultTag
+}
+
+// canonicalReference 返回镜像引用的规范形式 "<registry 域名>/<仓库>:<标签>"，标签为 digest 时以 @ 连接
+// 未指定标签时使用 defaultTag，都为空时使用 registry 配置的默认标签，未配置时为 latest
+func canonicalReference(image, defaultTag string) string {
+	name, tag := parseImageAndTag(image, defaultTag)
+	if tag == "" {
+		tag = "latest"
+		if config, ok := registry.GetRegistry(registry.DetectRegistry(name)); ok && config.DefaultTag != "" {
+			tag = config.DefaultTag
+		}
+	}
+	if strings.Contains(tag, ":") {
+		return registry.CanonicalImageName(name) + "@" + tag
+	}
+	return registry.CanonicalImageName(name) + ":" + tag
+}
+
+// dedupeImages 按规范引用去重镜像参数，保留第一次出现的参数，避免重复请求和重复输出
+// 如 nginx、library/nginx 和 docker.io/library/nginx:latest 视为同一镜像；合并的参数输出到标准错误
+func dedupeImages(images []string, defaultTag string) []string {
+	kept := make(map[string]string, len(images))
+	unique := images[:0:0]
+	var merged []string
+	for _, image := range images {
+		ref := canonicalReference(image, defaultTag)
+		if first, ok := kept[ref]; ok {
+			merged = append(merged, fmt.Sprintf("  %s -> %s (%s)", image, first, ref))
+			continue
+		}
+		kept[ref] = image
+		unique = append(unique, image)
+	}
+	if len(merged) > 0 {
+		fmt.Fprintf(os.Stderr, "已合并 %d 个重复的镜像参数:\n%s\n", len(merged), strings.Join(merged, "\n"))
+	}
+	return unique
+}
+
+// readImageFile 读取 -file 指定的镜像列表文件，path 为 - 时从 stdin 读取，格式见 scanImageList
+func readImageFile(path string, stdin io.Reader) ([]string, error) {
+	if path == "-" {
+		return scanImageList(stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取镜像列表失败: %w", err)
+	}
+	defer file.Close()
+	images, err := scanImageList(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取镜像列表 %s 失败: %w", path, err)
+	}
+	return images, nil
+}
+
+// scanImageList 逐行读取镜像列表，每行一个镜像（也可以用逗号分隔），忽略空行和 # 开头的注释，
+// 行尾以空白开始的 # 注释同样忽略，如 "nginx:1.27  # 入口网关"
+func scanImageList(r io.Reader) ([]string, error) {
+	var images []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i == 0 || i > 0 && (line[i-1] == ' ' || line[i-1] == '\t') {
+			line = line[:i]
+		}
+		images = append(images, splitList(line)...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// configureCache 为客户端设置保存在 dir 中的 token 和 manifest 缓存（dir 为空时使用默认目录），offline 时启用离线模式
+func configureCache(client *registry.Client, dir string, offline bool) error {
+	cache, err := registry.NewFileCache(dir)
+	if err != nil {
+		return err
+	}
+	client.WithCache(cache).WithOffline(offline)
+	return nil
+}
+
+// envReadOnly 判断环境变量 DOCKER_MANIFEST_READ_ONLY 是否要求只读模式
+func envReadOnly() bool {
+	value, err := strconv.ParseBool(os.Getenv("DOCKER_MANIFEST_READ_ONLY"))
+	return err == nil && value
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// runCompare 执行 compare 子命令，返回退出码
+// 获取多个 registry 中的同一镜像，比较根 digest 和每个平台的 digest，用于检查镜像仓库是否与上游一致
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	tag := fs.String("tag", "", "镜像未指定标签时使用的标签 (默认: registry 配置的默认标签，未配置时为 latest)")
+	var clientOpts clientFlags
+	clientOpts.register(fs)
+	timeout := fs.Duration("timeout", 30*time.Second, "单个 HTTP 请求的总超时，0 表示不限制")
+	output := fs.String("output", "text", "输出格式: text 或 json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s compare [选项] <镜像> <镜像>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "比较多个 registry 中同一镜像的 digest，报告每个平台是否一致。\n")
+		fmt.Fprintf(os.Stderr, "镜像可以用逗号分隔；镜像不一致时退出码为 %d。\n\n", exitMismatch)
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s compare -tag 1.27 nginx harbor.example.com/mirror/nginx\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text 或 json\n", *output)
+		return exitError
+	}
+	var images []string
+	for _, arg := range fs.Args() {
+		images = append(images, splitList(arg)...)
+	}
+	if len(images) < 2 {
+		fmt.Fprintf(os.Stderr, "错误: 至少需要指定两个镜像\n\n")
+		fs.Usage()
+		return exitError
+	}
+
+	client, err := clientOpts.newClient(*timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+
+	comparison, err := client.CompareRegistriesContext(context.Background(), images, *tag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+
+	errs := make([]error, len(comparison.Results))
+	for i, result := range comparison.Results {
+		errs[i] = result.Error
+	}
+	code, exitCode := errorsExitCode(errs)
+	if exitCode == exitOK && !comparison.Consistent() {
+		code, exitCode = codeDigestMismatch, exitMismatch
+	}
+
+	if *output == "json" {
+		data, _ := json.Marshal(struct {
+			*registry.RegistryComparison
+			ErrorCode string `json:"error_code,omitempty"`
+		}{comparison, code})
+		fmt.Println(string(data))
+		return exitCode
+	}
+
+	if err := comparison.WriteSummary(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	return exitCode
+}
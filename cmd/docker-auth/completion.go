@@ -0,0 +1,296 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// completionShells 为 completion 子命令支持的 shell
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// registryKeyFlags 为值以 registry key 开头的选项，值为 registry key 之后的分隔符，值只有 registry key 时为空
+var registryKeyFlags = map[string]string{
+	"credentials":     ":",
+	"bearer-token":    "=",
+	"tls-client-cert": "=",
+	"ca-cert":         "=",
+	"insecure":        "",
+}
+
+// completionCommand 表示补全脚本中的一个子命令及其选项
+type completionCommand struct {
+	name    string
+	summary string
+	flags   []*flag.Flag
+}
+
+func init() {
+	// completion 需要遍历 commands，在 init 中注册以避免初始化循环
+	commands = append(commands, command{"completion", "[选项] bash|zsh|fish", "生成 shell 自动补全脚本", runCompletion})
+}
+
+// runCompletion 执行 completion 子命令，返回退出码
+// 输出补全子命令、选项和已注册 registry key 的 shell 脚本
+func runCompletion(args []string) int {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	registriesConfig := fs.String("registries-config", "", "registry 配置文件 (JSON，可选)，其中的 registry key 同样用于补全")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s completion [选项] bash|zsh|fish\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "输出 shell 自动补全脚本，补全子命令、选项，以及 -credentials、-bearer-token、-tls-client-cert、-ca-cert、-insecure 中的 registry key。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  source <(%s completion bash)\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s completion zsh > \"${fpath[1]}/_docker-auth\"\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s completion fish > ~/.config/fish/completions/docker-auth.fish\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定一个 shell\n\n")
+		fs.Usage()
+		return exitError
+	}
+	shell := fs.Arg(0)
+	if *registriesConfig != "" {
+		if err := registry.LoadRegistriesFile(*registriesConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+	}
+
+	var keys []string
+	for key := range registry.ListRegistries() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	program := filepath.Base(os.Args[0])
+	cmds := completionCommands()
+
+	switch shell {
+	case "bash":
+		writeBashCompletion(os.Stdout, program, cmds, keys)
+	case "zsh":
+		writeZshCompletion(os.Stdout, program, cmds, keys)
+	case "fish":
+		writeFishCompletion(os.Stdout, program, cmds, keys)
+	default:
+		fmt.Fprintf(os.Stderr, "错误: 不支持的 shell '%s'，应为 %s\n", shell, strings.Join(completionShells, "、"))
+		return exitError
+	}
+	return exitOK
+}
+
+// completionCommands 返回全部子命令及其选项
+// 以收集模式执行每个子命令，子命令在解析参数前返回，不会发送请求
+func completionCommands() []completionCommand {
+	cmds := make([]completionCommand, 0, len(commands))
+	for _, cmd := range commands {
+		var flags []*flag.Flag
+		collectFlags = func(fs *flag.FlagSet) {
+			fs.VisitAll(func(f *flag.Flag) { flags = append(flags, f) })
+		}
+		cmd.run(nil)
+		collectFlags = nil
+		cmds = append(cmds, completionCommand{name: cmd.name, summary: cmd.summary, flags: flags})
+	}
+	return cmds
+}
+
+// flagNames 返回选项名称，每个名称带 - 前缀
+func flagNames(flags []*flag.Flag) []string {
+	names := make([]string, len(flags))
+	for i, f := range flags {
+		names[i] = "-" + f.Name
+	}
+	return names
+}
+
+// flagSummary 返回选项说明的第一行
+func flagSummary(f *flag.Flag) string {
+	summary, _, _ := strings.Cut(f.Usage, "\n")
+	return summary
+}
+
+// isBoolFlag 判断选项是否为不需要值的布尔选项
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// shellFuncName 将程序名转换为 shell 函数名，如 docker-auth 对应 _docker_auth
+func shellFuncName(program string) string {
+	return "_" + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, program)
+}
+
+// shellQuote 用单引号包裹 s，适用于 bash、zsh 和 fish
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeBashCompletion 输出 bash 补全脚本
+// 不带子命令的旧用法（第一个参数为选项）按 manifest 子命令的选项补全
+func writeBashCompletion(w io.Writer, program string, cmds []completionCommand, keys []string) {
+	fn := shellFuncName(program)
+	names := make([]string, 0, len(cmds)+1)
+	for _, cmd := range cmds {
+		names = append(names, cmd.name)
+	}
+	names = append(names, "help")
+
+	fmt.Fprintf(w, "# bash completion for %s\n", program)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "    local cur prev flags\n")
+	fmt.Fprintf(w, "    cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(w, "    prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(w, "    if [[ $COMP_CWORD -eq 1 && \"$cur\" != -* ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(names, " ")))
+	fmt.Fprintf(w, "        return\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "    case \"$prev\" in\n")
+	for _, sep := range []string{":", "=", ""} {
+		var opts []string
+		for _, name := range sortedRegistryKeyFlags(sep) {
+			opts = append(opts, "-"+name, "--"+name)
+		}
+		fmt.Fprintf(w, "    %s)\n", strings.Join(opts, "|"))
+		if sep != "" {
+			fmt.Fprintf(w, "        compopt -o nospace\n")
+		}
+		fmt.Fprintf(w, "        COMPREPLY=($(compgen -S %s -W %s -- \"$cur\"))\n", shellQuote(sep), shellQuote(strings.Join(keys, " ")))
+		fmt.Fprintf(w, "        return\n")
+		fmt.Fprintf(w, "        ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "    case \"${COMP_WORDS[1]}\" in\n")
+	for _, cmd := range cmds {
+		pattern := cmd.name
+		if cmd.name == "manifest" {
+			pattern += "|-*"
+		}
+		fmt.Fprintf(w, "    %s) flags=%s ;;\n", pattern, shellQuote(strings.Join(flagNames(cmd.flags), " ")))
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "    if [[ \"$cur\" == -* ]]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -o default -F %s %s\n", fn, program)
+}
+
+// writeZshCompletion 输出 zsh 补全脚本，子命令和选项带说明
+func writeZshCompletion(w io.Writer, program string, cmds []completionCommand, keys []string) {
+	fn := shellFuncName(program)
+	quotedKeys := make([]string, len(keys))
+	for i, key := range keys {
+		quotedKeys[i] = shellQuote(key)
+	}
+	// _describe 以 : 分隔名称和说明，说明中的 : 需要转义
+	describe := func(name, summary string) string {
+		return shellQuote(name + ":" + strings.ReplaceAll(summary, ":", `\:`))
+	}
+
+	fmt.Fprintf(w, "#compdef %s\n\n", program)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintf(w, "    local -a subcommands flags\n")
+	fmt.Fprintf(w, "    subcommands=(\n")
+	for _, cmd := range cmds {
+		fmt.Fprintf(w, "        %s\n", describe(cmd.name, cmd.summary))
+	}
+	fmt.Fprintf(w, "    )\n")
+	fmt.Fprintf(w, "    if (( CURRENT == 2 )) && [[ $PREFIX != -* ]]; then\n")
+	fmt.Fprintf(w, "        _describe 'command' subcommands\n")
+	fmt.Fprintf(w, "        return\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "    case $words[CURRENT-1] in\n")
+	for _, sep := range []string{":", "=", ""} {
+		var opts []string
+		for _, name := range sortedRegistryKeyFlags(sep) {
+			opts = append(opts, "-"+name, "--"+name)
+		}
+		fmt.Fprintf(w, "    %s)\n", strings.Join(opts, "|"))
+		fmt.Fprintf(w, "        compadd -S %s -- %s\n", shellQuote(sep), strings.Join(quotedKeys, " "))
+		fmt.Fprintf(w, "        return\n")
+		fmt.Fprintf(w, "        ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "    case $words[2] in\n")
+	for _, cmd := range cmds {
+		pattern := cmd.name
+		if cmd.name == "manifest" {
+			pattern += "|-*"
+		}
+		fmt.Fprintf(w, "    %s)\n", pattern)
+		fmt.Fprintf(w, "        flags=(\n")
+		for _, f := range cmd.flags {
+			fmt.Fprintf(w, "            %s\n", describe("-"+f.Name, flagSummary(f)))
+		}
+		fmt.Fprintf(w, "        )\n")
+		fmt.Fprintf(w, "        ;;\n")
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "    if [[ $PREFIX == -* ]]; then\n")
+	fmt.Fprintf(w, "        _describe 'option' flags\n")
+	fmt.Fprintf(w, "    else\n")
+	fmt.Fprintf(w, "        _files\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "compdef %s %s\n", fn, program)
+}
+
+// writeFishCompletion 输出 fish 补全脚本，子命令和选项带说明，需要值的选项补全文件名
+func writeFishCompletion(w io.Writer, program string, cmds []completionCommand, keys []string) {
+	fmt.Fprintf(w, "# fish completion for %s\n", program)
+	fmt.Fprintf(w, "complete -c %s -f\n", program)
+	for _, cmd := range cmds {
+		fmt.Fprintf(w, "complete -c %s -n '__fish_use_subcommand' -a %s -d %s\n", program, cmd.name, shellQuote(cmd.summary))
+	}
+	for _, cmd := range cmds {
+		condition := "__fish_seen_subcommand_from " + cmd.name
+		if cmd.name == "manifest" {
+			// 不带子命令的旧用法等同于 manifest 子命令
+			condition = "__fish_use_subcommand; or " + condition
+		}
+		for _, f := range cmd.flags {
+			line := fmt.Sprintf("complete -c %s -n %s -o %s -d %s", program, shellQuote(condition), f.Name, shellQuote(flagSummary(f)))
+			if sep, ok := registryKeyFlags[f.Name]; ok {
+				values := make([]string, len(keys))
+				for i, key := range keys {
+					values[i] = key + sep
+				}
+				line += " -x -a " + shellQuote(strings.Join(values, " "))
+			} else if !isBoolFlag(f) {
+				line += " -r -F"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// sortedRegistryKeyFlags 返回 registry key 之后的分隔符为 sep 的选项名称，按名称排序
+func sortedRegistryKeyFlags(sep string) []string {
+	var names []string
+	for name, s := range registryKeyFlags {
+		if s == sep {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
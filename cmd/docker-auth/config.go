@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// configJSONResult 表示 config 子命令 JSON 输出中单个镜像的结果
+type configJSONResult struct {
+	Image        string                `json:"image"`
+	Tag          string                `json:"tag"`
+	Digest       string                `json:"digest,omitempty"`
+	ConfigDigest string                `json:"config_digest,omitempty"`
+	Config       *registry.ImageConfig `json:"config,omitempty"`
+	Error        string                `json:"error,omitempty"`
+	ErrorCode    string                `json:"error_code,omitempty"`
+}
+
+// runConfig 执行 config 子命令，返回退出码
+// 获取每个镜像的配置 blob（labels、创建时间、架构、入口命令等），多架构镜像使用 -platform 选择的平台
+func runConfig(args []string) int {
+	fs := flag.NewFlagSet("config", flag.ExitOnError)
+	tag := fs.String("tag", "", "镜像未指定标签时使用的标签 (默认: registry 配置的默认标签，未配置时为 latest)")
+	platform := fs.String("platform", "", "多架构镜像使用的平台，如 linux/arm64 (默认: registry 配置的默认平台，未配置时为 linux/amd64)")
+	var clientOpts clientFlags
+	clientOpts.register(fs)
+	concurrency := fs.Int("concurrency", 10, "并发数")
+	timeout := fs.Duration("timeout", 30*time.Second, "单个 HTTP 请求的总超时，0 表示不限制")
+	useCache := fs.Bool("cache", false, "将 token、manifest 和镜像配置缓存到磁盘，供 -offline 使用")
+	cacheDir := fs.String("cache-dir", "", "-cache 使用的目录 (设置后自动启用 -cache)")
+	offline := fs.Bool("offline", false, "离线模式，不访问网络，只使用 -cache 缓存的 manifest 和镜像配置")
+	pretty := fs.Bool("pretty", false, "格式化输出 JSON")
+	output := fs.String("output", "text", "输出格式: text 或 json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s config [选项] <镜像>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "获取每个镜像的配置（labels、创建时间、架构、入口命令等）。\n")
+		fmt.Fprintf(os.Stderr, "镜像可以用逗号分隔；text 格式每个镜像输出一行配置 JSON，json 格式同时输出 digest。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s config -pretty nginx:1.27\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s config -platform linux/arm64 -output json nginx redis\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text 或 json\n", *output)
+		return exitError
+	}
+	var images []string
+	for _, arg := range fs.Args() {
+		images = append(images, splitList(arg)...)
+	}
+	if len(images) == 0 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定镜像名称\n\n")
+		fs.Usage()
+		return exitError
+	}
+
+	client, err := clientOpts.newClient(*timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	if *useCache || *cacheDir != "" || *offline {
+		if err := configureCache(client, *cacheDir, *offline); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+	}
+
+	images = dedupeImages(images, *tag)
+	specs := make([]registry.ImageSpec, len(images))
+	for i, img := range images {
+		imageName, imageTag := parseImageAndTag(img, *tag)
+		specs[i] = registry.ImageSpec{Image: imageName, Tag: imageTag}
+	}
+	results := client.GetConfigs(specs, registry.BatchOptions{Concurrency: *concurrency, BatchAuth: true, Platform: *platform})
+
+	errs := make([]error, len(results))
+	for i, result := range results {
+		errs[i] = result.Error
+	}
+	code, exitCode := errorsExitCode(errs)
+
+	marshal := func(v interface{}) []byte {
+		if *pretty {
+			data, _ := json.MarshalIndent(v, "", "  ")
+			return data
+		}
+		data, _ := json.Marshal(v)
+		return data
+	}
+
+	if *output == "json" {
+		items := make([]configJSONResult, len(results))
+		for i, result := range results {
+			items[i] = configJSONResult{
+				Image:        result.Image,
+				Tag:          result.Tag,
+				Digest:       result.Digest,
+				ConfigDigest: result.ConfigDigest,
+				Config:       result.Config,
+			}
+			if result.Error != nil {
+				items[i].Error = result.Error.Error()
+				items[i].ErrorCode, _ = classifyError(result.Error)
+			}
+		}
+		fmt.Println(string(marshal(struct {
+			Results   []configJSONResult `json:"results"`
+			ErrorCode string             `json:"error_code,omitempty"`
+		}{items, code})))
+		return exitCode
+	}
+
+	for _, result := range results {
+		if result.Error != nil {
+			fmt.Fprintf(os.Stderr, "✗ %s:%s: %v\n", result.Image, result.Tag, result.Error)
+			continue
+		}
+		if len(results) > 1 {
+			fmt.Printf("# %s:%s\n", result.Image, result.Tag)
+		}
+		fmt.Println(string(marshal(result.Config)))
+	}
+	return exitCode
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// runCopy 执行 copy 子命令，返回退出码
+// 将源镜像（默认包括多架构索引的全部平台）复制到目标仓库，目标中已存在的 blob 跳过
+// 源和目标可以带传输方式前缀，在 registry、OCI 布局目录、docker-archive、Docker daemon 和 containerd 之间复制
+func runCopy(args []string) int {
+	fs := flag.NewFlagSet("copy", flag.ExitOnError)
+	var clientOpts clientFlags
+	clientOpts.register(fs)
+	var transportOpts transportFlags
+	transportOpts.register(fs)
+	timeout := fs.Duration("timeout", 0, "单个 HTTP 请求的总超时，0 表示不限制 (复制大的 blob 可能需要较长时间)")
+	allPlatforms := fs.Bool("all-platforms", true, "复制多架构镜像的全部平台；-all-platforms=false 时只复制 -platform 指定的平台")
+	platform := fs.String("platform", "", "只复制多架构镜像中该平台的 manifest，如 linux/arm64，目标标签指向该平台的 manifest\n"+
+		"  (默认: -all-platforms=false 或目标为 docker-archive、docker-daemon 时为 registry 配置的默认平台，未配置时为 linux/amd64)")
+	dryRun := fs.Bool("dry-run", false, "只检查目标中已存在的 blob，输出需要推送的 manifest 和需要传输的 blob，不推送任何内容")
+	progress := fs.Bool("progress", true, "在标准错误中输出每个 blob 的进度 (只用于 text 格式)")
+	output := fs.String("output", "text", "输出格式: text 或 json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s copy [选项] <源镜像> <目标镜像>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "将源镜像复制到目标仓库，多架构镜像复制全部平台。\n")
+		fmt.Fprintf(os.Stderr, "源镜像未指定标签时使用 registry 配置的默认标签（未配置时为 latest），目标镜像未指定标签时使用源镜像的标签。\n\n")
+		fmt.Fprintf(os.Stderr, "源和目标可以带传输方式前缀（不带前缀时为 registry 中的镜像）:\n")
+		fmt.Fprintf(os.Stderr, "  registry:<镜像>[:标签|@digest]    registry 中的镜像，也可以写作 docker://<镜像>\n")
+		fmt.Fprintf(os.Stderr, "  oci:<目录>[:引用名]                OCI 镜像布局目录，引用名为 index.json 中的 org.opencontainers.image.ref.name\n")
+		fmt.Fprintf(os.Stderr, "  docker-archive:<文件>[:镜像引用]   docker save 格式的 tar 文件，只能保存单个平台\n")
+		fmt.Fprintf(os.Stderr, "  docker-daemon:<镜像>[:标签]        Docker daemon 中的镜像（-docker-host），只能保存单个平台\n")
+		fmt.Fprintf(os.Stderr, "  containerd:<镜像>[:标签]           containerd 中的镜像（-containerd-address、-namespace），不解压到 snapshotter\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s copy -credentials harbor:admin:token nginx:1.27 harbor.example.com/mirror/nginx\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s copy -dry-run nginx:1.27 harbor.example.com/mirror/nginx\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s copy -platform linux/arm64 nginx:1.27 harbor.example.com/mirror/nginx:1.27-arm64\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s copy nginx:1.27 oci:/data/layout\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s copy docker-daemon:myapp:dev harbor.example.com/team/myapp:dev\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s copy -namespace k8s.io nginx:1.27 containerd:nginx:1.27\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text 或 json\n", *output)
+		return exitError
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定源镜像和目标镜像\n\n")
+		fs.Usage()
+		return exitError
+	}
+	allPlatformsSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "all-platforms" {
+			allPlatformsSet = true
+		}
+	})
+	if *platform != "" && *allPlatforms && allPlatformsSet {
+		fmt.Fprintf(os.Stderr, "错误: -platform 不能与 -all-platforms 同时使用\n")
+		return exitError
+	}
+	if *platform != "" {
+		if _, err := registry.ParsePlatform(*platform); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+	}
+
+	src, err := registry.ParseImageReference(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	dst, err := registry.ParseImageReference(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	if src.Transport == registry.TransportRegistry && src.Tag == "" {
+		src.Tag = "latest"
+		if config, ok := registry.GetRegistry(registry.DetectRegistry(src.Name)); ok && config.DefaultTag != "" {
+			src.Tag = config.DefaultTag
+		}
+	}
+
+	client, err := clientOpts.newClient(*timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	closeTransports, err := transportOpts.configure(client, src, dst)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	defer closeTransports()
+
+	opts := registry.CopyOptions{Platform: *platform, DryRun: *dryRun}
+	if !*allPlatforms && opts.Platform == "" {
+		opts.Platform = "linux/amd64"
+		if src.Transport == registry.TransportRegistry {
+			if config, ok := registry.GetRegistry(registry.DetectRegistry(src.Name)); ok && config.DefaultPlatform != "" {
+				opts.Platform = config.DefaultPlatform
+			}
+		}
+	}
+	if *progress && *output == "text" {
+		opts.Progress = func(p registry.CopyProgress) {
+			fmt.Fprintf(os.Stderr, "[%d/%d] %-8s %s (%s)\n", p.Done, p.Total, p.Action, p.Blob.Digest, formatBytes(p.Blob.Size))
+		}
+	}
+	result, err := client.CopyImageReference(context.Background(), src, dst, opts)
+	code, exitCode := "", exitOK
+	if err != nil {
+		code, exitCode = classifyError(err)
+	}
+
+	if *output == "json" {
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		data, _ := json.Marshal(struct {
+			*registry.CopyResult
+			Error     string `json:"error,omitempty"`
+			ErrorCode string `json:"error_code,omitempty"`
+		}{result, errMsg, code})
+		fmt.Println(string(data))
+		return exitCode
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitCode
+	}
+	if result.DryRun {
+		fmt.Printf("试运行: %s -> %s %s\n", result.Source, result.Destination, result.Digest)
+		fmt.Fprintf(os.Stderr, "需要推送 %d 个 manifest，%d 个 blob (已存在 %d，需要传输 %d，%d 字节)\n",
+			result.Manifests, result.Blobs, result.BlobsExisting, result.BlobsUploaded, result.BytesUploaded)
+		return exitOK
+	}
+	fmt.Printf("✓ %s -> %s %s\n", result.Source, result.Destination, result.Digest)
+	fmt.Fprintf(os.Stderr, "%d 个 manifest，%d 个 blob (已存在 %d，挂载 %d，上传 %d，%d 字节)\n",
+		result.Manifests, result.Blobs, result.BlobsExisting, result.BlobsMounted, result.BlobsUploaded, result.BytesUploaded)
+	return exitOK
+}
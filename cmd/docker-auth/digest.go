@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// defaultDigestConcurrency digest 子命令默认的并发数
+const defaultDigestConcurrency = 32
+
+// digestJSONResult 表示 digest 子命令 JSON 输出中单个镜像的结果
+type digestJSONResult struct {
+	Image     string `json:"image"`
+	Tag       string `json:"tag"`
+	Digest    string `json:"digest,omitempty"`
+	Pinned    string `json:"pinned,omitempty"` // image@digest
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// runDigest 执行 digest 子命令，返回退出码
+// 只通过 HEAD 请求获取每个镜像的 digest，输出 image@digest，用于在 CI 中固定镜像版本
+func runDigest(args []string) int {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	tag := fs.String("tag", "", "镜像未指定标签时使用的标签 (默认: registry 配置的默认标签，未配置时为 latest)")
+	imageFile := fs.String("file", "", "从文件读取镜像列表，- 表示标准输入，格式与标准输入相同 (可选)")
+	platform := fs.String("platform", "", "多架构镜像输出该平台的 manifest digest，如 linux/arm64 (默认: 输出索引的 digest)\n"+
+		"  指定后需要下载索引，比只发送 HEAD 请求慢")
+	var clientOpts clientFlags
+	clientOpts.register(fs)
+	concurrency := fs.Int("concurrency", defaultDigestConcurrency, "并发数")
+	timeout := fs.Duration("timeout", 30*time.Second, "单个 HTTP 请求的总超时，0 表示不限制")
+	tokenCache := fs.Bool("token-cache", false, "将认证 token 缓存到磁盘，短时间内多次执行时复用未过期的 token")
+	tokenCacheFile := fs.String("token-cache-file", "", "token 缓存文件路径 (设置后自动启用 -token-cache)")
+	useCache := fs.Bool("cache", false, "将 token 和 manifest 缓存到磁盘，供 -offline 使用")
+	cacheDir := fs.String("cache-dir", "", "-cache 使用的目录 (设置后自动启用 -cache)")
+	offline := fs.Bool("offline", false, "离线模式，不访问网络，只使用 -cache 缓存的 digest")
+	anonymousFallback := fs.Bool("anonymous-fallback", false, "凭据被认证服务拒绝 (401，如 token 已过期) 时回退为匿名访问")
+	failFast := fs.Bool("fail-fast", false, "遇到第一个错误即中止")
+	output := fs.String("output", "text", "输出格式: text 或 json")
+	watch := fs.Bool("watch", false, "持续监视镜像，每隔 -interval 重新获取 digest，只输出发生变化的镜像，按 Ctrl+C 退出")
+	interval := fs.Duration("interval", 5*time.Minute, "-watch 模式下两次检查的间隔")
+	hook := fs.String("exec", "", "-watch 模式下 digest 变化时通过 shell 执行的命令 (可选)\n"+
+		"  变化的镜像通过环境变量 DOCKER_MANIFEST_IMAGE、DOCKER_MANIFEST_TAG、\n"+
+		"  DOCKER_MANIFEST_PREVIOUS_DIGEST、DOCKER_MANIFEST_DIGEST 传递")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s digest [选项] <镜像>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "输出每个镜像的 image@digest，只发送 HEAD 请求，不下载 manifest。\n")
+		fmt.Fprintf(os.Stderr, "镜像可以用逗号分隔；未指定镜像或指定 - 时从标准输入逐行读取。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s digest nginx:1.27 redis:7 ghcr.io/owner/repo:v1\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  cat images.txt | %s digest -token-cache\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s digest -file images.txt\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s digest -watch -interval 1m -exec 'kubectl rollout restart deploy/web' nginx:1.27\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text 或 json\n", *output)
+		return exitError
+	}
+	if *hook != "" && !*watch {
+		fmt.Fprintf(os.Stderr, "错误: -exec 只能与 -watch 同时使用\n")
+		return exitError
+	}
+	if *watch && *interval <= 0 {
+		fmt.Fprintf(os.Stderr, "错误: -interval 必须大于 0\n")
+		return exitError
+	}
+	if *watch && *offline {
+		fmt.Fprintf(os.Stderr, "错误: -watch 不能与 -offline 同时使用\n")
+		return exitError
+	}
+
+	args = fs.Args()
+	if *imageFile != "" {
+		fileImages, err := readImageFile(*imageFile, os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+		if args = append(args, fileImages...); len(args) == 0 {
+			fmt.Fprintf(os.Stderr, "错误: %s 中没有镜像\n", *imageFile)
+			return exitError
+		}
+	}
+
+	// 未指定镜像且标准输入是终端时直接报错，避免等待输入
+	if stat, err := os.Stdin.Stat(); len(args) == 0 && err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定镜像名称\n\n")
+		fs.Usage()
+		return exitError
+	}
+	images, err := digestImages(args, os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 读取镜像列表失败: %v\n", err)
+		return exitError
+	}
+	if len(images) == 0 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定镜像名称\n\n")
+		fs.Usage()
+		return exitError
+	}
+
+	client, err := clientOpts.newClient(*timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	client.WithAnonymousFallback(*anonymousFallback)
+	if *tokenCache || *tokenCacheFile != "" {
+		cache, err := registry.NewFileTokenCache(*tokenCacheFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+		client.WithTokenCache(cache)
+	}
+	if *useCache || *cacheDir != "" || *offline {
+		if err := configureCache(client, *cacheDir, *offline); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+	}
+
+	images = dedupeImages(images, *tag)
+	specs := make([]registry.ImageSpec, len(images))
+	for i, img := range images {
+		imageName, imageTag := parseImageAndTag(img, *tag)
+		specs[i] = registry.ImageSpec{Image: imageName, Tag: imageTag, Platform: *platform}
+	}
+	opts := registry.BatchOptions{Concurrency: *concurrency, BatchAuth: true}
+	if *failFast {
+		opts.MaxFailures = 1
+	}
+	if *watch {
+		return watchDigests(client, specs, opts, *interval, *hook, *output)
+	}
+	results := client.GetDigests(context.Background(), specs, opts)
+
+	errs := make([]error, len(results))
+	for i, result := range results {
+		errs[i] = result.Error
+	}
+	code, exitCode := errorsExitCode(errs)
+
+	if *output == "json" {
+		items := make([]digestJSONResult, len(results))
+		for i, result := range results {
+			items[i] = digestJSONResult{Image: result.Image, Tag: result.Tag, Digest: result.Digest, Pinned: result.Pinned()}
+			if result.Error != nil {
+				items[i].Error = result.Error.Error()
+				items[i].ErrorCode, _ = classifyError(result.Error)
+			}
+		}
+		data, _ := json.Marshal(struct {
+			Results   []digestJSONResult `json:"results"`
+			ErrorCode string             `json:"error_code,omitempty"`
+		}{items, code})
+		fmt.Println(string(data))
+		return exitCode
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for _, result := range results {
+		if result.Error != nil {
+			fmt.Fprintf(os.Stderr, "✗ %s:%s: %v\n", result.Image, result.Tag, result.Error)
+			continue
+		}
+		fmt.Fprintln(w, result.Pinned())
+	}
+	return exitCode
+}
+
+// digestImages 返回 digest 子命令要处理的镜像列表，去除重复的镜像并保持首次出现的顺序
+// 参数中的镜像可以用逗号分隔；没有参数或参数为 - 时从 stdin 逐行读取，忽略空行和 # 开头的注释
+func digestImages(args []string, stdin io.Reader) ([]string, error) {
+	var images []string
+	seen := make(map[string]bool)
+	add := func(value string) {
+		for _, img := range strings.Split(value, ",") {
+			img = strings.TrimSpace(img)
+			if img != "" && !seen[img] {
+				seen[img] = true
+				images = append(images, img)
+			}
+		}
+	}
+
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	for _, arg := range args {
+		if arg != "-" {
+			add(arg)
+			continue
+		}
+		lines, err := scanImageList(stdin)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			add(line)
+		}
+	}
+	return images, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withStdout 在 fn 执行期间捕获标准输出，返回输出的内容
+func withStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdout")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	stdout := os.Stdout
+	os.Stdout = file
+	defer func() { os.Stdout = stdout }()
+	fn()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestDigestWithCACert(t *testing.T) {
+	const digest = "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+		case "/v2/app/manifests/v1":
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", digest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+		io.Copy(io.Discard, r.Body)
+	}))
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // 未信任证书时的握手错误
+	server.StartTLS()
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+	image := host + "/app:v1"
+
+	dir := t.TempDir()
+	t.Setenv("DOCKER_MANIFEST_CONFIG_DIR", dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caCert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var code int
+	withStdout(t, func() { code = run([]string{"digest", image}) })
+	if code == exitOK {
+		t.Error("未信任自签名证书时应失败")
+	}
+
+	output := withStdout(t, func() { code = run([]string{"digest", "-ca-cert", host + "=" + caFile, image}) })
+	if code != exitOK {
+		t.Fatalf("退出码 = %d, 期望 %d", code, exitOK)
+	}
+	if want := host + "/app@" + digest; strings.TrimSpace(output) != want {
+		t.Errorf("输出 = %q, 期望 %q", output, want)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// formatFuncs 为 -format 模板中可用的函数，与 docker inspect -f 的常用函数一致
+var formatFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join":  strings.Join,
+	"split": strings.Split,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// parseFormat 解析 -format 指定的 Go 模板
+func parseFormat(text string) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(formatFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("-format 模板无效: %w", err)
+	}
+	return tmpl, nil
+}
+
+// printFormatted 对每个获取成功的镜像执行 -format 模板并输出一行，失败的镜像输出到标准错误，返回退出码
+// 模板的数据为 registry.ManifestResult，如 {{.Image}}、{{.Tag}}、{{.Digest}}、{{.IndexDigest}}、{{.Manifest}}
+// redactor 不为 nil 时对镜像名称和错误信息中的仓库名称脱敏；violations 存在时退出码为 exitAgeViolation
+func printFormatted(tmpl *template.Template, results []registry.ManifestResult, violations []registry.AgeViolation, redactor *registry.Redactor) int {
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for _, result := range results {
+		if result.Error != nil {
+			fmt.Fprintf(os.Stderr, "✗ %s:%s: %s\n", redactor.Image(result.Image), result.Tag,
+				redactor.Text(result.Error.Error(), result.Image))
+			continue
+		}
+		result.Image = redactor.Image(result.Image)
+		if err := tmpl.Execute(w, result); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 执行 -format 模板失败: %v\n", err)
+			return exitError
+		}
+		fmt.Fprintln(w)
+	}
+
+	_, exitCode := manifestsExitCode(results, violations)
+	return exitCode
+}
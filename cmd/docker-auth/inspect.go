@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// inspectLayer 表示 inspect 输出中的单个层
+type inspectLayer struct {
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	MediaType string `json:"media_type,omitempty"`
+}
+
+// inspectJSONResult 表示 inspect 子命令的 JSON 输出，字段与 skopeo inspect 的输出对应
+type inspectJSONResult struct {
+	Image        string            `json:"image"`
+	Tag          string            `json:"tag"`
+	Digest       string            `json:"digest,omitempty"`
+	MediaType    string            `json:"media_type,omitempty"`
+	ConfigDigest string            `json:"config_digest,omitempty"`
+	Created      *time.Time        `json:"created,omitempty"`
+	Architecture string            `json:"architecture,omitempty"`
+	Variant      string            `json:"variant,omitempty"`
+	OS           string            `json:"os,omitempty"`
+	Author       string            `json:"author,omitempty"`
+	User         string            `json:"user,omitempty"`
+	WorkingDir   string            `json:"working_dir,omitempty"`
+	Entrypoint   []string          `json:"entrypoint,omitempty"`
+	Cmd          []string          `json:"cmd,omitempty"`
+	ExposedPorts []string          `json:"exposed_ports,omitempty"`
+	Env          []string          `json:"env,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Layers       []inspectLayer    `json:"layers,omitempty"`
+	LayersSize   int64             `json:"layers_size,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	ErrorCode    string            `json:"error_code,omitempty"`
+}
+
+// runInspect 执行 inspect 子命令，返回退出码
+// 获取单个镜像的 manifest 和配置 blob，输出创建时间、架构、labels、环境变量、入口命令和各层大小
+func runInspect(args []string) int {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	tag := fs.String("tag", "", "镜像未指定标签时使用的标签 (默认: registry 配置的默认标签，未配置时为 latest)")
+	platform := fs.String("platform", "", "多架构镜像使用的平台，如 linux/arm64 (默认: registry 配置的默认平台，未配置时为 linux/amd64)")
+	var clientOpts clientFlags
+	clientOpts.register(fs)
+	timeout := fs.Duration("timeout", 30*time.Second, "单个 HTTP 请求的总超时，0 表示不限制")
+	useCache := fs.Bool("cache", false, "将 token、manifest 和镜像配置缓存到磁盘，供 -offline 使用")
+	cacheDir := fs.String("cache-dir", "", "-cache 使用的目录 (设置后自动启用 -cache)")
+	offline := fs.Bool("offline", false, "离线模式，不访问网络，只使用 -cache 缓存的 manifest 和镜像配置")
+	output := fs.String("output", "text", "输出格式: text 或 json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s inspect [选项] <镜像>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "输出镜像的详细信息（创建时间、架构、labels、环境变量、入口命令、各层大小），类似 skopeo inspect。\n")
+		fmt.Fprintf(os.Stderr, "多架构镜像使用 -platform 选择的平台。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s inspect nginx:1.27\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s inspect -platform linux/arm64 -output json ghcr.io/org/app:v1.2.0\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text 或 json\n", *output)
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定一个镜像\n\n")
+		fs.Usage()
+		return exitError
+	}
+
+	client, err := clientOpts.newClient(*timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	if *useCache || *cacheDir != "" || *offline {
+		if err := configureCache(client, *cacheDir, *offline); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+	}
+
+	imageName, imageTag := parseImageAndTag(fs.Arg(0), *tag)
+	results := client.GetConfigs([]registry.ImageSpec{{Image: imageName, Tag: imageTag}}, registry.BatchOptions{Platform: *platform})
+	result := results[0]
+	code, exitCode := "", exitOK
+	if result.Error != nil {
+		code, exitCode = classifyError(result.Error)
+	}
+
+	if *output == "json" {
+		item := newInspectResult(result)
+		if result.Error != nil {
+			item.Error = result.Error.Error()
+			item.ErrorCode = code
+		}
+		data, _ := json.MarshalIndent(item, "", "  ")
+		fmt.Println(string(data))
+		return exitCode
+	}
+
+	if result.Error != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", result.Error)
+		return exitCode
+	}
+	printInspect(newInspectResult(result))
+	return exitOK
+}
+
+// newInspectResult 将 ConfigResult 转换为 inspect 的输出，获取失败时只包含镜像和标签
+func newInspectResult(result registry.ConfigResult) inspectJSONResult {
+	item := inspectJSONResult{
+		Image:        result.Image,
+		Tag:          result.Tag,
+		Digest:       result.Digest,
+		MediaType:    result.MediaType,
+		ConfigDigest: result.ConfigDigest,
+	}
+	if config := result.Config; config != nil {
+		item.Created = config.Created
+		item.Architecture = config.Architecture
+		item.Variant = config.Variant
+		item.OS = config.OS
+		item.Author = config.Author
+		item.User = config.Config.User
+		item.WorkingDir = config.Config.WorkingDir
+		item.Entrypoint = config.Config.Entrypoint
+		item.Cmd = config.Config.Cmd
+		item.Env = config.Config.Env
+		item.Labels = config.Config.Labels
+		for port := range config.Config.ExposedPorts {
+			item.ExposedPorts = append(item.ExposedPorts, port)
+		}
+		sort.Strings(item.ExposedPorts)
+	}
+	for _, layer := range result.Layers {
+		item.Layers = append(item.Layers, inspectLayer{Digest: layer.Digest, Size: layer.Size, MediaType: layer.MediaType})
+		item.LayersSize += layer.Size
+	}
+	return item
+}
+
+// printInspect 以文本格式输出镜像详细信息，空字段不输出
+func printInspect(item inspectJSONResult) {
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Printf("%-14s %s\n", name+":", value)
+		}
+	}
+	command := func(args []string) string {
+		if len(args) == 0 {
+			return ""
+		}
+		data, _ := json.Marshal(args)
+		return string(data)
+	}
+
+	field("Image", item.Image)
+	field("Tag", item.Tag)
+	field("Digest", item.Digest)
+	field("MediaType", item.MediaType)
+	field("Config", item.ConfigDigest)
+	if item.Created != nil {
+		field("Created", item.Created.Format(time.RFC3339))
+	}
+	if item.Architecture != "" {
+		platform := item.OS + "/" + item.Architecture
+		if item.Variant != "" {
+			platform += "/" + item.Variant
+		}
+		field("Platform", platform)
+	}
+	field("Author", item.Author)
+	field("User", item.User)
+	field("WorkingDir", item.WorkingDir)
+	field("Entrypoint", command(item.Entrypoint))
+	field("Cmd", command(item.Cmd))
+	field("ExposedPorts", strings.Join(item.ExposedPorts, ", "))
+
+	if len(item.Env) > 0 {
+		fmt.Println("Env:")
+		for _, env := range item.Env {
+			fmt.Printf("  %s\n", env)
+		}
+	}
+	if len(item.Labels) > 0 {
+		keys := make([]string, 0, len(item.Labels))
+		for key := range item.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fmt.Println("Labels:")
+		for _, key := range keys {
+			fmt.Printf("  %s=%s\n", key, item.Labels[key])
+		}
+	}
+	fmt.Printf("Layers: %d 层，共 %s\n", len(item.Layers), formatBytes(item.LayersSize))
+	for _, layer := range item.Layers {
+		fmt.Printf("  %s  %s\n", layer.Digest, formatBytes(layer.Size))
+	}
+}
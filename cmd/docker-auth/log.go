@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// logFlags 表示客户端日志的命令行参数
+type logFlags struct {
+	level  string
+	format string
+}
+
+// register 在 fs 中注册日志相关参数
+func (l *logFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&l.level, "log-level", "", "将客户端日志输出到标准错误的级别: debug、info、warn 或 error (默认: 不输出日志)\n"+
+		"  debug 输出批量认证、分组和每个请求的决策，便于排查问题")
+	fs.StringVar(&l.format, "log-format", "text", "日志格式: text 或 json")
+}
+
+// configure 按 -log-level 和 -log-format 为客户端设置 slog logger，未指定 -log-level 时不输出日志
+// 使用标准库 log/slog，zap 和 nozap 构建下行为相同
+func (l *logFlags) configure(client *registry.Client) error {
+	if l.format != "text" && l.format != "json" {
+		return fmt.Errorf("不支持的日志格式 '%s'，应为 text 或 json", l.format)
+	}
+	if l.level == "" {
+		return nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(l.level)); err != nil {
+		return fmt.Errorf("不支持的日志级别 '%s'，应为 debug、info、warn 或 error", l.level)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	if l.format == "json" {
+		client.WithCustomLogger(slog.New(slog.NewJSONHandler(os.Stderr, opts)))
+	} else {
+		client.WithCustomLogger(slog.New(slog.NewTextHandler(os.Stderr, opts)))
+	}
+	return nil
+}
@@ -0,0 +1,610 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// credentialsFileName login 保存凭据的文件名，位于配置目录中
+	credentialsFileName = "credentials.json"
+	// credentialsKeyFileName 加密 token 的密钥文件名，未设置 DOCKER_MANIFEST_CREDENTIALS_KEY 时使用
+	credentialsKeyFileName = "credentials.key"
+	// credentialsKeyEnv 派生加密 token 的密钥的口令所在的环境变量
+	credentialsKeyEnv = "DOCKER_MANIFEST_CREDENTIALS_KEY"
+	// credentialHelperPrefix 凭据助手中保存凭据使用的 ServerURL 前缀，避免与 docker login 保存的凭据冲突
+	credentialHelperPrefix = "docker-manifest://"
+)
+
+// 由口令派生密钥的 scrypt 参数，新的凭据文件使用这些参数（约 32 MiB 内存）
+const (
+	scryptN        = 1 << 15
+	scryptR        = 8
+	scryptP        = 1
+	scryptSaltSize = 16
+	// scryptMaxN 读取凭据文件时接受的最大 N，防止被篡改的参数耗尽内存
+	scryptMaxN = 1 << 20
+)
+
+// storedCredential 表示 login 保存的单个 registry 的凭据
+// Helper 为空时 Token 为 AES-256-GCM 加密后 base64 编码的 token；否则用户名和 token 都保存在凭据助手中
+type storedCredential struct {
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Helper   string `json:"helper,omitempty"`
+}
+
+// credentialsFile 表示 login 保存凭据的文件，按凭据 key 索引
+type credentialsFile struct {
+	KDF        *credentialsKDF             `json:"kdf,omitempty"`
+	Registries map[string]storedCredential `json:"registries"`
+}
+
+// credentialsKDF 表示由 DOCKER_MANIFEST_CREDENTIALS_KEY 中的口令派生密钥的参数
+// 每个凭据文件使用随机的盐，泄露的凭据文件只能针对该文件逐个尝试口令，相同的口令在不同机器上得到不同的密钥
+type credentialsKDF struct {
+	Name string `json:"name"` // 目前只支持 scrypt
+	Salt []byte `json:"salt"` // JSON 中为 base64 编码
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// credentialHelperEntry 表示 docker-credential-<helper> 协议中的凭据
+type credentialHelperEntry struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// runLogin 执行 login 子命令，返回退出码
+// 提示输入用户名和 token，加密保存到配置目录或通过凭据助手保存到系统钥匙串，其他子命令自动使用
+func runLogin(args []string) int {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	registriesConfig := fs.String("registries-config", "", "registry 配置文件 (JSON，可选)，用于识别自定义 registry 的 key")
+	username := fs.String("username", "", "用户名 (默认: 提示输入)")
+	passwordStdin := fs.Bool("password-stdin", false, "从标准输入读取 token，而不是提示输入")
+	helper := fs.String("credential-helper", "", "通过 docker-credential-<名称> 凭据助手保存到系统钥匙串，如 osxkeychain、secretservice、wincred、pass\n"+
+		"  (默认: 加密保存在配置目录的 "+credentialsFileName+" 中)")
+	allowKeyFile := fs.Bool("allow-key-file", false, "未设置 "+credentialsKeyEnv+" 时，允许使用与 "+credentialsFileName+" 保存在同一目录的密钥文件 "+credentialsKeyFileName+"\n"+
+		"  能读取配置目录的用户或进程同样能读取密钥文件并解密 token，只能防止只复制了 "+credentialsFileName+" 时泄露")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s login [选项] <registry>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "保存 registry 的用户名和 token，之后其他子命令自动使用，不需要再指定 -credentials。\n")
+		fmt.Fprintf(os.Stderr, "<registry> 为 registry key（如 dockerhub、ghcr）或域名（如 harbor.example.com）。\n")
+		fmt.Fprintf(os.Stderr, "token 以 AES-256-GCM 加密保存在配置目录中 (%s)，可通过 DOCKER_MANIFEST_CONFIG_DIR 修改。\n\n", displayCredentialsDir())
+		fmt.Fprintf(os.Stderr, "加密密钥:\n")
+		fmt.Fprintf(os.Stderr, "  %-34s 由该环境变量中的口令派生密钥，口令不保存在磁盘上（推荐）\n", credentialsKeyEnv)
+		fmt.Fprintf(os.Stderr, "  %-34s 用户名和 token 保存在系统钥匙串中，不使用密钥\n", "-credential-helper")
+		fmt.Fprintf(os.Stderr, "  %-34s 随机密钥保存在同一目录的 %s 中，只是混淆：\n", "-allow-key-file", credentialsKeyFileName)
+		fmt.Fprintf(os.Stderr, "  %-34s 能读取配置目录的用户或进程可以解密 token\n", "")
+		fmt.Fprintf(os.Stderr, "都未指定时 login 拒绝保存凭据。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s=\"$(pass show docker-manifest)\" %s login ghcr\n", credentialsKeyEnv, os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s login -allow-key-file ghcr\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  echo \"$HARBOR_TOKEN\" | %s login -username robot -password-stdin harbor.example.com\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s login -credential-helper osxkeychain dockerhub\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定一个 registry\n\n")
+		fs.Usage()
+		return exitError
+	}
+	if *passwordStdin && *username == "" {
+		fmt.Fprintf(os.Stderr, "错误: -password-stdin 需要同时指定 -username\n")
+		return exitError
+	}
+	if *registriesConfig != "" {
+		if err := registry.LoadRegistriesFile(*registriesConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+	}
+	key, err := credentialKeyFor(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	if *helper == "" && os.Getenv(credentialsKeyEnv) == "" && !*allowKeyFile {
+		fmt.Fprintf(os.Stderr, "错误: 未设置 %s，也未指定 -credential-helper\n", credentialsKeyEnv)
+		fmt.Fprintf(os.Stderr, "  使用与凭据文件保存在同一目录的密钥文件 %s 时，能读取配置目录的用户或进程可以解密 token；\n", credentialsKeyFileName)
+		fmt.Fprintf(os.Stderr, "  接受这一点时请指定 -allow-key-file\n")
+		return exitError
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	user := *username
+	if user == "" {
+		fmt.Fprintf(os.Stderr, "用户名: ")
+		if user, err = readLine(reader); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 读取用户名失败: %v\n", err)
+			return exitError
+		}
+	}
+	var token string
+	if *passwordStdin {
+		token, err = readLine(reader)
+	} else {
+		token, err = readSecret(reader, "Token: ")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 读取 token 失败: %v\n", err)
+		return exitError
+	}
+	if user == "" || token == "" {
+		fmt.Fprintf(os.Stderr, "错误: 用户名和 token 不能为空\n")
+		return exitError
+	}
+
+	if err := saveCredential(key, user, token, *helper); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	fmt.Printf("✓ 已保存 %s 的凭据 (%s)\n", key, user)
+	return exitOK
+}
+
+// runLogout 执行 logout 子命令，返回退出码
+// 删除 login 保存的凭据，保存在凭据助手中时同时从凭据助手删除
+func runLogout(args []string) int {
+	fs := flag.NewFlagSet("logout", flag.ExitOnError)
+	registriesConfig := fs.String("registries-config", "", "registry 配置文件 (JSON，可选)，用于识别自定义 registry 的 key")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s logout [选项] <registry>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "删除 login 保存的 registry 凭据。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s logout ghcr\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定一个 registry\n\n")
+		fs.Usage()
+		return exitError
+	}
+	if *registriesConfig != "" {
+		if err := registry.LoadRegistriesFile(*registriesConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+	}
+	key, err := credentialKeyFor(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+
+	removed, err := removeCredential(key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	if !removed {
+		fmt.Fprintf(os.Stderr, "错误: 没有 %s 的已保存凭据\n", key)
+		return exitNotFound
+	}
+	fmt.Printf("✓ 已删除 %s 的凭据\n", key)
+	return exitOK
+}
+
+// credentialKeyFor 返回 login 参数对应的凭据 key：已注册的 registry key 原样返回，
+// 已注册 registry 的域名返回其 key，未注册的自定义源返回域名（与客户端按域名查找凭据一致）
+func credentialKeyFor(name string) (string, error) {
+	if _, ok := registry.GetRegistry(name); ok {
+		return name, nil
+	}
+	domain := strings.TrimPrefix(strings.TrimPrefix(name, "https://"), "http://")
+	domain = strings.TrimSuffix(domain, "/")
+	if domain == "" || strings.Contains(domain, "/") {
+		return "", fmt.Errorf("无效的 registry '%s'，应为 registry key 或域名", name)
+	}
+	key := registry.DetectRegistry(domain + "/")
+	if customDomain, ok := strings.CutPrefix(key, "custom:"); ok {
+		return customDomain, nil
+	}
+	if !strings.Contains(domain, ".") {
+		return "", fmt.Errorf("未找到 registry '%s'，应为已注册的 registry key 或域名", name)
+	}
+	return key, nil
+}
+
+// credentialsDir 返回 login 保存凭据的配置目录：DOCKER_MANIFEST_CONFIG_DIR，未设置时为用户配置目录下的 docker-manifest
+func credentialsDir() (string, error) {
+	if dir := os.Getenv("DOCKER_MANIFEST_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("无法确定配置目录，请设置 DOCKER_MANIFEST_CONFIG_DIR: %w", err)
+	}
+	return filepath.Join(dir, "docker-manifest"), nil
+}
+
+// displayCredentialsDir 返回用于帮助信息的配置目录
+func displayCredentialsDir() string {
+	dir, err := credentialsDir()
+	if err != nil {
+		return "未知"
+	}
+	return dir
+}
+
+// loadCredentialsFile 读取保存的凭据，文件不存在时返回空的凭据
+func loadCredentialsFile(dir string) (*credentialsFile, error) {
+	file := &credentialsFile{Registries: map[string]storedCredential{}}
+	data, err := os.ReadFile(filepath.Join(dir, credentialsFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取凭据文件失败: %w", err)
+	}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("解析凭据文件 %s 失败: %w", filepath.Join(dir, credentialsFileName), err)
+	}
+	if file.Registries == nil {
+		file.Registries = map[string]storedCredential{}
+	}
+	return file, nil
+}
+
+// save 将凭据写入配置目录，文件权限为 0600，先写入临时文件再重命名，避免中断时损坏已有凭据
+func (f *credentialsFile) save(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("创建配置目录失败: %w", err)
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, credentialsFileName+".*")
+	if err != nil {
+		return fmt.Errorf("写入凭据文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil && runtime.GOOS != "windows" {
+		tmp.Close()
+		return fmt.Errorf("写入凭据文件失败: %w", err)
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入凭据文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入凭据文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, credentialsFileName)); err != nil {
+		return fmt.Errorf("写入凭据文件失败: %w", err)
+	}
+	return nil
+}
+
+// saveCredential 保存 key 的凭据：helper 为空时加密写入凭据文件，否则保存到凭据助手，凭据文件只记录使用的助手
+func saveCredential(key, username, token, helper string) error {
+	dir, err := credentialsDir()
+	if err != nil {
+		return err
+	}
+	file, err := loadCredentialsFile(dir)
+	if err != nil {
+		return err
+	}
+
+	entry := storedCredential{Username: username}
+	if helper != "" {
+		input, _ := json.Marshal(credentialHelperEntry{ServerURL: credentialHelperPrefix + key, Username: username, Secret: token})
+		if _, err := runCredentialHelper(helper, "store", input); err != nil {
+			return err
+		}
+		entry.Helper = helper
+	} else {
+		aead, err := credentialsCipher(dir, file, true)
+		if err != nil {
+			return err
+		}
+		if entry.Token, err = sealToken(aead, key, token); err != nil {
+			return err
+		}
+	}
+
+	// 改为使用凭据文件或其他助手时，删除之前保存在助手中的凭据
+	if previous, ok := file.Registries[key]; ok && previous.Helper != "" && previous.Helper != helper {
+		if _, err := runCredentialHelper(previous.Helper, "erase", []byte(credentialHelperPrefix+key)); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+		}
+	}
+	file.Registries[key] = entry
+	return file.save(dir)
+}
+
+// removeCredential 删除 key 保存的凭据，返回是否存在该凭据
+func removeCredential(key string) (bool, error) {
+	dir, err := credentialsDir()
+	if err != nil {
+		return false, err
+	}
+	file, err := loadCredentialsFile(dir)
+	if err != nil {
+		return false, err
+	}
+	entry, ok := file.Registries[key]
+	if !ok {
+		return false, nil
+	}
+	if entry.Helper != "" {
+		if _, err := runCredentialHelper(entry.Helper, "erase", []byte(credentialHelperPrefix+key)); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+		}
+	}
+	delete(file.Registries, key)
+	return true, file.save(dir)
+}
+
+// addStoredCredentials 为客户端添加 login 保存的凭据，返回添加了凭据的 key
+// 应在加载 registry 配置文件之后、添加环境变量和参数中的凭据之前调用，使后者优先；
+// 没有保存的凭据时不做任何事，无法读取的凭据输出警告后跳过
+func addStoredCredentials(client *registry.Client) []string {
+	dir, err := credentialsDir()
+	if err != nil {
+		return nil
+	}
+	file, err := loadCredentialsFile(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v，已忽略 login 保存的凭据\n", err)
+		return nil
+	}
+
+	keys := make([]string, 0, len(file.Registries))
+	for key := range file.Registries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var aead cipher.AEAD
+	var added []string
+	for _, key := range keys {
+		entry := file.Registries[key]
+		username, token := entry.Username, ""
+		if entry.Helper != "" {
+			output, err := runCredentialHelper(entry.Helper, "get", []byte(credentialHelperPrefix+key))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 读取 %s 的凭据失败，已跳过: %v\n", key, err)
+				continue
+			}
+			var helperEntry credentialHelperEntry
+			if err := json.Unmarshal(output, &helperEntry); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 解析凭据助手 %s 的输出失败，已跳过 %s: %v\n", entry.Helper, key, err)
+				continue
+			}
+			username, token = helperEntry.Username, helperEntry.Secret
+		} else {
+			if aead == nil {
+				if aead, err = credentialsCipher(dir, file, false); err != nil {
+					fmt.Fprintf(os.Stderr, "警告: %v，已忽略 login 保存的凭据\n", err)
+					return added
+				}
+			}
+			if token, err = openToken(aead, key, entry.Token); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 解密 %s 的凭据失败，已跳过: %v\n", key, err)
+				continue
+			}
+		}
+		client.AddCredential(key, username, token)
+		added = append(added, key)
+	}
+	return added
+}
+
+// credentialsCipher 返回加密 token 使用的 AES-256-GCM
+// 密钥由环境变量 DOCKER_MANIFEST_CREDENTIALS_KEY 中的口令通过 scrypt 和 file 中的盐派生，
+// create 为 true 且 file 中没有派生参数时生成随机的盐并记录在 file 中（由调用方保存）；
+// 未设置口令时使用配置目录中的随机密钥文件，create 为 true 时在密钥文件不存在时生成（权限 0600）
+// 密钥文件与凭据文件在同一目录，只能防止单独泄露凭据文件，login 只在指定 -allow-key-file 时使用
+func credentialsCipher(dir string, file *credentialsFile, create bool) (cipher.AEAD, error) {
+	var key []byte
+	if passphrase := os.Getenv(credentialsKeyEnv); passphrase != "" {
+		if file.KDF == nil {
+			if !create {
+				return nil, fmt.Errorf("凭据文件中没有由 %s 派生密钥的参数，请重新 login", credentialsKeyEnv)
+			}
+			kdf, err := newCredentialsKDF()
+			if err != nil {
+				return nil, err
+			}
+			file.KDF = kdf
+		}
+		var err error
+		if key, err = file.KDF.deriveKey(passphrase); err != nil {
+			return nil, err
+		}
+	} else {
+		path := filepath.Join(dir, credentialsKeyFileName)
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil && len(data) == 32:
+			key = data
+		case err == nil:
+			return nil, fmt.Errorf("密钥文件 %s 的长度无效", path)
+		case errors.Is(err, os.ErrNotExist) && create:
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, fmt.Errorf("生成密钥失败: %w", err)
+			}
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return nil, fmt.Errorf("创建配置目录失败: %w", err)
+			}
+			if err := os.WriteFile(path, key, 0o600); err != nil {
+				return nil, fmt.Errorf("写入密钥文件失败: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("读取密钥文件失败: %w", err)
+		}
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// newCredentialsKDF 返回使用随机盐和默认 scrypt 参数的密钥派生参数
+func newCredentialsKDF() (*credentialsKDF, error) {
+	salt := make([]byte, scryptSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %w", err)
+	}
+	return &credentialsKDF{Name: "scrypt", Salt: salt, N: scryptN, R: scryptR, P: scryptP}, nil
+}
+
+// deriveKey 由口令派生 32 字节的密钥，参数来自凭据文件，使用前检查范围
+func (k *credentialsKDF) deriveKey(passphrase string) ([]byte, error) {
+	if k.Name != "scrypt" {
+		return nil, fmt.Errorf("凭据文件使用了不支持的密钥派生算法 %q", k.Name)
+	}
+	if len(k.Salt) < scryptSaltSize || k.N < 2 || k.N > scryptMaxN || k.R < 1 || k.P < 1 || k.R*k.P > 16 {
+		return nil, errors.New("凭据文件中的密钥派生参数无效")
+	}
+	key, err := scrypt.Key([]byte(passphrase), k.Salt, k.N, k.R, k.P, 32)
+	if err != nil {
+		return nil, fmt.Errorf("派生密钥失败: %w", err)
+	}
+	return key, nil
+}
+
+// sealToken 加密 token，以凭据 key 作为附加数据，防止密文被复制到其他 registry 的条目
+func sealToken(aead cipher.AEAD, key, token string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(token), []byte(key))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// openToken 解密 sealToken 加密的 token
+func openToken(aead cipher.AEAD, key, sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("密文长度无效")
+	}
+	token, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(key))
+	if err != nil {
+		return "", errors.New("密钥不匹配或密文已损坏")
+	}
+	return string(token), nil
+}
+
+// runCredentialHelper 按 docker 凭据助手协议执行 docker-credential-<helper> <action>，input 通过标准输入传递，
+// 避免 token 出现在进程列表中
+func runCredentialHelper(helper, action string, input []byte) ([]byte, error) {
+	program := "docker-credential-" + helper
+	cmd := exec.Command(program, action)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// 助手的错误信息输出在标准输出
+		message := strings.TrimSpace(stdout.String() + " " + stderr.String())
+		if message != "" {
+			return nil, fmt.Errorf("凭据助手 %s %s 失败: %s", program, action, message)
+		}
+		return nil, fmt.Errorf("凭据助手 %s %s 失败: %w", program, action, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// readLine 读取一行输入，去掉行尾的换行和空白
+func readLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// readSecret 输出提示后读取一行输入；标准输入是终端时通过 stty 关闭回显（Windows 上不关闭）
+func readSecret(reader *bufio.Reader, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 && runtime.GOOS != "windows" {
+		if restore := disableEcho(); restore != nil {
+			defer restore()
+		}
+	}
+	return readLine(reader)
+}
+
+// disableEcho 关闭终端回显，返回恢复回显的函数，关闭失败时返回 nil
+// 关闭回显期间收到 SIGINT 或 SIGTERM（如按 Ctrl+C）时先恢复回显再退出，避免终端停留在无回显状态
+func disableEcho() func() {
+	if stty("-echo") != nil {
+		return nil
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	var once sync.Once
+	restore := func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			stty("echo")
+			fmt.Fprintln(os.Stderr)
+		})
+	}
+	go func() {
+		select {
+		case <-signals:
+			restore()
+			os.Exit(exitError)
+		case <-done:
+		}
+	}()
+	return func() {
+		close(done)
+		restore()
+	}
+}
+
+// stty 对标准输入所在的终端执行 stty，测试中替换以避免依赖终端
+var stty = func(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// withStdin 在 fn 执行期间将标准输入替换为 input
+func withStdin(t *testing.T, input string, fn func()) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(path, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	stdin := os.Stdin
+	os.Stdin = file
+	defer func() { os.Stdin = stdin }()
+	fn()
+}
+
+func TestLoginRequiresKeyOptIn(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_MANIFEST_CONFIG_DIR", dir)
+	t.Setenv(credentialsKeyEnv, "")
+
+	var code int
+	withStdin(t, "secret\n", func() {
+		code = runLogin([]string{"-username", "robot", "-password-stdin", "ghcr"})
+	})
+	if code != exitError {
+		t.Errorf("未指定密钥来源时退出码 = %d, 期望 %d", code, exitError)
+	}
+	if _, err := os.Stat(filepath.Join(dir, credentialsKeyFileName)); !os.IsNotExist(err) {
+		t.Errorf("拒绝保存时不应生成密钥文件: %v", err)
+	}
+
+	withStdin(t, "secret\n", func() {
+		code = runLogin([]string{"-username", "robot", "-password-stdin", "-allow-key-file", "ghcr"})
+	})
+	if code != exitOK {
+		t.Fatalf("-allow-key-file 时退出码 = %d, 期望 %d", code, exitOK)
+	}
+	if _, err := os.Stat(filepath.Join(dir, credentialsKeyFileName)); err != nil {
+		t.Errorf("没有生成密钥文件: %v", err)
+	}
+	if added := addStoredCredentials(registry.NewClient()); len(added) != 1 || added[0] != "ghcr" {
+		t.Errorf("addStoredCredentials = %v, 期望 [ghcr]", added)
+	}
+}
+
+func TestLoginWithPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_MANIFEST_CONFIG_DIR", dir)
+	t.Setenv(credentialsKeyEnv, "correct horse battery staple")
+
+	var code int
+	withStdin(t, "secret\n", func() {
+		code = runLogin([]string{"-username", "robot", "-password-stdin", "harbor.example.com"})
+	})
+	if code != exitOK {
+		t.Fatalf("设置口令时退出码 = %d, 期望 %d", code, exitOK)
+	}
+	if _, err := os.Stat(filepath.Join(dir, credentialsKeyFileName)); !os.IsNotExist(err) {
+		t.Errorf("使用口令时不应生成密钥文件: %v", err)
+	}
+	file, err := loadCredentialsFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.KDF == nil || len(file.KDF.Salt) != scryptSaltSize {
+		t.Errorf("凭据文件中的 KDF = %+v, 期望记录 scrypt 参数和随机盐", file.KDF)
+	}
+	if added := addStoredCredentials(registry.NewClient()); len(added) != 1 || added[0] != "harbor.example.com" {
+		t.Errorf("addStoredCredentials = %v, 期望 [harbor.example.com]", added)
+	}
+
+	// 口令不同时无法解密，凭据被跳过
+	t.Setenv(credentialsKeyEnv, "wrong")
+	if added := addStoredCredentials(registry.NewClient()); len(added) != 0 {
+		t.Errorf("口令错误时 addStoredCredentials = %v, 期望为空", added)
+	}
+}
+
+func TestDisableEchoRestores(t *testing.T) {
+	var calls []string
+	original := stty
+	stty = func(arg string) error {
+		calls = append(calls, arg)
+		return nil
+	}
+	defer func() { stty = original }()
+
+	restore := disableEcho()
+	if restore == nil {
+		t.Fatal("disableEcho() = nil")
+	}
+	restore()
+	if len(calls) != 2 || calls[0] != "-echo" || calls[1] != "echo" {
+		t.Errorf("stty 调用 = %v, 期望 [-echo echo]", calls)
+	}
+}
+
+func TestSealOpenToken(t *testing.T) {
+	t.Setenv(credentialsKeyEnv, "passphrase")
+	aead, err := credentialsCipher(t.TempDir(), &credentialsFile{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := sealToken(aead, "ghcr", "secret-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, err := openToken(aead, "ghcr", sealed); err != nil || token != "secret-token" {
+		t.Errorf("openToken = %q, %v, 期望 secret-token", token, err)
+	}
+	if again, _ := sealToken(aead, "ghcr", "secret-token"); again == sealed {
+		t.Error("两次加密的结果相同, 期望使用随机 nonce")
+	}
+
+	// 凭据 key 作为附加数据，密文不能用于其他 registry
+	if _, err := openToken(aead, "docker-hub", sealed); err == nil {
+		t.Error("用其他 registry 的 key 解密应返回错误")
+	}
+	data, _ := base64.StdEncoding.DecodeString(sealed)
+	data[len(data)-1] ^= 1
+	tampered := base64.StdEncoding.EncodeToString(data)
+	for _, sealed := range []string{tampered, "not-base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := openToken(aead, "ghcr", sealed); err == nil {
+			t.Errorf("openToken(%q) 应返回错误", sealed)
+		}
+	}
+}
+
+func TestCredentialsCipher(t *testing.T) {
+	t.Run("passphrase", func(t *testing.T) {
+		t.Setenv(credentialsKeyEnv, "passphrase")
+		dir := t.TempDir()
+		file := &credentialsFile{}
+		if _, err := credentialsCipher(dir, file, false); err == nil {
+			t.Error("凭据文件中没有派生参数且 create 为 false 时应返回错误")
+		}
+		first, err := credentialsCipher(dir, file, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if file.KDF == nil || file.KDF.Name != "scrypt" || len(file.KDF.Salt) != scryptSaltSize {
+			t.Fatalf("KDF = %+v, 期望记录 scrypt 参数和随机盐", file.KDF)
+		}
+		sealed, _ := sealToken(first, "ghcr", "token")
+		// 相同的口令和盐派生相同的密钥，不创建密钥文件
+		second, err := credentialsCipher(t.TempDir(), file, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token, err := openToken(second, "ghcr", sealed); err != nil || token != "token" {
+			t.Errorf("openToken = %q, %v, 期望 token", token, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, credentialsKeyFileName)); !os.IsNotExist(err) {
+			t.Errorf("使用口令时创建了密钥文件: %v", err)
+		}
+
+		// 另一个凭据文件使用不同的盐，相同的口令得到不同的密钥
+		other, err := credentialsCipher(dir, &credentialsFile{}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := openToken(other, "ghcr", sealed); err == nil {
+			t.Error("用其他凭据文件的密钥解密应返回错误")
+		}
+		t.Setenv(credentialsKeyEnv, "other")
+		other, _ = credentialsCipher(dir, file, false)
+		if _, err := openToken(other, "ghcr", sealed); err == nil {
+			t.Error("用其他口令解密应返回错误")
+		}
+
+		for _, kdf := range []credentialsKDF{
+			{Name: "pbkdf2", Salt: file.KDF.Salt, N: scryptN, R: scryptR, P: scryptP},
+			{Name: "scrypt", Salt: []byte("short"), N: scryptN, R: scryptR, P: scryptP},
+			{Name: "scrypt", Salt: file.KDF.Salt, N: scryptMaxN * 2, R: scryptR, P: scryptP},
+		} {
+			if _, err := credentialsCipher(dir, &credentialsFile{KDF: &kdf}, false); err == nil {
+				t.Errorf("KDF %+v 应返回错误", kdf)
+			}
+		}
+	})
+
+	t.Run("key file", func(t *testing.T) {
+		t.Setenv(credentialsKeyEnv, "")
+		dir := filepath.Join(t.TempDir(), "config")
+		if _, err := credentialsCipher(dir, &credentialsFile{}, false); err == nil {
+			t.Error("密钥文件不存在且 create 为 false 时应返回错误")
+		}
+		first, err := credentialsCipher(dir, &credentialsFile{}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, credentialsKeyFileName)
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != 32 || runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+			t.Errorf("密钥文件大小 = %d, 权限 = %v, 期望 32 字节、0600", info.Size(), info.Mode().Perm())
+		}
+		sealed, _ := sealToken(first, "ghcr", "token")
+		second, err := credentialsCipher(dir, &credentialsFile{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token, err := openToken(second, "ghcr", sealed); err != nil || token != "token" {
+			t.Errorf("openToken = %q, %v, 期望 token", token, err)
+		}
+
+		if err := os.WriteFile(path, []byte("short"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := credentialsCipher(dir, &credentialsFile{}, true); err == nil {
+			t.Error("密钥文件长度无效时应返回错误")
+		}
+	})
+}
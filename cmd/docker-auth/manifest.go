@@ -0,0 +1,657 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// runManifest 执行 manifest 子命令，返回退出码
+// 获取一个或多个镜像的 manifest，多个镜像使用批量获取
+func runManifest(args []string) int {
+	fs := flag.NewFlagSet("manifest", flag.ExitOnError)
+	// 定义命令行参数
+	image := fs.String("image", "", "镜像名称，也可以作为参数传入\n"+
+		"  支持单个或多个镜像，多个镜像用逗号分隔\n"+
+		"  单个: nginx, library/nginx, ghcr.io/owner/repo\n"+
+		"  多个: nginx,redis,postgres 或 nginx:latest,redis:alpine")
+	imageFile := fs.String("file", "", "从文件读取镜像列表，- 表示标准输入 (可选)\n"+
+		"  每行一个镜像，忽略空行和 # 注释，与 -image 和参数中的镜像合并")
+	tag := fs.String("tag", "", "镜像标签 (默认: registry 配置的默认标签，未配置时为 latest)\n"+
+		"  注意: 如果镜像名中已包含标签（如 nginx:1.19），此参数将被忽略")
+	platform := fs.String("platform", "", "多架构镜像返回该平台的 manifest 和 digest，如 linux/arm64 (默认: registry 配置的默认平台，未配置时返回索引)\n"+
+		"  与 docker pull --platform 相同，索引的 digest 在 -digest 和 JSON 输出中一并给出")
+	registriesConfig := fs.String("registries-config", "", "registry 配置文件 (JSON，可选)\n"+
+		"  用于注册自定义 registry，并设置各 registry 的默认标签和默认平台")
+
+	// 多种凭据配置方式
+	dockerhubUsername := fs.String("dockerhub-username", "", "Docker Hub 用户名 (可选)")
+	dockerhubToken := fs.String("dockerhub-token", "", "Docker Hub token (可选)\n"+
+		"  格式: dckr_pat_xxx...")
+	ghcrUsername := fs.String("ghcr-username", "", "GitHub 用户名 (可选)")
+	ghcrToken := fs.String("ghcr-token", "", "GitHub token (可选)\n"+
+		"  格式: ghp_xxx... 或 github_pat_xxx...")
+
+	var credentialsList repeatedFlag
+	fs.Var(&credentialsList, "credentials", "通用凭据格式 (可重复使用)\n"+
+		"  格式: registry:username:token\n"+
+		"  示例: -credentials dockerhub:user1:token1 -credentials ghcr:user2:token2")
+	var bearerTokenList repeatedFlag
+	fs.Var(&bearerTokenList, "bearer-token", "预先获取的 bearer token (可重复使用)，直接用于访问 registry，不再请求认证服务\n"+
+		"  格式: registry=token（registry 可以是 registry key 或域名）\n"+
+		"  示例: -bearer-token myregistry.azurecr.io=$ACR_TOKEN")
+	var vault vaultFlags
+	vault.register(fs)
+	var logOpts logFlags
+	logOpts.register(fs)
+
+	// 镜像访问策略
+	var allowList, denyList repeatedFlag
+	fs.Var(&allowList, "allow", "允许访问的镜像模式 (可重复使用)\n"+
+		"  格式: <registry 域名>/<仓库>，支持 * 通配符，/** 匹配任意层级\n"+
+		"  示例: -allow docker.io/library/* -allow ghcr.io/myorg/**")
+	fs.Var(&denyList, "deny", "拒绝访问的镜像模式 (可重复使用，优先级高于 -allow)\n"+
+		"  示例: -deny docker.io/library/ubuntu")
+
+	// TLS 配置
+	var tlsOpts tlsFlags
+	tlsOpts.register(fs)
+
+	// 超时配置
+	timeout := fs.Duration("timeout", 30*time.Second, "单个 HTTP 请求的总超时，0 表示不限制")
+	tlsHandshakeTimeout := fs.Duration("tls-handshake-timeout", 0, "TLS 握手超时 (默认: 使用 -timeout)")
+	responseHeaderTimeout := fs.Duration("response-header-timeout", 0, "等待响应头的超时 (默认: 使用 -timeout)")
+	imageTimeout := fs.Duration("image-timeout", 0, "批量获取时单个镜像的超时，包括认证和所有请求 (默认: 不限制)\n"+
+		"  示例: -image-timeout 20s")
+	rateLimitWait := fs.Duration("rate-limit-wait", 0, "被限流 (429) 时单个请求最多等待的时间 (默认: 1m)\n"+
+		"  registry 返回 Retry-After 且不超过该时间时，等待后自动重试；负数表示不等待")
+	probeTimeout := fs.Duration("probe-timeout", 0, "批量获取前探测每个 registry 的超时 (默认: 不探测)\n"+
+		"  无法访问的 registry 的所有镜像直接标记为失败，不再逐个等待超时")
+	circuitBreaker := fs.Int("circuit-breaker", 0, "同一域名连续失败多少次后熔断 30 秒 (默认: 0，不熔断)\n"+
+		"  熔断期间对该域名的请求直接失败")
+
+	maxFailures := fs.Int("max-failures", 0, "批量获取时失败镜像数达到该值后中止，取消进行中的请求 (默认: 0，不中止)\n"+
+		"  未获取的镜像标记为 batch_aborted，退出码为导致中止的错误的退出码")
+	failFast := fs.Bool("fail-fast", false, "批量获取时遇到第一个错误即中止，等同于 -max-failures 1")
+	registerExitPolicy(fs)
+	retryFailed := fs.Int("retry-failed", 0, "批量获取结束后重试失败镜像的次数，重试时每个镜像单独认证并指数退避 (默认: 0，不重试)")
+
+	maxAge := fs.String("max-age", "", "镜像创建时间距今超过该值时检查失败，用于拦截长期未维护的镜像 (可选)\n"+
+		"  支持 d（天）、w（周）及 h、m 等单位，示例: -max-age 90d")
+	minAge := fs.String("min-age", "", "镜像创建时间距今不足该值时检查失败，用于拦截刚发布的镜像 (可选)\n"+
+		"  示例: -min-age 1h")
+
+	anonymousFallback := fs.Bool("anonymous-fallback", false, "凭据被认证服务拒绝 (401，如 token 已过期) 时回退为匿名访问，公开镜像仍可获取\n"+
+		"  回退的镜像会输出警告")
+	readOnly := fs.Bool("read-only", false, "只读模式，禁止推送、删除等修改 registry 的操作\n"+
+		"  设置环境变量 DOCKER_MANIFEST_READ_ONLY=1 时始终启用，不能通过参数关闭")
+
+	tokenCache := fs.Bool("token-cache", false, "将认证 token 缓存到磁盘，短时间内多次执行时复用未过期的 token\n"+
+		"  默认缓存文件: ~/.cache/docker-manifest/tokens.json")
+	tokenCacheFile := fs.String("token-cache-file", "", "token 缓存文件路径 (设置后自动启用 -token-cache)")
+	useCache := fs.Bool("cache", false, "将 token 和 manifest 缓存到磁盘，供 -offline 使用\n"+
+		"  默认目录: ~/.cache/docker-manifest/cache")
+	cacheDir := fs.String("cache-dir", "", "-cache 使用的目录 (设置后自动启用 -cache)")
+	offline := fs.Bool("offline", false, "离线模式，不访问网络，只使用 -cache 缓存的 manifest 和 digest\n"+
+		"  缓存中没有的镜像返回 cache_miss，用于复现 CI 的结果或在隔离网络中排查问题")
+
+	output := fs.String("output", "text", "输出格式: text、json、yaml、ndjson、table 或 csv\n"+
+		"  json: 输出包含 digest、manifest、error 和 error_code 字段的结构化结果，便于脚本处理\n"+
+		"  yaml: 输出包含 image、tag、digest、mediaType 和 error 字段的 YAML 文档 (不含 manifest)，便于 GitOps 工具读取\n"+
+		"  ndjson: 每个镜像完成后立即输出一行 JSON (按完成顺序)，便于下游逐条处理大批量结果；不支持 -retry-failed\n"+
+		"  table/csv: 每个镜像输出一行 IMAGE、TAG、DIGEST、SIZE、PLATFORMS、STATUS，不含 manifest")
+	redact := fs.String("redact", "none", "对输出中的仓库名称脱敏: none、hash 或 full\n"+
+		"  hash: 替换为仓库名称的哈希，同一仓库在不同报告中保持一致；full: 替换为 redacted\n"+
+		"  registry 域名、标签和 digest 保持不变，便于将报告分享给团队以外的人")
+	redactSalt := fs.String("redact-salt", "", "-redact hash 使用的盐值，防止通过常见仓库名称反查 (可选)\n"+
+		"  也可以通过环境变量 DOCKER_MANIFEST_REDACT_SALT 设置")
+	format := fs.String("format", "", "使用 Go 模板输出每个获取成功的镜像，字段为 ManifestResult 的字段 (可选)\n"+
+		"  如 '{{.Digest}} {{.Image}}:{{.Tag}}'，与 docker inspect -f 相同；可用函数: json、join、split、lower、upper")
+	outDir := fs.String("out-dir", "", "将每个 manifest 写入该目录中的 <registry>_<image>_<tag>.json，并写入汇总结果的 summary.json (可选)\n"+
+		"  标准输出只输出写入的文件路径，适合作为 CI 产物保存；只能与 -output text 同时使用")
+	pretty := fs.Bool("pretty", false, "格式化输出 JSON (默认: false)")
+	showDigest := fs.Bool("digest", false, "显示 manifest digest (默认: false)")
+	digestOnly := fs.Bool("digest-only", false, "只通过 HEAD 请求获取 digest，每行输出 <image>:<tag> <digest>，不下载 manifest\n"+
+		"  最快的模式，适合检查更新的脚本；只能与 -output text 同时使用")
+
+	// 自定义 Usage
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest [选项] [镜像]...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "获取镜像的 manifest，多个镜像使用批量获取。\n")
+		fmt.Fprintf(os.Stderr, "镜像可以通过 -image、参数或 -file 指定，-image 和参数支持逗号分隔；不带子命令的旧用法 (%s -image ...) 等同于 manifest 子命令。\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  # Docker Hub - 单个镜像\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -tag latest nginx\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Docker Hub - 多个镜像\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest nginx,redis,postgres\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # 多个镜像带不同标签\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest nginx:latest,redis:alpine,postgres:14\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # Docker Hub 镜像带认证\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -dockerhub-username user -dockerhub-token xxx nginx\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # GitHub Container Registry 带认证\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -ghcr-username ghuser -ghcr-token ghp_xxx ghcr.io/owner/repo\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # 同时访问多个 registry\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -credentials dockerhub:user1:token1 -credentials ghcr:user2:token2 nginx,ghcr.io/owner/repo\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # 只允许访问 Docker Hub 官方镜像\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -allow 'docker.io/library/*' nginx,redis\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # 获取多架构镜像中 linux/arm64 平台的 manifest\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -platform linux/arm64 -digest nginx\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # 只输出 digest，不下载 manifest\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -digest-only nginx redis postgres\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # 格式化输出并显示 digest\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -pretty -digest nginx\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # 只输出需要的字段\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -format '{{.Digest}} {{.Image}}:{{.Tag}}' nginx redis\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # JSON 输出，便于脚本处理\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -output json nginx redis\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # 每个镜像完成后立即输出一行 JSON\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -output ndjson nginx redis postgres\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # 以表格只输出 digest、大小和平台\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -output table nginx redis postgres\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # 从文件读取镜像列表，每行一个\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -file images.txt -output table\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # 将 manifest 写入目录，作为 CI 产物保存\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -out-dir ./manifests nginx redis postgres\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  # YAML 输出，便于 GitOps 工具读取\n")
+		fmt.Fprintf(os.Stderr, "  %s manifest -output yaml nginx redis\n\n", os.Args[0])
+		printExitCodes()
+	}
+
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	switch *output {
+	case "text", "json", "yaml", "ndjson", "table", "csv":
+	default:
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text、json、yaml、ndjson、table 或 csv\n\n", *output)
+		fs.Usage()
+		return exitError
+	}
+
+	if *outDir != "" && (*output != "text" || *format != "") {
+		fmt.Fprintf(os.Stderr, "错误: -out-dir 不能与 -output %s 或 -format 同时使用\n\n", *output)
+		fs.Usage()
+		return exitError
+	}
+
+	if *digestOnly && (*output != "text" || *format != "" || *outDir != "" || *maxAge != "" || *minAge != "" || *retryFailed > 0) {
+		fmt.Fprintf(os.Stderr, "错误: -digest-only 只能与 -output text 同时使用，且不能与 -format、-out-dir、-max-age、-min-age、-retry-failed 同时使用\n\n")
+		fs.Usage()
+		return exitError
+	}
+
+	var formatTmpl *template.Template
+	if *format != "" {
+		if *output != "text" {
+			fmt.Fprintf(os.Stderr, "错误: -format 不能与 -output %s 同时使用\n\n", *output)
+			fs.Usage()
+			return exitError
+		}
+		tmpl, err := parseFormat(*format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n\n", err)
+			fs.Usage()
+			return exitError
+		}
+		formatTmpl = tmpl
+	}
+
+	redactMode, err := registry.ParseRedactMode(*redact)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n\n", err)
+		fs.Usage()
+		return exitError
+	}
+	salt := *redactSalt
+	if salt == "" {
+		salt = os.Getenv("DOCKER_MANIFEST_REDACT_SALT")
+	}
+	redactor := registry.NewRedactor(redactMode, salt)
+
+	if *failFast {
+		*maxFailures = 1
+	}
+
+	if *platform != "" {
+		if _, err := registry.ParsePlatform(*platform); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n\n", err)
+			fs.Usage()
+			return exitError
+		}
+	}
+
+	var agePolicy registry.AgePolicy
+	for _, age := range []struct {
+		value string
+		dest  *time.Duration
+	}{{*maxAge, &agePolicy.MaxAge}, {*minAge, &agePolicy.MinAge}} {
+		if age.value == "" {
+			continue
+		}
+		if *age.dest, err = registry.ParseAge(age.value); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n\n", err)
+			fs.Usage()
+			return exitError
+		}
+	}
+
+	// 解析镜像列表（-image 和参数都支持逗号分隔），再加上 -file 中的镜像
+	images := splitList(*image)
+	for _, arg := range fs.Args() {
+		images = append(images, splitList(arg)...)
+	}
+	if *imageFile != "" {
+		fileImages, err := readImageFile(*imageFile, os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+		images = append(images, fileImages...)
+	}
+	if len(images) == 0 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定镜像名称\n\n")
+		fs.Usage()
+		return exitError
+	}
+
+	// 加载 registry 配置文件
+	if *registriesConfig != "" {
+		if err := registry.LoadRegistriesFile(*registriesConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+	}
+
+	// 按规范引用合并重复的镜像（需要在加载 registry 配置之后，以识别自定义 registry 的域名和默认标签）
+	images = dedupeImages(images, *tag)
+
+	// 创建客户端并配置凭据
+	client := registry.NewClient().
+		WithTimeout(*timeout).
+		WithBatchImageTimeout(*imageTimeout).
+		WithMaxRateLimitWait(*rateLimitWait)
+	if *tlsHandshakeTimeout > 0 {
+		client.WithTLSHandshakeTimeout(*tlsHandshakeTimeout)
+	}
+	if *responseHeaderTimeout > 0 {
+		client.WithResponseHeaderTimeout(*responseHeaderTimeout)
+	}
+	if *probeTimeout > 0 {
+		client.WithRegistryProbe(*probeTimeout)
+	}
+	if *circuitBreaker > 0 {
+		client.WithCircuitBreaker(*circuitBreaker, 0)
+	}
+	if err := logOpts.configure(client); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+
+	if *anonymousFallback {
+		client.WithAnonymousFallback(true)
+	}
+
+	// 只读模式：环境变量优先，便于在禁止写入的环境中统一部署
+	if *readOnly || envReadOnly() {
+		client.WithReadOnly(true)
+	}
+
+	// 配置 token 缓存
+	if *tokenCache || *tokenCacheFile != "" {
+		cache, err := registry.NewFileTokenCache(*tokenCacheFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+		client.WithTokenCache(cache)
+	}
+
+	// 配置 token 和 manifest 的磁盘缓存，离线模式只使用该缓存
+	if *useCache || *cacheDir != "" || *offline {
+		if err := configureCache(client, *cacheDir, *offline); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+	}
+
+	// login 保存的凭据优先级最低，之后添加的环境变量和命令行参数中的凭据覆盖它
+	for _, key := range addStoredCredentials(client) {
+		fmt.Fprintf(os.Stderr, "已使用 login 保存的 %s 凭据\n", key)
+	}
+
+	// 处理环境变量中的凭据，命令行参数指定的凭据优先
+	for _, key := range addEnvCredentials(client) {
+		fmt.Fprintf(os.Stderr, "已从环境变量配置 %s 凭据\n", key)
+	}
+
+	// 处理 Docker Hub 凭据
+	if *dockerhubUsername != "" && *dockerhubToken != "" {
+		client.AddCredential(registry.DockerHubKey, *dockerhubUsername, *dockerhubToken)
+		fmt.Fprintf(os.Stderr, "已配置 Docker Hub 凭据\n")
+	}
+
+	// 处理 GHCR 凭据
+	if *ghcrUsername != "" && *ghcrToken != "" {
+		client.AddCredential(registry.GHCRKey, *ghcrUsername, *ghcrToken)
+		fmt.Fprintf(os.Stderr, "已配置 GitHub Container Registry 凭据\n")
+	}
+
+	// 处理通用凭据格式
+	for _, cred := range credentialsList {
+		parts := strings.SplitN(cred, ":", 3)
+		if len(parts) != 3 {
+			fmt.Fprintf(os.Stderr, "警告: 凭据格式错误，应为 registry:username:token，跳过: %s\n", cred)
+			continue
+		}
+		registryKey, username, token := parts[0], parts[1], parts[2]
+		client.AddCredential(registryKey, username, token)
+		fmt.Fprintf(os.Stderr, "已配置 %s 凭据\n", registryKey)
+	}
+
+	// 处理预先获取的 bearer token
+	for _, value := range bearerTokenList {
+		registryKey, token, ok := strings.Cut(value, "=")
+		if !ok || registryKey == "" || token == "" {
+			fmt.Fprintf(os.Stderr, "警告: bearer token 格式错误，应为 registry=token，已跳过\n")
+			continue
+		}
+		client.AddBearerToken(registryKey, token)
+		fmt.Fprintf(os.Stderr, "已配置 %s 的 bearer token\n", registryKey)
+	}
+
+	// 从 Vault 读取未通过命令行指定的凭据
+	if err := vault.configure(client); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+
+	// 配置每个 registry 的 CA 证书、客户端证书和跳过校验
+	if err := tlsOpts.configure(client); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+
+	// 配置镜像访问策略
+	if len(allowList) > 0 || len(denyList) > 0 {
+		policy := &registry.ImagePolicy{Allow: allowList, Deny: denyList}
+		if err := client.SetImagePolicy(policy); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+	}
+
+	// checkAge 检查获取成功的镜像的创建时间，输出并返回违规的镜像
+	checkAge := func(results []registry.ManifestResult) []registry.AgeViolation {
+		if !agePolicy.Enabled() {
+			return nil
+		}
+		violations := checkImageAge(client, results, agePolicy)
+		if len(violations) > 0 {
+			printAgeViolations(violations, redactor)
+		}
+		return violations
+	}
+
+	// 只输出 digest：HEAD 请求，不下载 manifest
+	if *digestOnly {
+		return printDigestOnly(client, images, *tag, *platform, *maxFailures, redactor)
+	}
+
+	// 写入目录：每个 manifest 一个文件，另外写入索引文件
+	if *outDir != "" {
+		results := fetchManifests(client, images, *tag, *platform, *maxFailures, *retryFailed)
+		return writeOutDir(*outDir, results, checkAge(results), redactor)
+	}
+
+	// 模板输出：每个镜像按 -format 输出一行
+	if formatTmpl != nil {
+		results := fetchManifests(client, images, *tag, *platform, *maxFailures, *retryFailed)
+		return printFormatted(formatTmpl, results, checkAge(results), redactor)
+	}
+
+	// JSON 输出：统一输出结构化结果，违规的镜像记录在 age_violations 字段中
+	if *output == "json" {
+		results := fetchManifests(client, images, *tag, *platform, *maxFailures, *retryFailed)
+		var violations []registry.AgeViolation
+		if agePolicy.Enabled() {
+			violations = checkImageAge(client, results, agePolicy)
+		}
+		return printJSONResults(results, client.Availability().Unavailable(), violations, redactor, *pretty)
+	}
+
+	// NDJSON 输出：每个镜像完成后立即输出一行
+	if *output == "ndjson" {
+		results := printNDJSONResults(streamManifests(client, images, *tag, *platform, *maxFailures), redactor)
+		_, exitCode := manifestsExitCode(results, checkAge(results))
+		return exitCode
+	}
+
+	// 摘要输出：YAML、表格或 CSV，不含 manifest 内容
+	if *output == "yaml" || *output == "table" || *output == "csv" {
+		results := fetchManifests(client, images, *tag, *platform, *maxFailures, *retryFailed)
+		violations := checkAge(results)
+		switch *output {
+		case "table":
+			return printTableResults(results, *platform, violations, redactor)
+		case "csv":
+			return printCSVResults(results, *platform, violations, redactor)
+		}
+		return printYAMLResults(results, violations, redactor)
+	}
+
+	// 单个镜像：使用原有方式
+	if len(images) == 1 {
+		imageName, imageTag := parseImageAndTag(images[0], *tag)
+
+		client.UseFetchHook(registry.FetchHookFuncs{After: func(_ context.Context, result *registry.ManifestResult) {
+			for _, warning := range result.Warnings {
+				fmt.Fprintf(os.Stderr, "警告: %s\n", warning)
+			}
+		}})
+		single := fetchManifests(client, images, *tag, *platform, *maxFailures, *retryFailed)[0]
+		digest := single.Digest
+
+		if single.Error != nil {
+			fmt.Fprintf(os.Stderr, "错误: %s\n", redactor.Text(single.Error.Error(), imageName))
+			_, exitCode := classifyError(single.Error)
+			return exitCode
+		}
+
+		if *showDigest && digest != "" {
+			fmt.Fprintf(os.Stderr, "Digest: %s\n", digest)
+			if single.IndexDigest != "" {
+				fmt.Fprintf(os.Stderr, "Index Digest: %s\n", single.IndexDigest)
+			}
+			fmt.Fprintf(os.Stderr, "\n")
+		}
+
+		printManifest(single.Manifest, *pretty)
+
+		if agePolicy.Enabled() {
+			result := registry.ManifestResult{Image: imageName, Tag: imageTag, Digest: digest}
+			if violations := checkImageAge(client, []registry.ManifestResult{result}, agePolicy); len(violations) > 0 {
+				printAgeViolations(violations, redactor)
+				return exitAgeViolation
+			}
+		}
+		return exitOK
+	}
+
+	// 多个镜像：使用批量获取（更高效）
+	results := fetchManifests(client, images, *tag, *platform, *maxFailures, *retryFailed)
+
+	// 输出结果
+	fmt.Fprintf(os.Stderr, "\n========================================\n")
+	successCount := 0
+	failCount := 0
+
+	for i, result := range results {
+		fmt.Fprintf(os.Stderr, "\n[%d/%d] 镜像: %s:%s\n", i+1, len(results), redactor.Image(result.Image), result.Tag)
+		fmt.Fprintf(os.Stderr, "----------------------------------------\n")
+
+		if result.Error != nil {
+			fmt.Fprintf(os.Stderr, "✗ 失败: %s\n", redactor.Text(result.Error.Error(), result.Image))
+			failCount++
+			continue
+		}
+
+		successCount++
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(os.Stderr, "⚠ 警告: %s\n", warning)
+		}
+		if *showDigest && result.Digest != "" {
+			fmt.Fprintf(os.Stderr, "✓ Digest: %s\n", result.Digest)
+			if result.IndexDigest != "" {
+				fmt.Fprintf(os.Stderr, "  Index Digest: %s\n", result.IndexDigest)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "✓ 成功\n")
+		}
+
+		// 输出 manifest
+		printManifest(result.Manifest, *pretty)
+
+		if i < len(results)-1 {
+			fmt.Println()
+		}
+	}
+
+	// 输出统计信息
+	fmt.Fprintf(os.Stderr, "\n========================================\n")
+	fmt.Fprintf(os.Stderr, "总计: %d 个镜像, 成功: %d, 失败: %d\n",
+		len(results), successCount, failCount)
+
+	// 存在无法访问的 registry 时输出可用性汇总
+	if unavailable := client.Availability().Unavailable(); len(unavailable) > 0 {
+		images := resultImages(results)
+		for i := range unavailable {
+			unavailable[i].LastError = redactor.Text(unavailable[i].LastError, images...)
+		}
+		fmt.Fprintf(os.Stderr, "\nregistry 可用性:\n")
+		unavailable.WriteSummary(os.Stderr)
+	}
+
+	var violations []registry.AgeViolation
+	if agePolicy.Enabled() {
+		if violations = checkImageAge(client, results, agePolicy); len(violations) > 0 {
+			printAgeViolations(violations, redactor)
+		}
+	}
+
+	_, exitCode := manifestsExitCode(results, violations)
+	return exitCode
+}
+
+// fetchManifests 获取镜像列表的 manifest
+// 单个镜像直接获取，多个镜像使用批量获取（并发=5，使用批量认证），失败数达到 maxFailures 时中止（0 表示不中止），
+// 结束后重试失败的镜像 retryFailed 次；platform 不为空时多架构镜像返回该平台的 manifest
+func fetchManifests(client *registry.Client, images []string, tag, platform string, maxFailures, retryFailed int) []registry.ManifestResult {
+	if len(images) == 1 && platform == "" {
+		imageName, imageTag := parseImageAndTag(images[0], tag)
+		manifest, digest, err := client.GetManifestWithDigest(imageName, imageTag)
+		return []registry.ManifestResult{{
+			Image:    imageName,
+			Tag:      imageTag,
+			Manifest: manifest,
+			Digest:   digest,
+			Error:    err,
+		}}
+	}
+
+	if len(images) > 1 {
+		fmt.Fprintf(os.Stderr, "准备批量获取 %d 个镜像...\n", len(images))
+	}
+
+	// 构建 ImageSpec 列表
+	imageSpecs := make([]registry.ImageSpec, len(images))
+	for i, img := range images {
+		imageName, imageTag := parseImageAndTag(img, tag)
+		imageSpecs[i] = registry.ImageSpec{
+			Image:    imageName,
+			Tag:      imageTag,
+			Platform: platform,
+		}
+	}
+
+	return client.GetManifestsWithOptions(context.Background(), imageSpecs, registry.BatchOptions{
+		Concurrency: 5,
+		BatchAuth:   true,
+		MaxFailures: maxFailures,
+		RetryFailed: retryFailed,
+	})
+}
+
+// streamManifests 与 fetchManifests 相同，但通过 GetManifestsStream 获取，每个镜像完成后立即发送结果
+// 结果按完成顺序发送，不重试失败的镜像
+func streamManifests(client *registry.Client, images []string, tag, platform string, maxFailures int) <-chan registry.ManifestResult {
+	imageSpecs := make([]registry.ImageSpec, len(images))
+	for i, img := range images {
+		imageName, imageTag := parseImageAndTag(img, tag)
+		imageSpecs[i] = registry.ImageSpec{Image: imageName, Tag: imageTag, Platform: platform}
+	}
+	return client.GetManifestsStream(context.Background(), imageSpecs, registry.BatchOptions{
+		Concurrency: 5,
+		BatchAuth:   true,
+		MaxFailures: maxFailures,
+	})
+}
+
+// printDigestOnly 只通过 HEAD 请求获取每个镜像的 digest，每行输出 <image>:<tag> <digest>，返回退出码
+// 使用与 digest 子命令相同的并发数和批量认证；platform 不为空时多架构镜像需要下载索引
+// 失败的镜像输出到标准错误，失败数达到 maxFailures 时中止（0 表示不中止）
+func printDigestOnly(client *registry.Client, images []string, tag, platform string, maxFailures int, redactor *registry.Redactor) int {
+	imageSpecs := make([]registry.ImageSpec, len(images))
+	for i, img := range images {
+		imageName, imageTag := parseImageAndTag(img, tag)
+		imageSpecs[i] = registry.ImageSpec{Image: imageName, Tag: imageTag, Platform: platform}
+	}
+	results := client.GetDigests(context.Background(), imageSpecs, registry.BatchOptions{
+		Concurrency: defaultDigestConcurrency,
+		BatchAuth:   true,
+		MaxFailures: maxFailures,
+	})
+
+	errs := make([]error, len(results))
+	for i, result := range results {
+		errs[i] = result.Error
+		if result.Error != nil {
+			fmt.Fprintf(os.Stderr, "✗ %s:%s: %s\n", redactor.Image(result.Image), result.Tag,
+				redactor.Text(result.Error.Error(), result.Image))
+			continue
+		}
+		fmt.Printf("%s:%s %s\n", redactor.Image(result.Image), result.Tag, result.Digest)
+	}
+	_, exitCode := errorsExitCode(errs)
+	return exitCode
+}
+
+// printManifest 输出 manifest JSON
+func printManifest(manifestJSON string, pretty bool) {
+	if pretty {
+		var jsonData interface{}
+		if err := json.Unmarshal([]byte(manifestJSON), &jsonData); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 无法解析 JSON，将输出原始数据\n")
+			fmt.Println(manifestJSON)
+		} else {
+			prettyJSON, err := json.MarshalIndent(jsonData, "", "  ")
+			if err != nil {
+				fmt.Println(manifestJSON)
+			} else {
+				fmt.Println(string(prettyJSON))
+			}
+		}
+	} else {
+		fmt.Println(manifestJSON)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// outDirSummaryFile 为 -out-dir 中汇总全部镜像结果的索引文件
+const outDirSummaryFile = "summary.json"
+
+// outDirEntry 表示 -out-dir 索引文件中单个镜像的结果
+type outDirEntry struct {
+	Image       string `json:"image"`
+	Tag         string `json:"tag"`
+	Digest      string `json:"digest,omitempty"`
+	IndexDigest string `json:"index_digest,omitempty"`
+	File        string `json:"file,omitempty"` // manifest 文件名，相对于 -out-dir
+	Error       string `json:"error,omitempty"`
+	ErrorCode   string `json:"error_code,omitempty"`
+}
+
+// outDirSummary 表示 -out-dir 索引文件的结构
+type outDirSummary struct {
+	Results   []outDirEntry `json:"results"`
+	Total     int           `json:"total"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	ErrorCode string        `json:"error_code,omitempty"`
+}
+
+// writeOutDir 将每个获取成功的镜像的 manifest 原样写入 dir 中的 <registry>_<image>_<tag>.json，
+// 并写入汇总全部结果的 summary.json；标准输出每行输出一个写入的文件路径，失败的镜像输出到标准错误，返回退出码
+// manifest 保持 registry 返回的原始字节，写入的文件的 sha256 与 digest 一致
+func writeOutDir(dir string, results []registry.ManifestResult, violations []registry.AgeViolation, redactor *registry.Redactor) int {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 创建输出目录失败: %v\n", err)
+		return exitError
+	}
+
+	summary := outDirSummary{Results: make([]outDirEntry, len(results)), Total: len(results)}
+	for i, result := range results {
+		entry := outDirEntry{
+			Image:       redactor.Image(result.Image),
+			Tag:         result.Tag,
+			Digest:      result.Digest,
+			IndexDigest: result.IndexDigest,
+		}
+		if result.Error != nil {
+			entry.Error = redactor.Text(result.Error.Error(), result.Image)
+			entry.ErrorCode, _ = classifyError(result.Error)
+			summary.Failed++
+			summary.Results[i] = entry
+			fmt.Fprintf(os.Stderr, "✗ %s:%s: %s\n", entry.Image, result.Tag, entry.Error)
+			continue
+		}
+
+		entry.File = manifestFileName(result.Image, result.Tag, redactor)
+		path := filepath.Join(dir, entry.File)
+		if err := os.WriteFile(path, []byte(result.Manifest), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 写入 manifest 失败: %v\n", err)
+			return exitError
+		}
+		summary.Succeeded++
+		summary.Results[i] = entry
+		fmt.Println(path)
+	}
+
+	code, exitCode := manifestsExitCode(results, violations)
+	summary.ErrorCode = code
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 生成索引文件失败: %v\n", err)
+		return exitError
+	}
+	if err := os.WriteFile(filepath.Join(dir, outDirSummaryFile), append(data, '\n'), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 写入索引文件失败: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(os.Stderr, "已写入 %d 个 manifest 到 %s，索引文件: %s\n", summary.Succeeded, dir, outDirSummaryFile)
+	return exitCode
+}
+
+// manifestFileName 返回镜像 manifest 在 -out-dir 中的文件名 <registry>_<image>_<tag>.json
+// registry 为 registry key，image 为 registry 中的仓库名称（如 library/nginx），/、: 等字符替换为 _
+func manifestFileName(image, tag string, redactor *registry.Redactor) string {
+	registryKey := registry.DetectRegistry(image)
+	repository := redactor.Repository(registry.NormalizeImageName(image, registryKey))
+	name := registryKey + "_" + repository + "_" + tag
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name) + ".json"
+}
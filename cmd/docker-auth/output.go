@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+	"gopkg.in/yaml.v3"
+)
+
+// 退出码，便于脚本根据失败类型分支处理
+const (
+	exitOK             = 0 // 全部成功
+	exitError          = 1 // 参数错误或其他未分类的错误
+	exitAuthFailed     = 2 // 认证失败（401/403）
+	exitNotFound       = 3 // 镜像或标签不存在（404）
+	exitRateLimited    = 4 // 被 registry 限流（429）
+	exitNetwork        = 5 // 网络错误（连接失败、超时等）
+	exitPartialFailure = 6 // 批量获取时部分镜像失败
+	exitAgeViolation   = 7 // 镜像创建时间不符合 -max-age / -min-age
+	exitMismatch       = 8 // compare 子命令发现各 registry 中的镜像不一致
+)
+
+// 机器可读的错误码，用于 JSON 输出的 error_code 字段
+const (
+	codeAuthFailed     = "auth_failed"
+	codeNotFound       = "not_found"
+	codeRateLimited    = "rate_limited"
+	codeNetwork        = "network_error"
+	codeUnavailable    = "registry_unavailable"
+	codePolicyDenied   = "policy_denied"
+	codeReadOnly       = "read_only"
+	codePartialFailure = "partial_failure"
+	codeAborted        = "batch_aborted"
+	codeAgeViolation   = "age_violation"
+	codeDigestMismatch = "digest_mismatch"
+	codeCacheMiss      = "cache_miss"
+	codeOffline        = "offline"
+	codeUnknown        = "unknown_error"
+)
+
+// -partial-failure 的取值，决定批量获取时部分镜像失败的退出码
+const (
+	partialFailureError    = "error"    // 退出码为 exitPartialFailure
+	partialFailureIgnore   = "ignore"   // 只要有镜像获取成功，退出码为 exitOK
+	partialFailureClassify = "classify" // 退出码为第一个失败镜像的错误分类，如认证失败为 exitAuthFailed
+)
+
+// partialFailurePolicy 为 -partial-failure 指定的部分失败退出码策略
+var partialFailurePolicy = partialFailureError
+
+// registerExitPolicy 在 fs 中注册 -partial-failure 参数
+func registerExitPolicy(fs *flag.FlagSet) {
+	fs.Func("partial-failure", "批量获取时部分镜像失败的退出码: error、ignore 或 classify (默认: error)\n"+
+		"  error: 退出码为 6；ignore: 只要有镜像获取成功，退出码为 0；\n"+
+		"  classify: 退出码为第一个失败镜像的错误分类 (2 认证失败, 3 不存在, 4 被限流, 5 网络错误)", func(value string) error {
+		switch value {
+		case partialFailureError, partialFailureIgnore, partialFailureClassify:
+			partialFailurePolicy = value
+			return nil
+		}
+		return fmt.Errorf("应为 %s、%s 或 %s", partialFailureError, partialFailureIgnore, partialFailureClassify)
+	})
+}
+
+// classifyError 根据错误类型返回错误码和退出码
+func classifyError(err error) (string, int) {
+	if errors.Is(err, registry.ErrImageNotAllowed) {
+		return codePolicyDenied, exitError
+	}
+	if errors.Is(err, registry.ErrReadOnly) {
+		return codeReadOnly, exitError
+	}
+	if errors.Is(err, registry.ErrBatchAborted) {
+		return codeAborted, exitError
+	}
+	if errors.Is(err, registry.ErrCacheMiss) {
+		return codeCacheMiss, exitError
+	}
+	if errors.Is(err, registry.ErrOffline) {
+		return codeOffline, exitError
+	}
+
+	switch {
+	case errors.Is(err, registry.ErrRegistryUnavailable):
+		return codeUnavailable, exitNetwork
+	case errors.Is(err, registry.ErrRateLimited):
+		return codeRateLimited, exitRateLimited
+	case errors.Is(err, registry.ErrUnauthorized), errors.Is(err, registry.ErrForbidden):
+		return codeAuthFailed, exitAuthFailed
+	case errors.Is(err, registry.ErrNotFound):
+		return codeNotFound, exitNotFound
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return codeNetwork, exitNetwork
+	}
+
+	// 认证阶段的其他错误，如 registry 要求认证但未配置凭据
+	var authErr *registry.AuthError
+	if errors.As(err, &authErr) {
+		return codeAuthFailed, exitAuthFailed
+	}
+
+	return codeUnknown, exitError
+}
+
+// resultsExitCode 根据批量结果计算整体的错误码和退出码
+// 全部成功返回 exitOK；部分失败返回 exitPartialFailure；全部失败时使用第一个错误的分类
+// 批量任务因 -max-failures 中止时，使用第一个导致中止的错误的分类
+func resultsExitCode(results []registry.ManifestResult) (string, int) {
+	errs := make([]error, len(results))
+	for i, result := range results {
+		errs[i] = result.Error
+	}
+	return errorsExitCode(errs)
+}
+
+// manifestsExitCode 与 resultsExitCode 相同，但全部成功且存在创建时间不符合策略的镜像时返回 age_violation
+func manifestsExitCode(results []registry.ManifestResult, violations []registry.AgeViolation) (string, int) {
+	code, exitCode := resultsExitCode(results)
+	if exitCode == exitOK && len(violations) > 0 {
+		return codeAgeViolation, exitAgeViolation
+	}
+	return code, exitCode
+}
+
+// errorsExitCode 根据批量结果中每个镜像的错误（成功为 nil）计算整体的错误码和退出码，规则见 resultsExitCode
+// 部分失败时错误码始终为 partial_failure，退出码由 -partial-failure 决定
+func errorsExitCode(errs []error) (string, int) {
+	var firstErr error
+	failCount := 0
+	aborted := false
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		failCount++
+		if errors.Is(err, registry.ErrBatchAborted) {
+			aborted = true
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	switch {
+	case failCount == 0:
+		return "", exitOK
+	case aborted && firstErr != nil:
+		return classifyError(firstErr)
+	case failCount < len(errs):
+		switch {
+		case partialFailurePolicy == partialFailureIgnore:
+			return codePartialFailure, exitOK
+		case partialFailurePolicy == partialFailureClassify && firstErr != nil:
+			_, exitCode := classifyError(firstErr)
+			return codePartialFailure, exitCode
+		}
+		return codePartialFailure, exitPartialFailure
+	default:
+		return classifyError(firstErr)
+	}
+}
+
+// jsonResult 表示 JSON 输出中单个镜像的结果
+type jsonResult struct {
+	Image       string          `json:"image"`
+	Tag         string          `json:"tag"`
+	Digest      string          `json:"digest,omitempty"`
+	IndexDigest string          `json:"index_digest,omitempty"` // 按 -platform 解析多架构索引时为索引的 digest
+	Manifest    json.RawMessage `json:"manifest,omitempty"`
+	Warnings    []string        `json:"warnings,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	ErrorCode   string          `json:"error_code,omitempty"`
+}
+
+// jsonOutput 表示 -output json 的输出结构
+type jsonOutput struct {
+	Results   []jsonResult `json:"results"`
+	Total     int          `json:"total"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	ErrorCode string       `json:"error_code,omitempty"`
+
+	// UnavailableRegistries 为无法访问的 registry 及认证服务的可用性汇总
+	UnavailableRegistries registry.AvailabilityReport `json:"unavailable_registries,omitempty"`
+
+	// AgeViolations 为创建时间不符合 -max-age / -min-age 的镜像
+	AgeViolations []registry.AgeViolation `json:"age_violations,omitempty"`
+}
+
+// printJSONResults 以 JSON 格式输出结果，返回退出码
+// redactor 不为 nil 时对镜像名称和错误信息中的仓库名称脱敏，digest 保持不变
+// violations 为创建时间不符合策略的镜像，存在时整体的错误码为 age_violation
+func printJSONResults(results []registry.ManifestResult, unavailable registry.AvailabilityReport, violations []registry.AgeViolation, redactor *registry.Redactor, pretty bool) int {
+	images := resultImages(results)
+	for i := range unavailable {
+		unavailable[i].LastError = redactor.Text(unavailable[i].LastError, images...)
+	}
+	for i := range violations {
+		violations[i].Reason = redactor.Text(violations[i].Reason, violations[i].Image)
+		violations[i].Image = redactor.Image(violations[i].Image)
+	}
+
+	output := jsonOutput{
+		Results:               make([]jsonResult, len(results)),
+		Total:                 len(results),
+		UnavailableRegistries: unavailable,
+		AgeViolations:         violations,
+	}
+
+	for i, result := range results {
+		if result.Error != nil {
+			output.Failed++
+		} else {
+			output.Succeeded++
+		}
+		output.Results[i] = newJSONResult(result, redactor)
+	}
+
+	code, exitCode := manifestsExitCode(results, violations)
+	output.ErrorCode = code
+
+	var data []byte
+	var err error
+	if pretty {
+		data, err = json.MarshalIndent(output, "", "  ")
+	} else {
+		data, err = json.Marshal(output)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 生成 JSON 输出失败: %v\n", err)
+		return exitError
+	}
+	fmt.Println(string(data))
+	return exitCode
+}
+
+// newJSONResult 将单个镜像的结果转换为 JSON 输出的结构，对镜像名称和错误信息中的仓库名称脱敏
+func newJSONResult(result registry.ManifestResult, redactor *registry.Redactor) jsonResult {
+	item := jsonResult{
+		Image:       redactor.Image(result.Image),
+		Tag:         result.Tag,
+		Digest:      result.Digest,
+		IndexDigest: result.IndexDigest,
+	}
+	if result.Error != nil {
+		item.Error = redactor.Text(result.Error.Error(), result.Image)
+		item.ErrorCode, _ = classifyError(result.Error)
+	} else {
+		item.Manifest = manifestJSONValue(result.Manifest)
+		item.Warnings = result.Warnings
+	}
+	return item
+}
+
+// printNDJSONResults 每收到一个镜像的结果立即输出一行 JSON（NDJSON），结构与 -output json 中的单个结果相同
+// 返回收到的全部结果，供调用方计算退出码
+func printNDJSONResults(stream <-chan registry.ManifestResult, redactor *registry.Redactor) []registry.ManifestResult {
+	var results []registry.ManifestResult
+	encoder := json.NewEncoder(os.Stdout)
+	for result := range stream {
+		results = append(results, result)
+		if err := encoder.Encode(newJSONResult(result, redactor)); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 生成 JSON 输出失败: %v\n", err)
+		}
+	}
+	return results
+}
+
+// yamlResult 表示 -output yaml 输出中单个镜像的结果，不包含 manifest 内容
+type yamlResult struct {
+	Image       string `yaml:"image"`
+	Tag         string `yaml:"tag"`
+	Digest      string `yaml:"digest,omitempty"`
+	IndexDigest string `yaml:"index_digest,omitempty"`
+	MediaType   string `yaml:"mediaType,omitempty"`
+	Error       string `yaml:"error,omitempty"`
+	ErrorCode   string `yaml:"error_code,omitempty"`
+}
+
+// yamlOutput 表示 -output yaml 的输出结构
+type yamlOutput struct {
+	Results   []yamlResult `yaml:"results"`
+	Total     int          `yaml:"total"`
+	Succeeded int          `yaml:"succeeded"`
+	Failed    int          `yaml:"failed"`
+	ErrorCode string       `yaml:"error_code,omitempty"`
+}
+
+// printYAMLResults 以 YAML 格式输出结果，返回退出码
+// 每个镜像只输出 digest 和 manifest 的媒体类型，便于 GitOps 工具直接读取；脱敏和退出码规则与 printJSONResults 相同
+func printYAMLResults(results []registry.ManifestResult, violations []registry.AgeViolation, redactor *registry.Redactor) int {
+	output := yamlOutput{
+		Results: make([]yamlResult, len(results)),
+		Total:   len(results),
+	}
+	for i, result := range results {
+		item := yamlResult{
+			Image:       redactor.Image(result.Image),
+			Tag:         result.Tag,
+			Digest:      result.Digest,
+			IndexDigest: result.IndexDigest,
+		}
+		if result.Error != nil {
+			item.Error = redactor.Text(result.Error.Error(), result.Image)
+			item.ErrorCode, _ = classifyError(result.Error)
+			output.Failed++
+		} else {
+			item.MediaType = manifestMediaType(result.Manifest)
+			output.Succeeded++
+		}
+		output.Results[i] = item
+	}
+
+	code, exitCode := manifestsExitCode(results, violations)
+	output.ErrorCode = code
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(output); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 生成 YAML 输出失败: %v\n", err)
+		return exitError
+	}
+	encoder.Close()
+	return exitCode
+}
+
+// manifestMediaType 返回 manifest 中 mediaType 字段的值，无法解析时返回空字符串
+func manifestMediaType(manifest string) string {
+	var header struct {
+		MediaType string `json:"mediaType"`
+	}
+	if err := json.Unmarshal([]byte(manifest), &header); err != nil {
+		return ""
+	}
+	return header.MediaType
+}
+
+// checkImageAge 获取成功镜像的配置，按创建时间检查是否符合策略，返回违规的镜像
+// 按结果中的 digest 获取配置，确保检查的是刚获取到的同一个镜像
+func checkImageAge(client *registry.Client, results []registry.ManifestResult, policy registry.AgePolicy) []registry.AgeViolation {
+	var specs []registry.ImageSpec
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+		reference := result.Tag
+		if result.Digest != "" {
+			reference = result.Digest
+		}
+		specs = append(specs, registry.ImageSpec{Image: result.Image, Tag: reference})
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+
+	configs := client.GetConfigs(specs, registry.BatchOptions{Concurrency: 5, BatchAuth: true})
+	// 报告中使用原始标签
+	for i := range configs {
+		configs[i].Tag = tagOf(results, configs[i].Image, configs[i].Tag)
+	}
+	return policy.Check(configs, time.Now())
+}
+
+// tagOf 返回结果中 digest 对应的原始标签
+func tagOf(results []registry.ManifestResult, image, digest string) string {
+	for _, result := range results {
+		if result.Image == image && result.Digest == digest {
+			return result.Tag
+		}
+	}
+	return digest
+}
+
+// printAgeViolations 输出创建时间不符合策略的镜像
+func printAgeViolations(violations []registry.AgeViolation, redactor *registry.Redactor) {
+	fmt.Fprintf(os.Stderr, "\n镜像年龄检查失败 (%d 个镜像):\n", len(violations))
+	for _, violation := range violations {
+		fmt.Fprintf(os.Stderr, "✗ %s:%s: %s\n", redactor.Image(violation.Image), violation.Tag,
+			redactor.Text(violation.Reason, violation.Image))
+	}
+}
+
+// resultImages 返回结果中的所有镜像名称，用于对错误信息脱敏
+func resultImages(results []registry.ManifestResult) []string {
+	images := make([]string, len(results))
+	for i, result := range results {
+		images[i] = result.Image
+	}
+	return images
+}
+
+// manifestJSONValue 将 manifest 转换为 JSON 值
+// 内容不是合法 JSON 时作为字符串输出
+func manifestJSONValue(manifest string) json.RawMessage {
+	if json.Valid([]byte(manifest)) {
+		return json.RawMessage(manifest)
+	}
+	quoted, _ := json.Marshal(manifest)
+	return quoted
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// runRegistries 执行 registries 子命令，返回退出码
+// 列出内置和 -registries-config 中注册的 registry，按 key 排序
+func runRegistries(args []string) int {
+	fs := flag.NewFlagSet("registries", flag.ExitOnError)
+	registriesConfig := fs.String("registries-config", "", "registry 配置文件 (JSON，可选)")
+	output := fs.String("output", "text", "输出格式: text 或 json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s registries [选项]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "列出已注册的 registry，包括内置的 registry 和 -registries-config 中的 registry。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s registries -registries-config registries.json\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text 或 json\n", *output)
+		return exitError
+	}
+	if *registriesConfig != "" {
+		if err := registry.LoadRegistriesFile(*registriesConfig); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+	}
+
+	var configs []*registry.RegistryConfig
+	for _, config := range registry.ListRegistries() {
+		configs = append(configs, config)
+	}
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Key < configs[j].Key })
+
+	if *output == "json" {
+		data, _ := json.Marshal(struct {
+			Registries []*registry.RegistryConfig `json:"registries"`
+		}{configs})
+		fmt.Println(string(data))
+		return exitOK
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tNAME\tREGISTRY URL\tDEFAULT TAG\tDEFAULT PLATFORM")
+	for _, config := range configs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", config.Key, config.Name, config.RegistryURL,
+			valueOrDash(config.DefaultTag), valueOrDash(config.DefaultPlatform))
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// valueOrDash 返回 value，value 为空时返回 -
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
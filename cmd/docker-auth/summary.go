@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// summaryColumns 为 -output table 和 -output csv 的列
+var summaryColumns = []string{"IMAGE", "TAG", "DIGEST", "SIZE", "PLATFORMS", "STATUS"}
+
+// manifestSummary 表示 -output table 和 -output csv 中单个镜像的一行
+type manifestSummary struct {
+	Image     string
+	Tag       string
+	Digest    string
+	Size      int64    // 配置和全部层的大小之和，索引和无法计算时为 -1
+	Platforms []string // 索引中的平台（不包括 unknown/unknown 的证明 manifest），按 -platform 解析时为所选平台
+	Status    string   // 成功为 ok，失败为错误码
+}
+
+// summarizeManifest 从 manifest 内容中提取摘要，不发送额外请求
+// platform 为 -platform 指定的平台，结果由索引解析而来时作为该镜像的平台
+func summarizeManifest(result registry.ManifestResult, platform string, redactor *registry.Redactor) manifestSummary {
+	summary := manifestSummary{
+		Image:  redactor.Image(result.Image),
+		Tag:    result.Tag,
+		Digest: result.Digest,
+		Size:   -1,
+		Status: "ok",
+	}
+	if result.Error != nil {
+		summary.Status, _ = classifyError(result.Error)
+		return summary
+	}
+	if result.IndexDigest != "" && platform != "" {
+		summary.Platforms = []string{platform}
+	}
+
+	var probe struct {
+		registry.ImageManifest
+		Manifests []registry.Descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal([]byte(result.Manifest), &probe); err != nil {
+		return summary
+	}
+	if probe.Manifests != nil {
+		for _, desc := range probe.Manifests {
+			if desc.Platform == nil || desc.Platform.OS == "unknown" {
+				continue
+			}
+			summary.Platforms = append(summary.Platforms, desc.Platform.String())
+		}
+		return summary
+	}
+	if probe.Config.Digest == "" {
+		// schema 1 等没有配置描述符的 manifest
+		return summary
+	}
+	summary.Size = probe.Config.Size
+	for _, layer := range probe.Layers {
+		summary.Size += layer.Size
+	}
+	return summary
+}
+
+// printTableResults 以表格输出每个镜像的摘要，失败的镜像同样输出一行，错误信息输出到标准错误，返回退出码
+func printTableResults(results []registry.ManifestResult, platform string, violations []registry.AgeViolation, redactor *registry.Redactor) int {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(summaryColumns, "\t"))
+	for _, result := range results {
+		summary := summarizeManifest(result, platform, redactor)
+		size := "-"
+		if summary.Size >= 0 {
+			size = formatBytes(summary.Size)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", summary.Image, summary.Tag, valueOrDash(summary.Digest), size,
+			valueOrDash(strings.Join(summary.Platforms, ",")), summary.Status)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	printResultErrors(results, redactor)
+
+	_, exitCode := manifestsExitCode(results, violations)
+	return exitCode
+}
+
+// printCSVResults 以 CSV 输出每个镜像的摘要，第一行为列名，SIZE 为字节数（无法计算时为空），
+// 多个平台以空格分隔；错误信息输出到标准错误，返回退出码
+func printCSVResults(results []registry.ManifestResult, platform string, violations []registry.AgeViolation, redactor *registry.Redactor) int {
+	w := csv.NewWriter(os.Stdout)
+	w.Write(summaryColumns)
+	for _, result := range results {
+		summary := summarizeManifest(result, platform, redactor)
+		var size string
+		if summary.Size >= 0 {
+			size = strconv.FormatInt(summary.Size, 10)
+		}
+		w.Write([]string{summary.Image, summary.Tag, summary.Digest, size, strings.Join(summary.Platforms, " "), summary.Status})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	printResultErrors(results, redactor)
+
+	_, exitCode := manifestsExitCode(results, violations)
+	return exitCode
+}
+
+// printResultErrors 将失败镜像的错误信息输出到标准错误
+func printResultErrors(results []registry.ManifestResult, redactor *registry.Redactor) {
+	for _, result := range results {
+		if result.Error != nil {
+			fmt.Fprintf(os.Stderr, "✗ %s:%s: %s\n", redactor.Image(result.Image), result.Tag,
+				redactor.Text(result.Error.Error(), result.Image))
+		}
+	}
+}
+
+// formatBytes 将字节数格式化为易读的形式
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
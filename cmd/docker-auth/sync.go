@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/mirror"
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// runSync 执行 sync 子命令，返回退出码
+// 按映射比较源和目标的 digest，只复制目标中不存在或 digest 不同的标签
+// 源和目标可以带传输方式前缀，与 copy 子命令相同（docker-archive 除外）
+func runSync(args []string) int {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	configFile := fs.String("config", "", "同步映射文件 (JSON)，格式见 README")
+	tags := fs.String("tags", "", "命令行映射要同步的标签，逗号分隔，支持通配符，如 '1.27*,stable' (默认: 全部标签)")
+	tagRegex := fs.String("tag-regex", "", "命令行映射的标签还需匹配的正则表达式")
+	excludeTags := fs.String("exclude-tags", "", "命令行映射排除的标签，逗号分隔，支持通配符")
+	var clientOpts clientFlags
+	clientOpts.register(fs)
+	var transportOpts transportFlags
+	transportOpts.register(fs)
+	concurrency := fs.Int("concurrency", mirror.DefaultConcurrency, "同时复制的镜像数")
+	timeout := fs.Duration("timeout", 0, "单个 HTTP 请求的总超时，0 表示不限制 (复制大的 blob 可能需要较长时间)")
+	dryRun := fs.Bool("dry-run", false, "只比较 digest 并列出需要复制的标签，不写入目标")
+	output := fs.String("output", "text", "输出格式: text 或 json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s sync [选项] [源仓库=目标仓库]...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "将源仓库的标签同步到目标仓库，只复制目标中不存在或 digest 不同的标签。\n")
+		fmt.Fprintf(os.Stderr, "映射可以通过参数和 -config 同时指定；-tags 等标签过滤只作用于参数中的映射。\n")
+		fmt.Fprintf(os.Stderr, "源和目标可以带 oci:、docker-daemon:、containerd: 前缀（见 copy -h），源不是 registry 时需要用 -tags 指定不含通配符的标签；\n")
+		fmt.Fprintf(os.Stderr, "docker-daemon 中通过 docker load 导入的镜像没有 registry digest，每次同步都会重新复制。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s sync -tags '1.27*' nginx=registry.example.com/mirror/nginx\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s sync -config mirror.json -dry-run\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s sync -tags '1.27,1.26' nginx=oci:/data/mirror/nginx\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s sync -namespace k8s.io -tags v1 ghcr.io/org/app=containerd:ghcr.io/org/app\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text 或 json\n", *output)
+		return exitError
+	}
+
+	var mappings []mirror.Mapping
+	if *configFile != "" {
+		loaded, err := mirror.LoadMappingsFile(*configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			return exitError
+		}
+		mappings = append(mappings, loaded...)
+	}
+	for _, arg := range fs.Args() {
+		source, destination, ok := strings.Cut(arg, "=")
+		if !ok || source == "" || destination == "" {
+			fmt.Fprintf(os.Stderr, "错误: 映射格式错误，应为 源仓库=目标仓库: %s\n", arg)
+			return exitError
+		}
+		mappings = append(mappings, mirror.Mapping{
+			Source:      source,
+			Destination: destination,
+			Tags:        splitList(*tags),
+			TagRegex:    *tagRegex,
+			ExcludeTags: splitList(*excludeTags),
+		})
+	}
+	if len(mappings) == 0 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定同步映射\n\n")
+		fs.Usage()
+		return exitError
+	}
+
+	client, err := clientOpts.newClient(*timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	var endpoints []registry.ImageReference
+	for _, mapping := range mappings {
+		for _, ref := range []string{mapping.Source, mapping.Destination} {
+			if parsed, err := registry.ParseImageReference(ref); err == nil {
+				endpoints = append(endpoints, parsed)
+			}
+		}
+	}
+	closeTransports, err := transportOpts.configure(client, endpoints...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	defer closeTransports()
+
+	opts := mirror.Options{Concurrency: *concurrency, DryRun: *dryRun}
+	if *output == "text" {
+		opts.OnResult = printSyncResult
+	}
+	start := time.Now()
+	report, err := mirror.New(client, opts).Run(context.Background(), mappings)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+
+	errs := make([]error, len(report.Results))
+	for i, result := range report.Results {
+		errs[i] = result.Error
+	}
+	code, exitCode := errorsExitCode(errs)
+
+	if *output == "json" {
+		data, _ := json.Marshal(struct {
+			*mirror.Report
+			ErrorCode string `json:"error_code,omitempty"`
+		}{report, code})
+		fmt.Println(string(data))
+		return exitCode
+	}
+
+	verb := "已复制"
+	if *dryRun {
+		verb = "需要复制"
+	}
+	fmt.Fprintf(os.Stderr, "\n%s %d 个，已是最新 %d 个，失败 %d 个，耗时 %s\n",
+		verb, report.Count(mirror.ActionCopy), report.Count(mirror.ActionUpToDate), report.Count(mirror.ActionFailed),
+		time.Since(start).Round(time.Millisecond))
+	return exitCode
+}
+
+// printSyncResult 输出单个标签的同步结果
+func printSyncResult(result mirror.Result) {
+	switch result.Action {
+	case mirror.ActionFailed:
+		fmt.Fprintf(os.Stderr, "✗ %s: %v\n", result.Source, result.Error)
+	case mirror.ActionUpToDate:
+		fmt.Printf("= %s → %s (%s)\n", result.Source, result.Destination, result.SourceDigest)
+	default:
+		line := fmt.Sprintf("+ %s → %s (%s)", result.Source, result.Destination, result.SourceDigest)
+		if copied := result.Copy; copied != nil {
+			line += fmt.Sprintf(" 挂载 %d 个 blob，上传 %d 个 (%d 字节)", copied.BlobsMounted, copied.BlobsUploaded, copied.BytesUploaded)
+		}
+		fmt.Println(line)
+	}
+}
+
+// splitList 按逗号拆分参数值，去除空白和空项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
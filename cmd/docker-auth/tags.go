@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// runTags 执行 tags 子命令，返回退出码
+// 列出镜像仓库的标签，每行一个，可以按正则表达式和版本约束过滤、排序，或只输出最新的版本标签
+func runTags(args []string) int {
+	fs := flag.NewFlagSet("tags", flag.ExitOnError)
+	var clientOpts clientFlags
+	clientOpts.register(fs)
+	timeout := fs.Duration("timeout", 30*time.Second, "单个 HTTP 请求的总超时，0 表示不限制")
+	regex := fs.String("regex", "", "只输出匹配该正则表达式的标签，如 '^1\\.27\\.' (可选)")
+	semver := fs.String("semver", "", "只输出满足版本约束的版本标签，多个约束以逗号或空格分隔，需同时满足 (可选)\n"+
+		"  约束为 >=、>、<=、<、= 加版本号，或不带运算符的版本前缀（1.27 匹配 1.27 和 1.27.x）；* 匹配任意版本标签\n"+
+		"  版本标签的格式为 [v]X[.Y[.Z...]][-后缀]，比较时忽略后缀，缺少的部分视为 0\n"+
+		"  示例: -semver '>=1.25,<2'")
+	sortBy := fs.String("sort", "none", "排序方式: none (registry 返回的顺序)、name 或 version\n"+
+		"  version: 版本标签按版本号从旧到新排列，其他标签按名称排在之后")
+	reverse := fs.Bool("reverse", false, "倒序输出")
+	latest := fs.Bool("latest", false, "只输出过滤后版本号最新的标签，版本号相同时不带后缀的标签优先；没有版本标签时退出码为 3")
+	output := fs.String("output", "text", "输出格式: text 或 json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s tags [选项] <镜像>\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "列出镜像仓库的标签，每行一个，自动处理分页。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s tags nginx\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s tags -output json ghcr.io/owner/repo\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s tags -semver '>=1.25,<2' -sort version -reverse nginx\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s tags -latest -regex '^[0-9.]+$' nginx\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text 或 json\n", *output)
+		return exitError
+	}
+	if *sortBy != "none" && *sortBy != "name" && *sortBy != "version" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的排序方式 '%s'，应为 none、name 或 version\n", *sortBy)
+		return exitError
+	}
+	var pattern *regexp.Regexp
+	if *regex != "" {
+		var err error
+		if pattern, err = regexp.Compile(*regex); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: -regex 无效: %v\n", err)
+			return exitError
+		}
+	}
+	constraints, err := parseVersionConstraints(*semver)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定一个镜像\n\n")
+		fs.Usage()
+		return exitError
+	}
+	image := fs.Arg(0)
+
+	client, err := clientOpts.newClient(*timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	tags, err := client.ListTags(image)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		_, exitCode := classifyError(err)
+		return exitCode
+	}
+
+	tags = filterTags(tags, pattern, constraints)
+	if *latest {
+		newest, ok := latestTag(tags)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "错误: %s 中没有符合条件的版本标签\n", image)
+			return exitNotFound
+		}
+		tags = []string{newest}
+	}
+	sortTags(tags, *sortBy, *reverse)
+
+	if *output == "json" {
+		data, _ := json.Marshal(struct {
+			Image string   `json:"image"`
+			Tags  []string `json:"tags"`
+		}{image, tags})
+		fmt.Println(string(data))
+		return exitOK
+	}
+	for _, tag := range tags {
+		fmt.Println(tag)
+	}
+	return exitOK
+}
+
+// versionConstraint 表示 -semver 中的一个版本约束
+type versionConstraint struct {
+	op      string // >=、>、<=、<、= 或空（前缀匹配）
+	numbers []int
+}
+
+// parseVersionConstraints 解析 -semver 的值，多个约束以逗号或空格分隔；* 匹配任意版本标签，返回空的约束列表
+// value 为空时返回 nil，表示不按版本过滤
+func parseVersionConstraints(value string) ([]versionConstraint, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+	constraints := []versionConstraint{}
+	for _, term := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if term == "*" {
+			continue
+		}
+		op := ""
+		for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(term, candidate) {
+				op = candidate
+				break
+			}
+		}
+		version, ok := registry.ParseTagVersion(strings.TrimPrefix(term, op))
+		if !ok || version.Suffix != "" {
+			return nil, fmt.Errorf("-semver 约束无效: '%s'，应为 >=、>、<=、<、= 加版本号或版本前缀，如 >=1.25", term)
+		}
+		constraints = append(constraints, versionConstraint{op: op, numbers: version.Numbers})
+	}
+	return constraints, nil
+}
+
+// match 判断版本号是否满足约束
+func (c versionConstraint) match(numbers []int) bool {
+	if c.op == "" {
+		if len(numbers) < len(c.numbers) {
+			return false
+		}
+		for i, n := range c.numbers {
+			if numbers[i] != n {
+				return false
+			}
+		}
+		return true
+	}
+
+	cmp := compareVersionNumbers(numbers, c.numbers)
+	switch c.op {
+	case ">=":
+		return cmp >= 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	case "<":
+		return cmp < 0
+	}
+	return cmp == 0
+}
+
+// compareVersionNumbers 比较两个版本号，缺少的部分视为 0（1.25 与 1.25.0 相同）
+func compareVersionNumbers(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
+
+// filterTags 返回匹配 pattern 且满足全部约束的标签，保持原有顺序
+// pattern 为 nil 时不按正则表达式过滤；constraints 为 nil 时不按版本过滤，否则只保留版本标签
+func filterTags(tags []string, pattern *regexp.Regexp, constraints []versionConstraint) []string {
+	selected := []string{}
+	for _, tag := range tags {
+		if pattern != nil && !pattern.MatchString(tag) {
+			continue
+		}
+		if constraints != nil {
+			version, ok := registry.ParseTagVersion(tag)
+			if !ok {
+				continue
+			}
+			matched := true
+			for _, c := range constraints {
+				if !c.match(version.Numbers) {
+					matched = false
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		selected = append(selected, tag)
+	}
+	return selected
+}
+
+// latestTag 返回版本号最新的版本标签，没有版本标签时返回 false
+func latestTag(tags []string) (string, bool) {
+	var newest registry.TagVersion
+	found := false
+	for _, tag := range tags {
+		version, ok := registry.ParseTagVersion(tag)
+		if ok && (!found || version.Compare(newest) > 0) {
+			newest, found = version, true
+		}
+	}
+	return newest.Tag, found
+}
+
+// sortTags 按 sortBy 排序标签：none 保持原有顺序，name 按名称，version 版本标签按版本号从旧到新、其他标签按名称排在之后
+func sortTags(tags []string, sortBy string, reverse bool) {
+	switch sortBy {
+	case "name":
+		sort.Strings(tags)
+	case "version":
+		sort.SliceStable(tags, func(i, j int) bool {
+			a, aok := registry.ParseTagVersion(tags[i])
+			b, bok := registry.ParseTagVersion(tags[j])
+			switch {
+			case aok && bok:
+				if cmp := a.Compare(b); cmp != 0 {
+					return cmp < 0
+				}
+				return tags[i] < tags[j]
+			case aok != bok:
+				return aok
+			}
+			return tags[i] < tags[j]
+		})
+	}
+	if reverse {
+		for i, j := 0, len(tags)-1; i < j; i, j = i+1, j-1 {
+			tags[i], tags[j] = tags[j], tags[i]
+		}
+	}
+}
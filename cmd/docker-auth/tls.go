@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// tlsFlags 表示按 registry 配置 TLS 的命令行参数，用于使用内部 PKI 的离线 registry
+type tlsFlags struct {
+	insecure    repeatedFlag
+	caCerts     repeatedFlag
+	clientCerts repeatedFlag
+}
+
+// register 在 fs 中注册 TLS 相关参数
+func (t *tlsFlags) register(fs *flag.FlagSet) {
+	fs.Var(&t.insecure, "insecure", "跳过该 registry 的 TLS 证书校验 (可重复使用)，只用于自签名证书的测试环境\n"+
+		"  registry 可以是 registry key 或域名，其他 registry 仍正常校验\n"+
+		"  示例: -insecure registry.test:5000")
+	fs.Var(&t.caCerts, "ca-cert", "registry 额外信任的 CA 证书 (PEM，可重复使用)，在系统根证书基础上追加\n"+
+		"  格式: registry=CA 证书文件\n"+
+		"  示例: -ca-cert harbor.example.com=/etc/pki/internal-ca.pem")
+	fs.Var(&t.clientCerts, "tls-client-cert", "registry 的客户端证书，用于双向 TLS (可重复使用)\n"+
+		"  格式: registry=证书文件,私钥文件\n"+
+		"  registry 可以是 registry key 或域名\n"+
+		"  示例: -tls-client-cert harbor.example.com=client.crt,client.key")
+}
+
+// configure 按参数为客户端设置每个 registry 的 CA 证书、客户端证书和跳过校验，应在加载 registry 配置文件之后调用
+func (t *tlsFlags) configure(client *registry.Client) error {
+	for _, value := range t.caCerts {
+		registryKey, caFile, ok := strings.Cut(value, "=")
+		if !ok || registryKey == "" || caFile == "" {
+			return fmt.Errorf("CA 证书格式错误，应为 registry=CA 证书文件: %s", value)
+		}
+		if err := client.SetRegistryCACertFile(registryKey, caFile); err != nil {
+			return err
+		}
+	}
+	for _, value := range t.clientCerts {
+		registryKey, certFile, keyFile, ok := parseClientCertFlag(value)
+		if !ok {
+			return fmt.Errorf("客户端证书格式错误，应为 registry=证书文件,私钥文件: %s", value)
+		}
+		if err := client.SetRegistryClientCertFile(registryKey, certFile, keyFile); err != nil {
+			return err
+		}
+	}
+	for _, registryKey := range t.insecure {
+		if err := client.SetRegistryInsecureSkipVerify(registryKey, true); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "警告: 已跳过 %s 的 TLS 证书校验，请勿在生产环境使用\n", registryKey)
+	}
+	return nil
+}
+
+// parseClientCertFlag 解析 -tls-client-cert 参数
+// 格式: registry=证书文件,私钥文件
+func parseClientCertFlag(value string) (registryKey, certFile, keyFile string, ok bool) {
+	registryKey, files, found := strings.Cut(value, "=")
+	if !found || registryKey == "" {
+		return "", "", "", false
+	}
+	certFile, keyFile, found = strings.Cut(files, ",")
+	if !found || certFile == "" || keyFile == "" {
+		return "", "", "", false
+	}
+	return registryKey, certFile, keyFile, true
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// tokenJSONResult 表示 tokens 子命令 JSON 输出中单个 registry 的结果
+type tokenJSONResult struct {
+	Registry  string   `json:"registry"`
+	Images    []string `json:"images"`
+	Token     string   `json:"token,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	ErrorCode string   `json:"error_code,omitempty"`
+}
+
+// runTokens 执行 tokens 子命令，返回退出码
+// 按 registry 分组镜像，每个 registry 获取一个可以拉取组内全部镜像的 bearer token
+func runTokens(args []string) int {
+	fs := flag.NewFlagSet("tokens", flag.ExitOnError)
+	var clientOpts clientFlags
+	clientOpts.register(fs)
+	timeout := fs.Duration("timeout", 30*time.Second, "单个 HTTP 请求的总超时，0 表示不限制")
+	output := fs.String("output", "text", "输出格式: text 或 json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s tokens [选项] <镜像>...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "为每个 registry 获取一个可以拉取全部指定镜像的 bearer token，text 格式每行输出 registry 和 token。\n")
+		fmt.Fprintf(os.Stderr, "镜像可以用逗号分隔。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s tokens nginx redis ghcr.io/owner/repo\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text 或 json\n", *output)
+		return exitError
+	}
+	var images []string
+	for _, arg := range fs.Args() {
+		images = append(images, splitList(arg)...)
+	}
+	if len(images) == 0 {
+		fmt.Fprintf(os.Stderr, "错误: 必须指定镜像名称\n\n")
+		fs.Usage()
+		return exitError
+	}
+
+	client, err := clientOpts.newClient(*timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+
+	// 按 registry 分组，保持 registry 首次出现的顺序
+	var results []tokenJSONResult
+	index := make(map[string]int)
+	for _, img := range images {
+		imageName, _ := parseImageAndTag(img, "")
+		key := registry.DetectRegistry(imageName)
+		i, ok := index[key]
+		if !ok {
+			i = len(results)
+			index[key] = i
+			results = append(results, tokenJSONResult{Registry: key})
+		}
+		results[i].Images = append(results[i].Images, imageName)
+	}
+
+	errs := make([]error, len(results))
+	for i := range results {
+		token, err := client.GetAuthTokenForImages(results[i].Images, results[i].Registry)
+		if err != nil {
+			errs[i] = err
+			results[i].Error = err.Error()
+			results[i].ErrorCode, _ = classifyError(err)
+			continue
+		}
+		results[i].Token = token
+	}
+	code, exitCode := errorsExitCode(errs)
+
+	if *output == "json" {
+		data, _ := json.Marshal(struct {
+			Results   []tokenJSONResult `json:"results"`
+			ErrorCode string            `json:"error_code,omitempty"`
+		}{results, code})
+		fmt.Println(string(data))
+		return exitCode
+	}
+
+	for _, result := range results {
+		if result.Error != "" {
+			fmt.Fprintf(os.Stderr, "✗ %s: %s\n", result.Registry, result.Error)
+			continue
+		}
+		fmt.Printf("%s\t%s\n", result.Registry, result.Token)
+	}
+	return exitCode
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/docker-make/docker-mainifest/pkg/containerd"
+	"github.com/docker-make/docker-mainifest/pkg/dockerd"
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// transportFlags 表示 docker-daemon 和 containerd 传输方式的连接参数（copy 和 sync 子命令）
+type transportFlags struct {
+	dockerHost        string
+	containerdAddress string
+	namespace         string
+}
+
+// register 在 fs 中注册 Docker daemon 和 containerd 的连接参数，与 updates 子命令相同
+func (t *transportFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&t.dockerHost, "docker-host", "", "docker-daemon: 镜像使用的 Docker daemon 地址 (默认: DOCKER_HOST 环境变量，未设置时为 "+dockerd.DefaultHost+")")
+	fs.StringVar(&t.containerdAddress, "containerd-address", "", "containerd: 镜像使用的 containerd socket 路径 (默认: CONTAINERD_ADDRESS 环境变量，未设置时为 "+containerd.DefaultAddress+")")
+	fs.StringVar(&t.namespace, "namespace", "", "containerd: 镜像的命名空间，Kubernetes 节点上为 k8s.io (默认: CONTAINERD_NAMESPACE 环境变量，未设置时为 "+containerd.DefaultNamespace+")")
+}
+
+// configure 为 refs 中用到的 docker-daemon 和 containerd 传输方式创建连接并注册到客户端，返回的函数关闭连接
+// 没有用到时不连接，oci 和 docker-archive 为内置实现，不需要注册
+func (t *transportFlags) configure(client *registry.Client, refs ...registry.ImageReference) (func(), error) {
+	var daemonUsed, containerdUsed bool
+	for _, ref := range refs {
+		daemonUsed = daemonUsed || ref.Transport == registry.TransportDockerDaemon
+		containerdUsed = containerdUsed || ref.Transport == registry.TransportContainerd
+	}
+	if daemonUsed {
+		daemon, err := dockerd.New(dockerd.Options{Host: t.dockerHost})
+		if err != nil {
+			return nil, err
+		}
+		client.WithImageTransport(registry.TransportDockerDaemon, daemon)
+	}
+	if containerdUsed {
+		store, err := containerd.New(containerd.Options{Address: t.containerdAddress, Namespace: t.namespace})
+		if err != nil {
+			return nil, err
+		}
+		client.WithImageTransport(registry.TransportContainerd, store)
+		return func() { store.Close() }, nil
+	}
+	return func() {}, nil
+}
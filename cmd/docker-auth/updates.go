@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/containerd"
+	"github.com/docker-make/docker-mainifest/pkg/dockerd"
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// runUpdates 执行 updates 子命令，返回退出码
+// 读取本地 Docker daemon 或 containerd 中的镜像，与 registry 中标签当前的 digest 比较，报告有可用更新的镜像，不拉取镜像
+func runUpdates(args []string) int {
+	fs := flag.NewFlagSet("updates", flag.ExitOnError)
+	source := fs.String("source", "docker", "读取本地镜像的来源: docker 或 containerd")
+	dockerHost := fs.String("docker-host", "", "Docker daemon 地址，如 unix:///var/run/docker.sock (默认: DOCKER_HOST 环境变量，未设置时为 "+dockerd.DefaultHost+")")
+	containerdAddress := fs.String("containerd-address", "", "containerd socket 路径 (默认: CONTAINERD_ADDRESS 环境变量，未设置时为 "+containerd.DefaultAddress+")")
+	namespace := fs.String("namespace", "", "containerd 命名空间，Kubernetes 节点上为 k8s.io (默认: CONTAINERD_NAMESPACE 环境变量，未设置时为 "+containerd.DefaultNamespace+")")
+	var clientOpts clientFlags
+	clientOpts.register(fs)
+	concurrency := fs.Int("concurrency", defaultDigestConcurrency, "并发数")
+	timeout := fs.Duration("timeout", 30*time.Second, "单个 HTTP 请求的总超时，0 表示不限制")
+	all := fs.Bool("all", false, "同时输出已是最新和无法比较的镜像 (默认: 只输出有更新和失败的镜像)")
+	output := fs.String("output", "text", "输出格式: text 或 json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s updates [选项] [镜像过滤器]...\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "比较本地 Docker daemon 或 containerd 中镜像的 digest 与 registry 中标签当前的 digest，报告有可用更新的镜像。\n")
+		fmt.Fprintf(os.Stderr, "只发送 HEAD 请求，不拉取镜像；镜像过滤器的语法与 docker images 的 reference 过滤器相同，\n")
+		fmt.Fprintf(os.Stderr, "-source containerd 时为 containerd 的过滤器，如 'name~=\"nginx\"'。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\n示例:\n")
+		fmt.Fprintf(os.Stderr, "  %s updates\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s updates -all 'nginx' 'ghcr.io/org/*'\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s updates -source containerd -namespace k8s.io\n", os.Args[0])
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text 或 json\n", *output)
+		return exitError
+	}
+	if *source != "docker" && *source != "containerd" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的来源 '%s'，应为 docker 或 containerd\n", *source)
+		return exitError
+	}
+
+	client, err := clientOpts.newClient(*timeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+
+	opts := registry.BatchOptions{Concurrency: *concurrency, BatchAuth: true}
+	var updates []registry.ImageUpdate
+	if *source == "containerd" {
+		updates, err = checkContainerdUpdates(client, opts, *containerdAddress, *namespace, fs.Args())
+	} else {
+		updates, err = checkDockerUpdates(client, opts, *dockerHost, fs.Args())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		return exitError
+	}
+	return printUpdates(updates, *all, *output)
+}
+
+// checkDockerUpdates 检查 Docker daemon 中的镜像是否有可用更新
+func checkDockerUpdates(client *registry.Client, opts registry.BatchOptions, host string, references []string) ([]registry.ImageUpdate, error) {
+	daemon, err := dockerd.New(dockerd.Options{Host: host})
+	if err != nil {
+		return nil, err
+	}
+	return daemon.CheckUpdates(context.Background(), client, opts, references...)
+}
+
+// checkContainerdUpdates 检查 containerd 命名空间中的镜像是否有可用更新
+func checkContainerdUpdates(client *registry.Client, opts registry.BatchOptions, address, namespace string, filters []string) ([]registry.ImageUpdate, error) {
+	store, err := containerd.New(containerd.Options{Address: address, Namespace: namespace})
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return store.CheckUpdates(context.Background(), client, opts, filters...)
+}
+
+// printUpdates 输出本地镜像的更新检查结果，返回退出码
+func printUpdates(updates []registry.ImageUpdate, all bool, output string) int {
+	errs := make([]error, len(updates))
+	for i, update := range updates {
+		errs[i] = update.Error
+	}
+	code, exitCode := errorsExitCode(errs)
+
+	if output == "json" {
+		data, _ := json.Marshal(struct {
+			Images    []registry.ImageUpdate `json:"images"`
+			ErrorCode string                 `json:"error_code,omitempty"`
+		}{updates, code})
+		fmt.Println(string(data))
+		return exitCode
+	}
+
+	available := 0
+	for _, update := range updates {
+		switch update.Status {
+		case registry.UpdateStatusAvailable:
+			available++
+			fmt.Printf("↑ %s:%s %s\n", update.Image, update.Tag, update.RemoteDigest)
+		case registry.UpdateStatusFailed:
+			fmt.Fprintf(os.Stderr, "✗ %s:%s: %v\n", update.Image, update.Tag, update.Error)
+		case registry.UpdateStatusUpToDate:
+			if all {
+				fmt.Printf("= %s:%s %s\n", update.Image, update.Tag, update.RemoteDigest)
+			}
+		default:
+			if all {
+				fmt.Printf("? %s:%s (本地镜像没有 registry digest)\n", update.Image, update.Tag)
+			}
+		}
+	}
+	fmt.Fprintf(os.Stderr, "\n检查 %d 个镜像，%d 个有可用更新\n", len(updates), available)
+	return exitCode
+}
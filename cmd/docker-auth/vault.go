@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+	"github.com/docker-make/docker-mainifest/pkg/vaultcred"
+)
+
+// vaultFlags 表示从 Vault 读取凭据的命令行参数
+type vaultFlags struct {
+	addr       string
+	mount      string
+	kvVersion  int
+	pathPrefix string
+	tokenFile  string
+}
+
+// register 在 fs 中注册 Vault 相关参数
+func (v *vaultFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&v.pathPrefix, "vault-path", "", "从 Vault KV 读取凭据的路径前缀，凭据 key（如 dockerhub、ghcr、域名）拼接在其后 (可选)\n"+
+		"  secret 中包含 username 和 token (或 password) 字段\n"+
+		"  示例: -vault-path ci/registries/ 从 secret/data/ci/registries/dockerhub 读取 Docker Hub 凭据")
+	fs.StringVar(&v.addr, "vault-addr", "", "Vault 地址 (默认: 环境变量 VAULT_ADDR)")
+	fs.StringVar(&v.mount, "vault-mount", vaultcred.DefaultMount, "Vault KV 引擎的挂载路径")
+	fs.IntVar(&v.kvVersion, "vault-kv-version", vaultcred.DefaultKVVersion, "Vault KV 引擎版本: 1 或 2")
+	fs.StringVar(&v.tokenFile, "vault-token-file", "", "保存 Vault token 的文件，如 Vault Agent 的 sink 文件\n"+
+		"  (默认: 环境变量 VAULT_TOKEN，未设置时为 ~/.vault-token)")
+}
+
+// configure 为客户端设置从 Vault 读取凭据的提供者，未指定 -vault-path 时不使用 Vault
+// 命令行指定的凭据优先于 Vault 中的凭据
+func (v *vaultFlags) configure(client *registry.Client) error {
+	if v.pathPrefix == "" {
+		return nil
+	}
+	provider, err := vaultcred.New(vaultcred.Options{
+		Addr:       v.addr,
+		TokenFile:  v.tokenFile,
+		Mount:      v.mount,
+		KVVersion:  v.kvVersion,
+		PathPrefix: v.pathPrefix,
+		RenewToken: true,
+	})
+	if err != nil {
+		return err
+	}
+	provider.OnError = func(err error) {
+		fmt.Fprintf(os.Stderr, "警告: %v，继续使用上次读取的凭据\n", err)
+	}
+	client.WithCredentialProvider(provider)
+	return nil
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// version 构建版本，发布时通过 -ldflags "-X main.version=v1.2.3" 注入
+var version = "dev"
+
+// releaseImage 发布二进制文件的 OCI 仓库（GHCR），由 make publish 推送
+// 每个版本是一个镜像索引，标签为版本号，latest 指向最新的版本；索引中每个平台一个 artifact manifest，
+// 唯一的层是该平台的二进制文件，文件名记录在 org.opencontainers.image.title 注解中
+// 测试中替换为本地的 registry
+var releaseImage = "ghcr.io/docker-make/docker-mainifest/docker-auth"
+
+// newReleaseClient 创建访问 releaseImage 的客户端，测试中替换
+var newReleaseClient = registry.NewClient
+
+const (
+	// annotationVersion 发布索引上记录版本号的注解
+	annotationVersion = "org.opencontainers.image.version"
+	// maxReleaseBinarySize 二进制文件的最大大小
+	maxReleaseBinarySize = 256 << 20
+)
+
+// features 当前版本支持的功能，version 子命令的 features 字段只来自这一个列表；
+// 可用的子命令由 commands 生成，输出在 commands 字段中。新增功能时在对应的分组中追加
+var features = []string{
+	// 认证
+	"batch-auth",          // 批量认证
+	"basic-auth",          // 仅支持 Basic 认证的 registry
+	"www-authenticate",    // 任意 registry 的 WWW-Authenticate 认证
+	"anonymous-fallback",  // 凭据被拒绝时回退为匿名访问
+	"token-post",          // POST 表单方式获取 token
+	"bearer-token",        // 预先获取的 bearer token
+	"token-cache",         // token 缓存（内存、磁盘）
+	"credential-provider", // 按需获取凭据（环境变量、Vault KV）
+	"credential-store",    // login 保存的加密凭据和系统钥匙串
+	"prefetch-tokens",     // 预先获取每个镜像的 token
+
+	// 连接
+	"mtls",              // 双向 TLS
+	"custom-ca",         // 按 registry 信任额外的 CA 证书
+	"insecure-registry", // 按 registry 跳过 TLS 证书校验
+	"rate-limit-retry",  // 429 限流等待重试
+	"availability",      // 跳过不可用的 registry（熔断）
+	"ping",              // 检测 registry 是否可用及其能力
+	"metrics",           // Prometheus 指标
+	"request-hooks",     // 请求和获取结果钩子
+
+	// 读取
+	"index-walk",           // 递归遍历镜像索引
+	"index-limits",         // 镜像索引的深度和大小限制
+	"platform-default",     // registry 默认平台
+	"tag-alias",            // 标签别名链
+	"image-config",         // 获取镜像配置
+	"config-consistency",   // 多架构镜像的配置一致性检查
+	"image-age",            // 按镜像创建时间过滤
+	"manifest-cache",       // manifest 缓存和条件请求
+	"shared-cache",         // 内存、文件和 Redis 共享缓存
+	"offline",              // 离线模式，只使用缓存
+	"spill",                // 大批量结果写入磁盘
+	"stream",               // 流式输出批量结果
+	"retry-failed",         // 批量任务失败项重试
+	"dockerhub-rate-limit", // Docker Hub 剩余拉取次数
+	"blob-download",        // 下载 blob，校验 sha256 并断点续传
+	"layer-list",           // 列出层中的文件（gzip、zstd）
+	"find-file",            // 在镜像层中查找文件
+	"diff",                 // 比较镜像配置和各层
+	"compare-registries",   // 比较多个 registry 中的镜像
+	"local-updates",        // 检查 Docker daemon 和 containerd 中镜像的更新
+
+	// 写入
+	"push",         // 推送 manifest 和 blob
+	"copy",         // 复制镜像，跨仓库挂载 blob
+	"transports",   // oci、docker-archive、docker-daemon、containerd 传输方式
+	"sync",         // 按映射同步仓库（pkg/mirror）
+	"plan-copy",    // 复制前估算需要传输的内容
+	"tag",          // 服务端重新打标签
+	"oci-layout",   // 从 OCI 布局目录导入镜像
+	"referrers",    // 附加和查询 referrer artifact
+	"sbom",         // 获取 SPDX 和 CycloneDX SBOM
+	"provenance",   // 获取 SLSA provenance
+	"helm",         // 获取 OCI 中的 Helm chart
+	"read-only",    // 只读模式
+	"image-policy", // 镜像访问策略
+	"redact",       // 报告中的仓库名称脱敏
+}
+
+// commandNames 返回全部子命令的名称
+// commands 引用了 runVersion，直接遍历 commands 会形成初始化循环，因此在 init 中设置
+var commandNames func() []string
+
+func init() {
+	commandNames = func() []string {
+		names := make([]string, len(commands))
+		for i, cmd := range commands {
+			names[i] = cmd.name
+		}
+		return names
+	}
+}
+
+// versionInfo 表示 version 命令的输出
+type versionInfo struct {
+	Version    string   `json:"version"`
+	Commit     string   `json:"commit,omitempty"`
+	GoVersion  string   `json:"goVersion"`
+	Platform   string   `json:"platform"`
+	Registries []string `json:"registries"`
+	Features   []string `json:"features"`
+	Commands   []string `json:"commands"`
+	Latest     string   `json:"latest,omitempty"`
+	LatestRef  string   `json:"latestRef,omitempty"` // 最新版本固定到 digest 的引用，如 ghcr.io/...@sha256:...
+}
+
+// runVersion 执行 version 子命令，返回退出码
+func runVersion(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	check := fs.Bool("check", false, "检查 "+releaseImage+" 的 latest 标签是否有新版本")
+	update := fs.Bool("update", false, "有新版本时下载并替换当前二进制文件（按 digest 校验 manifest 和二进制文件）")
+	digest := fs.String("digest", "", "使用 digest 固定的发布索引代替 latest 标签，如 sha256:...\n"+
+		"  内容与 digest 不一致时拒绝更新；应从发布说明等 GHCR 以外的可信来源获取")
+	output := fs.String("output", "text", "输出格式: text 或 json")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法:\n")
+		fmt.Fprintf(os.Stderr, "  %s version [-check] [-update [-digest sha256:...]] [-output text|json]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "发布文件从 %s 获取，manifest 和二进制文件按 digest 校验。\n", releaseImage)
+		fmt.Fprintf(os.Stderr, "只按 latest 标签更新时信任能向该仓库推送的人；指定 -digest 时只信任该 digest。\n\n")
+		fmt.Fprintf(os.Stderr, "选项:\n")
+		fs.PrintDefaults()
+	}
+	if !parseFlags(fs, args) {
+		return exitOK
+	}
+
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的输出格式 '%s'，应为 text 或 json\n", *output)
+		return exitError
+	}
+
+	if *digest != "" && !strings.HasPrefix(*digest, "sha256:") {
+		fmt.Fprintf(os.Stderr, "错误: -digest 应为 sha256:<hex>\n")
+		return exitError
+	}
+
+	info := buildVersionInfo()
+
+	var latest *release
+	if *check || *update || *digest != "" {
+		reference := "latest"
+		if *digest != "" {
+			reference = *digest
+		}
+		var err error
+		latest, err = fetchRelease(context.Background(), newReleaseClient(), reference)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 检查新版本失败: %v\n", err)
+			return exitNetwork
+		}
+		info.Latest = latest.Version
+		info.LatestRef = releaseImage + "@" + latest.Digest
+	}
+
+	if *output == "json" {
+		data, _ := json.MarshalIndent(info, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printVersionInfo(info)
+	}
+
+	if !*update || latest == nil {
+		return exitOK
+	}
+	if version == "dev" {
+		fmt.Fprintf(os.Stderr, "错误: 开发版本不支持自动更新\n")
+		return exitError
+	}
+	if compareVersions(latest.Version, version) <= 0 {
+		fmt.Fprintf(os.Stderr, "已是最新版本\n")
+		return exitOK
+	}
+	if err := selfUpdate(context.Background(), newReleaseClient(), latest); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 更新失败: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintf(os.Stderr, "已更新到 %s (%s)\n", latest.Version, latest.Digest)
+	return exitOK
+}
+
+// buildVersionInfo 收集版本信息
+func buildVersionInfo() versionInfo {
+	info := versionInfo{
+		Version:   version,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Features:  features,
+	}
+	info.Commands = commandNames()
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			if setting.Key == "vcs.revision" {
+				info.Commit = setting.Value
+			}
+		}
+	}
+	for key := range registry.ListRegistries() {
+		info.Registries = append(info.Registries, key)
+	}
+	sort.Strings(info.Registries)
+	return info
+}
+
+// printVersionInfo 以文本格式输出版本信息
+func printVersionInfo(info versionInfo) {
+	fmt.Printf("版本: %s\n", info.Version)
+	if info.Commit != "" {
+		fmt.Printf("提交: %s\n", info.Commit)
+	}
+	fmt.Printf("Go 版本: %s\n", info.GoVersion)
+	fmt.Printf("平台: %s\n", info.Platform)
+	fmt.Printf("内置 registry: %s\n", strings.Join(info.Registries, ", "))
+	fmt.Printf("功能: %s\n", strings.Join(info.Features, ", "))
+	fmt.Printf("子命令: %s\n", strings.Join(info.Commands, ", "))
+	if info.Latest == "" {
+		return
+	}
+	if version != "dev" && compareVersions(info.Latest, version) > 0 {
+		fmt.Printf("有新版本: %s (%s)\n", info.Latest, info.LatestRef)
+	} else {
+		fmt.Printf("最新版本: %s\n", info.Latest)
+	}
+}
+
+// release 表示 releaseImage 中一个版本的镜像索引
+type release struct {
+	Version string              // 索引的 org.opencontainers.image.version 注解
+	Digest  string              // 索引的 digest，由内容计算
+	Index   registry.ImageIndex // 各平台的 artifact manifest
+}
+
+// fetchRelease 获取 reference（标签或 digest）对应的发布索引
+func fetchRelease(ctx context.Context, client *registry.Client, reference string) (*release, error) {
+	body, digest, err := fetchVerifiedManifest(ctx, client, reference)
+	if err != nil {
+		return nil, err
+	}
+	rel := &release{Digest: digest}
+	if err := json.Unmarshal(body, &rel.Index); err != nil {
+		return nil, fmt.Errorf("解析发布索引失败: %w", err)
+	}
+	if !registry.IsIndexMediaType(rel.Index.MediaType) {
+		return nil, fmt.Errorf("%s:%s 不是镜像索引 (%s)", releaseImage, reference, rel.Index.MediaType)
+	}
+	if rel.Version = rel.Index.Annotations[annotationVersion]; rel.Version == "" {
+		return nil, fmt.Errorf("发布索引 %s 中没有 %s 注解", digest, annotationVersion)
+	}
+	return rel, nil
+}
+
+// fetchVerifiedManifest 获取 releaseImage 中 reference 对应的 manifest，返回内容和由内容计算的 digest
+// reference 为 digest 时内容必须与其一致；registry 返回的 Docker-Content-Digest 也必须与内容一致
+func fetchVerifiedManifest(ctx context.Context, client *registry.Client, reference string) ([]byte, string, error) {
+	manifest, claimed, err := client.GetManifestWithDigestContext(ctx, releaseImage, reference)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256([]byte(manifest))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if strings.HasPrefix(reference, "sha256:") && reference != digest {
+		return nil, "", fmt.Errorf("manifest digest 不匹配: 期望 %s，实际 %s", reference, digest)
+	}
+	if claimed != "" && claimed != digest {
+		return nil, "", fmt.Errorf("manifest digest 不匹配: registry 返回 %s，实际 %s", claimed, digest)
+	}
+	return []byte(manifest), digest, nil
+}
+
+// releaseAssetName 返回当前平台的发布文件名，与 make release 的产物一致
+func releaseAssetName() string {
+	name := fmt.Sprintf("docker-auth_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// selfUpdate 下载发布索引中当前平台的二进制文件并替换当前可执行文件
+func selfUpdate(ctx context.Context, client *registry.Client, rel *release) error {
+	binary, err := downloadReleaseBinary(ctx, client, rel)
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return err
+	}
+	return replaceExecutable(executable, binary)
+}
+
+// downloadReleaseBinary 下载发布索引中当前平台的二进制文件
+// 平台 manifest 按索引中的 digest 获取和校验，二进制文件按 manifest 中层的 digest 和大小校验，
+// 因此下载的内容完全由索引的 digest 确定
+func downloadReleaseBinary(ctx context.Context, client *registry.Client, rel *release) ([]byte, error) {
+	platform := registry.Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+	var platformManifest *registry.Descriptor
+	for i := range rel.Index.Manifests {
+		if desc := &rel.Index.Manifests[i]; desc.Platform != nil && platform.Matches(*desc.Platform) {
+			platformManifest = desc
+			break
+		}
+	}
+	if platformManifest == nil {
+		return nil, fmt.Errorf("发布 %s 中没有 %s 平台的文件", rel.Version, platform)
+	}
+
+	body, _, err := fetchVerifiedManifest(ctx, client, platformManifest.Digest)
+	if err != nil {
+		return nil, err
+	}
+	var manifest registry.ImageManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("解析 %s 平台的 manifest 失败: %w", platform, err)
+	}
+	assetName := releaseAssetName()
+	var layer *registry.Descriptor
+	for i := range manifest.Layers {
+		if manifest.Layers[i].Annotations[registry.AnnotationTitle] == assetName {
+			layer = &manifest.Layers[i]
+			break
+		}
+	}
+	if layer == nil {
+		return nil, fmt.Errorf("发布 %s 中没有文件 %s", rel.Version, assetName)
+	}
+	if layer.Size <= 0 || layer.Size > maxReleaseBinarySize {
+		return nil, fmt.Errorf("%s 的大小无效 (%d 字节)", assetName, layer.Size)
+	}
+
+	// GetBlob 按 digest 校验内容，大小超过描述符时提前停止
+	binary := &limitedBuffer{limit: layer.Size}
+	if _, err := client.GetBlobContext(ctx, releaseImage, layer.Digest, binary); err != nil {
+		return nil, err
+	}
+	if int64(binary.Len()) != layer.Size {
+		return nil, fmt.Errorf("%s 的大小不匹配: 期望 %d 字节，实际 %d 字节", assetName, layer.Size, binary.Len())
+	}
+	return binary.Bytes(), nil
+}
+
+// errBinaryTooLarge 表示下载的二进制文件超过了描述符中的大小
+var errBinaryTooLarge = errors.New("二进制文件超过 manifest 中记录的大小")
+
+// limitedBuffer 最多写入 limit 字节的缓冲区
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int64
+}
+
+// Write 实现 io.Writer，超过 limit 时返回 errBinaryTooLarge
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if int64(b.Len()+len(p)) > b.limit {
+		return 0, errBinaryTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// replaceExecutable 先写入同目录的临时文件再重命名，避免更新中断时留下不完整的可执行文件
+func replaceExecutable(path string, binary []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".docker-auth-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(binary); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// compareVersions 比较两个 vX.Y.Z 格式的版本号
+// a 较新时返回正数，相同返回 0，较旧返回负数；预发布后缀（如 -rc.1）被忽略
+func compareVersions(a, b string) int {
+	partsA := versionParts(a)
+	partsB := versionParts(b)
+	for i := 0; i < 3; i++ {
+		if partsA[i] != partsB[i] {
+			return partsA[i] - partsB[i]
+		}
+	}
+	return 0
+}
+
+// versionParts 解析版本号的主、次、修订号，无法解析的部分为 0
+func versionParts(v string) [3]int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var parts [3]int
+	for i, s := range strings.SplitN(v, ".", 3) {
+		parts[i], _ = strconv.Atoi(s)
+	}
+	return parts
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+func TestFeaturesUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, feature := range features {
+		if seen[feature] {
+			t.Errorf("功能 %s 重复", feature)
+		}
+		seen[feature] = true
+	}
+	for _, feature := range []string{"push", "referrers", "copy", "sync", "offline", "credential-store", "transports"} {
+		if !seen[feature] {
+			t.Errorf("功能列表中没有 %s", feature)
+		}
+	}
+}
+
+func TestVersionInfoCommands(t *testing.T) {
+	info := buildVersionInfo()
+	if len(info.Commands) != len(commands) {
+		t.Fatalf("Commands = %v, 期望 %d 个子命令", info.Commands, len(commands))
+	}
+	for i, cmd := range commands {
+		if info.Commands[i] != cmd.name {
+			t.Errorf("Commands[%d] = %s, 期望 %s", i, info.Commands[i], cmd.name)
+		}
+	}
+	if info.Commands[len(info.Commands)-1] != "completion" {
+		t.Errorf("Commands 中没有在 init 中注册的 completion: %v", info.Commands)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int // 只比较符号
+	}{
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.10.0", "v1.9.9", 1},
+		{"v1.2.3", "v1.2.10", -1},
+		{"v2.0.0", "v1.99.99", 1},
+		{"v1.2.3-rc.1", "v1.2.3", 0},
+		{"v1.2.3+build.5", "v1.2.3", 0},
+		{"v1.2", "v1.2.0", 0},
+		{"dev", "v0.0.1", -1},
+	}
+	for _, tt := range tests {
+		got := compareVersions(tt.a, tt.b)
+		if sign(got) != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, 期望符号为 %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
+
+// releaseRegistry 是发布 docker-auth 的测试 registry，manifests 和 blobs 按 "<引用>" 和 digest 索引
+type releaseRegistry struct {
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+// digestOf 返回内容的 sha256 digest
+func digestOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// newReleaseRegistry 启动 registry，发布包含当前平台二进制文件 binary 的 v9.9.9，并将 releaseImage 指向它
+func newReleaseRegistry(t *testing.T, binary []byte) *releaseRegistry {
+	t.Helper()
+	reg := &releaseRegistry{manifests: make(map[string][]byte), blobs: make(map[string][]byte)}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var content []byte
+		switch {
+		case r.URL.Path == "/v2/":
+			return
+		case strings.HasPrefix(r.URL.Path, "/v2/docker-auth/manifests/"):
+			content = reg.manifests[strings.TrimPrefix(r.URL.Path, "/v2/docker-auth/manifests/")]
+			w.Header().Set("Content-Type", registry.MediaTypeOCIIndex)
+			if content != nil && !bytes.Contains(content, []byte(registry.MediaTypeOCIIndex)) {
+				w.Header().Set("Content-Type", registry.MediaTypeOCIManifest)
+			}
+		case strings.HasPrefix(r.URL.Path, "/v2/docker-auth/blobs/"):
+			content = reg.blobs[strings.TrimPrefix(r.URL.Path, "/v2/docker-auth/blobs/")]
+		}
+		if content == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(content)
+	}))
+	t.Cleanup(server.Close)
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	image, client := releaseImage, newReleaseClient
+	releaseImage = host + "/docker-auth"
+	newReleaseClient = func() *registry.Client {
+		client := registry.NewClient()
+		if err := client.SetRegistryInsecureSkipVerify(host, true); err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+	t.Cleanup(func() { releaseImage, newReleaseClient = image, client })
+
+	empty := []byte("{}")
+	reg.blobs[digestOf(empty)] = empty
+	reg.blobs[digestOf(binary)] = binary
+	manifest, _ := json.Marshal(registry.ImageManifest{
+		SchemaVersion: 2,
+		MediaType:     registry.MediaTypeOCIManifest,
+		ArtifactType:  "application/vnd.docker-make.docker-auth.binary",
+		Config:        registry.Descriptor{MediaType: "application/vnd.oci.empty.v1+json", Digest: digestOf(empty), Size: int64(len(empty))},
+		Layers: []registry.Descriptor{{
+			MediaType:   "application/octet-stream",
+			Digest:      digestOf(binary),
+			Size:        int64(len(binary)),
+			Annotations: map[string]string{registry.AnnotationTitle: releaseAssetName()},
+		}},
+	})
+	reg.manifests[digestOf(manifest)] = manifest
+	index, _ := json.Marshal(registry.ImageIndex{
+		SchemaVersion: 2,
+		MediaType:     registry.MediaTypeOCIIndex,
+		Manifests: []registry.Descriptor{{
+			MediaType: registry.MediaTypeOCIManifest,
+			Digest:    digestOf(manifest),
+			Size:      int64(len(manifest)),
+			Platform:  &registry.Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH},
+		}},
+		Annotations: map[string]string{annotationVersion: "v9.9.9"},
+	})
+	reg.manifests["latest"] = index
+	reg.manifests[digestOf(index)] = index
+	return reg
+}
+
+func TestReleaseDownloadVerified(t *testing.T) {
+	binary := []byte("new-binary")
+	reg := newReleaseRegistry(t, binary)
+	ctx := context.Background()
+
+	latest, err := fetchRelease(ctx, newReleaseClient(), "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Version != "v9.9.9" || latest.Digest != digestOf(reg.manifests["latest"]) {
+		t.Errorf("fetchRelease = %s %s, 期望 v9.9.9 %s", latest.Version, latest.Digest, digestOf(reg.manifests["latest"]))
+	}
+	if got, err := downloadReleaseBinary(ctx, newReleaseClient(), latest); err != nil || !bytes.Equal(got, binary) {
+		t.Errorf("downloadReleaseBinary = %q, %v, 期望 %q", got, err, binary)
+	}
+
+	// 固定的 digest 与 registry 返回的内容不一致
+	pinned := digestOf([]byte("other-index"))
+	reg.manifests[pinned] = reg.manifests["latest"]
+	if _, err := fetchRelease(ctx, newReleaseClient(), pinned); err == nil || !strings.Contains(err.Error(), "digest 不匹配") {
+		t.Errorf("固定到其他 digest 时错误 = %v, 期望 digest 不匹配", err)
+	}
+	pinnedLatest, err := fetchRelease(ctx, newReleaseClient(), latest.Digest)
+	if err != nil || pinnedLatest.Version != "v9.9.9" {
+		t.Errorf("按 digest 获取 = %+v, %v, 期望 v9.9.9", pinnedLatest, err)
+	}
+
+	// 二进制文件被替换时按层的 digest 拒绝
+	reg.blobs[digestOf(binary)] = []byte("evil-bin!!")
+	if _, err := downloadReleaseBinary(ctx, newReleaseClient(), latest); err == nil {
+		t.Error("二进制文件与 digest 不一致时应返回错误")
+	}
+
+	// 索引中没有当前平台
+	other := *latest
+	other.Index.Manifests = nil
+	if _, err := downloadReleaseBinary(ctx, newReleaseClient(), &other); err == nil || !strings.Contains(err.Error(), "平台的文件") {
+		t.Errorf("没有当前平台时错误 = %v, 期望包含 平台的文件", err)
+	}
+}
+
+func TestVersionCheckJSON(t *testing.T) {
+	reg := newReleaseRegistry(t, []byte("new-binary"))
+	var code int
+	output := withStdout(t, func() { code = run([]string{"version", "-check", "-output", "json"}) })
+	if code != exitOK {
+		t.Fatalf("退出码 = %d, 期望 %d", code, exitOK)
+	}
+	var info versionInfo
+	if err := json.Unmarshal([]byte(output), &info); err != nil {
+		t.Fatal(err)
+	}
+	if want := releaseImage + "@" + digestOf(reg.manifests["latest"]); info.Latest != "v9.9.9" || info.LatestRef != want {
+		t.Errorf("latest = %s %s, 期望 v9.9.9 %s", info.Latest, info.LatestRef, want)
+	}
+}
+
+func TestReplaceExecutable(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "docker-auth")
+	if err := os.WriteFile(path, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := replaceExecutable(path, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "new" {
+		t.Errorf("文件内容 = %q, 期望 new", content)
+	}
+	if info, _ := os.Stat(path); runtime.GOOS != "windows" && info.Mode().Perm() != 0o755 {
+		t.Errorf("权限 = %v, 期望 0755", info.Mode().Perm())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("目录中有 %d 个文件, 期望临时文件已删除", len(entries))
+	}
+}
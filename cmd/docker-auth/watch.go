@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// digestChange 表示 -watch 检测到的一次 digest 变化
+type digestChange struct {
+	Image    string    `json:"image"`
+	Tag      string    `json:"tag"`
+	Previous string    `json:"previous"`
+	Digest   string    `json:"digest"`
+	Time     time.Time `json:"time"`
+}
+
+// watchDigests 每隔 interval 重新获取 specs 的 digest，直到收到 SIGINT 或 SIGTERM，返回退出码
+// 第一次获取的 digest 作为基准不输出；之后 digest 变化时输出一行变化（json 格式每行一个 JSON 对象），
+// hook 不为空时执行 hook。获取失败的镜像输出错误后保留上一次的 digest，不视为变化
+func watchDigests(client *registry.Client, specs []registry.ImageSpec, opts registry.BatchOptions, interval time.Duration, hook, output string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	digests := make(map[string]string, len(specs))
+	for round := 0; ; round++ {
+		results := client.GetDigests(ctx, specs, opts)
+		if ctx.Err() != nil {
+			return exitOK
+		}
+		for _, result := range results {
+			ref := result.Image + ":" + result.Tag
+			if result.Error != nil {
+				fmt.Fprintf(os.Stderr, "✗ %s: %v\n", ref, result.Error)
+				continue
+			}
+			previous, seen := digests[ref]
+			digests[ref] = result.Digest
+			if !seen || previous == result.Digest {
+				continue
+			}
+
+			change := digestChange{Image: result.Image, Tag: result.Tag, Previous: previous, Digest: result.Digest, Time: time.Now().UTC()}
+			if output == "json" {
+				data, _ := json.Marshal(change)
+				fmt.Println(string(data))
+			} else {
+				fmt.Printf("%s %s -> %s\n", ref, previous, result.Digest)
+			}
+			if hook != "" {
+				if err := runWatchHook(ctx, hook, change); err != nil {
+					fmt.Fprintf(os.Stderr, "警告: %s 的 -exec 命令执行失败: %v\n", ref, err)
+				}
+			}
+		}
+		if round == 0 {
+			fmt.Fprintf(os.Stderr, "开始监视 %d 个镜像，每 %s 检查一次，按 Ctrl+C 退出\n", len(specs), interval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return exitOK
+		case <-time.After(interval):
+		}
+	}
+}
+
+// runWatchHook 通过 shell 执行 -exec 命令，变化的镜像通过环境变量传递:
+// DOCKER_MANIFEST_IMAGE、DOCKER_MANIFEST_TAG、DOCKER_MANIFEST_PREVIOUS_DIGEST、DOCKER_MANIFEST_DIGEST
+func runWatchHook(ctx context.Context, hook string, change digestChange) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", hook)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", hook)
+	}
+	cmd.Env = append(os.Environ(),
+		"DOCKER_MANIFEST_IMAGE="+change.Image,
+		"DOCKER_MANIFEST_TAG="+change.Tag,
+		"DOCKER_MANIFEST_PREVIOUS_DIGEST="+change.Previous,
+		"DOCKER_MANIFEST_DIGEST="+change.Digest,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,136 @@
+// Package containerd 通过 containerd 的 gRPC API 读取节点上的镜像，与 registry 中的最新 digest 比较
+// 用于审计 Kubernetes 节点上的镜像是否为最新（CRI 拉取的镜像位于 k8s.io 命名空间）
+//
+// 使用方式：
+//
+//	store, err := containerd.New(containerd.Options{Namespace: "k8s.io"})
+//	if err != nil {
+//		return err
+//	}
+//	defer store.Close()
+//	updates, err := store.CheckUpdates(ctx, registry.NewClient(), registry.BatchOptions{Concurrency: 8, BatchAuth: true})
+//
+// Store 同时实现了 registry.ImageTransport，注册后可以在 containerd 和其他存储之间复制镜像：
+//
+//	client := registry.NewClient().WithImageTransport(registry.TransportContainerd, store)
+//	result, err := client.CopyImage("ghcr.io/org/app", "v1", "containerd:ghcr.io/org/app", "v1")
+//
+// 复制只写入内容存储并创建镜像，不解压到 snapshotter
+//
+// 只使用 containerd 的 images、namespaces、content 和 leases 服务，不依赖 containerd 客户端库
+package containerd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	namespacesapi "github.com/containerd/containerd/api/services/namespaces/v1"
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// 默认配置
+const (
+	DefaultAddress   = "/run/containerd/containerd.sock"
+	DefaultNamespace = "default"
+)
+
+// namespaceHeader containerd 通过 gRPC metadata 中的该字段确定请求的命名空间
+const namespaceHeader = "containerd-namespace"
+
+// Options 表示 containerd 连接配置
+type Options struct {
+	Address   string // containerd socket 路径；为空时使用环境变量 CONTAINERD_ADDRESS，未设置时为 DefaultAddress
+	Namespace string // 命名空间，Kubernetes 节点上为 k8s.io；为空时使用环境变量 CONTAINERD_NAMESPACE，未设置时为 DefaultNamespace
+}
+
+// Store 表示一个 containerd 连接
+type Store struct {
+	conn      *grpc.ClientConn
+	namespace string
+}
+
+// New 创建 containerd 连接，不会立即访问 containerd，使用完后需要调用 Close
+func New(opts Options) (*Store, error) {
+	if opts.Address == "" {
+		opts.Address = os.Getenv("CONTAINERD_ADDRESS")
+	}
+	if opts.Address == "" {
+		opts.Address = DefaultAddress
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = os.Getenv("CONTAINERD_NAMESPACE")
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultNamespace
+	}
+
+	target := opts.Address
+	if !strings.Contains(target, "://") {
+		target = "unix://" + target
+	}
+	conn, err := grpc.Dial(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("containerd: 连接 '%s' 失败: %w", opts.Address, err)
+	}
+	return &Store{conn: conn, namespace: opts.Namespace}, nil
+}
+
+// Close 关闭连接
+func (s *Store) Close() error {
+	return s.conn.Close()
+}
+
+// Namespaces 列出 containerd 中的全部命名空间
+func (s *Store) Namespaces(ctx context.Context) ([]string, error) {
+	resp, err := namespacesapi.NewNamespacesClient(s.conn).List(ctx, &namespacesapi.ListNamespacesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("containerd: 列出命名空间失败: %w", err)
+	}
+	names := make([]string, len(resp.Namespaces))
+	for i, namespace := range resp.Namespaces {
+		names[i] = namespace.Name
+	}
+	return names, nil
+}
+
+// Images 列出命名空间中带标签的镜像，每个标签一条，Digests 为镜像指向的索引或 manifest digest
+// filters 使用 containerd 的过滤器语法，如 `name~="nginx"`，多个过滤器之间为或的关系
+// 只有 digest 的引用（如 CRI 记录的 repo@sha256:...）和镜像 ID 引用（sha256:...）不列出
+func (s *Store) Images(ctx context.Context, filters ...string) ([]registry.LocalImage, error) {
+	resp, err := imagesapi.NewImagesClient(s.conn).List(s.withNamespace(ctx), &imagesapi.ListImagesRequest{Filters: filters})
+	if err != nil {
+		return nil, fmt.Errorf("containerd: 列出命名空间 %s 中的镜像失败: %w", s.namespace, err)
+	}
+
+	var images []registry.LocalImage
+	for _, image := range resp.Images {
+		if image.Target == nil || strings.Contains(image.Name, "@") || strings.HasPrefix(image.Name, "sha256:") {
+			continue
+		}
+		i := strings.LastIndex(image.Name, ":")
+		if i < 0 || strings.Contains(image.Name[i:], "/") {
+			continue
+		}
+		images = append(images, registry.LocalImage{
+			Image:   image.Name[:i],
+			Tag:     image.Name[i+1:],
+			Digests: []string{image.Target.Digest},
+		})
+	}
+	return images, nil
+}
+
+// CheckUpdates 列出命名空间中的镜像（filters 的含义与 Images 相同），通过 client 获取远程 digest 并比较
+// 只发送 HEAD 请求，不拉取镜像
+func (s *Store) CheckUpdates(ctx context.Context, client *registry.Client, opts registry.BatchOptions, filters ...string) ([]registry.ImageUpdate, error) {
+	images, err := s.Images(ctx, filters...)
+	if err != nil {
+		return nil, err
+	}
+	return client.CheckUpdates(ctx, images, opts), nil
+}
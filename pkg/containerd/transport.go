@@ -0,0 +1,356 @@
+package containerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	contentapi "github.com/containerd/containerd/api/services/content/v1"
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	leasesapi "github.com/containerd/containerd/api/services/leases/v1"
+	"github.com/containerd/containerd/api/types"
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// leaseHeader containerd 通过 gRPC metadata 中的该字段确定请求所属的租约，租约内写入的内容在租约删除前不会被垃圾回收
+const leaseHeader = "containerd-lease"
+
+// 写入时的配置
+const (
+	writeChunkSize = 1 << 20   // 每条 Write 消息的数据大小
+	leaseExpire    = time.Hour // 进程异常退出未删除租约时，containerd 在该时间后回收租约
+)
+
+// Store 实现 registry.ImageTransport 和 registry.ImageDigestResolver，注册为 registry.TransportContainerd 后可以作为 CopyImage 的源和目标
+var _ registry.ImageTransport = (*Store)(nil)
+
+// OpenSource 实现 registry.ImageTransport 接口，name 按 registry.CanonicalImageName 补全（如 nginx -> docker.io/library/nginx），tag 为空时使用 latest
+func (s *Store) OpenSource(ctx context.Context, name, tag string) (registry.ImageSource, error) {
+	image, err := s.getImage(ctx, name, tag)
+	if err != nil {
+		return nil, err
+	}
+	root := registry.Descriptor{MediaType: image.Target.MediaType, Digest: image.Target.Digest, Size: image.Target.Size}
+	return &storeSource{store: s, root: root}, nil
+}
+
+// OpenDestination 实现 registry.ImageTransport 接口，Commit 时创建或更新名为 name:tag 的镜像
+// 只写入内容存储，不解压到 snapshotter；运行容器前 containerd 会在需要时解压（如 ctr run、nerdctl run）
+func (s *Store) OpenDestination(ctx context.Context, name, tag string) (registry.ImageDestination, error) {
+	return &storeDestination{store: s, name: imageName(name, tag)}, nil
+}
+
+// ResolveDigest 实现 registry.ImageDigestResolver 接口，返回镜像指向的索引或 manifest digest
+func (s *Store) ResolveDigest(ctx context.Context, name, tag string) (string, error) {
+	image, err := s.getImage(ctx, name, tag)
+	if err != nil {
+		return "", err
+	}
+	return image.Target.Digest, nil
+}
+
+// getImage 返回命名空间中的镜像，不存在时返回可通过 errors.Is(err, registry.ErrNotFound) 判断的错误
+func (s *Store) getImage(ctx context.Context, name, tag string) (*imagesapi.Image, error) {
+	reference := imageName(name, tag)
+	resp, err := imagesapi.NewImagesClient(s.conn).Get(s.withNamespace(ctx), &imagesapi.GetImageRequest{Name: reference})
+	if err != nil {
+		return nil, storeError("获取镜像 "+reference, err)
+	}
+	if resp.Image == nil || resp.Image.Target == nil {
+		return nil, fmt.Errorf("containerd: 镜像 %s 没有目标描述符", reference)
+	}
+	return resp.Image, nil
+}
+
+// withNamespace 在请求的 gRPC metadata 中设置命名空间
+func (s *Store) withNamespace(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, namespaceHeader, s.namespace)
+}
+
+// imageName 返回 containerd 中的镜像名：完整的镜像名加上标签或 digest，tag 为空时使用 latest
+func imageName(name, tag string) string {
+	name = registry.CanonicalImageName(name)
+	switch {
+	case tag == "":
+		return name + ":latest"
+	case strings.Contains(tag, ":"):
+		return name + "@" + tag
+	default:
+		return name + ":" + tag
+	}
+}
+
+// storeError 包装 containerd 返回的错误，NotFound 可通过 errors.Is(err, registry.ErrNotFound) 判断
+func storeError(action string, err error) error {
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("containerd: %s失败: %w: %s", action, registry.ErrNotFound, status.Convert(err).Message())
+	}
+	return fmt.Errorf("containerd: %s失败: %w", action, err)
+}
+
+// storeSource 表示 containerd 中作为复制源的镜像
+type storeSource struct {
+	store *Store
+	root  registry.Descriptor
+}
+
+// Root 实现 registry.ImageSource 接口
+func (s *storeSource) Root(ctx context.Context) (registry.Descriptor, []byte, error) {
+	body, err := s.Manifest(ctx, s.root)
+	if err != nil {
+		return registry.Descriptor{}, nil, err
+	}
+	return s.root, body, nil
+}
+
+// Manifest 实现 registry.ImageSource 接口
+func (s *storeSource) Manifest(ctx context.Context, desc registry.Descriptor) ([]byte, error) {
+	if desc.Size > registry.MaxManifestSize {
+		return nil, fmt.Errorf("%w (%d > %d 字节)", registry.ErrManifestTooLarge, desc.Size, registry.MaxManifestSize)
+	}
+	content, err := s.Blob(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+	return io.ReadAll(io.LimitReader(content, registry.MaxManifestSize+1))
+}
+
+// Blob 实现 registry.ImageSource 接口，内容通过 Read 流式读取
+func (s *storeSource) Blob(ctx context.Context, desc registry.Descriptor) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(s.store.withNamespace(ctx))
+	stream, err := contentapi.NewContentClient(s.store.conn).Read(ctx, &contentapi.ReadContentRequest{Digest: desc.Digest})
+	if err != nil {
+		cancel()
+		return nil, storeError("读取 "+desc.Digest, err)
+	}
+	return &contentReader{stream: stream, cancel: cancel, digest: desc.Digest}, nil
+}
+
+// Close 实现 registry.ImageSource 接口
+func (s *storeSource) Close() error {
+	return nil
+}
+
+// contentReader 将 Read 流转换为 io.ReadCloser
+type contentReader struct {
+	stream contentapi.Content_ReadClient
+	cancel context.CancelFunc
+	digest string
+	buf    []byte
+}
+
+func (r *contentReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		resp, err := r.stream.Recv()
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, storeError("读取 "+r.digest, err)
+		}
+		r.buf = resp.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (r *contentReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+// storeDestination 表示作为复制目标的 containerd 命名空间
+// 内容在租约中写入，manifest 和索引带有 containerd.io/gc.ref.content 标签引用其配置、层和子 manifest，
+// Commit 创建镜像后这些内容由镜像引用，Close 时删除租约
+type storeDestination struct {
+	store *Store
+	name  string
+	lease string
+	root  *registry.Descriptor
+}
+
+// SupportsIndex 实现 registry.ImageDestination 接口
+func (d *storeDestination) SupportsIndex() bool {
+	return true
+}
+
+// HasBlob 实现 registry.ImageDestination 接口
+func (d *storeDestination) HasBlob(ctx context.Context, desc registry.Descriptor) (bool, error) {
+	_, err := contentapi.NewContentClient(d.store.conn).Info(d.store.withNamespace(ctx), &contentapi.InfoRequest{Digest: desc.Digest})
+	switch {
+	case err == nil:
+		return true, nil
+	case status.Code(err) == codes.NotFound:
+		return false, nil
+	}
+	return false, storeError("查询 "+desc.Digest, err)
+}
+
+// PutBlob 实现 registry.ImageDestination 接口
+func (d *storeDestination) PutBlob(ctx context.Context, desc registry.Descriptor, content io.Reader) error {
+	return d.write(ctx, desc, content, nil)
+}
+
+// PutManifest 实现 registry.ImageDestination 接口，写入时添加引用子内容的垃圾回收标签
+func (d *storeDestination) PutManifest(ctx context.Context, desc registry.Descriptor, body []byte, root bool) error {
+	labels, err := gcLabels(desc.MediaType, body)
+	if err != nil {
+		return err
+	}
+	if err := d.write(ctx, desc, strings.NewReader(string(body)), labels); err != nil {
+		return err
+	}
+	if root {
+		d.root = &registry.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size}
+	}
+	return nil
+}
+
+// Commit 实现 registry.ImageDestination 接口，创建镜像，已存在时更新其目标
+func (d *storeDestination) Commit(ctx context.Context) error {
+	if d.root == nil {
+		return errors.New("containerd: 没有写入根 manifest")
+	}
+	image := &imagesapi.Image{
+		Name:   d.name,
+		Target: &types.Descriptor{MediaType: d.root.MediaType, Digest: d.root.Digest, Size: d.root.Size},
+	}
+	client := imagesapi.NewImagesClient(d.store.conn)
+	ctx = d.store.withNamespace(ctx)
+	_, err := client.Create(ctx, &imagesapi.CreateImageRequest{Image: image})
+	if status.Code(err) == codes.AlreadyExists {
+		_, err = client.Update(ctx, &imagesapi.UpdateImageRequest{Image: image})
+	}
+	if err != nil {
+		return storeError("创建镜像 "+d.name, err)
+	}
+	return nil
+}
+
+// Close 实现 registry.ImageDestination 接口，删除写入时创建的租约，未被镜像引用的内容随后被垃圾回收
+func (d *storeDestination) Close() error {
+	if d.lease == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(d.store.withNamespace(context.Background()), 30*time.Second)
+	defer cancel()
+	_, err := leasesapi.NewLeasesClient(d.store.conn).Delete(ctx, &leasesapi.DeleteRequest{ID: d.lease})
+	d.lease = ""
+	if err != nil {
+		return storeError("删除租约", err)
+	}
+	return nil
+}
+
+// leaseContext 返回带有命名空间和租约的 ctx，第一次调用时创建租约
+func (d *storeDestination) leaseContext(ctx context.Context) (context.Context, error) {
+	ctx = d.store.withNamespace(ctx)
+	if d.lease == "" {
+		resp, err := leasesapi.NewLeasesClient(d.store.conn).Create(ctx, &leasesapi.CreateRequest{
+			ID:     "docker-mainifest-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+			Labels: map[string]string{"containerd.io/gc.expire": time.Now().Add(leaseExpire).Format(time.RFC3339)},
+		})
+		if err != nil {
+			return nil, storeError("创建租约", err)
+		}
+		d.lease = resp.Lease.ID
+	}
+	return metadata.AppendToOutgoingContext(ctx, leaseHeader, d.lease), nil
+}
+
+// write 通过 Write 流写入内容，containerd 在提交时校验大小和 digest；内容已存在时视为成功
+func (d *storeDestination) write(ctx context.Context, desc registry.Descriptor, content io.Reader, labels map[string]string) error {
+	ctx, err := d.leaseContext(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := contentapi.NewContentClient(d.store.conn).Write(ctx)
+	if err != nil {
+		return storeError("写入 "+desc.Digest, err)
+	}
+	send := func(req *contentapi.WriteContentRequest) error {
+		req.Ref = "docker-mainifest-" + desc.Digest
+		req.Total = desc.Size
+		req.Expected = desc.Digest
+		if err := stream.Send(req); err != nil {
+			return err
+		}
+		_, err := stream.Recv()
+		return err
+	}
+
+	buf := make([]byte, writeChunkSize)
+	var offset int64
+	for {
+		n, readErr := io.ReadFull(content, buf)
+		if n > 0 {
+			if err := send(&contentapi.WriteContentRequest{Action: contentapi.WriteAction_WRITE, Offset: offset, Data: buf[:n]}); err != nil {
+				return writeError(desc.Digest, err)
+			}
+			offset += int64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return fmt.Errorf("containerd: 读取 %s 失败: %w", desc.Digest, readErr)
+		}
+	}
+	if offset != desc.Size {
+		return fmt.Errorf("containerd: blob %s 大小不匹配: 期望 %d 字节，实际 %d 字节", desc.Digest, desc.Size, offset)
+	}
+	if err := send(&contentapi.WriteContentRequest{Action: contentapi.WriteAction_COMMIT, Offset: offset, Labels: labels}); err != nil {
+		return writeError(desc.Digest, err)
+	}
+	return stream.CloseSend()
+}
+
+// writeError 包装写入错误，内容已存在（AlreadyExists）时返回 nil
+func writeError(digest string, err error) error {
+	if status.Code(err) == codes.AlreadyExists {
+		return nil
+	}
+	return storeError("写入 "+digest, err)
+}
+
+// gcLabels 返回 manifest 或索引引用子内容的垃圾回收标签：
+// containerd.io/gc.ref.content.config、containerd.io/gc.ref.content.l.<i> 和 containerd.io/gc.ref.content.m.<i>
+func gcLabels(mediaType string, body []byte) (map[string]string, error) {
+	labels := make(map[string]string)
+	if registry.IsIndexMediaType(mediaType) {
+		var index registry.ImageIndex
+		if err := json.Unmarshal(body, &index); err != nil {
+			return nil, fmt.Errorf("containerd: 解析镜像索引失败: %w", err)
+		}
+		for i, manifest := range index.Manifests {
+			labels["containerd.io/gc.ref.content.m."+strconv.Itoa(i)] = manifest.Digest
+		}
+		return labels, nil
+	}
+	var manifest registry.ImageManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("containerd: 解析 manifest 失败: %w", err)
+	}
+	if manifest.Config.Digest != "" {
+		labels["containerd.io/gc.ref.content.config"] = manifest.Config.Digest
+	}
+	for i, layer := range manifest.Layers {
+		if len(layer.URLs) > 0 {
+			continue
+		}
+		labels["containerd.io/gc.ref.content.l."+strconv.Itoa(i)] = layer.Digest
+	}
+	return labels, nil
+}
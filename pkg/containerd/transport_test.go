@@ -0,0 +1,269 @@
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	contentapi "github.com/containerd/containerd/api/services/content/v1"
+	imagesapi "github.com/containerd/containerd/api/services/images/v1"
+	leasesapi "github.com/containerd/containerd/api/services/leases/v1"
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// memoryStore 在内存中模拟 containerd 的 content 服务，并保存 images 和 leases 服务的状态
+type memoryStore struct {
+	contentapi.UnimplementedContentServer
+
+	mu      sync.Mutex
+	blobs   map[string][]byte
+	labels  map[string]map[string]string
+	images  map[string]*imagesapi.Image
+	leases  map[string]bool
+	updates int
+}
+
+func (m *memoryStore) Info(ctx context.Context, req *contentapi.InfoRequest) (*contentapi.InfoResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.blobs[req.Digest]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "content %s: not found", req.Digest)
+	}
+	return &contentapi.InfoResponse{Info: &contentapi.Info{Digest: req.Digest, Size: int64(len(data))}}, nil
+}
+
+func (m *memoryStore) Read(req *contentapi.ReadContentRequest, stream contentapi.Content_ReadServer) error {
+	m.mu.Lock()
+	data, ok := m.blobs[req.Digest]
+	m.mu.Unlock()
+	if !ok {
+		return status.Errorf(codes.NotFound, "content %s: not found", req.Digest)
+	}
+	for offset := 0; offset < len(data); offset += 7 {
+		end := min(offset+7, len(data))
+		if err := stream.Send(&contentapi.ReadContentResponse{Offset: int64(offset), Data: data[offset:end]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryStore) Write(stream contentapi.Content_WriteServer) error {
+	var buf bytes.Buffer
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		m.mu.Lock()
+		_, exists := m.blobs[req.Expected]
+		m.mu.Unlock()
+		if exists {
+			return status.Errorf(codes.AlreadyExists, "content %s: already exists", req.Expected)
+		}
+		if req.Offset != int64(buf.Len()) {
+			return status.Errorf(codes.OutOfRange, "offset %d != %d", req.Offset, buf.Len())
+		}
+		buf.Write(req.Data)
+		if req.Action == contentapi.WriteAction_COMMIT {
+			if int64(buf.Len()) != req.Total || sha256Digest(buf.Bytes()) != req.Expected {
+				return status.Errorf(codes.FailedPrecondition, "unexpected commit content")
+			}
+			m.mu.Lock()
+			m.blobs[req.Expected] = bytes.Clone(buf.Bytes())
+			m.labels[req.Expected] = req.Labels
+			m.mu.Unlock()
+		}
+		if err := stream.Send(&contentapi.WriteContentResponse{Action: req.Action, Offset: int64(buf.Len()), Total: req.Total}); err != nil {
+			return err
+		}
+	}
+}
+
+// memoryImages 实现 images 服务，Delete 方法与 content 服务同名，需要单独的类型
+type memoryImages struct {
+	imagesapi.UnimplementedImagesServer
+	store *memoryStore
+}
+
+func (i memoryImages) Get(ctx context.Context, req *imagesapi.GetImageRequest) (*imagesapi.GetImageResponse, error) {
+	i.store.mu.Lock()
+	defer i.store.mu.Unlock()
+	image, ok := i.store.images[req.Name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "image %q: not found", req.Name)
+	}
+	return &imagesapi.GetImageResponse{Image: image}, nil
+}
+
+func (i memoryImages) Create(ctx context.Context, req *imagesapi.CreateImageRequest) (*imagesapi.CreateImageResponse, error) {
+	i.store.mu.Lock()
+	defer i.store.mu.Unlock()
+	if _, ok := i.store.images[req.Image.Name]; ok {
+		return nil, status.Errorf(codes.AlreadyExists, "image %q: already exists", req.Image.Name)
+	}
+	i.store.images[req.Image.Name] = req.Image
+	return &imagesapi.CreateImageResponse{Image: req.Image}, nil
+}
+
+func (i memoryImages) Update(ctx context.Context, req *imagesapi.UpdateImageRequest) (*imagesapi.UpdateImageResponse, error) {
+	i.store.mu.Lock()
+	defer i.store.mu.Unlock()
+	i.store.images[req.Image.Name] = req.Image
+	i.store.updates++
+	return &imagesapi.UpdateImageResponse{Image: req.Image}, nil
+}
+
+// memoryLeases 实现 leases 服务
+type memoryLeases struct {
+	leasesapi.UnimplementedLeasesServer
+	store *memoryStore
+}
+
+func (l memoryLeases) Create(ctx context.Context, req *leasesapi.CreateRequest) (*leasesapi.CreateResponse, error) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	l.store.leases[req.ID] = true
+	return &leasesapi.CreateResponse{Lease: &leasesapi.Lease{ID: req.ID, Labels: req.Labels}}, nil
+}
+
+func (l memoryLeases) Delete(ctx context.Context, req *leasesapi.DeleteRequest) (*emptypb.Empty, error) {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	delete(l.store.leases, req.ID)
+	return &emptypb.Empty{}, nil
+}
+
+// newTestStore 在 unix socket 上启动内存中的 containerd 服务并连接
+func newTestStore(t *testing.T) (*Store, *memoryStore) {
+	t.Helper()
+	memory := &memoryStore{
+		blobs:  make(map[string][]byte),
+		labels: make(map[string]map[string]string),
+		images: make(map[string]*imagesapi.Image),
+		leases: make(map[string]bool),
+	}
+	socket := filepath.Join(t.TempDir(), "containerd.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	contentapi.RegisterContentServer(server, memory)
+	imagesapi.RegisterImagesServer(server, memoryImages{store: memory})
+	leasesapi.RegisterLeasesServer(server, memoryLeases{store: memory})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	store, err := New(Options{Address: socket, Namespace: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, memory
+}
+
+func TestStoreTransportRoundTrip(t *testing.T) {
+	store, memory := newTestStore(t)
+	client := registry.NewClient().WithImageTransport(registry.TransportContainerd, store)
+	ctx := context.Background()
+
+	archive := filepath.Join(t.TempDir(), "app.tar")
+	manifestDigest := writeTestArchive(t, archive)
+	src, _ := registry.ParseImageReference("docker-archive:" + archive)
+	dst, _ := registry.ParseImageReference("containerd:app:v1")
+	result, err := client.CopyImageReference(ctx, src, dst, registry.CopyOptions{})
+	if err != nil {
+		t.Fatalf("复制到 containerd: %v", err)
+	}
+	if result.BlobsUploaded != 2 {
+		t.Errorf("上传的 blob = %d, 期望 2", result.BlobsUploaded)
+	}
+	image, ok := memory.images["docker.io/library/app:v1"]
+	if !ok {
+		t.Fatalf("没有创建镜像 docker.io/library/app:v1: %v", memory.images)
+	}
+	if image.Target.Digest != manifestDigest {
+		t.Errorf("镜像目标 = %s, 期望 %s", image.Target.Digest, manifestDigest)
+	}
+	labels := memory.labels[manifestDigest]
+	if labels["containerd.io/gc.ref.content.config"] == "" || labels["containerd.io/gc.ref.content.l.0"] == "" {
+		t.Errorf("manifest 缺少垃圾回收标签: %v", labels)
+	}
+	if len(memory.leases) != 0 {
+		t.Errorf("租约未删除: %v", memory.leases)
+	}
+
+	// 再次复制时 blob 已存在，镜像被更新
+	result, err = client.CopyImageReference(ctx, src, dst, registry.CopyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BlobsExisting != 2 || memory.updates != 1 {
+		t.Errorf("已存在的 blob = %d, 更新次数 = %d, 期望 2 和 1", result.BlobsExisting, memory.updates)
+	}
+
+	digest, err := client.ResolveImageDigest(ctx, dst)
+	if err != nil || digest != manifestDigest {
+		t.Errorf("ResolveImageDigest = %s, %v, 期望 %s", digest, err, manifestDigest)
+	}
+
+	layout := t.TempDir()
+	result, err = client.CopyImageReference(ctx, dst, registry.ImageReference{Transport: registry.TransportOCI, Name: layout}, registry.CopyOptions{})
+	if err != nil {
+		t.Fatalf("从 containerd 复制: %v", err)
+	}
+	if result.Digest != manifestDigest {
+		t.Errorf("digest = %s, 期望 %s", result.Digest, manifestDigest)
+	}
+
+	missing, _ := registry.ParseImageReference("containerd:other:v1")
+	if _, err := client.ResolveImageDigest(ctx, missing); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("不存在的镜像错误 = %v, 期望 ErrNotFound", err)
+	}
+}
+
+// writeTestArchive 写入只有一个层的 docker-archive，返回其 manifest 的 digest
+func writeTestArchive(t *testing.T, path string) string {
+	t.Helper()
+	ctx := context.Background()
+	destination, err := registry.NewDockerArchiveDestination(path, "app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destination.Close()
+	put := func(mediaType string, content []byte) registry.Descriptor {
+		desc := registry.Descriptor{MediaType: mediaType, Digest: sha256Digest(content), Size: int64(len(content))}
+		if err := destination.PutBlob(ctx, desc, bytes.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		return desc
+	}
+	config := put(registry.MediaTypeOCIConfig, []byte(`{"architecture":"amd64","os":"linux"}`))
+	layer := put(registry.MediaTypeOCILayer, make([]byte, 1024))
+	body := []byte(`{"schemaVersion":2,"mediaType":"` + registry.MediaTypeOCIManifest + `","config":{"mediaType":"` + config.MediaType + `","digest":"` + config.Digest + `","size":37},"layers":[{"mediaType":"` + layer.MediaType + `","digest":"` + layer.Digest + `","size":1024}]}`)
+	root := registry.Descriptor{MediaType: registry.MediaTypeOCIManifest, Digest: sha256Digest(body), Size: int64(len(body))}
+	if err := destination.PutManifest(ctx, root, body, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := destination.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return root.Digest
+}
+
+func sha256Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
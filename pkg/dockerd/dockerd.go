@@ -0,0 +1,172 @@
+// Package dockerd 通过 Docker Engine API 读取本地 Docker daemon 中的镜像，与 registry 中的最新 digest 比较
+// 用于在不拉取镜像的情况下检查哪些本地镜像有可用的更新（类似 watchtower 的检查）
+//
+// 使用方式：
+//
+//	daemon, err := dockerd.New(dockerd.Options{})
+//	if err != nil {
+//		return err
+//	}
+//	updates, err := daemon.CheckUpdates(ctx, registry.NewClient(), registry.BatchOptions{Concurrency: 8, BatchAuth: true})
+//
+// Daemon 同时实现了 registry.ImageTransport，注册后可以在 docker-daemon 和其他存储之间复制镜像：
+//
+//	client := registry.NewClient().WithImageTransport(registry.TransportDockerDaemon, daemon)
+//	result, err := client.CopyImage("docker-daemon:nginx", "1.27", "ghcr.io/org/nginx", "1.27")
+//
+// 读取通过 docker save（GET /images/get）导出到临时文件，写入先生成 docker-archive 临时文件再通过 docker load（POST /images/load）导入；
+// 导出的镜像只有各层的 tar，复制时生成新的 manifest，digest 与 registry 中的不同；导入只支持单个平台的镜像
+//
+// 通过 Docker Engine API 直接通信，不依赖 Docker SDK；只支持 unix:// 和 tcp://（不带 TLS）地址
+package dockerd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// 默认配置
+const (
+	DefaultHost    = "unix:///var/run/docker.sock"
+	DefaultTimeout = 30 * time.Second
+)
+
+// ErrUnsupportedHost 表示不支持的 Docker daemon 地址
+var ErrUnsupportedHost = errors.New("dockerd: 不支持的地址")
+
+// Options 表示 Docker daemon 连接配置
+type Options struct {
+	Host       string       // daemon 地址，如 unix:///var/run/docker.sock、tcp://127.0.0.1:2375；为空时使用环境变量 DOCKER_HOST，未设置时为 DefaultHost
+	APIVersion string       // Engine API 版本，如 1.43；为空时使用 daemon 支持的最新版本（不带版本前缀）
+	HTTPClient *http.Client // 访问 daemon 使用的 HTTP 客户端，为 nil 时根据 Host 创建超时为 DefaultTimeout 的客户端
+}
+
+// Daemon 表示一个 Docker daemon 连接
+type Daemon struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// engineImage 表示 GET /images/json 返回的镜像
+type engineImage struct {
+	ID          string   `json:"Id"`
+	RepoTags    []string `json:"RepoTags"`
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+// New 创建 Docker daemon 连接，不会立即访问 daemon
+func New(opts Options) (*Daemon, error) {
+	if opts.Host == "" {
+		opts.Host = os.Getenv("DOCKER_HOST")
+	}
+	if opts.Host == "" {
+		opts.Host = DefaultHost
+	}
+	u, err := url.Parse(opts.Host)
+	if err != nil {
+		return nil, fmt.Errorf("dockerd: 解析地址 '%s' 失败: %w", opts.Host, err)
+	}
+
+	d := &Daemon{httpClient: opts.HTTPClient}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		d.baseURL = "http://docker"
+		if d.httpClient == nil {
+			transport := &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			}
+			d.httpClient = &http.Client{Transport: transport, Timeout: DefaultTimeout}
+		}
+	case "tcp", "http":
+		d.baseURL = "http://" + u.Host
+	default:
+		return nil, fmt.Errorf("%w '%s'，只支持 unix:// 和 tcp://", ErrUnsupportedHost, opts.Host)
+	}
+	if d.httpClient == nil {
+		d.httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	if opts.APIVersion != "" {
+		d.baseURL += "/v" + strings.TrimPrefix(opts.APIVersion, "v")
+	}
+	return d, nil
+}
+
+// Images 列出 daemon 中带标签的镜像，每个标签一条，按 daemon 返回的顺序排列
+// references 不为空时只列出匹配的镜像，语法与 docker images 的 reference 过滤器相同，如 "nginx"、"ghcr.io/org/*:v1*"
+// 悬空镜像（<none>:<none>）不列出；Digests 为 RepoDigests 中属于同一仓库的 digest
+func (d *Daemon) Images(ctx context.Context, references ...string) ([]registry.LocalImage, error) {
+	path := "/images/json"
+	if len(references) > 0 {
+		filters, _ := json.Marshal(map[string][]string{"reference": references})
+		path += "?filters=" + url.QueryEscape(string(filters))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("dockerd: 访问 Docker daemon 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("dockerd: 列出镜像失败 (状态码: %d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var engineImages []engineImage
+	if err := json.NewDecoder(resp.Body).Decode(&engineImages); err != nil {
+		return nil, fmt.Errorf("dockerd: 解析镜像列表失败: %w", err)
+	}
+
+	var images []registry.LocalImage
+	for _, engine := range engineImages {
+		for _, repoTag := range engine.RepoTags {
+			name, tag, ok := splitRepoTag(repoTag)
+			if !ok {
+				continue
+			}
+			image := registry.LocalImage{Image: name, Tag: tag, ID: engine.ID}
+			for _, repoDigest := range engine.RepoDigests {
+				if repository, digest, ok := strings.Cut(repoDigest, "@"); ok && repository == name {
+					image.Digests = append(image.Digests, digest)
+				}
+			}
+			images = append(images, image)
+		}
+	}
+	return images, nil
+}
+
+// CheckUpdates 列出 daemon 中的镜像（references 的含义与 Images 相同），通过 client 获取远程 digest 并比较
+// 只发送 HEAD 请求，不拉取镜像；本地构建或导入的镜像没有 registry digest，状态为 registry.UpdateStatusUnknown
+func (d *Daemon) CheckUpdates(ctx context.Context, client *registry.Client, opts registry.BatchOptions, references ...string) ([]registry.ImageUpdate, error) {
+	images, err := d.Images(ctx, references...)
+	if err != nil {
+		return nil, err
+	}
+	return client.CheckUpdates(ctx, images, opts), nil
+}
+
+// splitRepoTag 拆分 RepoTags 中的 "name:tag"，悬空镜像返回 false
+func splitRepoTag(repoTag string) (string, string, bool) {
+	i := strings.LastIndex(repoTag, ":")
+	if i < 0 || strings.Contains(repoTag[i:], "/") || repoTag == "<none>:<none>" {
+		return "", "", false
+	}
+	return repoTag[:i], repoTag[i+1:], true
+}
@@ -0,0 +1,219 @@
+package dockerd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// Daemon 实现 registry.ImageTransport 和 registry.ImageDigestResolver，注册为 registry.TransportDockerDaemon 后可以作为 CopyImage 的源和目标
+var _ registry.ImageTransport = (*Daemon)(nil)
+
+// OpenSource 实现 registry.ImageTransport 接口，tag 为空时使用 latest
+func (d *Daemon) OpenSource(ctx context.Context, name, tag string) (registry.ImageSource, error) {
+	reference := daemonReference(name, tag)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+"/images/get?names="+url.QueryEscape(reference), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.transferClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("dockerd: 访问 Docker daemon 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "导出镜像 "+reference); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "docker-daemon-*.tar")
+	if err != nil {
+		return nil, fmt.Errorf("dockerd: 创建临时文件失败: %w", err)
+	}
+	_, err = io.Copy(tmp, resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("dockerd: 导出镜像 %s 失败: %w", reference, err)
+	}
+	source, err := registry.NewDockerArchiveSource(tmp.Name(), "")
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("dockerd: %w", err)
+	}
+	return &daemonSource{ImageSource: source, path: tmp.Name()}, nil
+}
+
+// OpenDestination 实现 registry.ImageTransport 接口，tag 为空时使用 latest，不能为 digest
+func (d *Daemon) OpenDestination(ctx context.Context, name, tag string) (registry.ImageDestination, error) {
+	if strings.Contains(tag, ":") {
+		return nil, fmt.Errorf("dockerd: 导入 Docker daemon 的镜像需要标签，不能为 digest '%s'", tag)
+	}
+	tmp, err := os.CreateTemp("", "docker-daemon-*.tar")
+	if err != nil {
+		return nil, fmt.Errorf("dockerd: 创建临时文件失败: %w", err)
+	}
+	tmp.Close()
+	archive, err := registry.NewDockerArchiveDestination(tmp.Name(), daemonReference(name, tag))
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return &daemonDestination{ImageDestination: archive, daemon: d, path: tmp.Name()}, nil
+}
+
+// ResolveDigest 实现 registry.ImageDigestResolver 接口，返回镜像 RepoDigests 中属于同一仓库的 digest
+// 本地构建或通过 docker load 导入的镜像没有 registry digest，此时返回空字符串
+func (d *Daemon) ResolveDigest(ctx context.Context, name, tag string) (string, error) {
+	reference := daemonReference(name, tag)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.baseURL+"/images/"+reference+"/json", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("dockerd: 访问 Docker daemon 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "查询镜像 "+reference); err != nil {
+		return "", err
+	}
+	var image engineImage
+	if err := json.NewDecoder(resp.Body).Decode(&image); err != nil {
+		return "", fmt.Errorf("dockerd: 解析镜像信息失败: %w", err)
+	}
+	if strings.Contains(tag, ":") {
+		return tag, nil
+	}
+	want := registry.CanonicalImageName(name)
+	for _, repoDigest := range image.RepoDigests {
+		if repository, digest, ok := strings.Cut(repoDigest, "@"); ok && registry.CanonicalImageName(repository) == want {
+			return digest, nil
+		}
+	}
+	return "", nil
+}
+
+// daemonSource 表示从 Docker daemon 导出的镜像，Close 时删除临时文件
+type daemonSource struct {
+	registry.ImageSource
+	path string
+}
+
+// Close 实现 registry.ImageSource 接口
+func (s *daemonSource) Close() error {
+	err := s.ImageSource.Close()
+	os.Remove(s.path)
+	return err
+}
+
+// daemonDestination 表示导入 Docker daemon 的镜像，先写入 docker-archive 临时文件，Commit 时导入
+type daemonDestination struct {
+	registry.ImageDestination
+	daemon *Daemon
+	path   string
+}
+
+// HasBlob 实现 registry.ImageDestination 接口，docker load 需要完整的归档，所有 blob 都需要写入
+func (d *daemonDestination) HasBlob(ctx context.Context, desc registry.Descriptor) (bool, error) {
+	return false, nil
+}
+
+// Commit 实现 registry.ImageDestination 接口，通过 POST /images/load 导入归档
+func (d *daemonDestination) Commit(ctx context.Context) error {
+	if err := d.ImageDestination.Commit(ctx); err != nil {
+		return err
+	}
+	file, err := os.Open(d.path)
+	if err != nil {
+		return fmt.Errorf("dockerd: %w", err)
+	}
+	defer file.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.daemon.baseURL+"/images/load?quiet=1", file)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	resp, err := d.daemon.transferClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("dockerd: 访问 Docker daemon 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp, "导入镜像"); err != nil {
+		return err
+	}
+	// 导入失败时状态码仍为 200，错误在 JSON 消息流中返回
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Error       string `json:"error"`
+			ErrorDetail struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("dockerd: 解析导入结果失败: %w", err)
+		}
+		if message.ErrorDetail.Message != "" {
+			return fmt.Errorf("dockerd: 导入镜像失败: %s", message.ErrorDetail.Message)
+		}
+		if message.Error != "" {
+			return fmt.Errorf("dockerd: 导入镜像失败: %s", message.Error)
+		}
+	}
+}
+
+// Close 实现 registry.ImageDestination 接口，删除临时文件
+func (d *daemonDestination) Close() error {
+	err := d.ImageDestination.Close()
+	os.Remove(d.path)
+	return err
+}
+
+// transferClient 返回导出和导入镜像使用的 HTTP 客户端，传输大镜像可能超过 DefaultTimeout，不设置总超时（由 ctx 控制）
+func (d *Daemon) transferClient() *http.Client {
+	client := *d.httpClient
+	client.Timeout = 0
+	return &client
+}
+
+// daemonReference 返回 Docker daemon 中的镜像引用，tag 为空时使用 latest
+func daemonReference(name, tag string) string {
+	switch {
+	case tag == "":
+		return name + ":latest"
+	case strings.Contains(tag, ":"):
+		return name + "@" + tag
+	default:
+		return name + ":" + tag
+	}
+}
+
+// checkResponse 检查 Docker daemon 的响应状态码，镜像不存在时返回可通过 errors.Is(err, registry.ErrNotFound) 判断的错误
+func checkResponse(resp *http.Response, action string) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	message := strings.TrimSpace(string(body))
+	var engineError struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &engineError) == nil && engineError.Message != "" {
+		message = engineError.Message
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("dockerd: %s失败: %w: %s", action, registry.ErrNotFound, message)
+	}
+	return fmt.Errorf("dockerd: %s失败 (状态码: %d): %s", action, resp.StatusCode, message)
+}
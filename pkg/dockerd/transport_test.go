@@ -0,0 +1,120 @@
+package dockerd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// fakeDaemon 模拟 docker save 和 docker load：导出时返回最近一次导入的归档
+type fakeDaemon struct {
+	archive []byte
+	loads   int
+}
+
+func (f *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/images/load":
+		if r.Header.Get("Content-Type") != "application/x-tar" {
+			http.Error(w, `{"message":"bad content type"}`, http.StatusBadRequest)
+			return
+		}
+		f.archive, _ = io.ReadAll(r.Body)
+		f.loads++
+		w.Write([]byte(`{"stream":"Loaded image: app:v1\n"}`))
+	case r.Method == http.MethodGet && r.URL.Path == "/images/get":
+		if f.archive == nil || r.URL.Query().Get("names") != "app:v1" {
+			http.Error(w, `{"message":"reference does not exist"}`, http.StatusNotFound)
+			return
+		}
+		w.Write(f.archive)
+	case r.Method == http.MethodGet && r.URL.Path == "/images/app:v1/json":
+		w.Write([]byte(`{"Id":"sha256:1","RepoDigests":["docker.io/library/app@sha256:abc"]}`))
+	default:
+		http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+	}
+}
+
+func TestDaemonTransportRoundTrip(t *testing.T) {
+	fake := &fakeDaemon{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	daemon, err := New(Options{Host: "tcp://" + server.Listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := registry.NewClient().WithImageTransport(registry.TransportDockerDaemon, daemon)
+	ctx := context.Background()
+
+	// 通过 docker-archive 准备一个镜像：先导入 daemon，再导出到 OCI 布局目录
+	archive := filepath.Join(t.TempDir(), "app.tar")
+	writeTestArchive(t, archive)
+	src, _ := registry.ParseImageReference("docker-archive:" + archive)
+	dst, _ := registry.ParseImageReference("docker-daemon:app:v1")
+	if _, err := client.CopyImageReference(ctx, src, dst, registry.CopyOptions{}); err != nil {
+		t.Fatalf("导入 daemon: %v", err)
+	}
+	if fake.loads != 1 {
+		t.Errorf("导入次数 = %d, 期望 1", fake.loads)
+	}
+
+	layout := t.TempDir()
+	result, err := client.CopyImageReference(ctx, dst, registry.ImageReference{Transport: registry.TransportOCI, Name: layout}, registry.CopyOptions{})
+	if err != nil {
+		t.Fatalf("从 daemon 导出: %v", err)
+	}
+	if result.BlobsUploaded != 2 {
+		t.Errorf("上传的 blob = %d, 期望 2", result.BlobsUploaded)
+	}
+	digest, err := client.ResolveImageDigest(ctx, dst)
+	if err != nil || digest != "sha256:abc" {
+		t.Errorf("ResolveImageDigest = %s, %v, 期望 sha256:abc", digest, err)
+	}
+
+	missing, _ := registry.ParseImageReference("docker-daemon:other:v1")
+	if _, err := client.CopyImageReference(ctx, missing, registry.ImageReference{Transport: registry.TransportOCI, Name: layout}, registry.CopyOptions{}); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("不存在的镜像错误 = %v, 期望 ErrNotFound", err)
+	}
+}
+
+// writeTestArchive 写入只有一个层的 docker-archive
+func writeTestArchive(t *testing.T, path string) {
+	t.Helper()
+	ctx := context.Background()
+	destination, err := registry.NewDockerArchiveDestination(path, "app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destination.Close()
+	put := func(mediaType string, content []byte) registry.Descriptor {
+		desc := registry.Descriptor{MediaType: mediaType, Digest: sha256Digest(content), Size: int64(len(content))}
+		if err := destination.PutBlob(ctx, desc, bytes.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+		return desc
+	}
+	config := put(registry.MediaTypeOCIConfig, []byte(`{"architecture":"amd64","os":"linux"}`))
+	layer := put(registry.MediaTypeOCILayer, make([]byte, 1024))
+	body := []byte(`{"schemaVersion":2,"mediaType":"` + registry.MediaTypeOCIManifest + `","config":{"mediaType":"` + config.MediaType + `","digest":"` + config.Digest + `","size":37},"layers":[{"mediaType":"` + layer.MediaType + `","digest":"` + layer.Digest + `","size":1024}]}`)
+	root := registry.Descriptor{MediaType: registry.MediaTypeOCIManifest, Digest: sha256Digest(body), Size: int64(len(body))}
+	if err := destination.PutManifest(ctx, root, body, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := destination.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sha256Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,149 @@
+// Package metrics 提供 registry.Metrics 的 Prometheus 实现
+//
+// 使用方式：
+//
+//	m, err := metrics.NewPrometheus(prometheus.DefaultRegisterer)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	client := registry.NewClient().WithMetrics(m)
+package metrics
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// namespace 指标名称前缀
+const namespace = "docker_manifest"
+
+// Prometheus 将客户端的运行指标导出为 Prometheus 指标
+//
+// 导出的指标：
+//   - docker_manifest_requests_total{host, method, status}: HTTP 请求数，请求失败时 status 为 "error"
+//   - docker_manifest_request_duration_seconds{host, method}: HTTP 请求耗时
+//   - docker_manifest_token_cache_lookups_total{registry, result}: token 缓存查询次数，result 为 hit 或 miss
+//   - docker_manifest_batch_size{registry}: 批量获取时每个批次的镜像数量
+//   - docker_manifest_rate_limit_remaining{host}: registry 返回的剩余请求次数（RateLimit-Remaining header）
+type Prometheus struct {
+	requests           *prometheus.CounterVec
+	requestDuration    *prometheus.HistogramVec
+	tokenCacheLookups  *prometheus.CounterVec
+	batchSize          *prometheus.HistogramVec
+	rateLimitRemaining *prometheus.GaugeVec
+}
+
+// NewPrometheus 创建 Prometheus 指标并注册到 registerer
+// registerer 为 nil 时使用 prometheus.DefaultRegisterer
+func NewPrometheus(registerer prometheus.Registerer) (*Prometheus, error) {
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+
+	m := &Prometheus{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "registry 和认证服务的 HTTP 请求数",
+		}, []string{"host", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "registry 和认证服务的 HTTP 请求耗时",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"host", "method"}),
+		tokenCacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "token_cache_lookups_total",
+			Help:      "token 缓存查询次数",
+		}, []string{"registry", "result"}),
+		batchSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "batch_size",
+			Help:      "批量获取时每个批次的镜像数量",
+			Buckets:   []float64{1, 2, 5, 10, 20, 30},
+		}, []string{"registry"}),
+		rateLimitRemaining: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_remaining",
+			Help:      "registry 返回的剩余请求次数",
+		}, []string{"host"}),
+	}
+
+	collectors := []prometheus.Collector{
+		m.requests,
+		m.requestDuration,
+		m.tokenCacheLookups,
+		m.batchSize,
+		m.rateLimitRemaining,
+	}
+	for i, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			// 撤销已注册的指标，便于调用方处理错误后重试
+			for _, registered := range collectors[:i] {
+				registerer.Unregister(registered)
+			}
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// ObserveRequest 实现 registry.Metrics 接口
+func (m *Prometheus) ObserveRequest(event *registry.RequestEvent) {
+	host := requestHost(event.URL)
+
+	status := "error"
+	if event.Err == nil {
+		status = strconv.Itoa(event.StatusCode)
+	}
+	m.requests.WithLabelValues(host, event.Method, status).Inc()
+	m.requestDuration.WithLabelValues(host, event.Method).Observe(event.Duration.Seconds())
+
+	if event.Header == nil {
+		return
+	}
+	if remaining, ok := parseRemaining(event.Header.Get("Ratelimit-Remaining")); ok {
+		m.rateLimitRemaining.WithLabelValues(host).Set(remaining)
+	}
+}
+
+// ObserveBatch 实现 registry.Metrics 接口
+func (m *Prometheus) ObserveBatch(registryKey string, size int) {
+	m.batchSize.WithLabelValues(registryKey).Observe(float64(size))
+}
+
+// ObserveTokenCache 实现 registry.Metrics 接口
+func (m *Prometheus) ObserveTokenCache(registryKey string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.tokenCacheLookups.WithLabelValues(registryKey, result).Inc()
+}
+
+// requestHost 从请求地址中提取域名，作为指标标签（不包含路径，避免标签基数过高）
+func requestHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "unknown"
+	}
+	return u.Host
+}
+
+// parseRemaining 解析 RateLimit-Remaining header，格式为 "76;w=21600" 或 "76"
+func parseRemaining(value string) (float64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	count, _, _ := strings.Cut(value, ";")
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil {
+		return 0, false
+	}
+	return float64(n), true
+}
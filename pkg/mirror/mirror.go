@@ -0,0 +1,451 @@
+// Package mirror 在 registry 之间同步镜像，类似轻量的 skopeo sync
+// 源和目标也可以是带传输方式前缀的 OCI 布局目录、Docker daemon 或 containerd（见 registry.ParseImageReference）
+//
+// 使用方式：
+//
+//	mappings, err := mirror.LoadMappingsFile("mirror.json")
+//	syncer := mirror.New(client, mirror.Options{Concurrency: 4})
+//	report, err := syncer.Run(ctx, mappings)
+//
+// 每条映射将源仓库中符合标签过滤条件的标签同步到目标仓库的同名标签。
+// 同步前通过批量 HEAD 请求比较源和目标的 digest，只复制目标中不存在或 digest 不同的标签；
+// 复制使用 registry.Client.CopyImageReference，同一 registry 内的同步通过跨仓库挂载完成
+//
+// 源或目标不是 registry 时：
+//   - 源只能指定不含通配符的标签（oci 为 index.json 中的引用名），不列出标签
+//   - 通过 registry.Client.ResolveImageDigest 比较 digest，无法确定目标的 digest 时（如 docker load 导入的镜像）总是复制
+//   - docker-daemon 和 containerd 需要先通过 registry.Client.WithImageTransport 注册
+//   - docker-archive 只能保存单个镜像，不能用于同步
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// DefaultConcurrency 默认同时复制的镜像数
+const DefaultConcurrency = 4
+
+// Mapping 表示一条同步映射
+type Mapping struct {
+	Source      string   `json:"source"`                // 源仓库，如 docker.io/library/nginx；可以带传输方式前缀，如 oci:/data/layout
+	Destination string   `json:"destination"`           // 目标仓库，如 registry.example.com/mirror/nginx、containerd:registry.example.com/nginx
+	Tags        []string `json:"tags,omitempty"`        // 要同步的标签，支持 path.Match 通配符（如 "1.27*"）；为空时同步全部标签
+	TagRegex    string   `json:"tagRegex,omitempty"`    // 标签还需匹配的正则表达式，为空时不限制
+	ExcludeTags []string `json:"excludeTags,omitempty"` // 排除的标签，支持 path.Match 通配符
+}
+
+// mappingsFile 表示映射配置文件的格式
+type mappingsFile struct {
+	Mappings []Mapping `json:"mappings"`
+}
+
+// LoadMappingsFile 从 JSON 文件加载同步映射
+//
+// 文件格式:
+//
+//	{
+//	  "mappings": [
+//	    {"source": "nginx", "destination": "registry.example.com/mirror/nginx", "tags": ["1.27*", "stable"]},
+//	    {"source": "ghcr.io/org/app", "destination": "ghcr.io/org/app-release", "tagRegex": "^v[0-9]+\\."}
+//	  ]
+//	}
+func LoadMappingsFile(path string) ([]Mapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取同步映射文件失败: %w", err)
+	}
+	var file mappingsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析同步映射文件失败: %w", err)
+	}
+	return file.Mappings, nil
+}
+
+// Options 表示同步选项
+type Options struct {
+	Concurrency int  // 同时复制的镜像数，同时作为比较 digest 的并发数；0 使用 DefaultConcurrency
+	DryRun      bool // 只比较 digest 并报告需要复制的标签，不写入目标
+
+	// OnResult 在每个标签处理完成（复制完成、已是最新或失败）时调用，可用于输出进度
+	// 并发复制时会被并发调用；为 nil 时忽略
+	OnResult func(Result)
+}
+
+// Action 表示标签的同步结果
+type Action string
+
+const (
+	ActionCopy     Action = "copy"       // 目标不存在或 digest 不同，已复制（DryRun 时表示需要复制）
+	ActionUpToDate Action = "up-to-date" // 目标 digest 与源一致，跳过
+	ActionFailed   Action = "failed"     // 列出标签、比较 digest 或复制失败
+)
+
+// Result 表示单个标签的同步结果
+type Result struct {
+	Source            string               `json:"source"`                      // 源镜像，格式为 image:tag；列出标签失败时为源仓库
+	Destination       string               `json:"destination"`                 // 目标镜像，格式为 image:tag；列出标签失败时为目标仓库
+	SourceDigest      string               `json:"sourceDigest,omitempty"`      // 源镜像的 digest
+	DestinationDigest string               `json:"destinationDigest,omitempty"` // 同步前目标镜像的 digest，目标不存在时为空
+	Action            Action               `json:"action"`
+	Copy              *registry.CopyResult `json:"copy,omitempty"` // 复制的统计信息（仅实际复制时）
+	Error             error                `json:"-"`              // 错误信息（Action 为 ActionFailed 时）
+}
+
+// MarshalJSON 实现 json.Marshaler 接口，Error 输出为错误信息
+func (r Result) MarshalJSON() ([]byte, error) {
+	type plain Result
+	out := struct {
+		plain
+		Error string `json:"error,omitempty"`
+	}{plain: plain(r)}
+	if r.Error != nil {
+		out.Error = r.Error.Error()
+	}
+	return json.Marshal(out)
+}
+
+// Report 表示一次同步的结果
+type Report struct {
+	DryRun  bool     `json:"dryRun"`
+	Results []Result `json:"results"` // 列出标签失败的映射排在最前，其余按映射和标签的顺序排列
+}
+
+// Count 返回指定结果的数量
+func (r *Report) Count(action Action) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Errors 返回所有失败结果的错误
+func (r *Report) Errors() []error {
+	var errs []error
+	for _, result := range r.Results {
+		if result.Error != nil {
+			errs = append(errs, result.Error)
+		}
+	}
+	return errs
+}
+
+// Syncer 按映射在 registry 之间同步镜像
+type Syncer struct {
+	client *registry.Client
+	opts   Options
+}
+
+// New 创建 Syncer，client 需要配置好源和目标 registry 的凭据
+func New(client *registry.Client, opts Options) *Syncer {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	return &Syncer{client: client, opts: opts}
+}
+
+// syncItem 表示一个待同步的标签
+type syncItem struct {
+	mapping     *Mapping
+	source      registry.ImageReference // 源，Tag 为待同步的标签
+	destination registry.ImageReference // 目标，Tag 为待同步的标签
+}
+
+// Run 按映射同步镜像，返回每个标签的结果
+// 映射配置无效（缺少源或目标、正则表达式错误）时不开始同步，直接返回错误；
+// 单个仓库或标签的失败记录在结果中，不影响其他标签
+func (s *Syncer) Run(ctx context.Context, mappings []Mapping) (*Report, error) {
+	filters := make([]*tagFilter, len(mappings))
+	endpoints := make([][2]registry.ImageReference, len(mappings))
+	for i := range mappings {
+		filter, err := newTagFilter(&mappings[i])
+		if err != nil {
+			return nil, err
+		}
+		filters[i] = filter
+		if endpoints[i], err = parseEndpoints(&mappings[i], filter); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &Report{DryRun: s.opts.DryRun}
+	var items []syncItem
+	for i := range mappings {
+		mapping := &mappings[i]
+		tags, err := s.resolveTags(mapping, filters[i])
+		if err != nil {
+			s.addResult(report, Result{Source: mapping.Source, Destination: mapping.Destination, Action: ActionFailed, Error: err})
+			continue
+		}
+		for _, tag := range tags {
+			item := syncItem{mapping: mapping, source: endpoints[i][0], destination: endpoints[i][1]}
+			item.source.Tag, item.destination.Tag = tag, tag
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return report, ctx.Err()
+	}
+
+	results := s.compare(ctx, items)
+	var pending []int
+	for i, result := range results {
+		if result.Action == ActionCopy && !s.opts.DryRun {
+			pending = append(pending, i)
+			continue
+		}
+		s.notify(result)
+	}
+	s.copyAll(ctx, items, results, pending)
+
+	report.Results = append(report.Results, results...)
+	return report, ctx.Err()
+}
+
+// parseEndpoints 解析映射的源和目标，检查传输方式能否用于同步
+func parseEndpoints(mapping *Mapping, filter *tagFilter) ([2]registry.ImageReference, error) {
+	var endpoints [2]registry.ImageReference
+	for i, ref := range []string{mapping.Source, mapping.Destination} {
+		parsed, err := registry.ParseImageReference(ref)
+		if err != nil {
+			return endpoints, err
+		}
+		if parsed.Tag != "" {
+			return endpoints, fmt.Errorf("同步映射的源和目标应为仓库，不能带标签: %s", ref)
+		}
+		if parsed.Transport == registry.TransportDockerArchive {
+			return endpoints, fmt.Errorf("docker-archive 只能保存单个镜像，不能用于同步，请使用 copy: %s", ref)
+		}
+		endpoints[i] = parsed
+	}
+	if endpoints[0].Transport != registry.TransportRegistry && filter.literalTags() == nil {
+		return endpoints, fmt.Errorf("%s 不是 registry，无法列出标签，需要指定不含通配符的标签", mapping.Source)
+	}
+	return endpoints, nil
+}
+
+// resolveTags 返回映射需要同步的标签
+// 过滤条件都是不含通配符的标签时直接使用，不列出源仓库的标签
+func (s *Syncer) resolveTags(mapping *Mapping, filter *tagFilter) ([]string, error) {
+	if literal := filter.literalTags(); literal != nil {
+		return literal, nil
+	}
+	tags, err := s.client.ListTags(mapping.Source)
+	if err != nil {
+		return nil, fmt.Errorf("列出 %s 的标签失败: %w", mapping.Source, err)
+	}
+	var selected []string
+	for _, tag := range tags {
+		if filter.match(tag) {
+			selected = append(selected, tag)
+		}
+	}
+	return selected, nil
+}
+
+// compare 批量获取源和目标的 digest，确定每个标签是否需要复制
+func (s *Syncer) compare(ctx context.Context, items []syncItem) []Result {
+	sources := make([]registry.ImageReference, len(items))
+	destinations := make([]registry.ImageReference, len(items))
+	for i, item := range items {
+		sources[i], destinations[i] = item.source, item.destination
+	}
+	sourceDigests := s.digests(ctx, sources)
+	destDigests := s.digests(ctx, destinations)
+
+	results := make([]Result, len(items))
+	for i, item := range items {
+		result := Result{
+			Source:            item.source.String(),
+			Destination:       item.destination.String(),
+			SourceDigest:      sourceDigests[i].Digest,
+			DestinationDigest: destDigests[i].Digest,
+		}
+		switch {
+		case sourceDigests[i].Error != nil:
+			result.Action, result.Error = ActionFailed, fmt.Errorf("获取 %s 的 digest 失败: %w", result.Source, sourceDigests[i].Error)
+		case destDigests[i].Error != nil && !errors.Is(destDigests[i].Error, registry.ErrNotFound):
+			result.Action, result.Error = ActionFailed, fmt.Errorf("获取 %s 的 digest 失败: %w", result.Destination, destDigests[i].Error)
+		case result.SourceDigest != "" && result.SourceDigest == result.DestinationDigest:
+			result.Action = ActionUpToDate
+		default:
+			result.Action = ActionCopy
+		}
+		results[i] = result
+	}
+	return results
+}
+
+// digests 获取镜像的 digest：registry 中的镜像通过批量 HEAD 请求获取，其他通过 ResolveImageDigest 并发获取
+func (s *Syncer) digests(ctx context.Context, refs []registry.ImageReference) []registry.DigestResult {
+	results := make([]registry.DigestResult, len(refs))
+	var specs []registry.ImageSpec
+	var specIndexes, others []int
+	for i, ref := range refs {
+		if ref.Transport == registry.TransportRegistry {
+			specs = append(specs, registry.ImageSpec{Image: ref.Name, Tag: ref.Tag})
+			specIndexes = append(specIndexes, i)
+		} else {
+			others = append(others, i)
+		}
+	}
+	if len(specs) > 0 {
+		batched := s.client.GetDigests(ctx, specs, registry.BatchOptions{Concurrency: s.opts.Concurrency, BatchAuth: true})
+		for j, i := range specIndexes {
+			results[i] = batched[j]
+		}
+	}
+
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < s.opts.Concurrency && w < len(others); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				digest, err := s.client.ResolveImageDigest(ctx, refs[i])
+				results[i] = registry.DigestResult{Image: refs[i].Name, Tag: refs[i].Tag, Digest: digest, Error: err}
+			}
+		}()
+	}
+	for _, i := range others {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	return results
+}
+
+// copyAll 并发复制 pending 中的标签，将结果写回 results
+func (s *Syncer) copyAll(ctx context.Context, items []syncItem, results []Result, pending []int) {
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < s.opts.Concurrency && w < len(pending); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				s.copyItem(ctx, items[i], &results[i])
+				s.notify(results[i])
+			}
+		}()
+	}
+	for _, i := range pending {
+		if ctx.Err() != nil {
+			results[i].Action, results[i].Error = ActionFailed, ctx.Err()
+			s.notify(results[i])
+			continue
+		}
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+}
+
+// copyItem 复制单个标签
+// 源为 registry 时按比较时的源 digest 复制，避免源标签在比较之后变化导致复制的内容与报告不一致
+func (s *Syncer) copyItem(ctx context.Context, item syncItem, result *Result) {
+	source := item.source
+	if source.Transport == registry.TransportRegistry && result.SourceDigest != "" {
+		source.Tag = result.SourceDigest
+	}
+	copied, err := s.client.CopyImageReference(ctx, source, item.destination, registry.CopyOptions{})
+	if err != nil {
+		result.Action, result.Error = ActionFailed, fmt.Errorf("复制 %s 到 %s 失败: %w", result.Source, result.Destination, err)
+		return
+	}
+	result.Copy = copied
+}
+
+// addResult 添加结果并调用 OnResult
+func (s *Syncer) addResult(report *Report, result Result) {
+	report.Results = append(report.Results, result)
+	s.notify(result)
+}
+
+// notify 调用 OnResult
+func (s *Syncer) notify(result Result) {
+	if s.opts.OnResult != nil {
+		s.opts.OnResult(result)
+	}
+}
+
+// tagFilter 表示映射的标签过滤条件
+type tagFilter struct {
+	include []string
+	exclude []string
+	regex   *regexp.Regexp
+}
+
+// newTagFilter 校验映射并编译标签过滤条件
+func newTagFilter(mapping *Mapping) (*tagFilter, error) {
+	if mapping.Source == "" || mapping.Destination == "" {
+		return nil, fmt.Errorf("同步映射缺少源或目标: %q → %q", mapping.Source, mapping.Destination)
+	}
+	filter := &tagFilter{include: mapping.Tags, exclude: mapping.ExcludeTags}
+	for _, pattern := range append(append([]string(nil), mapping.Tags...), mapping.ExcludeTags...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s 的标签模式 '%s' 无效: %w", mapping.Source, pattern, err)
+		}
+	}
+	if mapping.TagRegex != "" {
+		regex, err := regexp.Compile(mapping.TagRegex)
+		if err != nil {
+			return nil, fmt.Errorf("%s 的标签正则表达式无效: %w", mapping.Source, err)
+		}
+		filter.regex = regex
+	}
+	return filter, nil
+}
+
+// literalTags 在过滤条件只包含不含通配符的标签时返回这些标签，否则返回 nil
+func (f *tagFilter) literalTags() []string {
+	if len(f.include) == 0 {
+		return nil
+	}
+	for _, pattern := range f.include {
+		if strings.ContainsAny(pattern, `*?[\`) {
+			return nil
+		}
+	}
+	tags := []string{}
+	for _, tag := range f.include {
+		if f.match(tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// match 判断标签是否符合过滤条件
+func (f *tagFilter) match(tag string) bool {
+	for _, pattern := range f.exclude {
+		if ok, _ := path.Match(pattern, tag); ok {
+			return false
+		}
+	}
+	if f.regex != nil && !f.regex.MatchString(tag) {
+		return false
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, pattern := range f.include {
+		if ok, _ := path.Match(pattern, tag); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// writeTestLayout 通过 docker-archive 在 OCI 布局目录中写入 tags 指定的镜像，每个标签的层内容不同
+func writeTestLayout(t *testing.T, dir string, tags ...string) {
+	t.Helper()
+	ctx := context.Background()
+	client := registry.NewClient()
+	for _, tag := range tags {
+		archive := filepath.Join(t.TempDir(), tag+".tar")
+		destination, err := registry.NewDockerArchiveDestination(archive, "app:"+tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		put := func(mediaType string, content []byte) registry.Descriptor {
+			desc := registry.Descriptor{MediaType: mediaType, Digest: sha256Digest(content), Size: int64(len(content))}
+			if err := destination.PutBlob(ctx, desc, bytes.NewReader(content)); err != nil {
+				t.Fatal(err)
+			}
+			return desc
+		}
+		config := put(registry.MediaTypeOCIConfig, []byte(`{"architecture":"amd64","os":"linux"}`))
+		layer := put(registry.MediaTypeOCILayer, []byte("layer-"+tag))
+		body, _ := json.Marshal(registry.ImageManifest{
+			SchemaVersion: 2,
+			MediaType:     registry.MediaTypeOCIManifest,
+			Config:        config,
+			Layers:        []registry.Descriptor{layer},
+		})
+		root := registry.Descriptor{MediaType: registry.MediaTypeOCIManifest, Digest: sha256Digest(body), Size: int64(len(body))}
+		if err := destination.PutManifest(ctx, root, body, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := destination.Commit(ctx); err != nil {
+			t.Fatal(err)
+		}
+		destination.Close()
+
+		src := registry.ImageReference{Transport: registry.TransportDockerArchive, Name: archive}
+		dst := registry.ImageReference{Transport: registry.TransportOCI, Name: dir, Tag: tag}
+		if _, err := client.CopyImageReference(ctx, src, dst, registry.CopyOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSyncBetweenLayouts(t *testing.T) {
+	source := t.TempDir()
+	writeTestLayout(t, source, "v1", "v2", "v3")
+	destination := filepath.Join(t.TempDir(), "mirror")
+	mappings := []Mapping{{Source: "oci:" + source, Destination: "oci:" + destination, Tags: []string{"v1", "v2", "v3"}}}
+
+	syncer := New(registry.NewClient(), Options{Concurrency: 3})
+	report, err := syncer.Run(context.Background(), mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := report.Count(ActionCopy); n != 3 {
+		t.Errorf("复制 %d 个, 期望 3: %v", n, report.Errors())
+	}
+	if report.Results[0].Source != "oci:"+source+":v1" {
+		t.Errorf("Source = %s, 期望 oci:%s:v1", report.Results[0].Source, source)
+	}
+
+	// 并发复制到同一布局目录后 index.json 中有全部标签，再次同步时都已是最新
+	report, err = syncer.Run(context.Background(), mappings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := report.Count(ActionUpToDate); n != 3 {
+		t.Errorf("已是最新 %d 个, 期望 3: %+v", n, report.Results)
+	}
+}
+
+func TestSyncRejectsUnsupportedMappings(t *testing.T) {
+	tests := []struct {
+		mapping Mapping
+		want    string
+	}{
+		{Mapping{Source: "oci:/data/layout", Destination: "registry.example.com/app"}, "无法列出标签"},
+		{Mapping{Source: "oci:/data/layout", Destination: "registry.example.com/app", Tags: []string{"v*"}}, "无法列出标签"},
+		{Mapping{Source: "nginx", Destination: "docker-archive:/tmp/nginx.tar", Tags: []string{"1.27"}}, "docker-archive"},
+		{Mapping{Source: "nginx:1.27", Destination: "registry.example.com/nginx"}, "不能带标签"},
+	}
+	for _, tt := range tests {
+		_, err := New(registry.NewClient(), Options{}).Run(context.Background(), []Mapping{tt.mapping})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Run(%+v) 错误 = %v, 期望包含 %q", tt.mapping, err, tt.want)
+		}
+	}
+}
+
+func sha256Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
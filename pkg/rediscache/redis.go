@@ -0,0 +1,342 @@
+// Package rediscache 提供基于 Redis 的 registry.Cache 实现
+// 多个服务实例连接同一个 Redis 时，可以共享认证 token 和 manifest 缓存
+//
+// 使用方式：
+//
+//	cache := rediscache.New(rediscache.Options{Addr: "127.0.0.1:6379", Prefix: "docker-manifest:"})
+//	defer cache.Close()
+//	client := registry.NewClient().WithCache(cache)
+//
+// 只使用 GET、SET PX 和 DEL 命令，通过 RESP 协议直接通信，不依赖第三方 Redis 客户端
+package rediscache
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+)
+
+// 默认配置
+const (
+	DefaultDialTimeout = 5 * time.Second
+	DefaultIOTimeout   = 3 * time.Second
+	DefaultMaxIdle     = 4
+)
+
+// Options 表示 Redis 连接配置
+type Options struct {
+	Addr        string        // 地址，如 127.0.0.1:6379
+	Username    string        // ACL 用户名（Redis 6+），为空时只使用 Password 认证
+	Password    string        // 密码，为空时不认证
+	DB          int           // 数据库编号
+	Prefix      string        // 所有 key 的前缀，用于与其他应用隔离
+	TLSConfig   *tls.Config   // 不为 nil 时使用 TLS 连接
+	DialTimeout time.Duration // 连接超时，0 使用 DefaultDialTimeout
+	IOTimeout   time.Duration // 单个命令的读写超时，0 使用 DefaultIOTimeout
+	MaxIdle     int           // 最多保留的空闲连接数，0 使用 DefaultMaxIdle
+}
+
+// Cache 是基于 Redis 的 registry.Cache 实现
+// Redis 不可用时 Get 返回未命中，Set 和 Delete 静默失败，不影响请求流程；
+// 错误可通过 OnError 记录
+type Cache struct {
+	opts Options
+
+	// OnError 在命令执行失败时调用，可用于记录日志；为 nil 时忽略错误
+	OnError func(err error)
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// 编译期检查 Cache 实现了 registry.Cache 接口
+var _ registry.Cache = (*Cache)(nil)
+
+// errNil 表示 Redis 返回了 nil（key 不存在）
+var errNil = errors.New("redis: nil")
+
+// New 创建 Redis 缓存，连接在第一次使用时建立
+func New(opts Options) *Cache {
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DefaultDialTimeout
+	}
+	if opts.IOTimeout <= 0 {
+		opts.IOTimeout = DefaultIOTimeout
+	}
+	if opts.MaxIdle <= 0 {
+		opts.MaxIdle = DefaultMaxIdle
+	}
+	return &Cache{opts: opts}
+}
+
+// Get 实现 registry.Cache 接口
+func (c *Cache) Get(key string) ([]byte, bool) {
+	reply, err := c.do("GET", c.opts.Prefix+key)
+	if err != nil {
+		if !errors.Is(err, errNil) {
+			c.reportError(err)
+		}
+		return nil, false
+	}
+	value, ok := reply.([]byte)
+	return value, ok
+}
+
+// Set 实现 registry.Cache 接口
+func (c *Cache) Set(key string, value []byte, ttl time.Duration) {
+	args := []string{"SET", c.opts.Prefix + key, string(value)}
+	if ttl > 0 {
+		ms := ttl.Milliseconds()
+		if ms < 1 {
+			ms = 1
+		}
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+	if _, err := c.do(args...); err != nil {
+		c.reportError(err)
+	}
+}
+
+// Delete 实现 registry.Cache 接口
+func (c *Cache) Delete(key string) {
+	if _, err := c.do("DEL", c.opts.Prefix+key); err != nil {
+		c.reportError(err)
+	}
+}
+
+// Ping 检查 Redis 是否可用
+func (c *Cache) Ping(ctx context.Context) error {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = cn.command(c.opts.IOTimeout, "PING")
+	c.put(cn, err)
+	return err
+}
+
+// Close 关闭所有空闲连接，之后的命令返回错误
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, cn := range c.idle {
+		cn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+// reportError 调用 OnError 记录错误
+func (c *Cache) reportError(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// do 从连接池获取连接并执行命令
+func (c *Cache) do(args ...string) (interface{}, error) {
+	cn, err := c.get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	reply, err := cn.command(c.opts.IOTimeout, args...)
+	c.put(cn, err)
+	return reply, err
+}
+
+// get 取出一个空闲连接，没有时新建连接
+func (c *Cache) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errors.New("redis: 缓存已关闭")
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+	return c.dial(ctx)
+}
+
+// put 归还连接；命令出现网络或协议错误时关闭连接，避免复用状态不确定的连接
+func (c *Cache) put(cn *conn, err error) {
+	var replyErr redisError
+	if err != nil && !errors.Is(err, errNil) && !errors.As(err, &replyErr) {
+		cn.Close()
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle) >= c.opts.MaxIdle {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+// dial 建立新连接，并按配置认证和选择数据库
+func (c *Cache) dial(ctx context.Context) (*conn, error) {
+	dialer := &net.Dialer{Timeout: c.opts.DialTimeout}
+	var netConn net.Conn
+	var err error
+	if c.opts.TLSConfig != nil {
+		netConn, err = (&tls.Dialer{NetDialer: dialer, Config: c.opts.TLSConfig}).DialContext(ctx, "tcp", c.opts.Addr)
+	} else {
+		netConn, err = dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: 连接 %s 失败: %w", c.opts.Addr, err)
+	}
+
+	cn := &conn{Conn: netConn, reader: bufio.NewReader(netConn)}
+	if c.opts.Password != "" {
+		args := []string{"AUTH", c.opts.Password}
+		if c.opts.Username != "" {
+			args = []string{"AUTH", c.opts.Username, c.opts.Password}
+		}
+		if _, err := cn.command(c.opts.IOTimeout, args...); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis: 认证失败: %w", err)
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := cn.command(c.opts.IOTimeout, "SELECT", strconv.Itoa(c.opts.DB)); err != nil {
+			cn.Close()
+			return nil, fmt.Errorf("redis: 选择数据库失败: %w", err)
+		}
+	}
+	return cn, nil
+}
+
+// conn 表示一个 Redis 连接
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// redisError 表示 Redis 返回的错误回复，连接仍可继续使用
+type redisError string
+
+// Error 实现 error 接口
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// command 发送命令并读取回复
+func (cn *conn) command(timeout time.Duration, args ...string) (interface{}, error) {
+	if err := cn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := cn.Write(encodeCommand(args)); err != nil {
+		return nil, err
+	}
+	return readReply(cn.reader)
+}
+
+// encodeCommand 将命令编码为 RESP 数组
+func encodeCommand(args []string) []byte {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// readReply 读取一个 RESP 回复
+// 简单字符串返回 string，整数返回 int64，批量字符串返回 []byte，数组返回 []interface{}
+// nil 回复返回 errNil，错误回复返回 redisError；数组中的 nil 元素为 nil，含有错误元素时返回第一个错误
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: 空回复")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: 无效的长度 %q", line)
+		}
+		if n < 0 {
+			return nil, errNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: 无效的长度 %q", line)
+		}
+		if n < 0 {
+			return nil, errNil
+		}
+		// 元素为错误回复时仍读完其余元素，否则未读的回复会留在连接中，被之后的命令当作自己的回复
+		items := make([]interface{}, n)
+		var replyErr error
+		for i := range items {
+			item, err := readReply(r)
+			var itemErr redisError
+			switch {
+			case err == nil:
+				items[i] = item
+			case errors.Is(err, errNil):
+			case errors.As(err, &itemErr):
+				if replyErr == nil {
+					replyErr = err
+				}
+			default:
+				return nil, err
+			}
+		}
+		if replyErr != nil {
+			return nil, replyErr
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: 无法识别的回复 %q", line)
+}
+
+// readLine 读取以 \r\n 结尾的一行，不包含行尾
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: 无效的行 %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,276 @@
+package rediscache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis 是测试用的 Redis 服务，按 reply 返回每个命令的 RESP 回复，并记录收到的命令
+type fakeRedis struct {
+	addr string
+
+	mu       sync.Mutex
+	data     map[string]string
+	commands []string // 收到的命令，格式为 "<连接序号> <参数...>"
+	conns    int      // 已接受的连接数
+
+	// reply 不为 nil 时替代默认的处理，返回空字符串时使用默认的处理
+	reply func(args []string) string
+}
+
+// newFakeRedis 启动 fakeRedis，默认支持 AUTH、SELECT、PING、GET、SET 和 DEL
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{addr: ln.Addr().String(), data: make(map[string]string)}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns++
+			id := f.conns
+			f.mu.Unlock()
+			go f.serve(c, id)
+		}
+	}()
+	return f
+}
+
+// serve 处理一个连接上的命令
+func (f *fakeRedis) serve(c net.Conn, id int) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		request, err := readReply(r)
+		if err != nil {
+			return
+		}
+		items := request.([]interface{})
+		args := make([]string, len(items))
+		for i, item := range items {
+			args[i] = string(item.([]byte))
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, strconv.Itoa(id)+" "+strings.Join(args, " "))
+		reply := ""
+		if f.reply != nil {
+			reply = f.reply(args)
+		}
+		if reply == "" {
+			reply = f.handle(args)
+		}
+		f.mu.Unlock()
+		if _, err := io.WriteString(c, reply); err != nil {
+			return
+		}
+	}
+}
+
+// handle 执行默认的命令处理，调用时持有 f.mu
+func (f *fakeRedis) handle(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		value, ok := f.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return "$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n"
+	case "SET":
+		f.data[args[1]] = args[2]
+		return "+OK\r\n"
+	case "DEL":
+		if _, ok := f.data[args[1]]; !ok {
+			return ":0\r\n"
+		}
+		delete(f.data, args[1])
+		return ":1\r\n"
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+// connCount 返回已接受的连接数
+func (f *fakeRedis) connCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.conns
+}
+
+// received 返回收到的命令
+func (f *fakeRedis) received() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+func TestCacheGetSetDelete(t *testing.T) {
+	redis := newFakeRedis(t)
+	cache := New(Options{Addr: redis.addr, Prefix: "dm:"})
+	defer cache.Close()
+	var errs []error
+	cache.OnError = func(err error) { errs = append(errs, err) }
+
+	// 未命中（$-1）不是错误
+	if value, ok := cache.Get("token"); ok || value != nil {
+		t.Errorf("Get = %q, %v, 期望未命中", value, ok)
+	}
+
+	// 值中可以包含 \r\n
+	cache.Set("token", []byte("line1\r\nline2"), 1500*time.Millisecond)
+	if value, ok := cache.Get("token"); !ok || string(value) != "line1\r\nline2" {
+		t.Errorf("Get = %q, %v, 期望命中", value, ok)
+	}
+	cache.Set("manifest", []byte("{}"), 0)
+	cache.Delete("token")
+	if _, ok := cache.Get("token"); ok {
+		t.Error("Delete 之后 Get 应未命中")
+	}
+	if len(errs) != 0 {
+		t.Errorf("OnError 收到 %v, 期望没有错误", errs)
+	}
+
+	want := []string{
+		"1 GET dm:token",
+		"1 SET dm:token line1\r\nline2 PX 1500",
+		"1 GET dm:token",
+		"1 SET dm:manifest {}",
+		"1 DEL dm:token",
+		"1 GET dm:token",
+	}
+	if got := redis.received(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("命令 = %q, 期望 %q（复用同一个连接）", got, want)
+	}
+}
+
+func TestCacheSetShortTTL(t *testing.T) {
+	redis := newFakeRedis(t)
+	cache := New(Options{Addr: redis.addr})
+	defer cache.Close()
+
+	// 不足 1 毫秒的 TTL 按 1 毫秒设置，避免 PX 0 被 Redis 拒绝
+	cache.Set("k", []byte("v"), time.Microsecond)
+	if got := redis.received(); len(got) != 1 || got[0] != "1 SET k v PX 1" {
+		t.Errorf("命令 = %q, 期望 SET k v PX 1", got)
+	}
+}
+
+func TestCacheDialAuthSelect(t *testing.T) {
+	redis := newFakeRedis(t)
+	cache := New(Options{Addr: redis.addr, Username: "app", Password: "secret", DB: 2})
+	defer cache.Close()
+	if err := cache.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1 AUTH app secret", "1 SELECT 2", "1 PING"}
+	if got := redis.received(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("命令 = %q, 期望 %q", got, want)
+	}
+
+	// 只有密码时使用单参数的 AUTH
+	redis = newFakeRedis(t)
+	cache = New(Options{Addr: redis.addr, Password: "secret"})
+	defer cache.Close()
+	if err := cache.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := redis.received(); len(got) != 2 || got[0] != "1 AUTH secret" {
+		t.Errorf("命令 = %q, 期望 AUTH secret 和 PING", got)
+	}
+
+	// 认证失败时连接被关闭，不放入连接池
+	redis = newFakeRedis(t)
+	redis.reply = func(args []string) string {
+		if args[0] == "AUTH" {
+			return "-WRONGPASS invalid username-password pair\r\n"
+		}
+		return ""
+	}
+	cache = New(Options{Addr: redis.addr, Password: "wrong"})
+	defer cache.Close()
+	err := cache.Ping(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Ping 错误 = %v, 期望认证失败", err)
+	}
+	cache.Ping(context.Background())
+	if n := redis.connCount(); n != 2 {
+		t.Errorf("建立了 %d 个连接, 期望认证失败后每次重新连接", n)
+	}
+}
+
+func TestCacheErrorReply(t *testing.T) {
+	redis := newFakeRedis(t)
+	redis.reply = func(args []string) string {
+		if args[0] == "GET" && args[1] == "broken" {
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+		return ""
+	}
+	cache := New(Options{Addr: redis.addr})
+	defer cache.Close()
+	var errs []error
+	cache.OnError = func(err error) { errs = append(errs, err) }
+
+	if _, ok := cache.Get("broken"); ok {
+		t.Error("错误回复时 Get 应未命中")
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "WRONGTYPE") {
+		t.Errorf("OnError 收到 %v, 期望 WRONGTYPE 错误", errs)
+	}
+
+	// 错误回复后连接仍可使用
+	cache.Set("k", []byte("v"), 0)
+	if value, ok := cache.Get("k"); !ok || string(value) != "v" {
+		t.Errorf("Get = %q, %v, 期望命中", value, ok)
+	}
+	if n := redis.connCount(); n != 1 {
+		t.Errorf("建立了 %d 个连接, 期望错误回复后复用连接", n)
+	}
+}
+
+func TestReadReplyArrayError(t *testing.T) {
+	// 数组中间的错误元素之后的元素也被读取，下一个回复不受影响
+	r := bufio.NewReader(strings.NewReader("*4\r\n$1\r\na\r\n-ERR bad\r\n$-1\r\n$1\r\nb\r\n+OK\r\n"))
+	_, err := readReply(r)
+	var replyErr redisError
+	if !errors.As(err, &replyErr) || string(replyErr) != "ERR bad" {
+		t.Fatalf("错误 = %v, 期望 ERR bad", err)
+	}
+	if reply, err := readReply(r); err != nil || reply != "OK" {
+		t.Errorf("下一个回复 = %v, %v, 期望 OK", reply, err)
+	}
+
+	r = bufio.NewReader(strings.NewReader("*3\r\n:1\r\n$-1\r\n*1\r\n+x\r\n"))
+	reply, err := readReply(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := reply.([]interface{})
+	if len(items) != 3 || items[0] != int64(1) || items[1] != nil {
+		t.Errorf("回复 = %#v, 期望 [1 nil [x]]", items)
+	}
+
+	for _, input := range []string{"", "?\r\n", "$x\r\n", "+OK\n", "$5\r\nab\r\n"} {
+		if _, err := readReply(bufio.NewReader(strings.NewReader(input))); err == nil {
+			t.Errorf("readReply(%q) 应返回错误", input)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package registry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AgePolicy 表示按镜像创建时间（配置 blob 的 created 字段）检查镜像的策略
+// 用于在 CI 中拦截长期未维护（超过 MaxAge）或刚发布（不足 MinAge）的镜像
+type AgePolicy struct {
+	MaxAge time.Duration // 创建时间距今超过 MaxAge 时违规，0 表示不检查
+	MinAge time.Duration // 创建时间距今不足 MinAge 时违规，0 表示不检查
+}
+
+// Enabled 判断是否设置了任何检查
+func (p AgePolicy) Enabled() bool {
+	return p.MaxAge > 0 || p.MinAge > 0
+}
+
+// AgeViolation 表示不符合 AgePolicy 的镜像
+type AgeViolation struct {
+	Image   string        `json:"image"`
+	Tag     string        `json:"tag"`
+	Digest  string        `json:"digest,omitempty"`
+	Created *time.Time    `json:"created,omitempty"` // 镜像创建时间，无法获取时为 nil
+	Age     time.Duration `json:"-"`                 // 镜像年龄，无法获取创建时间时为 0
+	Reason  string        `json:"reason"`            // 违规原因
+}
+
+// String 返回可读的违规描述
+func (v AgeViolation) String() string {
+	return fmt.Sprintf("%s:%s: %s", v.Image, v.Tag, v.Reason)
+}
+
+// Check 检查镜像的创建时间，返回不符合策略的镜像，顺序与 results 一致
+// now 为计算镜像年龄使用的当前时间；获取配置失败或配置中没有创建时间的镜像无法确认是否符合策略，同样视为违规
+func (p AgePolicy) Check(results []ConfigResult, now time.Time) []AgeViolation {
+	if !p.Enabled() {
+		return nil
+	}
+
+	var violations []AgeViolation
+	for _, result := range results {
+		violation := AgeViolation{Image: result.Image, Tag: result.Tag, Digest: result.Digest}
+		switch {
+		case result.Error != nil:
+			violation.Reason = fmt.Sprintf("无法获取创建时间: %v", result.Error)
+		case result.Config == nil || result.Config.Created == nil || result.Config.Created.IsZero():
+			violation.Reason = "镜像配置中没有创建时间"
+		default:
+			created := *result.Config.Created
+			age := now.Sub(created)
+			violation.Created = &created
+			violation.Age = age
+			switch {
+			case p.MaxAge > 0 && age > p.MaxAge:
+				violation.Reason = fmt.Sprintf("镜像已创建 %s，超过最大年龄 %s", FormatAge(age), FormatAge(p.MaxAge))
+			case p.MinAge > 0 && age < p.MinAge:
+				violation.Reason = fmt.Sprintf("镜像仅创建 %s，不足最小年龄 %s", FormatAge(age), FormatAge(p.MinAge))
+			default:
+				continue
+			}
+		}
+		violations = append(violations, violation)
+	}
+	return violations
+}
+
+// ParseAge 解析镜像年龄，除 time.ParseDuration 支持的格式外，还支持以 d（天）和 w（周）为单位的整数
+// 例如 "90d"、"2w"、"36h"、"1h30m"
+func ParseAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if number, ok := strings.CutSuffix(value, suffix); ok {
+			n, err := strconv.Atoi(number)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("无效的年龄 '%s'，应为 90d、2w、36h 等格式", value)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("无效的年龄 '%s'，应为 90d、2w、36h 等格式", value)
+	}
+	return d, nil
+}
+
+// FormatAge 将年龄格式化为可读的形式，超过一天时以天和小时表示，如 "97d3h"
+func FormatAge(d time.Duration) string {
+	if d < 24*time.Hour {
+		return d.Truncate(time.Second).String()
+	}
+	days := d / (24 * time.Hour)
+	hours := (d % (24 * time.Hour)) / time.Hour
+	if hours == 0 {
+		return fmt.Sprintf("%dd", days)
+	}
+	return fmt.Sprintf("%dd%dh", days, hours)
+}
@@ -0,0 +1,64 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 同步镜像时记录来源的 annotation key
+const (
+	AnnotationSyncSource       = "io.github.docker-make.sync.source"        // 上游镜像引用，如 docker.io/library/nginx:1.25
+	AnnotationSyncSourceDigest = "io.github.docker-make.sync.source-digest" // 上游 manifest digest
+	AnnotationSyncTime         = "io.github.docker-make.sync.time"          // 同步时间（RFC 3339，UTC）
+)
+
+// SourceAnnotations 构建记录镜像来源的 annotations，用于同步或复制到目标 registry 时注入
+// sourceDigest 为空时不记录 digest
+func SourceAnnotations(sourceRef, sourceDigest string, syncedAt time.Time) map[string]string {
+	annotations := map[string]string{
+		AnnotationSyncSource: sourceRef,
+		AnnotationSyncTime:   syncedAt.UTC().Format(time.RFC3339),
+	}
+	if sourceDigest != "" {
+		annotations[AnnotationSyncSourceDigest] = sourceDigest
+	}
+	return annotations
+}
+
+// AnnotateManifest 向 manifest 顶层的 annotations 中合并指定的 annotations，返回新的 manifest 内容
+// 已存在的同名 annotation 会被覆盖，其他字段（包括未知字段）原样保留
+// 仅支持 OCI image manifest 和 OCI image index，Docker v2 格式不支持 annotations
+// 注意：修改后的 manifest digest 会改变，需要重新推送
+func AnnotateManifest(manifest []byte, mediaType string, annotations map[string]string) ([]byte, error) {
+	if mediaType == "" {
+		mediaType = detectManifestMediaType("", manifest)
+	}
+	if mediaType != MediaTypeOCIManifest && mediaType != MediaTypeOCIIndex {
+		return nil, fmt.Errorf("媒体类型 %s 不支持 annotations", mediaType)
+	}
+
+	// 使用 RawMessage 保留未知字段和原有格式
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(manifest, &fields); err != nil {
+		return nil, fmt.Errorf("解析 manifest 失败: %w", err)
+	}
+
+	merged := make(map[string]string)
+	if raw, ok := fields["annotations"]; ok {
+		if err := json.Unmarshal(raw, &merged); err != nil {
+			return nil, fmt.Errorf("解析 manifest annotations 失败: %w", err)
+		}
+	}
+	for key, value := range annotations {
+		merged[key] = value
+	}
+
+	raw, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	fields["annotations"] = raw
+
+	return json.Marshal(fields)
+}
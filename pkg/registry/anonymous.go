@@ -0,0 +1,68 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// WithAnonymousFallback 设置凭据被拒绝时是否回退为匿名访问，默认不回退
+// 启用后，配置的凭据在认证服务返回 401（如 PAT 已过期或被撤销）时，不带凭据重新获取 token，
+// 公开镜像仍可正常获取；ManifestResult.Warnings 中会记录回退的原因，同时输出 Warn 日志
+// 匿名 token 同样被拒绝时返回原来的认证错误
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithAnonymousFallback(enabled bool) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.anonymousFallback = enabled
+	return c
+}
+
+// getAnonymousFallback 返回凭据被拒绝时是否回退为匿名访问
+func (c *Client) getAnonymousFallback() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.anonymousFallback
+}
+
+// authorizeAnonymously 凭据被认证服务拒绝（401）且启用了匿名回退时，不带凭据重新获取 pull 权限的 token
+// 成功时 target 改为匿名访问（后续重新认证同样不带凭据），并记录被拒绝的凭据 key
+func (c *Client) authorizeAnonymously(ctx context.Context, target *registryTarget, err error) (string, bool) {
+	if !c.getAnonymousFallback() || errorStatusCode(err) != http.StatusUnauthorized {
+		return "", false
+	}
+	ctx = target.context(ctx)
+	if _, ok := c.basicAuthHeader(ctx, target.credentialKey); !ok {
+		return "", false // 没有使用凭据，匿名访问的结果相同
+	}
+
+	var authorization string
+	var anonErr error
+	if target.challengeAuth {
+		authorization, anonErr = c.getAuthorizationViaWWWAuthenticate(ctx, target.registryKey, target.registryURL, []string{pullScope(target.repository)}, "")
+	} else {
+		var token string
+		token, anonErr = c.getAuthToken(ctx, target.image, target.registryKey, "")
+		authorization = "Bearer " + token
+	}
+	if anonErr != nil {
+		c.logger.Debug("匿名获取 token 失败", "image", target.image, "error", anonErr)
+		return "", false
+	}
+
+	c.logger.Warn("凭据被认证服务拒绝，已回退为匿名访问",
+		"image", target.image,
+		"credentialKey", target.credentialKey,
+		"error", err)
+	target.rejectedCredential = target.credentialKey
+	target.credentialKey = ""
+	return authorization, true
+}
+
+// anonymousWarning 返回回退为匿名访问时记录到结果中的警告，未回退时返回空字符串
+func (t *registryTarget) anonymousWarning() string {
+	if t.rejectedCredential == "" {
+		return ""
+	}
+	return fmt.Sprintf("凭据 %s 被认证服务拒绝 (状态码: 401)，已回退为匿名访问", t.rejectedCredential)
+}
@@ -0,0 +1,437 @@
+package registry
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// docker-archive 中层的媒体类型：docker save 输出未压缩的 tar，containerd 镜像存储可能保留压缩后的层
+const (
+	MediaTypeOCIConfig        = "application/vnd.oci.image.config.v1+json"
+	MediaTypeOCILayer         = "application/vnd.oci.image.layer.v1.tar"
+	MediaTypeOCILayerGzip     = "application/vnd.oci.image.layer.v1.tar+gzip"
+	MediaTypeOCILayerZstd     = "application/vnd.oci.image.layer.v1.tar+zstd"
+	dockerArchiveManifestFile = "manifest.json"
+)
+
+// dockerArchiveTransport 是内置的 docker-archive 传输方式，读写 docker save 格式的 tar 文件
+// 镜像引用中的名称为文件路径，标签为归档中的镜像引用（如 nginx:1.27）
+type dockerArchiveTransport struct{}
+
+// OpenSource 实现 ImageTransport 接口
+func (dockerArchiveTransport) OpenSource(ctx context.Context, path, reference string) (ImageSource, error) {
+	return NewDockerArchiveSource(path, reference)
+}
+
+// OpenDestination 实现 ImageTransport 接口
+func (dockerArchiveTransport) OpenDestination(ctx context.Context, path, reference string) (ImageDestination, error) {
+	return NewDockerArchiveDestination(path, reference)
+}
+
+// dockerArchiveEntry 表示 docker save 归档中 manifest.json 的单个镜像
+type dockerArchiveEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// archiveFile 表示归档中的单个文件在 tar 文件中的位置
+type archiveFile struct {
+	offset int64
+	size   int64
+}
+
+// dockerArchiveSource 表示 docker save 归档中作为复制源的镜像
+// 归档中只有配置和各层的 tar，根据 manifest.json 生成 OCI manifest
+type dockerArchiveSource struct {
+	file     *os.File
+	root     Descriptor
+	manifest []byte
+	blobs    map[string]archiveFile // digest -> 归档中的文件
+}
+
+// NewDockerArchiveSource 打开 docker save 格式的 tar 文件作为复制源
+// reference 为归档中的镜像引用（如 nginx:1.27 或只有标签 1.27），为空时归档中必须只有一个镜像
+// 层的 digest 需要读取整个归档计算（docker save 较新的版本以 blobs/sha256/<digest> 命名时直接使用文件名）
+func NewDockerArchiveSource(path, reference string) (ImageSource, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开 docker-archive 失败: %w", err)
+	}
+	source, err := readDockerArchive(file, reference)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("读取 docker-archive %s 失败: %w", path, err)
+	}
+	return source, nil
+}
+
+// readDockerArchive 索引归档中的文件，选择镜像并生成其 manifest
+func readDockerArchive(file *os.File, reference string) (*dockerArchiveSource, error) {
+	files := make(map[string]archiveFile)
+	reader := tar.NewReader(file)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		// tar.Reader 不缓冲，Next 返回后文件位置即为内容的起始位置
+		offset, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(header.Name)] = archiveFile{offset: offset, size: header.Size}
+	}
+
+	manifestFile, ok := files[dockerArchiveManifestFile]
+	if !ok {
+		return nil, fmt.Errorf("归档中没有 %s，不是 docker save 格式", dockerArchiveManifestFile)
+	}
+	if manifestFile.size > MaxManifestSize {
+		return nil, fmt.Errorf("%s 超过大小限制", dockerArchiveManifestFile)
+	}
+	data, err := io.ReadAll(io.NewSectionReader(file, manifestFile.offset, manifestFile.size))
+	if err != nil {
+		return nil, err
+	}
+	var entries []dockerArchiveEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", dockerArchiveManifestFile, err)
+	}
+	entry, err := selectArchiveEntry(entries, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &dockerArchiveSource{file: file, blobs: make(map[string]archiveFile)}
+	describe := func(name, mediaType string) (Descriptor, error) {
+		located, ok := files[path.Clean(name)]
+		if !ok {
+			return Descriptor{}, fmt.Errorf("归档中没有 %s", name)
+		}
+		desc, err := describeArchiveFile(file, name, located, mediaType)
+		if err != nil {
+			return Descriptor{}, err
+		}
+		source.blobs[desc.Digest] = located
+		return desc, nil
+	}
+
+	manifest := ImageManifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest}
+	if manifest.Config, err = describe(entry.Config, MediaTypeOCIConfig); err != nil {
+		return nil, err
+	}
+	for _, layer := range entry.Layers {
+		desc, err := describe(layer, "")
+		if err != nil {
+			return nil, err
+		}
+		manifest.Layers = append(manifest.Layers, desc)
+	}
+	if source.manifest, err = json.Marshal(manifest); err != nil {
+		return nil, err
+	}
+	source.root = Descriptor{MediaType: MediaTypeOCIManifest, Digest: computeDigest(source.manifest), Size: int64(len(source.manifest))}
+	return source, nil
+}
+
+// selectArchiveEntry 按镜像引用选择归档中的镜像，先比较规范化后的完整引用，再只比较标签
+func selectArchiveEntry(entries []dockerArchiveEntry, reference string) (*dockerArchiveEntry, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: 归档中没有镜像", ErrNotFound)
+	}
+	if reference == "" {
+		if len(entries) > 1 {
+			return nil, fmt.Errorf("归档中有 %d 个镜像，需要指定镜像引用 (%s)", len(entries), archiveRepoTags(entries))
+		}
+		return &entries[0], nil
+	}
+	want := canonicalTaggedName(reference)
+	for i := range entries {
+		for _, repoTag := range entries[i].RepoTags {
+			if canonicalTaggedName(repoTag) == want {
+				return &entries[i], nil
+			}
+		}
+	}
+	if !strings.ContainsAny(reference, ":/") {
+		for i := range entries {
+			for _, repoTag := range entries[i].RepoTags {
+				if _, tag, ok := splitImageTag(repoTag); ok && tag == reference {
+					return &entries[i], nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: 归档中没有镜像 '%s' (%s)", ErrNotFound, reference, archiveRepoTags(entries))
+}
+
+// canonicalTaggedName 返回镜像引用的规范形式，未指定标签时为 latest
+func canonicalTaggedName(reference string) string {
+	name, tag, ok := splitImageTag(reference)
+	if !ok {
+		tag = "latest"
+	}
+	return CanonicalImageName(name) + ":" + tag
+}
+
+// archiveRepoTags 返回归档中全部镜像的标签，用于错误信息
+func archiveRepoTags(entries []dockerArchiveEntry) string {
+	var tags []string
+	for _, entry := range entries {
+		if len(entry.RepoTags) == 0 {
+			tags = append(tags, entry.Config)
+		}
+		tags = append(tags, entry.RepoTags...)
+	}
+	return strings.Join(tags, ", ")
+}
+
+// describeArchiveFile 返回归档中文件的描述符
+// 文件名为 blobs/sha256/<hex> 时直接使用其 digest，否则读取内容计算；mediaType 为空时按内容的魔数识别层的压缩格式
+func describeArchiveFile(file *os.File, name string, located archiveFile, mediaType string) (Descriptor, error) {
+	desc := Descriptor{MediaType: mediaType, Size: located.size}
+	section := io.NewSectionReader(file, located.offset, located.size)
+	if dir, encoded := path.Split(path.Clean(name)); dir == "blobs/sha256/" && len(encoded) == 64 {
+		desc.Digest = "sha256:" + encoded
+	} else {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, section); err != nil {
+			return Descriptor{}, fmt.Errorf("读取 %s 失败: %w", name, err)
+		}
+		desc.Digest = "sha256:" + hex.EncodeToString(hash.Sum(nil))
+	}
+	if desc.MediaType == "" {
+		magic := make([]byte, 4)
+		n, _ := io.ReadFull(io.NewSectionReader(file, located.offset, located.size), magic)
+		switch {
+		case n >= 2 && bytes.Equal(magic[:2], []byte{0x1f, 0x8b}):
+			desc.MediaType = MediaTypeOCILayerGzip
+		case n == 4 && bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+			desc.MediaType = MediaTypeOCILayerZstd
+		default:
+			desc.MediaType = MediaTypeOCILayer
+		}
+	}
+	return desc, nil
+}
+
+// Root 实现 ImageSource 接口，返回根据归档生成的 OCI manifest
+func (s *dockerArchiveSource) Root(ctx context.Context) (Descriptor, []byte, error) {
+	return s.root, s.manifest, nil
+}
+
+// Manifest 实现 ImageSource 接口，归档中只有一个 manifest
+func (s *dockerArchiveSource) Manifest(ctx context.Context, desc Descriptor) ([]byte, error) {
+	if desc.Digest != s.root.Digest {
+		return nil, fmt.Errorf("%w: 归档中没有 manifest %s", ErrNotFound, desc.Digest)
+	}
+	return s.manifest, nil
+}
+
+// Blob 实现 ImageSource 接口
+func (s *dockerArchiveSource) Blob(ctx context.Context, desc Descriptor) (io.ReadCloser, error) {
+	located, ok := s.blobs[desc.Digest]
+	if !ok {
+		return nil, fmt.Errorf("%w: 归档中没有 blob %s", ErrNotFound, desc.Digest)
+	}
+	return io.NopCloser(io.NewSectionReader(s.file, located.offset, located.size)), nil
+}
+
+// Close 实现 ImageSource 接口
+func (s *dockerArchiveSource) Close() error {
+	return s.file.Close()
+}
+
+// dockerArchiveDestination 表示作为复制目标的 docker save 格式 tar 文件
+// 内容先写入同一目录的临时文件，Commit 时写入 manifest.json 后重命名为目标路径
+type dockerArchiveDestination struct {
+	path      string
+	reference string
+	tmp       *os.File
+	buffered  *bufio.Writer
+	writer    *tar.Writer
+	written   map[string]bool
+	manifest  *ImageManifest
+	committed bool
+}
+
+// NewDockerArchiveDestination 创建写入 docker save 格式 tar 文件的复制目标，可以通过 docker load 导入
+// reference 为归档中的镜像引用（如 nginx:1.27），docker load 后镜像使用该标签，为空时导入后没有标签
+// 只能保存单个平台的镜像；已存在的文件在 Commit 时被替换
+func NewDockerArchiveDestination(path, reference string) (ImageDestination, error) {
+	if path == "" {
+		return nil, errors.New("docker-archive 路径不能为空")
+	}
+	if reference != "" {
+		name, _, ok := splitImageTag(reference)
+		if strings.Contains(reference, "@") || name == "" {
+			return nil, fmt.Errorf("docker-archive 的镜像引用应为 name:tag: %s", reference)
+		}
+		if !ok {
+			reference += ":latest"
+		}
+	}
+	return &dockerArchiveDestination{path: path, reference: reference, written: make(map[string]bool)}, nil
+}
+
+// SupportsIndex 实现 ImageDestination 接口，docker save 格式不能保存多架构索引
+func (d *dockerArchiveDestination) SupportsIndex() bool {
+	return false
+}
+
+// HasBlob 实现 ImageDestination 接口，只有已写入本归档的 blob 视为已存在
+func (d *dockerArchiveDestination) HasBlob(ctx context.Context, desc Descriptor) (bool, error) {
+	return d.written[desc.Digest], nil
+}
+
+// PutBlob 实现 ImageDestination 接口，blob 以 blobs/<algorithm>/<encoded> 写入归档
+// 写入 tar 的内容不能撤回，因此在写入之前拒绝无法校验的 digest（非 sha256）
+func (d *dockerArchiveDestination) PutBlob(ctx context.Context, desc Descriptor, content io.Reader) error {
+	if err := checkWritableDigest(desc.Digest); err != nil {
+		return err
+	}
+	name, err := archiveBlobName(desc.Digest)
+	if err != nil {
+		return err
+	}
+	if err := d.init(); err != nil {
+		return err
+	}
+	if err := d.writer.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: desc.Size, Typeflag: tar.TypeReg}); err != nil {
+		return fmt.Errorf("写入 docker-archive 失败: %w", err)
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(d.writer, hash), io.LimitReader(content, desc.Size))
+	if err != nil {
+		return fmt.Errorf("写入 blob %s 失败: %w", desc.Digest, err)
+	}
+	if n != desc.Size {
+		return fmt.Errorf("blob %s 大小不匹配: 期望 %d 字节，实际 %d 字节", desc.Digest, desc.Size, n)
+	}
+	if digest := "sha256:" + hex.EncodeToString(hash.Sum(nil)); digest != desc.Digest {
+		return fmt.Errorf("blob digest 不匹配: 期望 %s，实际 %s", desc.Digest, digest)
+	}
+	d.written[desc.Digest] = true
+	return nil
+}
+
+// PutManifest 实现 ImageDestination 接口，记录根 manifest 引用的配置和层，Commit 时写入 manifest.json
+func (d *dockerArchiveDestination) PutManifest(ctx context.Context, desc Descriptor, body []byte, root bool) error {
+	if IsIndexMediaType(desc.MediaType) {
+		return errors.New("docker-archive 不能保存多架构索引")
+	}
+	if !root {
+		return nil
+	}
+	var manifest ImageManifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return fmt.Errorf("解析 manifest %s 失败: %w", desc.Digest, err)
+	}
+	d.manifest = &manifest
+	return nil
+}
+
+// Commit 实现 ImageDestination 接口，写入 manifest.json 并将临时文件重命名为目标路径
+func (d *dockerArchiveDestination) Commit(ctx context.Context) error {
+	if d.manifest == nil {
+		return errors.New("没有写入根 manifest")
+	}
+	entry := dockerArchiveEntry{RepoTags: []string{}}
+	if d.reference != "" {
+		entry.RepoTags = append(entry.RepoTags, d.reference)
+	}
+	for i, desc := range append([]Descriptor{d.manifest.Config}, d.manifest.Layers...) {
+		if !d.written[desc.Digest] {
+			return fmt.Errorf("docker-archive 中缺少 %s（外部层不能保存到 docker-archive）", desc.Digest)
+		}
+		name, _ := archiveBlobName(desc.Digest)
+		if i == 0 {
+			entry.Config = name
+		} else {
+			entry.Layers = append(entry.Layers, name)
+		}
+	}
+	data, err := json.Marshal([]dockerArchiveEntry{entry})
+	if err != nil {
+		return err
+	}
+	if err := d.init(); err != nil {
+		return err
+	}
+	if err := d.writer.WriteHeader(&tar.Header{Name: dockerArchiveManifestFile, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+		return fmt.Errorf("写入 docker-archive 失败: %w", err)
+	}
+	if _, err := d.writer.Write(data); err != nil {
+		return fmt.Errorf("写入 docker-archive 失败: %w", err)
+	}
+	if err := d.writer.Close(); err != nil {
+		return fmt.Errorf("写入 docker-archive 失败: %w", err)
+	}
+	if err := d.buffered.Flush(); err != nil {
+		return fmt.Errorf("写入 docker-archive 失败: %w", err)
+	}
+	if err := d.tmp.Close(); err != nil {
+		return fmt.Errorf("写入 docker-archive 失败: %w", err)
+	}
+	if err := os.Rename(d.tmp.Name(), d.path); err != nil {
+		return fmt.Errorf("写入 docker-archive 失败: %w", err)
+	}
+	d.committed = true
+	return nil
+}
+
+// Close 实现 ImageDestination 接口，未提交时删除临时文件
+func (d *dockerArchiveDestination) Close() error {
+	if d.tmp == nil || d.committed {
+		return nil
+	}
+	d.tmp.Close()
+	return os.Remove(d.tmp.Name())
+}
+
+// init 在目标路径所在目录创建临时文件
+func (d *dockerArchiveDestination) init() error {
+	if d.tmp != nil {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(d.path), filepath.Base(d.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("创建 docker-archive 失败: %w", err)
+	}
+	tmp.Chmod(0o644)
+	d.tmp = tmp
+	d.buffered = bufio.NewWriterSize(tmp, 1<<20)
+	d.writer = tar.NewWriter(d.buffered)
+	return nil
+}
+
+// archiveBlobName 返回 blob 在归档中的文件名 blobs/<algorithm>/<encoded>（与 docker save 较新版本的布局一致）
+func archiveBlobName(digest string) (string, error) {
+	algorithm, encoded, err := splitDigest(digest)
+	if err != nil {
+		return "", err
+	}
+	return "blobs/" + algorithm + "/" + encoded, nil
+}
@@ -0,0 +1,231 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OCI 空配置，没有配置内容的 artifact 使用它作为 config
+const (
+	MediaTypeOCIEmpty = "application/vnd.oci.empty.v1+json"
+	ociEmptyConfig    = "{}"
+)
+
+// ArtifactBlob 表示附加到镜像的 artifact 中的一个 blob
+type ArtifactBlob struct {
+	MediaType   string            // blob 媒体类型，如 "application/json"
+	Content     []byte            // blob 内容
+	Annotations map[string]string // 描述符的注解（可选），如 org.opencontainers.image.title
+}
+
+// AttachResult 表示 AttachArtifact 的结果
+type AttachResult struct {
+	Manifest Descriptor // 推送的 artifact manifest 的描述符
+
+	// FallbackTag 为 registry 不支持 referrers API 时更新的标签（如 "sha256-<hex>"），
+	// registry 支持 referrers API 时为空
+	FallbackTag string
+}
+
+// AttachArtifact 将 artifact 附加到镜像：上传 blobs，推送带 subject 字段的 OCI manifest
+// image 为镜像所在仓库，subjectDigest 为被附加的 manifest digest
+// artifactType 为 artifact 类型（如 "application/vnd.example.report+json"），annotations 为 manifest 的注解（可选）
+// registry 不支持 referrers API 时（推送响应没有 OCI-Subject header），按 OCI 规范的标签方案
+// 将 artifact 记录到 "sha256-<hex>" 标签指向的索引中，使不支持 referrers API 的 registry 上也能发现该 artifact
+func (c *Client) AttachArtifact(image, subjectDigest, artifactType string, blobs []ArtifactBlob, annotations map[string]string) (*AttachResult, error) {
+	return c.AttachArtifactContext(context.Background(), image, subjectDigest, artifactType, blobs, annotations)
+}
+
+// AttachArtifactContext 与 AttachArtifact 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) AttachArtifactContext(ctx context.Context, image, subjectDigest, artifactType string, blobs []ArtifactBlob, annotations map[string]string) (*AttachResult, error) {
+	if artifactType == "" {
+		return nil, fmt.Errorf("artifactType 不能为空")
+	}
+	if !strings.Contains(subjectDigest, ":") {
+		return nil, fmt.Errorf("无效的 subject digest '%s'", subjectDigest)
+	}
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorizePush(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	// subject 描述符需要被附加的 manifest 的媒体类型和大小
+	subjectManifest, err := c.fetchManifest(ctx, target, authorization, subjectDigest)
+	if err != nil {
+		return nil, fmt.Errorf("获取 subject manifest 失败: %w", err)
+	}
+	subject := &Descriptor{
+		MediaType: subjectManifest.mediaType,
+		Digest:    subjectDigest,
+		Size:      int64(len(subjectManifest.body)),
+	}
+
+	// 上传空配置和所有 blob
+	config := Descriptor{MediaType: MediaTypeOCIEmpty, Digest: computeDigest([]byte(ociEmptyConfig)), Size: int64(len(ociEmptyConfig))}
+	if err := c.pushBlob(ctx, target, authorization, config, []byte(ociEmptyConfig)); err != nil {
+		return nil, err
+	}
+	layers := make([]Descriptor, 0, len(blobs))
+	for _, blob := range blobs {
+		desc := Descriptor{
+			MediaType:   blob.MediaType,
+			Digest:      computeDigest(blob.Content),
+			Size:        int64(len(blob.Content)),
+			Annotations: blob.Annotations,
+		}
+		if err := c.pushBlob(ctx, target, authorization, desc, blob.Content); err != nil {
+			return nil, err
+		}
+		layers = append(layers, desc)
+	}
+	if len(layers) == 0 {
+		// OCI 规范要求 layers 至少包含一项，没有内容时使用空描述符
+		layers = append(layers, config)
+	}
+
+	body, err := json.Marshal(ImageManifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIManifest,
+		ArtifactType:  artifactType,
+		Config:        config,
+		Layers:        layers,
+		Subject:       subject,
+		Annotations:   annotations,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成 artifact manifest 失败: %w", err)
+	}
+	result := &AttachResult{
+		Manifest: Descriptor{
+			MediaType:    MediaTypeOCIManifest,
+			Digest:       computeDigest(body),
+			Size:         int64(len(body)),
+			ArtifactType: artifactType,
+			Annotations:  annotations,
+		},
+	}
+
+	header, err := c.putManifest(ctx, target, authorization, result.Manifest.Digest, MediaTypeOCIManifest, body)
+	if err != nil {
+		return nil, err
+	}
+	if header.Get("OCI-Subject") != "" {
+		return result, nil
+	}
+
+	// registry 不支持 referrers API，使用标签方案
+	c.logger.Debug("registry 不支持 referrers API，使用标签方案",
+		"repository", target.repository,
+		"subject", subjectDigest)
+	result.FallbackTag = referrersTag(subjectDigest)
+	if err := c.addReferrerToTag(ctx, target, authorization, result.FallbackTag, result.Manifest); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// referrersTag 返回 OCI 规范标签方案中 subject 对应的标签，如 sha256:abc -> sha256-abc
+func referrersTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1)
+}
+
+// addReferrerToTag 将 artifact 的描述符加入标签指向的 referrers 索引，标签不存在时创建
+func (c *Client) addReferrerToTag(ctx context.Context, target *registryTarget, authorization, tag string, desc Descriptor) error {
+	index := ImageIndex{SchemaVersion: 2, MediaType: MediaTypeOCIIndex}
+
+	existing, err := c.fetchManifest(ctx, target, authorization, tag)
+	switch {
+	case errors.Is(err, ErrNotFound):
+	case err != nil:
+		return fmt.Errorf("获取 referrers 索引失败: %w", err)
+	default:
+		if err := json.Unmarshal(existing.body, &index); err != nil {
+			return fmt.Errorf("解析 referrers 索引失败: %w", err)
+		}
+		for _, referrer := range index.Manifests {
+			if referrer.Digest == desc.Digest {
+				return nil // 已经记录过
+			}
+		}
+	}
+
+	index.Manifests = append(index.Manifests, desc)
+	body, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("生成 referrers 索引失败: %w", err)
+	}
+	_, err = c.putManifest(ctx, target, authorization, tag, MediaTypeOCIIndex, body)
+	return err
+}
+
+// putManifest 推送 manifest，reference 为标签或 digest，返回 registry 的响应 header
+func (c *Client) putManifest(ctx context.Context, target *registryTarget, authorization, reference, mediaType string, body []byte) (http.Header, error) {
+	header := http.Header{"Content-Type": []string{mediaType}}
+	resp, err := c.doRegistryRequestWithBody(ctx, http.MethodPut, target, "manifests/"+reference, authorization, header, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, newResponseError("推送 manifest 失败", resp)
+	}
+	return resp.Header, nil
+}
+
+// listReferrers 返回引用 subjectDigest 的 artifact manifest 的描述符
+// 优先使用 referrers API（GET /v2/<name>/referrers/<digest>），registry 不支持时读取 OCI 规范标签方案的 "sha256-<hex>" 索引
+// 没有 referrer 时返回空列表
+func (c *Client) listReferrers(ctx context.Context, target *registryTarget, authorization, subjectDigest string) ([]Descriptor, error) {
+	header := http.Header{"Accept": []string{MediaTypeOCIIndex}}
+	resp, err := c.doRegistryRequest(ctx, http.MethodGet, target, "referrers/"+subjectDigest, authorization, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var index ImageIndex
+		if err := json.NewDecoder(io.LimitReader(resp.Body, MaxManifestSize)).Decode(&index); err != nil {
+			return nil, fmt.Errorf("解析 referrers 响应失败: %w", err)
+		}
+		return index.Manifests, nil
+	case http.StatusNotFound, http.StatusBadRequest, http.StatusMethodNotAllowed:
+		// registry 不支持 referrers API，使用标签方案
+	default:
+		return nil, newResponseError("获取 referrers 失败", resp)
+	}
+
+	fallback, err := c.fetchManifest(ctx, target, authorization, referrersTag(subjectDigest))
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取 referrers 索引失败: %w", err)
+	}
+	var index ImageIndex
+	if err := json.Unmarshal(fallback.body, &index); err != nil {
+		return nil, fmt.Errorf("解析 referrers 索引失败: %w", err)
+	}
+	return index.Manifests, nil
+}
+
+// subjectDigest 返回 reference 对应的 manifest digest，reference 本身是 digest 时直接返回
+func (c *Client) subjectDigest(ctx context.Context, target *registryTarget, authorization, reference string) (string, error) {
+	if strings.Contains(reference, ":") {
+		return reference, nil
+	}
+	return c.headDigest(ctx, target, authorization, defaultTag(target.registryKey, reference))
+}
@@ -1,33 +1,30 @@
 package registry
 
 import (
+	"context"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
-
-	"go.uber.org/zap"
 )
 
-// tokenResponse 表示认证服务器返回的 token 响应
-type tokenResponse struct {
-	Token       string `json:"token"`
-	AccessToken string `json:"access_token"`
-	ExpiresIn   int    `json:"expires_in"`
-}
-
 // getAuthToken 获取用于访问 registry 的 bearer token
-func (c *Client) getAuthToken(image string, registryKey string) (string, error) {
+// credentialKey 为查找凭据使用的 key，通常与 registryKey 相同
+func (c *Client) getAuthToken(ctx context.Context, image, registryKey, credentialKey string) (string, error) {
 	// 规范化镜像名称
 	normalizedImage := NormalizeImageName(image, registryKey)
 
 	// 构建 scope
 	scopes := []string{fmt.Sprintf("repository:%s:pull", normalizedImage)}
 
-	return c.GetAuthTokenWithScopes(scopes, registryKey)
+	info, err := c.getTokenInfoWithScopes(ctx, scopes, registryKey, credentialKey)
+	if err != nil {
+		return "", err
+	}
+	return info.Token, nil
 }
 
 // GetAuthTokenForImages 获取可以访问多个镜像的 bearer token
@@ -39,94 +36,220 @@ func (c *Client) getAuthToken(image string, registryKey string) (string, error)
 //   - 如果需要访问更多镜像，建议分批获取 token 或使用缓存机制
 //   - 镜像名称越长，支持的数量越少
 func (c *Client) GetAuthTokenForImages(images []string, registryKey string) (string, error) {
+	return c.getAuthTokenForImages(context.Background(), images, registryKey, registryKey)
+}
+
+// getAuthTokenForImages 与 GetAuthTokenForImages 相同，但使用 credentialKey 对应的凭据
+func (c *Client) getAuthTokenForImages(ctx context.Context, images []string, registryKey, credentialKey string) (string, error) {
 	if len(images) == 0 {
 		return "", fmt.Errorf("镜像列表不能为空")
 	}
 
+	// 检查镜像访问策略
+	for _, image := range images {
+		if err := c.checkImagePolicy(image); err != nil {
+			return "", err
+		}
+	}
+
 	// 建议的最大数量（保守估计）
 	const maxRecommendedImages = 50
 	if len(images) > maxRecommendedImages {
 		c.logger.Warn("请求的镜像数量超过建议值",
-			zap.Int("count", len(images)),
-			zap.Int("maxRecommended", maxRecommendedImages),
-			zap.String("message", "可能会遇到 URL 长度限制或服务器拒绝"))
+			"count", len(images),
+			"maxRecommended", maxRecommendedImages,
+			"message", "可能会遇到 URL 长度限制或服务器拒绝")
 	}
 
-	// 为每个镜像构建 scope
+	info, err := c.getTokenInfoWithScopes(ctx, imagePullScopes(images, registryKey), registryKey, credentialKey)
+	if err != nil {
+		return "", err
+	}
+	return info.Token, nil
+}
+
+// imagePullScopes 为每个镜像构建 pull scope，顺序与 images 相同
+func imagePullScopes(images []string, registryKey string) []string {
 	scopes := make([]string, 0, len(images))
 	for _, image := range images {
-		normalizedImage := NormalizeImageName(image, registryKey)
-		scope := fmt.Sprintf("repository:%s:pull", normalizedImage)
-		scopes = append(scopes, scope)
+		scopes = append(scopes, pullScope(NormalizeImageName(image, registryKey)))
 	}
-
-	return c.GetAuthTokenWithScopes(scopes, registryKey)
+	return scopes
 }
 
 // getAuthTokenWithScopes 使用指定的 scopes 获取认证 token
 func (c *Client) GetAuthTokenWithScopes(scopes []string, registryKey string) (string, error) {
+	info, err := c.GetTokenInfoWithScopes(scopes, registryKey)
+	if err != nil {
+		return "", err
+	}
+	return info.Token, nil
+}
+
+// GetTokenInfoWithScopes 使用指定的 scopes 获取认证 token 及其元数据（有效期、签发时间）
+// 返回的有效期已规范化，可用于 token 缓存
+// registryKey 可以是未注册的自定义源（"custom:<域名>"），此时与未配置 AuthURL 的 registry 一样，
+// 按 registry /v2/ 接口返回的 Bearer 质询中的 realm 和 service 请求 token
+func (c *Client) GetTokenInfoWithScopes(scopes []string, registryKey string) (*TokenInfo, error) {
+	return c.getTokenInfoWithScopes(context.Background(), scopes, registryKey, registryKey)
+}
+
+// getTokenInfoWithScopes 使用指定的 scopes 获取认证 token，请求受 ctx 控制
+// credentialKey 为查找凭据使用的 key，通常与 registryKey 相同
+// 并发的相同请求（registry、凭据和 scope 集合相同）只会发送一次
+func (c *Client) getTokenInfoWithScopes(ctx context.Context, scopes []string, registryKey, credentialKey string) (*TokenInfo, error) {
 	// 获取 registry 配置
 	config, ok := GetRegistry(registryKey)
 	if !ok {
-		return "", fmt.Errorf("未找到 registry 配置: %s", registryKey)
+		// 未注册的自定义源按 /v2/ 接口的质询获取 token，凭据按域名查找
+		domain, custom := strings.CutPrefix(registryKey, "custom:")
+		if !custom {
+			return nil, fmt.Errorf("未找到 registry 配置: %s", registryKey)
+		}
+		if credentialKey == registryKey {
+			credentialKey = domain
+		}
+		return c.getTokenInfoViaWWWAuthenticate(ctx, registryKey, "https://"+domain, scopes, credentialKey)
+	}
+	if config.AuthURL == "" {
+		// 未配置认证服务地址时按 /v2/ 接口的质询获取 token
+		return c.getTokenInfoViaWWWAuthenticate(ctx, registryKey, config.RegistryURL, scopes, credentialKey)
+	}
+	if info, ok := c.staticToken(ctx, credentialKey); ok {
+		return info, nil
+	}
+
+	key := c.registryTokenKey(ctx, registryKey, credentialKey, scopes)
+	if info, ok := c.cachedToken(key, registryKey); ok {
+		return info, nil
+	}
+
+	value, err := c.shareAuth(ctx, key, func(ctx context.Context) (any, error) {
+		return c.requestTokenInfo(ctx, config, scopes, credentialKey)
+	})
+	if err != nil {
+		return nil, err
+	}
+	info := value.(*TokenInfo)
+	c.storeToken(key, info)
+	return info, nil
+}
+
+// requestTokenInfo 向 registry 的认证服务请求 token，credentialKey 对应的凭据（如果有）以 Basic 认证发送
+// 使用 POST 方式时（见 tokenForm）以 OAuth2 表单发送凭据，不受 URL 长度限制
+func (c *Client) requestTokenInfo(ctx context.Context, config *RegistryConfig, scopes []string, credentialKey string) (*TokenInfo, error) {
+	if form, ok := c.tokenForm(ctx, config, config.Service, scopes, credentialKey); ok {
+		return c.postTokenForm(ctx, config.AuthURL+"/token", form)
 	}
 
 	// 构建认证 URL
 	authURL, err := c.BuildAuthURLWithScopes(config, scopes)
 	if err != nil {
-		return "", fmt.Errorf("构建认证 URL 失败: %w", err)
+		return nil, fmt.Errorf("构建认证 URL 失败: %w", err)
 	}
 
 	// 创建请求
-	req, err := http.NewRequest("GET", authURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", authURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("创建认证请求失败: %w", err)
+		return nil, fmt.Errorf("创建认证请求失败: %w", err)
 	}
 
 	// 如果有凭据，添加 Basic Auth
-	// 根据 registry key 查找对应的凭据
-	if cred, ok := c.GetCredential(registryKey); ok && cred.Username != "" && cred.Token != "" {
-		auth := cred.Username + ":" + cred.Token
-		encodedAuth := base64.StdEncoding.EncodeToString([]byte(auth))
-		req.Header.Set("Authorization", "Basic "+encodedAuth)
+	if basicAuth, ok := c.basicAuthHeader(ctx, credentialKey); ok {
+		req.Header.Set("Authorization", basicAuth)
 	}
 
 	// 发送请求
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return "", fmt.Errorf("认证请求失败: %w", err)
+		return nil, fmt.Errorf("认证请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 检查响应状态
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("认证失败 (状态码: %d): %s", resp.StatusCode, string(body))
+		return nil, newResponseError("认证失败", resp)
 	}
 
 	// 解析响应
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取认证响应失败: %w", err)
+		return nil, fmt.Errorf("读取认证响应失败: %w", err)
 	}
 
-	var tokenResp tokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return "", fmt.Errorf("解析认证响应失败: %w", err)
+	return parseTokenResponse(body)
+}
+
+// IdentityTokenUsername 凭据的用户名为该值时，Token 为认证服务签发的 refresh token（identity token），
+// 与 docker login 保存的 identity token 约定相同；此类凭据始终以 POST 方式请求 token
+const IdentityTokenUsername = "<token>"
+
+// defaultTokenClientID 以 POST 方式请求 token 时默认的 client_id，可通过 TokenParams 覆盖
+const defaultTokenClientID = "docker-auth"
+
+// tokenForm 构建以 POST 方式请求 token 的 OAuth2 表单
+// registry 配置了 TokenMethod 为 POST，或凭据为 identity token 时使用 POST；
+// 规范要求 POST 方式必须携带凭据，没有凭据或不使用 POST 时返回 false，调用方改用 GET
+// config 为 nil 表示未注册的自定义源
+func (c *Client) tokenForm(ctx context.Context, config *RegistryConfig, service string, scopes []string, credentialKey string) (url.Values, bool) {
+	cred, ok := c.credential(ctx, credentialKey)
+	if !ok || cred.Username == "" || cred.Token == "" {
+		return nil, false
+	}
+	identityToken := cred.Username == IdentityTokenUsername
+	if !identityToken && (config == nil || config.TokenMethod != TokenMethodPOST) {
+		return nil, false
 	}
 
-	// 返回 token（优先使用 token 字段，如果没有则使用 access_token）
-	if tokenResp.Token != "" {
-		return tokenResp.Token, nil
+	form := url.Values{}
+	if identityToken {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", cred.Token)
+	} else {
+		form.Set("grant_type", "password")
+		form.Set("username", cred.Username)
+		form.Set("password", cred.Token)
+	}
+	if service != "" {
+		form.Set("service", service)
+	}
+	if len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
 	}
-	if tokenResp.AccessToken != "" {
-		return tokenResp.AccessToken, nil
+	form.Set("client_id", defaultTokenClientID)
+	if config != nil {
+		applyTokenParams(form, config)
 	}
+	return form, true
+}
 
-	return "", fmt.Errorf("认证响应中没有找到 token")
+// postTokenForm 以 POST 方式向认证服务提交 OAuth2 表单请求 token
+func (c *Client) postTokenForm(ctx context.Context, tokenURL string, form url.Values) (*TokenInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("创建认证请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("认证请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResponseError("认证失败", resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取认证响应失败: %w", err)
+	}
+	return parseTokenResponse(body)
 }
 
 // buildAuthURLWithScopes 构建认证服务的 URL（支持多个 scope）
+// registry 配置了 Audience 或 TokenParams 时会附加到查询参数中
 func (c *Client) BuildAuthURLWithScopes(config *RegistryConfig, scopes []string) (string, error) {
 	var finalURL string
 
@@ -140,6 +263,7 @@ func (c *Client) BuildAuthURLWithScopes(config *RegistryConfig, scopes []string)
 		for _, scope := range scopes {
 			params.Add("scope", scope)
 		}
+		applyTokenParams(params, config)
 		finalURL = authURL + "?" + params.Encode()
 
 	case GHCRKey:
@@ -151,6 +275,7 @@ func (c *Client) BuildAuthURLWithScopes(config *RegistryConfig, scopes []string)
 			params.Add("scope", scope)
 		}
 		// GHCR 不需要 service 参数，但需要正确的 scope 格式
+		applyTokenParams(params, config)
 		finalURL = authURL + "?" + params.Encode()
 
 	default:
@@ -163,19 +288,74 @@ func (c *Client) BuildAuthURLWithScopes(config *RegistryConfig, scopes []string)
 		for _, scope := range scopes {
 			params.Add("scope", scope)
 		}
+		applyTokenParams(params, config)
 		finalURL = authURL + "?" + params.Encode()
 	}
 
-	// 检查 URL 长度（保守的限制是 2048 字符）
-	const maxURLLength = 2048
+	// 检查 URL 长度（默认使用保守的 2048 字符限制）
+	maxURLLength := config.authURLLimit()
 	if len(finalURL) > maxURLLength {
-		return "", fmt.Errorf("生成的 URL 太长 (%d 字符 > %d)，请减少镜像数量或使用分批处理",
-			len(finalURL), maxURLLength)
+		return "", fmt.Errorf("%w (%d 字符 > %d)，请减少镜像数量或使用分批处理",
+			errAuthURLTooLong, len(finalURL), maxURLLength)
 	}
 
 	return finalURL, nil
 }
 
+// defaultMaxAuthURLLength 认证 URL 的默认最大长度
+const defaultMaxAuthURLLength = 2048
+
+// errAuthURLTooLong 表示认证 URL 超过 registry 配置的长度限制
+var errAuthURLTooLong = errors.New("生成的 URL 太长")
+
+// authURLLimit 返回认证 URL 的最大长度，未配置时为 defaultMaxAuthURLLength
+func (config *RegistryConfig) authURLLimit() int {
+	if config.MaxAuthURLLength > 0 {
+		return config.MaxAuthURLLength
+	}
+	return defaultMaxAuthURLLength
+}
+
+// splitScopesForAuthURL 将 scopes 按顺序拆分为多组，使每组构建的认证 URL 不超过长度限制
+// 以 POST 方式请求 token（见 tokenForm）或按质询获取 token 时不拆分
+func (c *Client) splitScopesForAuthURL(ctx context.Context, config *RegistryConfig, scopes []string, credentialKey string) [][]string {
+	if config.AuthURL == "" || len(scopes) <= 1 {
+		return [][]string{scopes}
+	}
+	if _, ok := c.tokenForm(ctx, config, config.Service, scopes, credentialKey); ok {
+		return [][]string{scopes}
+	}
+
+	baseURL, err := c.BuildAuthURLWithScopes(config, nil)
+	if err != nil {
+		return [][]string{scopes}
+	}
+	available := config.authURLLimit() - len(baseURL)
+
+	var chunks [][]string
+	start, length := 0, 0
+	for i, scope := range scopes {
+		// 每个 scope 占用 "&scope=" 和编码后的值
+		scopeLength := len("&scope=") + len(url.QueryEscape(scope))
+		if i > start && length+scopeLength > available {
+			chunks = append(chunks, scopes[start:i])
+			start, length = i, 0
+		}
+		length += scopeLength
+	}
+	return append(chunks, scopes[start:])
+}
+
+// applyTokenParams 将 registry 配置的 audience 和额外参数添加到认证请求的查询参数中
+func applyTokenParams(params url.Values, config *RegistryConfig) {
+	if config.Audience != "" {
+		params.Set("audience", config.Audience)
+	}
+	for key, value := range config.TokenParams {
+		params.Set(key, value)
+	}
+}
+
 // EstimateMaxImagesForBatch 估算在不超过 URL 长度限制的情况下，可以一次性获取多少个镜像的 token
 // 这是一个辅助函数，帮助您确定合适的批处理大小
 func (c *Client) EstimateMaxImagesForBatch(sampleImages []string, registryKey string) int {
@@ -200,15 +380,15 @@ func (c *Client) EstimateMaxImagesForBatch(sampleImages []string, registryKey st
 
 	avgScopeLength := totalLength / len(sampleImages)
 
-	// 基础 URL 长度
-	baseURL := config.AuthURL + "/token?service=" + config.Service
+	// 基础 URL 长度（包含 service 及 registry 配置的额外参数）
+	baseURL, err := c.BuildAuthURLWithScopes(config, nil)
+	if err != nil {
+		return 0
+	}
 	baseLength := len(baseURL)
 
-	// 保守的 URL 长度限制
-	const maxURLLength = 2048
-
 	// 计算可以容纳多少个 scope
-	availableLength := maxURLLength - baseLength
+	availableLength := config.authURLLimit() - baseLength
 	maxImages := availableLength / avgScopeLength
 
 	// 保守估计，减少 10%
@@ -221,86 +401,191 @@ func (c *Client) EstimateMaxImagesForBatch(sampleImages []string, registryKey st
 	return maxImages
 }
 
-// parseWWWAuthenticate 解析 WWW-Authenticate header（如果需要动态获取认证参数）
-// 这个函数可以在未来用于更灵活的认证流程
-func ParseWWWAuthenticate(header string) (realm, service, scope string, err error) {
-	// WWW-Authenticate: Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"
+// authChallenge 表示 WWW-Authenticate header 中的认证质询
+type authChallenge struct {
+	scheme string            // 认证类型（小写），如 "bearer"、"basic"
+	params map[string]string // 参数（名称为小写），如 realm、service、scope
+}
 
-	if !strings.HasPrefix(header, "Bearer ") {
-		return "", "", "", fmt.Errorf("不支持的认证类型")
+// parseChallenge 解析 WWW-Authenticate header 中的认证质询
+// 参数值可以是带引号的字符串，引号中的逗号（如 scope="repository:foo:pull,push"）和转义字符会被正确处理
+func parseChallenge(header string) (authChallenge, error) {
+	header = strings.TrimSpace(header)
+	scheme, rest, _ := strings.Cut(header, " ")
+	if scheme == "" {
+		return authChallenge{}, fmt.Errorf("WWW-Authenticate header 为空")
 	}
 
-	// 移除 "Bearer " 前缀
-	params := strings.TrimPrefix(header, "Bearer ")
+	challenge := authChallenge{scheme: strings.ToLower(scheme), params: make(map[string]string)}
+	for rest = strings.TrimSpace(rest); rest != ""; {
+		name, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break // Basic 质询的 token68 等不含参数的部分
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimLeft(value, " \t")
+
+		if strings.HasPrefix(value, `"`) {
+			// 带引号的字符串，读取到未转义的结束引号
+			var b strings.Builder
+			i := 1
+			for ; i < len(value) && value[i] != '"'; i++ {
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+				}
+				b.WriteByte(value[i])
+			}
+			if i >= len(value) {
+				return authChallenge{}, fmt.Errorf("参数 %s 的引号未闭合", name)
+			}
+			challenge.params[name] = b.String()
+			rest = value[i+1:]
+		} else {
+			token, remaining, _ := strings.Cut(value, ",")
+			challenge.params[name] = strings.TrimSpace(token)
+			rest = "," + remaining
+		}
 
-	// 解析参数
-	parts := strings.Split(params, ",")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		kv := strings.SplitN(part, "=", 2)
-		if len(kv) != 2 {
-			continue
+		// 跳过参数之间的逗号和空白
+		rest = strings.TrimLeft(rest, " \t")
+		if rest != "" && rest[0] != ',' {
+			return authChallenge{}, fmt.Errorf("参数 %s 之后的内容无效: %s", name, rest)
 		}
+		rest = strings.TrimLeft(rest, ", \t")
+	}
+	return challenge, nil
+}
 
-		key := strings.TrimSpace(kv[0])
-		value := strings.Trim(strings.TrimSpace(kv[1]), "\"")
+// scopes 返回 Bearer 质询中的 scope 列表，多个 scope 以空格分隔
+func (ch authChallenge) scopes() []string {
+	return strings.Fields(ch.params["scope"])
+}
 
-		switch key {
-		case "realm":
-			realm = value
-		case "service":
-			service = value
-		case "scope":
-			scope = value
-		}
+// ParseWWWAuthenticate 解析 Bearer 质询的 WWW-Authenticate header，返回 realm、service 和 scope
+// 认证类型和参数名不区分大小写，带引号的参数值中可以包含逗号；质询包含多个 scope 时以空格分隔
+func ParseWWWAuthenticate(header string) (realm, service, scope string, err error) {
+	// WWW-Authenticate: Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"
+	challenge, err := parseChallenge(header)
+	if err != nil {
+		return "", "", "", err
+	}
+	if challenge.scheme != "bearer" {
+		return "", "", "", fmt.Errorf("不支持的认证类型")
 	}
 
+	realm = challenge.params["realm"]
 	if realm == "" {
 		return "", "", "", fmt.Errorf("未找到 realm 参数")
 	}
-
-	return realm, service, scope, nil
+	return realm, challenge.params["service"], challenge.params["scope"], nil
 }
 
-// getAuthTokenViaWWWAuthenticate 通过 WWW-Authenticate 动态获取认证 token
-// 用于未注册的自定义 registry
-func (c *Client) getAuthTokenViaWWWAuthenticate(registryURL, image string) (string, error) {
-	// 首先尝试访问 manifest 接口，不带认证
-	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/latest", registryURL, image)
+// errNoTokenService 表示 registry 不需要认证或只支持 Basic 认证，没有签发 token 的认证服务
+var errNoTokenService = errors.New("registry 没有 token 认证服务")
 
-	req, err := http.NewRequest("GET", manifestURL, nil)
+// getAuthorizationViaWWWAuthenticate 按 registry /v2/ 接口的质询获取 scopes 对应的 Authorization header
+// 用于未注册的自定义源和未配置 AuthURL 的 registry
+// 质询类型为 Bearer 时返回 "Bearer <token>"，为 Basic 时直接返回 "Basic <凭据>"，不需要认证时返回空字符串
+// credentialKey 为查找凭据使用的 key，通常为 registry 域名
+func (c *Client) getAuthorizationViaWWWAuthenticate(ctx context.Context, registryKey, registryURL string, scopes []string, credentialKey string) (string, error) {
+	if info, ok := c.staticToken(ctx, credentialKey); ok {
+		return "Bearer " + info.Token, nil
+	}
+	challenge, err := c.registryChallenge(ctx, registryURL)
 	if err != nil {
-		return "", fmt.Errorf("创建探测请求失败: %w", err)
+		return "", err
 	}
 
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
+	switch {
+	case challenge == "":
+		return "", nil // 不需要认证
+	case isBasicChallenge(challenge):
+		// 仅支持 Basic 认证的 registry（如 htpasswd、Nexus），直接使用凭据
+		basicAuth, ok := c.basicAuthHeader(ctx, credentialKey)
+		if !ok {
+			return "", fmt.Errorf("registry %s 要求 Basic 认证，但未配置凭据", credentialKey)
+		}
+		c.logger.Debug("registry 使用 Basic 认证", "domain", extractDomain(registryURL))
+		return basicAuth, nil
+	}
 
-	resp, err := c.httpClient.Do(req)
+	info, err := c.getChallengeTokenInfo(ctx, registryKey, challenge, scopes, credentialKey, true)
 	if err != nil {
-		return "", fmt.Errorf("探测请求失败: %w", err)
+		return "", err
 	}
-	defer resp.Body.Close()
+	return "Bearer " + info.Token, nil
+}
 
-	// 如果不是 401，说明不需要认证或有其他问题
-	if resp.StatusCode != http.StatusUnauthorized {
-		return "", fmt.Errorf("未预期的响应状态: %d", resp.StatusCode)
+// getTokenInfoViaWWWAuthenticate 按 registry /v2/ 接口的 Bearer 质询获取 scopes 对应的 token
+// registry 不需要认证或只支持 Basic 认证时返回 errNoTokenService
+func (c *Client) getTokenInfoViaWWWAuthenticate(ctx context.Context, registryKey, registryURL string, scopes []string, credentialKey string) (*TokenInfo, error) {
+	if info, ok := c.staticToken(ctx, credentialKey); ok {
+		return info, nil
+	}
+	challenge, err := c.registryChallenge(ctx, registryURL)
+	if err != nil {
+		return nil, err
 	}
+	if challenge == "" || isBasicChallenge(challenge) {
+		return nil, errNoTokenService
+	}
+	return c.getChallengeTokenInfo(ctx, registryKey, challenge, scopes, credentialKey, true)
+}
 
-	// 解析 WWW-Authenticate header
-	wwwAuth := resp.Header.Get("Www-Authenticate")
-	if wwwAuth == "" {
-		return "", fmt.Errorf("未找到 Www-Authenticate header")
+// getAuthorizationForChallenge 按 Bearer 质询获取 token，返回 "Bearer <token>"
+// 质询中带有 scope 时使用质询的 scope（如 scope 不足时 registry 要求的权限），否则使用 defaultScopes
+// useCache 为 false 时不使用缓存的 token（如缓存的 token 已被 registry 拒绝），新获取的 token 仍会写入缓存
+func (c *Client) getAuthorizationForChallenge(ctx context.Context, registryKey, challenge, credentialKey string, defaultScopes []string, useCache bool) (string, error) {
+	scopes := defaultScopes
+	if parsed, err := parseChallenge(challenge); err == nil && len(parsed.scopes()) > 0 {
+		scopes = parsed.scopes()
 	}
+	info, err := c.getChallengeTokenInfo(ctx, registryKey, challenge, scopes, credentialKey, useCache)
+	if err != nil {
+		return "", err
+	}
+	return "Bearer " + info.Token, nil
+}
 
-	realm, service, scope, err := ParseWWWAuthenticate(wwwAuth)
+// getChallengeTokenInfo 按 Bearer 质询中的 realm、service 向认证服务请求 scopes 对应的 token
+// credentialKey 对应的凭据（如果有）会以 Basic 认证发送给认证服务
+// 相同 realm、service、凭据和 scope 集合的 token 共享缓存，并发的相同请求只会发送一次
+func (c *Client) getChallengeTokenInfo(ctx context.Context, registryKey, challenge string, scopes []string, credentialKey string, useCache bool) (*TokenInfo, error) {
+	realm, service, _, err := ParseWWWAuthenticate(challenge)
 	if err != nil {
-		return "", fmt.Errorf("解析 WWW-Authenticate 失败: %w", err)
+		return nil, fmt.Errorf("解析 WWW-Authenticate 失败: %w", err)
 	}
 
 	c.logger.Debug("从 WWW-Authenticate 获取认证参数",
-		zap.String("realm", realm),
-		zap.String("service", service),
-		zap.String("scope", scope))
+		"realm", realm,
+		"service", service,
+		"scopes", scopes)
+
+	key := c.challengeTokenKey(ctx, realm, service, credentialKey, scopes)
+	if useCache {
+		if info, ok := c.cachedToken(key, registryKey); ok {
+			return info, nil
+		}
+	}
+
+	value, err := c.shareAuth(ctx, key, func(ctx context.Context) (any, error) {
+		return c.requestChallengeToken(ctx, registryKey, realm, service, scopes, credentialKey)
+	})
+	if err != nil {
+		return nil, err
+	}
+	info := value.(*TokenInfo)
+	c.storeToken(key, info)
+	return info, nil
+}
+
+// requestChallengeToken 按质询参数向认证服务请求 token
+// registryKey 对应已注册的 registry 时，同时发送其配置的 audience 和额外参数
+func (c *Client) requestChallengeToken(ctx context.Context, registryKey, realm, service string, scopes []string, credentialKey string) (*TokenInfo, error) {
+	config, _ := GetRegistry(registryKey)
+	if form, ok := c.tokenForm(ctx, config, service, scopes, credentialKey); ok {
+		return c.postTokenForm(ctx, realm, form)
+	}
 
 	// 构建认证 URL
 	authURL := realm
@@ -308,60 +593,157 @@ func (c *Client) getAuthTokenViaWWWAuthenticate(registryURL, image string) (stri
 	if service != "" {
 		params.Set("service", service)
 	}
-	if scope != "" {
-		params.Set("scope", scope)
+	for _, scope := range scopes {
+		params.Add("scope", scope)
+	}
+	if config != nil {
+		applyTokenParams(params, config)
 	}
 
 	if len(params) > 0 {
-		authURL += "?" + params.Encode()
+		separator := "?"
+		if strings.Contains(authURL, "?") {
+			separator = "&"
+		}
+		authURL += separator + params.Encode()
 	}
 
 	// 请求 token
-	authReq, err := http.NewRequest("GET", authURL, nil)
+	authReq, err := http.NewRequestWithContext(ctx, "GET", authURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("创建认证请求失败: %w", err)
+		return nil, fmt.Errorf("创建认证请求失败: %w", err)
 	}
 
 	// 尝试添加凭据（如果有的话）
-	// 对于自定义源，尝试使用域名作为 key 查找凭据
-	domain := extractDomain(registryURL)
-	if cred, ok := c.GetCredential(domain); ok && cred.Username != "" && cred.Token != "" {
-		auth := cred.Username + ":" + cred.Token
-		encodedAuth := base64.StdEncoding.EncodeToString([]byte(auth))
-		authReq.Header.Set("Authorization", "Basic "+encodedAuth)
+	if basicAuth, ok := c.basicAuthHeader(ctx, credentialKey); ok {
+		authReq.Header.Set("Authorization", basicAuth)
 	}
 
-	authResp, err := c.httpClient.Do(authReq)
+	authResp, err := c.do(authReq)
 	if err != nil {
-		return "", fmt.Errorf("认证请求失败: %w", err)
+		return nil, fmt.Errorf("认证请求失败: %w", err)
 	}
 	defer authResp.Body.Close()
 
 	if authResp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(authResp.Body)
-		return "", fmt.Errorf("认证失败 (状态码: %d): %s", authResp.StatusCode, string(body))
+		return nil, newResponseError("认证失败", authResp)
 	}
 
 	// 解析 token 响应
 	body, err := io.ReadAll(authResp.Body)
 	if err != nil {
-		return "", fmt.Errorf("读取认证响应失败: %w", err)
+		return nil, fmt.Errorf("读取认证响应失败: %w", err)
+	}
+
+	return parseTokenResponse(body)
+}
+
+// pullScope 返回仓库 pull 权限的 scope
+func pullScope(repository string) string {
+	return fmt.Sprintf("repository:%s:pull", repository)
+}
+
+// basicAuthHeader 根据凭据 key 构建 Basic 认证的 Authorization header
+// 如果没有配置完整的凭据，返回 false
+func (c *Client) basicAuthHeader(ctx context.Context, credentialKey string) (string, bool) {
+	cred, ok := c.credential(ctx, credentialKey)
+	if !ok || cred.Username == "" || cred.Token == "" {
+		return "", false
+	}
+	auth := cred.Username + ":" + cred.Token
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(auth)), true
+}
+
+// isBasicChallenge 判断 WWW-Authenticate header 是否为 Basic 质询
+func isBasicChallenge(header string) bool {
+	return len(header) >= 5 && strings.EqualFold(header[:5], "Basic")
+}
+
+// probeChallenge 访问 registry 的 /v2/ 接口，返回未认证时的 WWW-Authenticate header
+// 2xx 表示不需要认证，返回空字符串；401 以外的错误状态码（如 5xx、超过最大等待时间的 429）不说明认证方式，返回 *ResponseError
+func (c *Client) probeChallenge(ctx context.Context, registryURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", registryURL+"/v2/", nil)
+	if err != nil {
+		return "", fmt.Errorf("创建探测请求失败: %w", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("探测请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		io.Copy(io.Discard, resp.Body)
+		return resp.Header.Get("Www-Authenticate"), nil
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		io.Copy(io.Discard, resp.Body)
+		return "", nil
 	}
+	return "", newResponseError("探测 registry 认证方式失败", resp)
+}
 
-	var tokenResp tokenResponse
-	if err := json.Unmarshal(body, &tokenResp); err != nil {
-		return "", fmt.Errorf("解析认证响应失败: %w", err)
+// registryChallenge 返回 registry /v2/ 接口的 WWW-Authenticate header，结果按 registry 地址缓存
+// 质询中的 realm、service 对同一 registry 的所有仓库相同，缓存后每个 registry 只需探测一次；探测失败时不缓存，下次重新探测
+func (c *Client) registryChallenge(ctx context.Context, registryURL string) (string, error) {
+	c.challengeMu.Lock()
+	challenge, ok := c.challenges[registryURL]
+	c.challengeMu.Unlock()
+	if ok {
+		return challenge, nil
 	}
 
-	// 返回 token
-	if tokenResp.Token != "" {
-		return tokenResp.Token, nil
+	value, err := c.shareAuth(ctx, "probe|"+registryURL, func(ctx context.Context) (any, error) {
+		return c.probeChallenge(ctx, registryURL)
+	})
+	if err != nil {
+		return "", err
 	}
-	if tokenResp.AccessToken != "" {
-		return tokenResp.AccessToken, nil
+	challenge = value.(string)
+
+	c.challengeMu.Lock()
+	if c.challenges == nil {
+		c.challenges = make(map[string]string)
 	}
+	c.challenges[registryURL] = challenge
+	c.challengeMu.Unlock()
+	return challenge, nil
+}
 
-	return "", fmt.Errorf("认证响应中没有找到 token")
+// doWithBasicFallback 发送 registry 请求（manifest、blob 等）
+// 如果响应为 401 且质询类型为 Basic，使用 credentialKey 对应的凭据重试一次
+// 用于只支持 HTTP Basic 认证、不签发 bearer token 的 registry
+func (c *Client) doWithBasicFallback(req *http.Request, credentialKey string) (*http.Response, error) {
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusUnauthorized || !isBasicChallenge(resp.Header.Get("Www-Authenticate")) {
+		return resp, nil
+	}
+
+	basicAuth, ok := c.basicAuthHeader(req.Context(), credentialKey)
+	if !ok || req.Header.Get("Authorization") == basicAuth {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	c.logger.Debug("registry 要求 Basic 认证，使用凭据重试",
+		"credentialKey", credentialKey,
+		"url", req.URL.String())
+
+	retryReq := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retryReq.Body = body
+	}
+	retryReq.Header.Set("Authorization", basicAuth)
+	return c.do(retryReq)
 }
 
 // extractDomain 从 URL 中提取域名
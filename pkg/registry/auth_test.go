@@ -0,0 +1,200 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAnonymousFallback(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	specs := []ImageSpec{{Image: reg.host() + "/app", Tag: "v1"}}
+
+	// 凭据被认证服务拒绝，未启用回退时返回认证错误
+	client := reg.client()
+	client.AddCredential(reg.host(), "robot", "expired")
+	if result := client.GetManifestsWithOptions(context.Background(), specs, BatchOptions{})[0]; result.Error == nil {
+		t.Fatal("凭据被拒绝且未启用匿名回退时应返回错误")
+	}
+
+	client.WithAnonymousFallback(true)
+	result := client.GetManifestsWithOptions(context.Background(), specs, BatchOptions{})[0]
+	if result.Error != nil {
+		t.Fatalf("启用匿名回退后获取失败: %v", result.Error)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "匿名") {
+		t.Errorf("Warnings = %v, 期望记录回退为匿名访问", result.Warnings)
+	}
+
+	// 凭据有效时不回退
+	reg.users["robot"] = "secret"
+	client = reg.client().WithAnonymousFallback(true)
+	client.AddCredential(reg.host(), "robot", "secret")
+	result = client.GetManifestsWithOptions(context.Background(), specs, BatchOptions{})[0]
+	if result.Error != nil || len(result.Warnings) != 0 {
+		t.Errorf("凭据有效时结果 = %v, Warnings = %v, 期望成功且没有警告", result.Error, result.Warnings)
+	}
+}
+
+func TestBasicAuthRegistry(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.basic = true
+	reg.users["robot"] = "secret"
+	root := reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	image := reg.host() + "/app"
+
+	client := reg.client()
+	client.AddCredential(reg.host(), "robot", "secret")
+	if _, digest, err := client.GetManifestWithDigest(image, "v1"); err != nil || digest != root.Digest {
+		t.Errorf("GetManifestWithDigest = %s, %v, 期望 %s", digest, err, root.Digest)
+	}
+	if n := reg.countRequests(0, "GET /token"); n != 0 {
+		t.Errorf("只支持 Basic 认证的 registry 收到 %d 个 token 请求", n)
+	}
+
+	client = reg.client()
+	client.AddCredential(reg.host(), "robot", "wrong")
+	if _, _, err := client.GetManifestWithDigest(image, "v1"); err == nil {
+		t.Error("错误的 Basic 凭据应返回错误")
+	}
+	if _, _, err := reg.client().GetManifestWithDigest(image, "v1"); err == nil {
+		t.Error("没有凭据时应返回错误")
+	}
+}
+
+func TestTokenCacheReusesToken(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	reg.putImage("app", "v2", `{"architecture":"arm64","os":"linux"}`)
+	image := reg.host() + "/app"
+	cache := NewMemoryTokenCache()
+
+	if _, err := reg.client().WithTokenCache(cache).GetDigest(image, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	// 新的客户端共享缓存，相同 registry 和 scope 不再请求 token
+	requests := reg.requestCount()
+	if _, err := reg.client().WithTokenCache(cache).GetDigest(image, "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if n := reg.countRequests(requests, "GET /token"); n != 0 {
+		t.Errorf("使用缓存的 token 时仍请求了 %d 次 token", n)
+	}
+
+	// 不同的凭据不使用匿名获取的 token
+	reg.users["robot"] = "secret"
+	client := reg.client().WithTokenCache(cache)
+	client.AddCredential(reg.host(), "robot", "secret")
+	requests = reg.requestCount()
+	if _, err := client.GetDigest(image, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := reg.countRequests(requests, "GET /token"); n != 1 {
+		t.Errorf("带凭据时请求了 %d 次 token, 期望 1", n)
+	}
+}
+
+// tokenCacheMetrics 记录 ObserveTokenCache 收到的 registry 标签
+type tokenCacheMetrics struct {
+	mu     sync.Mutex
+	labels []string
+}
+
+func (m *tokenCacheMetrics) ObserveRequest(event *RequestEvent)        {}
+func (m *tokenCacheMetrics) ObserveBatch(registryKey string, size int) {}
+
+func (m *tokenCacheMetrics) ObserveTokenCache(registryKey string, hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.labels = append(m.labels, registryKey)
+}
+
+func TestTokenCacheMetricsLabel(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	reg.users["robot"] = "secret"
+	image := reg.host() + "/app"
+
+	// 按镜像指定的凭据 key 不作为指标的 registry 标签，与其他指标一样使用 registry key
+	metrics := &tokenCacheMetrics{}
+	client := reg.client().WithTokenCache(NewMemoryTokenCache()).WithMetrics(metrics)
+	client.AddCredential("team-a", "robot", "secret")
+	specs := []ImageSpec{{Image: image, Tag: "v1", CredentialKey: "team-a"}}
+	for _, result := range client.GetManifestsWithOptions(context.Background(), specs, BatchOptions{}) {
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+	}
+	if len(metrics.labels) == 0 {
+		t.Fatal("没有查询 token 缓存")
+	}
+	for _, label := range metrics.labels {
+		if label != "custom:"+reg.host() {
+			t.Errorf("token 缓存指标的 registry 标签 = %s, 期望 custom:%s", label, reg.host())
+		}
+	}
+}
+
+func TestImagePolicyDeniesBeforeRequests(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	reg.putImage("secret", "v1", `{"architecture":"amd64","os":"linux"}`)
+
+	client := reg.client()
+	if err := client.SetImagePolicy(&ImagePolicy{Deny: []string{reg.host() + "/secret"}}); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := client.GetManifestWithDigest(reg.host()+"/secret", "v1")
+	if !errors.Is(err, ErrImageNotAllowed) {
+		t.Errorf("错误 = %v, 期望 ErrImageNotAllowed", err)
+	}
+	if n := reg.requestCount(); n != 0 {
+		t.Errorf("被拒绝的镜像发送了 %d 个请求", n)
+	}
+	if _, _, err := client.GetManifestWithDigest(reg.host()+"/app", "v1"); err != nil {
+		t.Errorf("未被拒绝的镜像获取失败: %v", err)
+	}
+
+	if err := client.SetImagePolicy(&ImagePolicy{Allow: []string{"ghcr.io/**"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := client.GetManifestWithDigest(reg.host()+"/app", "v1"); !errors.Is(err, ErrImageNotAllowed) {
+		t.Errorf("不在允许列表中的镜像错误 = %v, 期望 ErrImageNotAllowed", err)
+	}
+}
+
+func TestChallengeProbeErrorNotCached(t *testing.T) {
+	reg := newTestRegistry(t)
+	root := reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	image := reg.host() + "/app"
+
+	// 第一次探测 /v2/ 时 registry 暂时不可用
+	reg.handler = func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	client := reg.client()
+	_, err := client.GetDigest(image, "v1")
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("错误 = %v, 期望状态码 503 的 ResponseError", err)
+	}
+
+	// 恢复后重新探测，得到 401 质询并正常获取 token
+	reg.mu.Lock()
+	reg.handler = nil
+	reg.mu.Unlock()
+	requests := reg.requestCount()
+	if digest, err := client.GetDigest(image, "v1"); err != nil || digest != root.Digest {
+		t.Fatalf("GetDigest = %s, %v, 期望 %s", digest, err, root.Digest)
+	}
+	if n := reg.countRequests(requests, "GET /v2/"); n != 1 {
+		t.Errorf("恢复后探测了 %d 次 /v2/, 期望 1", n)
+	}
+	if n := reg.countRequests(requests, "GET /token"); n != 1 {
+		t.Errorf("恢复后请求了 %d 次 token, 期望 1", n)
+	}
+}
@@ -0,0 +1,286 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultBreakerCooldown 熔断后默认暂停请求的时间
+const DefaultBreakerCooldown = 30 * time.Second
+
+// ErrRegistryUnavailable 表示 registry 无法访问（连接失败、超时或返回 502/503/504），
+// 或因连续失败被熔断、在批量任务开始前的探测中失败
+// 可通过 errors.Is(err, ErrRegistryUnavailable) 判断
+var ErrRegistryUnavailable = errors.New("registry 不可用")
+
+// UnavailableError 表示请求因 registry 不可用而被跳过
+// Err 为导致不可用的底层错误（最近一次失败的原因）
+type UnavailableError struct {
+	Host string // registry 域名
+	Err  error  // 底层错误
+}
+
+// Error 实现 error 接口
+func (e *UnavailableError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("registry 不可用 (%s)", e.Host)
+	}
+	return fmt.Sprintf("registry 不可用 (%s): %v", e.Host, e.Err)
+}
+
+// Is 使 errors.Is(err, ErrRegistryUnavailable) 返回 true
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrRegistryUnavailable
+}
+
+// Unwrap 返回底层错误
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// HostAvailability 表示客户端访问过的一个域名的可用性
+type HostAvailability struct {
+	Host      string    `json:"host"`
+	Available bool      `json:"available"`           // 最近一次请求是否收到了正常响应
+	Requests  int       `json:"requests"`            // 实际发出的请求数
+	Failures  int       `json:"failures"`            // 连续失败次数
+	Skipped   int       `json:"skipped"`             // 因熔断或探测失败而跳过的请求或镜像数
+	LastError string    `json:"lastError,omitempty"` // 最近一次失败的原因
+	RetryAt   time.Time `json:"retryAt,omitempty"`   // 熔断时恢复请求的时间
+}
+
+// AvailabilityReport 表示各域名的可用性汇总，按域名排序
+type AvailabilityReport []HostAvailability
+
+// Unavailable 返回不可用的域名
+func (r AvailabilityReport) Unavailable() AvailabilityReport {
+	var unavailable AvailabilityReport
+	for _, host := range r {
+		if !host.Available {
+			unavailable = append(unavailable, host)
+		}
+	}
+	return unavailable
+}
+
+// WriteSummary 输出可读的可用性汇总
+func (r AvailabilityReport) WriteSummary(w io.Writer) error {
+	for _, host := range r {
+		var line string
+		if host.Available {
+			line = fmt.Sprintf("✓ %s: 可用 (请求 %d 次)\n", host.Host, host.Requests)
+		} else {
+			line = fmt.Sprintf("✗ %s: 不可用 (连续失败 %d 次，跳过 %d 个): %s\n",
+				host.Host, host.Failures, host.Skipped, host.LastError)
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hostHealth 记录一个域名的请求结果
+type hostHealth struct {
+	requests  int
+	failures  int
+	skipped   int
+	lastErr   error
+	openUntil time.Time // 熔断截止时间，零值表示未熔断
+}
+
+// availabilityTracker 记录各域名的可用性，并在连续失败时熔断
+type availabilityTracker struct {
+	mu        sync.Mutex
+	threshold int           // 连续失败多少次后熔断，0 表示不熔断
+	cooldown  time.Duration // 熔断后暂停请求的时间
+	hosts     map[string]*hostHealth
+}
+
+// WithCircuitBreaker 设置熔断：对同一域名连续 threshold 次请求失败（连接失败、超时或 502/503/504）后，
+// 在 cooldown 内对该域名的请求直接返回可通过 errors.Is(err, ErrRegistryUnavailable) 判断的错误，
+// 避免批量任务中每个镜像都等待超时；cooldown 结束后放行请求，成功即恢复
+// threshold 为 0 或负数时不熔断（默认）；cooldown 为 0 或负数时使用 DefaultBreakerCooldown
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithCircuitBreaker(threshold int, cooldown time.Duration) *Client {
+	if threshold < 0 {
+		threshold = 0
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	c.availability.mu.Lock()
+	defer c.availability.mu.Unlock()
+	c.availability.threshold = threshold
+	c.availability.cooldown = cooldown
+	return c
+}
+
+// WithRegistryProbe 设置批量任务开始前探测 registry 的超时
+// 设置后批量获取会先并发访问每个 registry 的 /v2/ 接口，无法访问的 registry 的所有镜像直接标记为失败
+// （可通过 errors.Is(err, ErrRegistryUnavailable) 判断），不再逐个镜像等待超时
+// 0 表示不探测（默认）
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithRegistryProbe(timeout time.Duration) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.probeTimeout = timeout
+	return c
+}
+
+// getProbeTimeout 返回批量任务开始前探测 registry 的超时
+func (c *Client) getProbeTimeout() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.probeTimeout
+}
+
+// Availability 返回客户端访问过的域名（包括认证服务）的可用性汇总
+// 可在批量任务结束后调用，了解哪些 registry 无法访问
+func (c *Client) Availability() AvailabilityReport {
+	t := &c.availability
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	report := make(AvailabilityReport, 0, len(t.hosts))
+	for host, health := range t.hosts {
+		item := HostAvailability{
+			Host:      host,
+			Available: health.failures == 0,
+			Requests:  health.requests,
+			Failures:  health.failures,
+			Skipped:   health.skipped,
+		}
+		if health.lastErr != nil {
+			item.LastError = health.lastErr.Error()
+		}
+		if health.openUntil.After(now) {
+			item.RetryAt = health.openUntil
+		}
+		report = append(report, item)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Host < report[j].Host })
+	return report
+}
+
+// health 返回域名的记录，不存在时创建，调用方需持有 mu
+func (t *availabilityTracker) health(host string) *hostHealth {
+	if t.hosts == nil {
+		t.hosts = make(map[string]*hostHealth)
+	}
+	health, ok := t.hosts[host]
+	if !ok {
+		health = &hostHealth{}
+		t.hosts[host] = health
+	}
+	return health
+}
+
+// allow 检查域名是否处于熔断期，熔断时返回 UnavailableError 并计为跳过
+func (t *availabilityTracker) allow(host string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	health, ok := t.hosts[host]
+	if !ok || !time.Now().Before(health.openUntil) {
+		return nil
+	}
+	health.skipped++
+	return &UnavailableError{Host: host, Err: health.lastErr}
+}
+
+// record 记录一次请求的结果
+// 调用方主动取消的请求不计入
+func (t *availabilityTracker) record(req *http.Request, resp *http.Response, err error) {
+	if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	health := t.health(req.URL.Host)
+	health.requests++
+
+	failure := err
+	if failure == nil && resp != nil && unavailableStatus(resp.StatusCode) {
+		failure = fmt.Errorf("%s 返回状态码 %d", req.URL.Host, resp.StatusCode)
+	}
+	if failure == nil {
+		health.failures = 0
+		health.lastErr = nil
+		health.openUntil = time.Time{}
+		return
+	}
+
+	health.failures++
+	health.lastErr = failure
+	if t.threshold > 0 && health.failures >= t.threshold {
+		health.openUntil = time.Now().Add(t.cooldown)
+	}
+}
+
+// skip 将域名因探测失败而跳过的镜像计入汇总
+func (t *availabilityTracker) skip(host string, count int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.health(host).skipped += count
+}
+
+// unavailableStatus 判断状态码是否表示 registry 暂时不可用
+func unavailableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// probeRegistries 并发探测批量任务涉及的 registry，返回无法访问的 registry key 及原因
+// 未设置 WithRegistryProbe 或处于离线模式时不探测
+func (c *Client) probeRegistries(specs []ImageSpec) map[string]*UnavailableError {
+	timeout := c.getProbeTimeout()
+	if timeout <= 0 || c.Offline() {
+		return nil
+	}
+
+	targets := make(map[string]*registryTarget)
+	for _, spec := range specs {
+		registryKey := DetectRegistry(spec.Image)
+		if _, ok := targets[registryKey]; ok {
+			continue
+		}
+		target, err := c.resolveTarget(spec.Image)
+		if err != nil {
+			continue // 解析失败的镜像由后续流程报告错误
+		}
+		targets[registryKey] = target
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	unavailable := make(map[string]*UnavailableError)
+	for registryKey, target := range targets {
+		wg.Add(1)
+		go func(registryKey string, target *registryTarget) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if err := c.probeRegistry(ctx, target.registryURL); err != nil {
+				c.logger.Warn("registry 不可用，跳过该 registry 的所有镜像",
+					"registryKey", registryKey,
+					"error", err)
+				mu.Lock()
+				unavailable[registryKey] = err
+				mu.Unlock()
+			}
+		}(registryKey, target)
+	}
+	wg.Wait()
+	return unavailable
+}
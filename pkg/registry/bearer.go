@@ -0,0 +1,26 @@
+package registry
+
+import (
+	"context"
+	"time"
+)
+
+// AddBearerToken 为指定 registry 设置预先获取的 bearer token，访问该 registry 时直接使用，不再请求认证服务
+// 用于由外部系统完成认证的场景，如 ACR 的 token 交换、ECR 凭据助手的输出或代理签发的 JWT
+// 会替换该 registry 已有的凭据；token 被 registry 拒绝时不会重新获取，过期后需要重新设置，
+// 需要定期更新时可通过 CredentialProvider 返回设置了 BearerToken 的凭据
+func (c *Client) AddBearerToken(registryKey, token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.credentials[registryKey] = &RegistryCredential{BearerToken: token}
+}
+
+// staticToken 返回 credentialKey 对应凭据中预先获取的 bearer token
+// 有效期未知，按默认有效期返回，只影响 token 缓存
+func (c *Client) staticToken(ctx context.Context, credentialKey string) (*TokenInfo, bool) {
+	cred, ok := c.credential(ctx, credentialKey)
+	if !ok || cred.BearerToken == "" {
+		return nil, false
+	}
+	return &TokenInfo{Token: cred.BearerToken, ExpiresIn: defaultTokenExpiry, IssuedAt: time.Now()}, true
+}
@@ -0,0 +1,169 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MaxBlobResumes GetBlob 下载中断后通过 Range 请求续传的最大次数
+const MaxBlobResumes = 3
+
+// GetBlob 下载 blob 并流式写入 w，返回写入的字节数
+// 写入的同时计算 sha256，下载完成后与 digest 比对，不一致时返回错误（此时 w 中已写入了全部内容，调用方应丢弃）
+// 连接中断时通过 HTTP Range 请求从已写入的位置续传，最多 MaxBlobResumes 次；
+// registry 不支持 Range 时重新下载并跳过已写入的部分，w 不会收到重复的内容
+// 只支持 sha256 digest；w 返回的写入错误和 ctx 取消不会触发续传
+func (c *Client) GetBlob(image, digest string, w io.Writer) (int64, error) {
+	return c.GetBlobContext(context.Background(), image, digest, w)
+}
+
+// GetBlobContext 与 GetBlob 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) GetBlobContext(ctx context.Context, image, digest string, w io.Writer) (int64, error) {
+	if err := c.checkImagePolicy(image); err != nil {
+		return 0, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return 0, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return 0, err
+	}
+	return c.streamBlob(ctx, target, authorization, digest, w)
+}
+
+// streamBlob 下载 blob 并写入 w，校验 sha256，连接中断时续传
+func (c *Client) streamBlob(ctx context.Context, target *registryTarget, authorization, digest string, w io.Writer) (int64, error) {
+	if encoded, ok := strings.CutPrefix(digest, "sha256:"); !ok || len(encoded) != sha256.Size*2 {
+		return 0, fmt.Errorf("无效的 blob digest '%s'，只支持 sha256", digest)
+	}
+
+	download := &blobDownload{w: w, hash: sha256.New(), size: -1}
+	for resumes := 0; ; resumes++ {
+		body, err := c.openBlobRange(ctx, target, authorization, digest, download)
+		if newAuthorization, ok := c.reauthorize(ctx, target, err); ok {
+			authorization = newAuthorization
+			body, err = c.openBlobRange(ctx, target, authorization, digest, download)
+		}
+		if err != nil {
+			return download.written, err
+		}
+		_, err = io.Copy(download, body)
+		body.Close()
+		if err == nil && download.size >= 0 && download.written < download.size {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil {
+			break
+		}
+		if download.writeErr != nil {
+			return download.written, fmt.Errorf("写入 blob 失败: %w", download.writeErr)
+		}
+		if ctx.Err() != nil || resumes >= MaxBlobResumes {
+			return download.written, fmt.Errorf("下载 blob %s 失败: %w", digest, err)
+		}
+		c.logger.Debug("blob 下载中断，续传",
+			"repository", target.repository,
+			"digest", digest,
+			"offset", download.written,
+			"error", err)
+	}
+
+	if actual := "sha256:" + hex.EncodeToString(download.hash.Sum(nil)); actual != digest {
+		return download.written, fmt.Errorf("blob digest 不匹配: 期望 %s，实际 %s", digest, actual)
+	}
+	return download.written, nil
+}
+
+// blobDownload 记录 blob 下载的进度，写入时同时计算摘要
+type blobDownload struct {
+	w        io.Writer
+	hash     hash.Hash
+	written  int64 // 已写入 w 的字节数
+	size     int64 // blob 总大小，未知时为 -1
+	writeErr error // w 返回的写入错误
+}
+
+// Write 实现 io.Writer 接口
+func (d *blobDownload) Write(p []byte) (int, error) {
+	n, err := d.w.Write(p)
+	d.hash.Write(p[:n])
+	d.written += int64(n)
+	if err != nil {
+		d.writeErr = err
+	}
+	return n, err
+}
+
+// openBlobRange 从已写入的位置打开 blob，返回的响应体由调用方关闭
+// registry 忽略 Range 返回完整内容时，跳过已写入的部分
+func (c *Client) openBlobRange(ctx context.Context, target *registryTarget, authorization, digest string, download *blobDownload) (io.ReadCloser, error) {
+	var header http.Header
+	if download.written > 0 {
+		header = http.Header{"Range": {fmt.Sprintf("bytes=%d-", download.written)}}
+	}
+	resp, err := c.doRegistryRequest(withTransfer(ctx), http.MethodGet, target, "blobs/"+digest, authorization, header)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if resp.ContentLength >= 0 {
+			download.size = resp.ContentLength
+		}
+		if download.written > 0 {
+			if _, err := io.CopyN(io.Discard, resp.Body, download.written); err != nil {
+				resp.Body.Close()
+				return nil, fmt.Errorf("跳过已下载的内容失败: %w", err)
+			}
+		}
+		return resp.Body, nil
+	case http.StatusPartialContent:
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != download.written {
+			resp.Body.Close()
+			return nil, fmt.Errorf("registry 返回的 Content-Range '%s' 与请求的位置 %d 不符", resp.Header.Get("Content-Range"), download.written)
+		}
+		download.size = size
+		return resp.Body, nil
+	default:
+		defer resp.Body.Close()
+		return nil, newResponseError("获取 blob 失败", resp)
+	}
+}
+
+// parseContentRange 解析 "bytes <start>-<end>/<size>" 格式的 Content-Range，size 为 "*" 时返回 -1
+func parseContentRange(value string) (start, size int64, ok bool) {
+	rangeSpec, ok := strings.CutPrefix(value, "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+	rangeSpec, sizeSpec, ok := strings.Cut(rangeSpec, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	startSpec, _, ok := strings.Cut(rangeSpec, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(startSpec, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	size = -1
+	if sizeSpec != "*" {
+		if size, err = strconv.ParseInt(sizeSpec, 10, 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, size, true
+}
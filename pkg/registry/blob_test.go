@@ -0,0 +1,84 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowBlobHandler 分两次写出 blob 内容，中间等待 delay，模拟传输较慢的大 blob
+func slowBlobHandler(content []byte, delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !strings.Contains(req.URL.Path, "/blobs/") {
+			return
+		}
+		half := len(content) / 2
+		w.Write(content[:half])
+		w.(http.Flusher).Flush()
+		time.Sleep(delay)
+		w.Write(content[half:])
+	}
+}
+
+func TestGetBlobNotLimitedByRequestTimeout(t *testing.T) {
+	reg := newTestRegistry(t)
+	content := bytes.Repeat([]byte("layer"), 1024)
+	desc := reg.putBlob(MediaTypeOCILayer, content)
+	reg.handler = slowBlobHandler(content, 300*time.Millisecond)
+
+	// 读取响应体超过 WithTimeout 设置的总超时，下载仍然成功
+	client := reg.client().WithTimeout(100 * time.Millisecond)
+	var buf bytes.Buffer
+	if _, err := client.GetBlob(reg.host()+"/app", desc.Digest, &buf); err != nil {
+		t.Fatalf("GetBlob: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), content) {
+		t.Errorf("下载了 %d 字节, 期望 %d 字节", buf.Len(), len(content))
+	}
+
+	// 传输由 ctx 控制
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetBlobContext(ctx, reg.host()+"/app", desc.Digest, &bytes.Buffer{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ctx 超时时错误 = %v, 期望 context.DeadlineExceeded", err)
+	}
+}
+
+func TestWithTimeoutWhileInUse(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	desc := reg.putBlob(MediaTypeOCILayer, []byte("layer"))
+	image := reg.host() + "/app"
+	client := reg.client()
+
+	// 请求进行中修改超时不产生数据竞争（go test -race）
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := client.GetBlob(image, desc.Digest, &bytes.Buffer{}); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := client.GetDigest(image, "v1"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		client.WithTimeout(time.Duration(i+1) * time.Second)
+	}
+	wg.Wait()
+	if timeout := client.getHTTPClient().Timeout; timeout != 50*time.Second {
+		t.Errorf("Timeout = %v, 期望 50s", timeout)
+	}
+}
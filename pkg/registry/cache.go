@@ -0,0 +1,242 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultManifestCacheTTL 通过 Cache 缓存 manifest 时默认的有效期
+const DefaultManifestCacheTTL = 24 * time.Hour
+
+// Cache 是通用的键值缓存，可作为 token 缓存和 manifest 缓存的存储后端
+// 多个服务实例使用同一个共享后端（如 Redis）时，可以共享 token 和 manifest
+// 实现需要保证并发安全；后端出错时按未命中处理，不影响请求流程
+type Cache interface {
+	// Get 返回缓存的值，不存在或已过期时返回 false
+	Get(key string) ([]byte, bool)
+	// Set 缓存值，ttl 为 0 或负数表示不过期
+	Set(key string, value []byte, ttl time.Duration)
+	// Delete 删除缓存的值
+	Delete(key string)
+}
+
+// WithCache 使用 cache 同时作为 token 缓存和 manifest 缓存
+// token 按过期时间缓存，manifest 缓存 DefaultManifestCacheTTL
+// 为 nil 时关闭两种缓存
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithCache(cache Cache) *Client {
+	if cache == nil {
+		return c.WithTokenCache(nil).WithManifestCache(nil)
+	}
+	return c.WithTokenCache(NewCacheTokenCache(cache)).
+		WithManifestCache(NewCacheManifestCache(cache, DefaultManifestCacheTTL))
+}
+
+// cacheTokenEntry 表示通过 Cache 保存的 token
+type cacheTokenEntry struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	IssuedAt     time.Time `json:"issuedAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// cacheTokenCache 将 Cache 适配为 TokenCache
+type cacheTokenCache struct {
+	cache Cache
+}
+
+// NewCacheTokenCache 使用 Cache 作为 TokenCache 的存储后端，token 在过期时间后自动失效
+func NewCacheTokenCache(cache Cache) TokenCache {
+	return &cacheTokenCache{cache: cache}
+}
+
+// Get 实现 TokenCache 接口
+func (t *cacheTokenCache) Get(key string) (*TokenInfo, bool) {
+	data, ok := t.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	var entry cacheTokenEntry
+	if err := json.Unmarshal(data, &entry); err != nil || !time.Now().Before(entry.ExpiresAt) {
+		return nil, false
+	}
+	return &TokenInfo{
+		Token:        entry.Token,
+		RefreshToken: entry.RefreshToken,
+		IssuedAt:     entry.IssuedAt,
+		ExpiresIn:    entry.ExpiresAt.Sub(entry.IssuedAt),
+	}, true
+}
+
+// Set 实现 TokenCache 接口
+func (t *cacheTokenCache) Set(key string, info *TokenInfo) {
+	ttl := time.Until(info.ExpiresAt())
+	if ttl <= 0 {
+		return
+	}
+	data, err := json.Marshal(cacheTokenEntry{
+		Token:        info.Token,
+		RefreshToken: info.RefreshToken,
+		IssuedAt:     info.IssuedAt,
+		ExpiresAt:    info.ExpiresAt(),
+	})
+	if err != nil {
+		return
+	}
+	t.cache.Set(key, data, ttl)
+}
+
+// cacheManifestCache 将 Cache 适配为 ManifestCache
+type cacheManifestCache struct {
+	cache Cache
+	ttl   time.Duration
+}
+
+// NewCacheManifestCache 使用 Cache 作为 ManifestCache 的存储后端
+// ttl 为每个 manifest 的缓存时间，0 或负数表示不过期
+func NewCacheManifestCache(cache Cache, ttl time.Duration) ManifestCache {
+	return &cacheManifestCache{cache: cache, ttl: ttl}
+}
+
+// Get 实现 ManifestCache 接口
+func (m *cacheManifestCache) Get(key string) (*CachedManifest, bool) {
+	data, ok := m.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	var manifest CachedManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, false
+	}
+	return &manifest, true
+}
+
+// Set 实现 ManifestCache 接口
+func (m *cacheManifestCache) Set(key string, manifest *CachedManifest) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return
+	}
+	m.cache.Set(key, data, m.ttl)
+}
+
+// MemoryCache 是进程内的 Cache 实现，超过最大条目数时淘汰最久未使用的条目
+type MemoryCache struct {
+	lru *lruCache[[]byte]
+}
+
+// NewMemoryCache 创建进程内的缓存
+// maxEntries 为最大条目数，0 或负数使用默认值 DefaultManifestCacheSize
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{lru: newLRUCache[[]byte](maxEntries)}
+}
+
+// Get 实现 Cache 接口
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	return m.lru.get(key)
+}
+
+// Set 实现 Cache 接口
+func (m *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	m.lru.set(key, value, ttl)
+}
+
+// Delete 实现 Cache 接口
+func (m *MemoryCache) Delete(key string) {
+	m.lru.delete(key)
+}
+
+// FileCache 是将每个条目保存为目录中一个文件的 Cache 实现
+// 文件名为 key 的 sha256，写入时先写临时文件再重命名，可以被同一台机器上的多个进程共享
+// 文件权限为 0600（临时文件的默认权限），已过期的条目在读取时删除
+type FileCache struct {
+	dir string
+}
+
+// fileCacheEntry 表示 FileCache 中的条目
+type fileCacheEntry struct {
+	Key       string    `json:"key"`
+	Value     []byte    `json:"value"`
+	ExpiresAt time.Time `json:"expiresAt"` // 零值表示不过期
+}
+
+// DefaultCacheDir 返回 FileCache 默认的目录
+// Linux 上为 ~/.cache/docker-manifest/cache
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("获取缓存目录失败: %w", err)
+	}
+	return filepath.Join(dir, "docker-manifest", "cache"), nil
+}
+
+// NewFileCache 创建保存在 dir 中的缓存，dir 为空时使用 DefaultCacheDir
+func NewFileCache(dir string) (*FileCache, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	return &FileCache{dir: dir}, nil
+}
+
+// Dir 返回缓存目录
+func (f *FileCache) Dir() string {
+	return f.dir
+}
+
+// Get 实现 Cache 接口
+func (f *FileCache) Get(key string) ([]byte, bool) {
+	path := f.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry fileCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return nil, false
+	}
+	if !entry.ExpiresAt.IsZero() && !time.Now().Before(entry.ExpiresAt) {
+		os.Remove(path)
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+// Set 实现 Cache 接口
+// 写入失败（如目录不可写）时忽略，不影响请求流程
+func (f *FileCache) Set(key string, value []byte, ttl time.Duration) {
+	data, err := json.Marshal(fileCacheEntry{Key: key, Value: value, ExpiresAt: expiresAt(ttl)})
+	if err != nil {
+		return
+	}
+	writeFileAtomic(f.dir, f.path(key), data)
+}
+
+// Delete 实现 Cache 接口
+func (f *FileCache) Delete(key string) {
+	os.Remove(f.path(key))
+}
+
+// path 返回 key 对应的文件路径
+func (f *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// expiresAt 根据 ttl 计算过期时间，ttl 为 0 或负数时返回零值（不过期）
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
@@ -1,18 +1,20 @@
 package registry
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 	"sync"
 	"time"
-
-	"go.uber.org/zap"
 )
 
 // RegistryCredential 表示 registry 的认证凭据
 type RegistryCredential struct {
 	Username string
 	Token    string
+
+	// BearerToken 预先获取的 bearer token，设置后直接用于访问 registry，不再请求认证服务（见 AddBearerToken）
+	BearerToken string
 }
 
 // Client 表示一个 Docker Registry 客户端
@@ -20,8 +22,37 @@ type RegistryCredential struct {
 type Client struct {
 	httpClient  *http.Client
 	credentials map[string]*RegistryCredential // registry key -> 凭据
-	mu          sync.RWMutex                   // 保护 credentials 的并发访问
-	logger      *zap.Logger                    // 日志记录器
+	policy      *ImagePolicy                   // 镜像访问策略，nil 表示不限制
+	mu          sync.RWMutex                   // 保护 credentials、policy 等配置的并发访问
+	logger      Logger                         // 日志记录器
+
+	credentialProvider CredentialProvider // 凭据提供者，nil 表示只使用 credentials
+
+	batchImageTimeout time.Duration             // 批量获取时单个镜像的超时，0 表示不限制
+	indexLimits       IndexLimits               // 处理镜像索引时的安全限制
+	maxRateLimitWait  time.Duration             // 被限流时单个请求最多等待的时间，0 使用默认值
+	spillDir          string                    // 批量获取时 manifest 内容的落盘目录，为空时不落盘
+	hooks             []RequestHook             // HTTP 请求钩子
+	fetchHooks        []FetchHook               // manifest 获取钩子
+	metrics           Metrics                   // 运行指标，nil 表示不收集
+	tokenCache        TokenCache                // token 缓存，nil 表示不缓存
+	manifestCache     ManifestCache             // manifest 缓存，nil 表示不缓存
+	readOnly          bool                      // 只读模式，禁止修改 registry 的操作
+	offline           bool                      // 离线模式，禁止网络请求，只使用缓存
+	anonymousFallback bool                      // 凭据被认证服务拒绝时是否回退为匿名访问
+	probeTimeout      time.Duration             // 批量任务开始前探测 registry 的超时，0 表示不探测
+	blobBackends      map[string]BlobBackend    // registry key 或域名 -> blob 上传的存储后端
+	imageTransports   map[string]ImageTransport // 传输方式 -> 通过 WithImageTransport 注册的实现
+
+	rateMu           sync.Mutex           // 保护 rateLimitedUntil
+	rateLimitedUntil map[string]time.Time // 域名 -> 限流暂停截止时间
+
+	challengeMu sync.Mutex        // 保护 challenges
+	challenges  map[string]string // registry 地址 -> /v2/ 接口返回的 WWW-Authenticate header
+
+	authGroup flightGroup // 合并并发的相同认证请求
+
+	availability availabilityTracker // 各域名的可用性和熔断状态
 }
 
 // NewClient 创建一个空的 registry 客户端
@@ -30,13 +61,13 @@ type Client struct {
 func NewClient() *Client {
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-			Transport: &http.Transport{
+			Timeout: defaultRequestTimeout,
+			Transport: newRegistryTransport(&http.Transport{
 				Proxy: http.ProxyFromEnvironment,
-			},
+			}),
 		},
 		credentials: make(map[string]*RegistryCredential),
-		logger:      zap.NewNop(),
+		logger:      nopLogger{},
 	}
 }
 
@@ -72,14 +103,55 @@ func NewClientWithProxy(proxyURL string) (*Client, error) {
 
 	return &Client{
 		httpClient: &http.Client{
-			Timeout:   30 * time.Second,
-			Transport: transport,
+			Timeout:   defaultRequestTimeout,
+			Transport: newRegistryTransport(transport),
 		},
 		credentials: make(map[string]*RegistryCredential),
-		logger:      zap.NewNop(),
+		logger:      nopLogger{},
 	}, nil
 }
 
+// NewClientWithHTTPClient 创建一个使用自定义 http.Client 的 registry 客户端
+// 可用于调整连接池、自定义 TLS、录制请求或在测试中注入替身
+// 传入的 http.Client 会被复制，不会被修改；为 nil 时等同于 NewClient()
+func NewClientWithHTTPClient(httpClient *http.Client) *Client {
+	client := NewClient()
+	if httpClient != nil {
+		hc := *httpClient
+		hc.Transport = wrapTransport(hc.Transport)
+		client.httpClient = &hc
+	}
+	return client
+}
+
+// WithTransport 为已存在的 Client 设置自定义 RoundTripper
+// 返回 Client 本身以支持链式调用
+// 注意：替换 transport 会清除之前通过 SetRegistryCACert 等方法设置的 TLS 配置
+func (c *Client) WithTransport(transport http.RoundTripper) *Client {
+	if transport != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		client := *c.httpClient
+		client.Transport = wrapTransport(transport)
+		c.httpClient = &client
+	}
+	return c
+}
+
+// wrapTransport 包装自定义 transport
+// *http.Transport 会被包装为按域名路由的 transport，以继续支持按 registry 配置 TLS
+// 其他 RoundTripper 原样使用
+func wrapTransport(transport http.RoundTripper) http.RoundTripper {
+	switch t := transport.(type) {
+	case nil:
+		return newRegistryTransport(http.DefaultTransport.(*http.Transport).Clone())
+	case *http.Transport:
+		return newRegistryTransport(t)
+	default:
+		return transport
+	}
+}
+
 // AddCredential 添加或更新指定 registry 的凭据
 func (c *Client) AddCredential(registryKey, username, token string) {
 	c.mu.Lock()
@@ -98,28 +170,12 @@ func (c *Client) RemoveCredential(registryKey string) {
 }
 
 // GetCredential 获取指定 registry 的凭据
+// 优先返回 AddCredential 添加的凭据，没有时从 WithCredentialProvider 设置的凭据提供者获取
 func (c *Client) GetCredential(registryKey string) (*RegistryCredential, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	cred, ok := c.credentials[registryKey]
-	return cred, ok
-}
-
-// NewClientWithLogger 创建一个带自定义 logger 的 registry 客户端
-// logger: 自定义的 zap.Logger 实例
-func NewClientWithLogger(logger *zap.Logger) *Client {
-	client := NewClient()
-	if logger != nil {
-		client.logger = logger
-	}
-	return client
+	return c.GetCredentialContext(context.Background(), registryKey)
 }
 
-// WithLogger 为已存在的 Client 设置 logger
-// 返回 Client 本身以支持链式调用
-func (c *Client) WithLogger(logger *zap.Logger) *Client {
-	if logger != nil {
-		c.logger = logger
-	}
-	return c
+// GetCredentialContext 与 GetCredential 相同，但凭据提供者的调用受 ctx 控制
+func (c *Client) GetCredentialContext(ctx context.Context, registryKey string) (*RegistryCredential, bool) {
+	return c.credential(ctx, registryKey)
 }
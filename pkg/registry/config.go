@@ -0,0 +1,237 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxConfigSize 镜像配置 blob 的最大字节数
+const MaxConfigSize = 8 << 20
+
+// ImageConfig 表示镜像配置（config blob）中常用的字段
+type ImageConfig struct {
+	Architecture string          `json:"architecture"`
+	OS           string          `json:"os"`
+	Variant      string          `json:"variant,omitempty"`
+	Created      *time.Time      `json:"created,omitempty"`
+	Author       string          `json:"author,omitempty"`
+	Config       ContainerConfig `json:"config"`
+	RootFS       RootFS          `json:"rootfs"`
+	History      []History       `json:"history,omitempty"`
+}
+
+// ContainerConfig 表示镜像的默认运行参数
+type ContainerConfig struct {
+	User         string              `json:"User,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	Volumes      map[string]struct{} `json:"Volumes,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	StopSignal   string              `json:"StopSignal,omitempty"`
+}
+
+// RootFS 表示镜像的根文件系统层
+type RootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+// History 表示镜像构建历史中的一步
+type History struct {
+	Created    *time.Time `json:"created,omitempty"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	Author     string     `json:"author,omitempty"`
+	Comment    string     `json:"comment,omitempty"`
+	EmptyLayer bool       `json:"empty_layer,omitempty"`
+}
+
+// ConfigResult 表示单个镜像的配置获取结果
+type ConfigResult struct {
+	Image        string       // 镜像名称
+	Tag          string       // 镜像标签
+	Digest       string       // 镜像 manifest digest（多架构镜像为所选平台的 manifest）
+	MediaType    string       // 镜像 manifest 的媒体类型
+	ConfigDigest string       // 配置 blob 的 digest
+	Config       *ImageConfig // 解析后的镜像配置
+	Layers       []Descriptor // 镜像 manifest 中的层（包含大小）
+	Error        error        // 错误信息（如果获取失败）
+}
+
+// batchErr 实现 batchResult 接口
+func (r ConfigResult) batchErr() error {
+	return r.Error
+}
+
+// GetConfigs 批量获取多个镜像的配置（labels、创建时间、架构等）
+// 分组和批量认证与 GetManifestsWithDigest 相同，每个镜像额外请求一次配置 blob
+// 镜像为多架构索引时按 ImageSpec.Platform 或 opts.Platform 选择平台
+func (c *Client) GetConfigs(imageSpecs []ImageSpec, opts BatchOptions) []ConfigResult {
+	var platform *Platform
+	if opts.Platform != "" {
+		p, err := ParsePlatform(opts.Platform)
+		if err != nil {
+			results := make([]ConfigResult, len(imageSpecs))
+			for i, spec := range imageSpecs {
+				results[i] = ConfigResult{Image: spec.Image, Tag: spec.Tag, Error: err}
+			}
+			return results
+		}
+		platform = &p
+	}
+
+	fetch := func(ctx context.Context, spec ImageSpec, token groupToken) ConfigResult {
+		return c.fetchSingleConfig(ctx, spec, token, platform)
+	}
+	return runBatch(c, context.Background(), imageSpecs, opts, fetch, func(spec ImageSpec, err error) ConfigResult {
+		return ConfigResult{Image: spec.Image, Tag: spec.Tag, Error: err}
+	})
+}
+
+// fetchSingleConfig 获取单个镜像的配置
+func (c *Client) fetchSingleConfig(batchCtx context.Context, spec ImageSpec, token groupToken, platform *Platform) ConfigResult {
+	ctx, cancel := c.imageContext(batchCtx)
+	defer cancel()
+
+	result := ConfigResult{
+		Image: spec.Image,
+		Tag:   spec.Tag,
+	}
+	result.Error = c.wrapImageTimeout(ctx, c.getConfig(ctx, spec, token.token, platform, &result))
+	return result
+}
+
+// getConfig 获取镜像 manifest 和配置 blob，结果写入 result
+// batchToken 为空时单独认证；spec 指定的平台优先于 platform，都为空时使用 registry 默认平台
+func (c *Client) getConfig(ctx context.Context, spec ImageSpec, batchToken string, platform *Platform, result *ConfigResult) error {
+	if spec.Platform != "" {
+		p, err := ParsePlatform(spec.Platform)
+		if err != nil {
+			return err
+		}
+		platform = &p
+	}
+
+	target, err := c.resolveSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	var authorization string
+	if batchToken != "" {
+		authorization = "Bearer " + batchToken
+	} else if authorization, err = c.authorize(ctx, target); err != nil {
+		return err
+	}
+
+	fetched, err := c.fetchManifest(ctx, target, authorization, spec.Tag)
+	if newAuthorization, ok := c.reauthorizer(batchToken)(ctx, target, err); ok {
+		authorization = newAuthorization
+		fetched, err = c.fetchManifest(ctx, target, authorization, spec.Tag)
+	}
+	if err != nil {
+		return err
+	}
+
+	fetched, err = c.resolveImageManifest(ctx, target, authorization, fetched, platform)
+	if err != nil {
+		return err
+	}
+	result.Digest = fetched.digest
+	result.MediaType = fetched.mediaType
+
+	config, configDigest, err := c.fetchImageConfig(ctx, target, authorization, fetched)
+	if err != nil {
+		return err
+	}
+	result.ConfigDigest = configDigest
+	result.Config = config
+
+	// fetchImageConfig 已校验过 manifest 可以解析
+	var manifest ImageManifest
+	if err := json.Unmarshal(fetched.body, &manifest); err == nil {
+		result.Layers = manifest.Layers
+	}
+	return nil
+}
+
+// resolveImageManifest 将多架构索引解析为单个平台的镜像 manifest，不是索引时原样返回
+// 按指定平台、registry 默认平台、linux/amd64 的顺序选择
+func (c *Client) resolveImageManifest(ctx context.Context, target *registryTarget, authorization string, fetched *fetchedManifest, platform *Platform) (*fetchedManifest, error) {
+	if platform == nil {
+		p, ok, err := defaultPlatform(target.registryKey)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			p = Platform{OS: "linux", Architecture: "amd64"}
+		}
+		platform = &p
+	}
+	return c.resolvePlatform(ctx, target, authorization, fetched, *platform)
+}
+
+// fetchImageConfig 获取镜像 manifest（不能是索引）引用的配置 blob，返回解析后的配置和配置 blob 的 digest
+func (c *Client) fetchImageConfig(ctx context.Context, target *registryTarget, authorization string, fetched *fetchedManifest) (*ImageConfig, string, error) {
+	var manifest ImageManifest
+	if err := json.Unmarshal(fetched.body, &manifest); err != nil {
+		return nil, "", fmt.Errorf("解析 manifest 失败: %w", err)
+	}
+	if manifest.Config.Digest == "" {
+		return nil, "", fmt.Errorf("manifest 中没有配置 blob (媒体类型: %s)", fetched.mediaType)
+	}
+
+	body, err := c.fetchConfigBlob(ctx, target, authorization, manifest.Config)
+	if err != nil {
+		return nil, "", fmt.Errorf("获取镜像配置失败: %w", err)
+	}
+
+	var config ImageConfig
+	if err := json.Unmarshal(body, &config); err != nil {
+		return nil, "", fmt.Errorf("解析镜像配置失败: %w", err)
+	}
+	return &config, manifest.Config.Digest, nil
+}
+
+// fetchConfigBlob 获取镜像配置 blob
+// 设置了 manifest 缓存时按 digest 缓存配置 blob（内容由 digest 确定，不会过时），缓存命中时不再请求 registry；
+// 离线模式下只读取缓存
+func (c *Client) fetchConfigBlob(ctx context.Context, target *registryTarget, authorization string, desc Descriptor) ([]byte, error) {
+	if c.Offline() {
+		return c.cachedConfig(target, desc)
+	}
+	if body, ok := c.lookupConfig(desc); ok {
+		return body, nil
+	}
+	body, err := c.fetchBlob(ctx, target, authorization, desc, MaxConfigSize)
+	if err != nil {
+		return nil, err
+	}
+	if cache := c.getManifestCache(); cache != nil && strings.HasPrefix(desc.Digest, "sha256:") {
+		cache.Set(configCacheKey(desc.Digest), &CachedManifest{Body: body, MediaType: desc.MediaType, Digest: desc.Digest})
+	}
+	return body, nil
+}
+
+// lookupConfig 从 manifest 缓存中读取镜像配置 blob，内容与 digest 不一致（如缓存文件损坏）时视为未命中
+func (c *Client) lookupConfig(desc Descriptor) ([]byte, bool) {
+	cache := c.getManifestCache()
+	if cache == nil || !strings.HasPrefix(desc.Digest, "sha256:") {
+		return nil, false
+	}
+	entry, ok := cache.Get(configCacheKey(desc.Digest))
+	if !ok || computeDigest(entry.Body) != desc.Digest {
+		return nil, false
+	}
+	return entry.Body, true
+}
+
+// configCacheKey 返回镜像配置 blob 在 manifest 缓存中的 key，只按 digest 区分，不同仓库中相同的配置共用一个条目
+func configCacheKey(digest string) string {
+	return "config|" + digest
+}
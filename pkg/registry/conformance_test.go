@@ -0,0 +1,319 @@
+package registry
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// corpusDir 为 manifest 语料库目录
+// 添加新的 registry 兼容问题时，将 registry 返回的 manifest 原样（不要重新格式化）保存到该目录，
+// 并在 corpus.json 中添加一项期望结果
+const corpusDir = "testdata/manifests"
+
+// corpusEntry 表示语料库中一个 manifest 的期望结果
+type corpusEntry struct {
+	File         string            `json:"file"`         // 文件名
+	Description  string            `json:"description"`  // 来源说明
+	ContentType  string            `json:"contentType"`  // registry 返回的 Content-Type，为空表示未返回
+	MediaType    string            `json:"mediaType"`    // 期望识别的媒体类型
+	Digest       string            `json:"digest"`       // 期望的 digest
+	ArtifactType string            `json:"artifactType"` // 期望的 artifactType（仅 manifest）
+	Config       string            `json:"config"`       // 期望的 config 媒体类型（仅 manifest）
+	Layers       int               `json:"layers"`       // 期望的层数（仅 manifest）
+	Subject      string            `json:"subject"`      // 期望的 subject digest（仅 manifest）
+	Platforms    []string          `json:"platforms"`    // 期望的子 manifest 平台，按顺序，没有平台的子项不列出（仅索引）
+	Select       map[string]string `json:"select"`       // 平台 -> 期望选择的子 manifest digest（仅索引）
+}
+
+// corpusManifest 表示语料库中的一个 manifest 及其期望结果
+type corpusManifest struct {
+	corpusEntry
+	body []byte
+}
+
+// loadCorpus 读取 manifest 语料库
+func loadCorpus(t *testing.T) []corpusManifest {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(corpusDir, "corpus.json"))
+	if err != nil {
+		t.Fatalf("读取 corpus.json 失败: %v", err)
+	}
+	var entries []corpusEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("解析 corpus.json 失败: %v", err)
+	}
+
+	listed := make(map[string]bool, len(entries))
+	corpus := make([]corpusManifest, len(entries))
+	for i, entry := range entries {
+		body, err := os.ReadFile(filepath.Join(corpusDir, entry.File))
+		if err != nil {
+			t.Fatalf("读取 %s 失败: %v", entry.File, err)
+		}
+		listed[entry.File] = true
+		corpus[i] = corpusManifest{corpusEntry: entry, body: body}
+	}
+
+	// 语料库中的每个文件都必须有期望结果
+	files, err := filepath.Glob(filepath.Join(corpusDir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if name := filepath.Base(file); name != "corpus.json" && !listed[name] {
+			t.Errorf("%s 没有在 corpus.json 中列出", name)
+		}
+	}
+	return corpus
+}
+
+func TestCorpusDigest(t *testing.T) {
+	for _, m := range loadCorpus(t) {
+		t.Run(m.File, func(t *testing.T) {
+			// digest 按原始字节计算，文件被重新格式化（包括换行符转换）后 digest 会改变
+			if got := computeDigest(m.body); got != m.Digest {
+				t.Errorf("digest = %s, 期望 %s", got, m.Digest)
+			}
+		})
+	}
+}
+
+func TestCorpusMediaType(t *testing.T) {
+	for _, m := range loadCorpus(t) {
+		t.Run(m.File, func(t *testing.T) {
+			if got := detectManifestMediaType(m.ContentType, m.body); got != m.MediaType {
+				t.Errorf("Content-Type %q: 媒体类型 = %s, 期望 %s", m.ContentType, got, m.MediaType)
+			}
+			// registry 未返回 Content-Type 时从内容推断
+			if got := detectManifestMediaType("", m.body); got != m.MediaType {
+				t.Errorf("无 Content-Type: 媒体类型 = %s, 期望 %s", got, m.MediaType)
+			}
+			// 带参数的 Content-Type
+			if m.ContentType != "" {
+				if got := detectManifestMediaType(m.ContentType+"; charset=utf-8", m.body); got != m.MediaType {
+					t.Errorf("带参数的 Content-Type: 媒体类型 = %s, 期望 %s", got, m.MediaType)
+				}
+			}
+		})
+	}
+}
+
+func TestCorpusParse(t *testing.T) {
+	for _, m := range loadCorpus(t) {
+		t.Run(m.File, func(t *testing.T) {
+			switch {
+			case IsIndexMediaType(m.MediaType):
+				checkCorpusIndex(t, m)
+			case m.MediaType == MediaTypeDockerManifestSchema1, m.MediaType == MediaTypeDockerManifestSchema1Signed:
+				// schema 1 不解析为 ImageManifest
+			default:
+				checkCorpusManifest(t, m)
+			}
+		})
+	}
+}
+
+// checkCorpusManifest 检查 manifest 的解析结果
+func checkCorpusManifest(t *testing.T, m corpusManifest) {
+	t.Helper()
+	var manifest ImageManifest
+	if err := json.Unmarshal(m.body, &manifest); err != nil {
+		t.Fatalf("解析 manifest 失败: %v", err)
+	}
+	if manifest.SchemaVersion != 2 {
+		t.Errorf("schemaVersion = %d, 期望 2", manifest.SchemaVersion)
+	}
+	if manifest.ArtifactType != m.ArtifactType {
+		t.Errorf("artifactType = %q, 期望 %q", manifest.ArtifactType, m.ArtifactType)
+	}
+	if manifest.Config.MediaType != m.Config {
+		t.Errorf("config 媒体类型 = %s, 期望 %s", manifest.Config.MediaType, m.Config)
+	}
+	if len(manifest.Layers) != m.Layers {
+		t.Errorf("层数 = %d, 期望 %d", len(manifest.Layers), m.Layers)
+	}
+	subject := ""
+	if manifest.Subject != nil {
+		subject = manifest.Subject.Digest
+	}
+	if subject != m.Subject {
+		t.Errorf("subject = %q, 期望 %q", subject, m.Subject)
+	}
+}
+
+// checkCorpusIndex 检查索引的解析结果和平台选择
+func checkCorpusIndex(t *testing.T, m corpusManifest) {
+	t.Helper()
+	var index ImageIndex
+	if err := json.Unmarshal(m.body, &index); err != nil {
+		t.Fatalf("解析索引失败: %v", err)
+	}
+
+	var platforms []string
+	for _, desc := range index.Manifests {
+		if desc.Platform != nil {
+			platforms = append(platforms, desc.Platform.String())
+		}
+	}
+	if !slices.Equal(platforms, m.Platforms) {
+		t.Errorf("平台 = %v, 期望 %v", platforms, m.Platforms)
+	}
+
+	for platform, want := range m.Select {
+		p, err := ParsePlatform(platform)
+		if err != nil {
+			t.Fatal(err)
+		}
+		desc, err := selectPlatformManifest(&index, p)
+		if err != nil {
+			t.Errorf("选择平台 %s 失败: %v", platform, err)
+			continue
+		}
+		if desc.Digest != want {
+			t.Errorf("平台 %s 选择了 %s, 期望 %s", platform, desc.Digest, want)
+		}
+	}
+
+	// 没有任何子项匹配的平台必须返回错误
+	if _, err := selectPlatformManifest(&index, Platform{OS: "plan9", Architecture: "amd64"}); err == nil {
+		t.Error("选择不存在的平台 plan9/amd64 时期望返回错误")
+	}
+}
+
+// TestCorpusReferences 检查语料库中相互引用的描述符与被引用文件的 digest 和大小一致
+func TestCorpusReferences(t *testing.T) {
+	corpus := loadCorpus(t)
+	byDigest := make(map[string]corpusManifest, len(corpus))
+	for _, m := range corpus {
+		byDigest[m.Digest] = m
+	}
+
+	checked := 0
+	check := func(from string, desc Descriptor) {
+		target, ok := byDigest[desc.Digest]
+		if !ok {
+			return
+		}
+		checked++
+		if desc.Size != int64(len(target.body)) {
+			t.Errorf("%s 中 %s 的大小 = %d, 期望 %d", from, target.File, desc.Size, len(target.body))
+		}
+		if desc.MediaType != target.MediaType {
+			t.Errorf("%s 中 %s 的媒体类型 = %s, 期望 %s", from, target.File, desc.MediaType, target.MediaType)
+		}
+	}
+	for _, m := range corpus {
+		var refs struct {
+			Manifests []Descriptor `json:"manifests"`
+			Subject   *Descriptor  `json:"subject"`
+		}
+		if err := json.Unmarshal(m.body, &refs); err != nil {
+			t.Fatalf("解析 %s 失败: %v", m.File, err)
+		}
+		for _, desc := range refs.Manifests {
+			check(m.File, desc)
+		}
+		if refs.Subject != nil {
+			check(m.File, *refs.Subject)
+		}
+	}
+	if checked == 0 {
+		t.Error("语料库中没有相互引用的 manifest")
+	}
+}
+
+func TestReferenceNormalization(t *testing.T) {
+	tests := []struct {
+		image      string
+		registry   string
+		repository string
+	}{
+		{"nginx", DockerHubKey, "library/nginx"},
+		{"library/nginx", DockerHubKey, "library/nginx"},
+		{"bitnami/redis", DockerHubKey, "bitnami/redis"},
+		{"docker.io/nginx", DockerHubKey, "library/nginx"},
+		{"docker.io/library/nginx", DockerHubKey, "library/nginx"},
+		{"index.docker.io/bitnami/redis", DockerHubKey, "bitnami/redis"},
+		{"ghcr.io/owner/repo", GHCRKey, "owner/repo"},
+		{"ghcr.io/owner/group/repo", GHCRKey, "owner/group/repo"},
+		{"quay.io/prometheus/node-exporter", "custom:quay.io", "prometheus/node-exporter"},
+		{"harbor.example.com:8443/team/app", "custom:harbor.example.com:8443", "team/app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			registryKey := DetectRegistry(tt.image)
+			if registryKey != tt.registry {
+				t.Errorf("DetectRegistry = %s, 期望 %s", registryKey, tt.registry)
+			}
+			if got := NormalizeImageName(tt.image, registryKey); got != tt.repository {
+				t.Errorf("NormalizeImageName = %s, 期望 %s", got, tt.repository)
+			}
+		})
+	}
+}
+
+func TestParsePlatformConformance(t *testing.T) {
+	valid := map[string]Platform{
+		"linux/amd64":    {OS: "linux", Architecture: "amd64"},
+		"linux/arm64/v8": {OS: "linux", Architecture: "arm64", Variant: "v8"},
+		"windows/amd64":  {OS: "windows", Architecture: "amd64"},
+	}
+	for s, want := range valid {
+		got, err := ParsePlatform(s)
+		if err != nil {
+			t.Errorf("ParsePlatform(%q) 返回错误: %v", s, err)
+			continue
+		}
+		if got.String() != want.String() || got.String() != s {
+			t.Errorf("ParsePlatform(%q) = %s, 期望 %s", s, got, want)
+		}
+	}
+	for _, s := range []string{"", "linux", "linux/", "/amd64", "linux/arm/v7/extra"} {
+		if _, err := ParsePlatform(s); err == nil {
+			t.Errorf("ParsePlatform(%q) 期望返回错误", s)
+		}
+	}
+}
+
+func TestParseWWWAuthenticateConformance(t *testing.T) {
+	tests := []struct {
+		header                string
+		realm, service, scope string
+	}{
+		// Docker Hub
+		{`Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`,
+			"https://auth.docker.io/token", "registry.docker.io", "repository:library/nginx:pull"},
+		// scope 中包含逗号（push 权限）
+		{`Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:owner/repo:pull,push"`,
+			"https://ghcr.io/token", "ghcr.io", "repository:owner/repo:pull,push"},
+		// scope 不足时带有 error 参数，多个 scope 以空格分隔
+		{`Bearer realm="https://harbor.example.com/service/token",service="harbor-registry",scope="repository:a:pull repository:b:pull",error="insufficient_scope"`,
+			"https://harbor.example.com/service/token", "harbor-registry", "repository:a:pull repository:b:pull"},
+		// 小写的认证类型、参数之间有空格、没有 scope
+		{`bearer realm="https://quay.io/v2/auth", service="quay.io"`,
+			"https://quay.io/v2/auth", "quay.io", ""},
+		// 不带引号的参数值
+		{`Bearer realm=https://registry.example.com/token,service=registry.example.com`,
+			"https://registry.example.com/token", "registry.example.com", ""},
+	}
+	for _, tt := range tests {
+		realm, service, scope, err := ParseWWWAuthenticate(tt.header)
+		if err != nil {
+			t.Errorf("ParseWWWAuthenticate(%q) 返回错误: %v", tt.header, err)
+			continue
+		}
+		if realm != tt.realm || service != tt.service || scope != tt.scope {
+			t.Errorf("ParseWWWAuthenticate(%q) = (%q, %q, %q), 期望 (%q, %q, %q)",
+				tt.header, realm, service, scope, tt.realm, tt.service, tt.scope)
+		}
+	}
+
+	for _, header := range []string{`Basic realm="Registry Realm"`, `Bearer service="registry.example.com"`, `Bearer realm="https://unterminated`} {
+		if _, _, _, err := ParseWWWAuthenticate(header); err == nil {
+			t.Errorf("ParseWWWAuthenticate(%q) 期望返回错误", header)
+		}
+	}
+}
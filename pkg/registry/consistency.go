@@ -0,0 +1,243 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// 平台一致性检查比较的配置项
+const (
+	FieldLabel       = "label"
+	FieldEnv         = "env"
+	FieldEntrypoint  = "entrypoint"
+	FieldCmd         = "cmd"
+	FieldUser        = "user"
+	FieldWorkingDir  = "workingDir"
+	FieldExposedPort = "exposedPort"
+)
+
+// consistencyFields 比较的配置项，也是报告中的排列顺序
+var consistencyFields = []string{FieldLabel, FieldEnv, FieldEntrypoint, FieldCmd, FieldUser, FieldWorkingDir, FieldExposedPort}
+
+// PlatformDivergence 表示多架构镜像各平台之间不一致的一项配置
+type PlatformDivergence struct {
+	Field   string            `json:"field"`             // 配置项，如 label、env、entrypoint
+	Key     string            `json:"key,omitempty"`     // label 或环境变量的名称、端口，entrypoint 等单值配置项为空
+	Values  map[string]string `json:"values"`            // 平台 -> 值，entrypoint 和 cmd 为 JSON 数组
+	Missing []string          `json:"missing,omitempty"` // 缺少该项的平台
+}
+
+// PlatformConsistencyReport 表示多架构镜像各平台配置的一致性检查结果
+type PlatformConsistencyReport struct {
+	Image       string               `json:"image"`
+	Tag         string               `json:"tag"`
+	Digest      string               `json:"digest"`    // 索引的 digest
+	Platforms   []string             `json:"platforms"` // 参与比较的平台，与索引中的顺序一致
+	Divergences []PlatformDivergence `json:"divergences,omitempty"`
+}
+
+// Consistent 判断各平台的配置是否一致
+func (r *PlatformConsistencyReport) Consistent() bool {
+	return len(r.Divergences) == 0
+}
+
+// WriteSummary 输出可读的检查结果
+func (r *PlatformConsistencyReport) WriteSummary(w io.Writer) error {
+	var b strings.Builder
+	if r.Consistent() {
+		fmt.Fprintf(&b, "✓ %s:%s: %d 个平台的配置一致 (%s)\n", r.Image, r.Tag, len(r.Platforms), strings.Join(r.Platforms, ", "))
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	fmt.Fprintf(&b, "✗ %s:%s: %d 项配置在平台之间不一致\n", r.Image, r.Tag, len(r.Divergences))
+	for _, divergence := range r.Divergences {
+		name := divergence.Field
+		if divergence.Key != "" {
+			name += " " + divergence.Key
+		}
+		fmt.Fprintf(&b, "  %s:\n", name)
+		for _, platform := range r.Platforms {
+			value, ok := divergence.Values[platform]
+			if !ok {
+				value = "(缺失)"
+			}
+			fmt.Fprintf(&b, "    %s: %s\n", platform, value)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// CheckPlatformConsistency 比较多架构镜像各平台的配置（labels、环境变量、entrypoint、cmd、用户、工作目录、暴露端口），
+// 报告不一致的项，如 arm64 镜像缺少某个 label
+// 镜像不是多架构索引时返回错误；索引中的 attestation 等没有平台信息的 manifest 不参与比较
+func (c *Client) CheckPlatformConsistency(image, tag string) (*PlatformConsistencyReport, error) {
+	return c.CheckPlatformConsistencyContext(context.Background(), image, tag)
+}
+
+// CheckPlatformConsistencyContext 与 CheckPlatformConsistency 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) CheckPlatformConsistencyContext(ctx context.Context, image, tag string) (*PlatformConsistencyReport, error) {
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	tag = defaultTag(target.registryKey, tag)
+
+	root, err := c.fetchManifest(ctx, target, authorization, tag)
+	if newAuthorization, ok := c.reauthorize(ctx, target, err); ok {
+		authorization = newAuthorization
+		root, err = c.fetchManifest(ctx, target, authorization, tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !IsIndexMediaType(root.mediaType) {
+		return nil, fmt.Errorf("%s:%s 不是多架构镜像 (媒体类型: %s)", image, tag, root.mediaType)
+	}
+
+	var index ImageIndex
+	if err := json.Unmarshal(root.body, &index); err != nil {
+		return nil, fmt.Errorf("解析镜像索引失败: %w", err)
+	}
+	if err := c.getIndexLimits().checkChildren(root.digest, &index); err != nil {
+		return nil, err
+	}
+
+	report := &PlatformConsistencyReport{Image: image, Tag: tag, Digest: root.digest}
+	if report.Digest == "" {
+		report.Digest = computeDigest(root.body)
+	}
+
+	configs := make(map[string]*ImageConfig)
+	for _, desc := range index.Manifests {
+		if !comparablePlatform(desc) {
+			continue
+		}
+		platform := desc.Platform.String()
+		if _, ok := configs[platform]; ok {
+			platform += "@" + desc.Digest
+		}
+
+		child, err := c.fetchManifest(ctx, target, authorization, desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("获取平台 %s 的 manifest 失败: %w", platform, err)
+		}
+		config, _, err := c.fetchImageConfig(ctx, target, authorization, child)
+		if err != nil {
+			return nil, fmt.Errorf("获取平台 %s 的配置失败: %w", platform, err)
+		}
+		configs[platform] = config
+		report.Platforms = append(report.Platforms, platform)
+	}
+
+	report.Divergences = comparePlatformConfigs(report.Platforms, configs)
+	return report, nil
+}
+
+// comparablePlatform 判断索引中的描述符是否为参与比较的平台镜像
+// 排除嵌套索引、没有平台信息以及 BuildKit 生成的 attestation manifest（平台为 unknown/unknown）
+func comparablePlatform(desc Descriptor) bool {
+	if IsIndexMediaType(desc.MediaType) || desc.Platform == nil {
+		return false
+	}
+	if desc.Platform.OS == "unknown" || desc.Annotations["vnd.docker.reference.type"] == "attestation-manifest" {
+		return false
+	}
+	return true
+}
+
+// configItems 将镜像配置展开为 配置项 -> 名称 -> 值，单值配置项的名称为空，未设置的项不包含在内
+func configItems(config *ImageConfig) map[string]map[string]string {
+	items := make(map[string]map[string]string)
+	set := func(field, key, value string) {
+		if items[field] == nil {
+			items[field] = make(map[string]string)
+		}
+		items[field][key] = value
+	}
+	list := func(values []string) string {
+		data, _ := json.Marshal(values)
+		return string(data)
+	}
+
+	for key, value := range config.Config.Labels {
+		set(FieldLabel, key, value)
+	}
+	for _, env := range config.Config.Env {
+		key, value, _ := strings.Cut(env, "=")
+		set(FieldEnv, key, value)
+	}
+	if len(config.Config.Entrypoint) > 0 {
+		set(FieldEntrypoint, "", list(config.Config.Entrypoint))
+	}
+	if len(config.Config.Cmd) > 0 {
+		set(FieldCmd, "", list(config.Config.Cmd))
+	}
+	if config.Config.User != "" {
+		set(FieldUser, "", config.Config.User)
+	}
+	if config.Config.WorkingDir != "" {
+		set(FieldWorkingDir, "", config.Config.WorkingDir)
+	}
+	for port := range config.Config.ExposedPorts {
+		set(FieldExposedPort, port, port)
+	}
+	return items
+}
+
+// comparePlatformConfigs 比较各平台的配置，返回不一致的项，按配置项和名称排序
+func comparePlatformConfigs(platforms []string, configs map[string]*ImageConfig) []PlatformDivergence {
+	if len(platforms) < 2 {
+		return nil
+	}
+
+	items := make(map[string]map[string]map[string]string, len(platforms)) // 平台 -> 配置项 -> 名称 -> 值
+	for _, platform := range platforms {
+		items[platform] = configItems(configs[platform])
+	}
+
+	var divergences []PlatformDivergence
+	for _, field := range consistencyFields {
+		keySet := make(map[string]bool)
+		for _, platform := range platforms {
+			for key := range items[platform][field] {
+				keySet[key] = true
+			}
+		}
+		keys := make([]string, 0, len(keySet))
+		for key := range keySet {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			divergence := PlatformDivergence{Field: field, Key: key, Values: make(map[string]string)}
+			distinct := make(map[string]bool)
+			for _, platform := range platforms {
+				value, ok := items[platform][field][key]
+				if !ok {
+					divergence.Missing = append(divergence.Missing, platform)
+					continue
+				}
+				divergence.Values[platform] = value
+				distinct[value] = true
+			}
+			if len(divergence.Missing) > 0 || len(distinct) > 1 {
+				divergences = append(divergences, divergence)
+			}
+		}
+	}
+	return divergences
+}
@@ -0,0 +1,342 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// CopyResult 表示复制镜像的结果
+type CopyResult struct {
+	Source        string `json:"source"`           // 源镜像，格式为 image:tag 或 image@digest
+	Destination   string `json:"destination"`      // 目标镜像，格式为 image:tag 或 image@digest
+	Digest        string `json:"digest"`           // 复制的 manifest digest，与源镜像一致（只复制一个平台时为该平台的 manifest digest）
+	Manifests     int    `json:"manifests"`        // 推送的 manifest 数量（包括索引和子 manifest）
+	Blobs         int    `json:"blobs"`            // 镜像引用的 blob 数量（去重后，不包括外部层）
+	BlobsExisting int    `json:"blobsExisting"`    // 目标仓库中已存在、跳过的 blob 数量
+	BlobsMounted  int    `json:"blobsMounted"`     // 通过跨仓库挂载复制、未传输内容的 blob 数量
+	BlobsUploaded int    `json:"blobsUploaded"`    // 下载后上传的 blob 数量
+	BytesUploaded int64  `json:"bytesUploaded"`    // 上传的 blob 字节数
+	DryRun        bool   `json:"dryRun,omitempty"` // 试运行，BlobsUploaded 和 BytesUploaded 为需要传输的数量，Manifests 为需要推送的数量
+}
+
+// 复制过程中单个 blob 的处理方式，见 CopyProgress.Action
+const (
+	CopyBlobExisting = "existing" // 目标仓库中已存在，跳过
+	CopyBlobMounted  = "mounted"  // 通过跨仓库挂载复制
+	CopyBlobUploaded = "uploaded" // 从源仓库下载后上传
+	CopyBlobPending  = "pending"  // 试运行时目标仓库中不存在、需要传输
+)
+
+// CopyOptions 表示复制镜像的选项
+type CopyOptions struct {
+	// Platform 不为空时多架构镜像只复制该平台的 manifest（如 linux/arm64），目标标签指向该平台的 manifest，
+	// digest 与源索引不同；源镜像不是索引时忽略
+	Platform string
+	// DryRun 为 true 时只检查目标仓库中已存在的 blob，不推送任何内容，只需要目标仓库的 pull 权限，只读模式下同样可用
+	DryRun bool
+	// Progress 不为 nil 时每个 blob 处理完成后调用，用于输出进度
+	Progress func(CopyProgress)
+}
+
+// CopyProgress 表示复制过程中单个 blob 的处理结果
+type CopyProgress struct {
+	Blob   Descriptor // 处理完成的 blob
+	Action string     // 处理方式: CopyBlobExisting、CopyBlobMounted、CopyBlobUploaded 或 CopyBlobPending
+	Done   int        // 已处理的 blob 数量，包括当前 blob
+	Total  int        // blob 总数
+}
+
+// addBlob 按 blob 的处理方式更新统计
+func (r *CopyResult) addBlob(action string, size int64) {
+	switch action {
+	case CopyBlobExisting:
+		r.BlobsExisting++
+	case CopyBlobMounted:
+		r.BlobsMounted++
+	default:
+		r.BlobsUploaded++
+		r.BytesUploaded += size
+	}
+}
+
+// copiedManifest 表示复制过程中收集的 manifest
+type copiedManifest struct {
+	desc Descriptor
+	body []byte
+}
+
+// CopyImage 将 srcImage:srcTag 复制为 dstImage:dstTag，包括多架构索引的全部子 manifest 和引用的 blob
+// srcImage 和 dstImage 可以带传输方式前缀（如 oci:/data/layout、docker-archive:/tmp/nginx.tar、docker-daemon:nginx），
+// 在 registry、OCI 布局目录、docker save 归档、Docker daemon 和 containerd 之间复制，见 CopyImageReference
+// srcTag 可以是标签或 digest；dstTag 为空时使用 srcTag（srcTag 为 digest 时按 digest 推送）
+// manifest 原样推送，目标镜像的 digest 与源镜像一致
+// 源和目标位于同一 registry 的不同仓库时（如 ghcr.io/org/a → ghcr.io/org/b），blob 通过跨仓库挂载复制，不下载和上传内容；
+// registry 不支持挂载时自动改为上传
+// 目标仓库中已存在的 blob 不会重复上传；带有 URLs 的外部层（如 Windows 基础层）不会复制
+// 只读模式下返回 ErrReadOnly
+func (c *Client) CopyImage(srcImage, srcTag, dstImage, dstTag string) (*CopyResult, error) {
+	return c.CopyImageContext(context.Background(), srcImage, srcTag, dstImage, dstTag)
+}
+
+// CopyImageContext 与 CopyImage 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) CopyImageContext(ctx context.Context, srcImage, srcTag, dstImage, dstTag string) (*CopyResult, error) {
+	return c.CopyImageWithOptions(ctx, srcImage, srcTag, dstImage, dstTag, CopyOptions{})
+}
+
+// CopyImageWithOptions 与 CopyImageContext 相同，但可以只复制一个平台、试运行或接收每个 blob 的进度，见 CopyOptions
+func (c *Client) CopyImageWithOptions(ctx context.Context, srcImage, srcTag, dstImage, dstTag string, opts CopyOptions) (*CopyResult, error) {
+	// 带传输方式前缀（如 oci:/data/layout、docker-daemon:nginx）时通过 ImageSource 和 ImageDestination 复制，见 CopyImageReference
+	srcTransport, srcName := splitTransport(srcImage)
+	dstTransport, dstName := splitTransport(dstImage)
+	if srcTransport != TransportRegistry || dstTransport != TransportRegistry {
+		src := ImageReference{Transport: srcTransport, Name: srcName, Tag: srcTag}
+		dst := ImageReference{Transport: dstTransport, Name: dstName, Tag: dstTag}
+		return c.copyBetweenTransports(ctx, src, dst, opts)
+	}
+	srcImage, dstImage = srcName, dstName
+
+	if dstTag == "" {
+		dstTag = srcTag
+	}
+	result := &CopyResult{Source: imageReference(srcImage, srcTag), Destination: imageReference(dstImage, dstTag), DryRun: opts.DryRun}
+
+	var platform Platform
+	if opts.Platform != "" {
+		var err error
+		if platform, err = ParsePlatform(opts.Platform); err != nil {
+			return result, err
+		}
+	}
+
+	if err := c.checkImagePolicy(srcImage); err != nil {
+		return result, err
+	}
+	if err := c.checkImagePolicy(dstImage); err != nil {
+		return result, err
+	}
+	source, err := c.resolveTarget(srcImage)
+	if err != nil {
+		return result, err
+	}
+	target, err := c.resolveTarget(dstImage)
+	if err != nil {
+		return result, err
+	}
+	if !opts.DryRun {
+		if err := c.checkReadOnly(http.MethodPut, target, "manifests/"+dstTag); err != nil {
+			return result, err
+		}
+	}
+
+	srcAuthorization, err := c.authorize(ctx, source)
+	if err != nil {
+		return result, err
+	}
+	root, err := c.fetchManifest(ctx, source, srcAuthorization, srcTag)
+	if newAuthorization, ok := c.reauthorize(ctx, source, err); ok {
+		srcAuthorization = newAuthorization
+		root, err = c.fetchManifest(ctx, source, srcAuthorization, srcTag)
+	}
+	if err == nil && opts.Platform != "" {
+		root, err = c.resolvePlatform(ctx, source, srcAuthorization, root, platform)
+	}
+	if err != nil {
+		return result, err
+	}
+	manifests, blobs, err := c.collectCopy(ctx, source, srcAuthorization, root)
+	if err != nil {
+		return result, err
+	}
+	result.Digest = manifests[0].desc.Digest
+	result.Blobs = len(blobs)
+	if strings.Contains(dstTag, ":") && dstTag != result.Digest {
+		return result, fmt.Errorf("digest '%s' 与源镜像的 manifest 不符 (%s)", dstTag, result.Digest)
+	}
+
+	// 同一 registry 的不同仓库之间可以挂载 blob，目标 token 需要同时具有源仓库的 pull 权限
+	var mountFrom string
+	if source.registryURL == target.registryURL && source.repository != target.repository {
+		mountFrom = source.repository
+	}
+	var dstAuthorization string
+	if opts.DryRun {
+		dstAuthorization, err = c.authorize(ctx, target)
+	} else {
+		dstAuthorization, err = c.authorizePushFrom(ctx, target, mountFrom)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	for i, blob := range blobs {
+		var action string
+		if opts.DryRun {
+			action, err = c.checkBlob(ctx, target, dstAuthorization, blob)
+		} else {
+			action, err = c.copyBlob(ctx, source, srcAuthorization, target, dstAuthorization, mountFrom, blob)
+		}
+		if err != nil {
+			return result, fmt.Errorf("复制 blob %s 失败: %w", blob.Digest, err)
+		}
+		result.addBlob(action, blob.Size)
+		if opts.Progress != nil {
+			opts.Progress(CopyProgress{Blob: blob, Action: action, Done: i + 1, Total: len(blobs)})
+		}
+	}
+	if opts.DryRun {
+		result.Manifests = len(manifests)
+		return result, nil
+	}
+
+	// 先推送子 manifest，再推送引用它们的索引
+	for i := len(manifests) - 1; i >= 0; i-- {
+		manifest := manifests[i]
+		reference := manifest.desc.Digest
+		if i == 0 {
+			reference = dstTag
+		}
+		if _, err := c.putManifest(ctx, target, dstAuthorization, reference, manifest.desc.MediaType, manifest.body); err != nil {
+			return result, fmt.Errorf("推送 manifest %s 失败: %w", manifest.desc.Digest, err)
+		}
+		result.Manifests++
+	}
+
+	c.logger.Debug("镜像复制完成",
+		"source", result.Source,
+		"destination", result.Destination,
+		"digest", result.Digest,
+		"existing", result.BlobsExisting,
+		"mounted", result.BlobsMounted,
+		"uploaded", result.BlobsUploaded)
+	return result, nil
+}
+
+// imageReference 返回镜像引用，reference 为 digest 时格式为 image@digest，否则为 image:tag
+func imageReference(image, reference string) string {
+	if strings.Contains(reference, ":") {
+		return image + "@" + reference
+	}
+	return image + ":" + reference
+}
+
+// collectCopy 从已获取的根 manifest 开始遍历源镜像（包括多架构索引的子 manifest），返回需要推送的 manifest 和引用的 blob
+// manifest 按遍历顺序返回，第一个为根节点，索引排在其子 manifest 之前
+func (c *Client) collectCopy(ctx context.Context, source *registryTarget, authorization string, root *fetchedManifest) ([]copiedManifest, []Descriptor, error) {
+	rootDesc := Descriptor{MediaType: root.mediaType, Digest: computeDigest(root.body), Size: int64(len(root.body))}
+
+	var manifests []copiedManifest
+	var blobs []Descriptor
+	seen := make(map[string]bool)
+	visitor := func(desc Descriptor, parents []Descriptor) error {
+		body := root.body
+		if len(parents) > 0 {
+			if seen[desc.Digest] {
+				return SkipIndex
+			}
+			child, err := c.fetchManifest(ctx, source, authorization, desc.Digest)
+			if err != nil {
+				return err
+			}
+			body = child.body
+		}
+		seen[desc.Digest] = true
+		manifests = append(manifests, copiedManifest{desc: desc, body: body})
+		if IsIndexMediaType(desc.MediaType) {
+			return nil
+		}
+
+		var manifest ImageManifest
+		if err := json.Unmarshal(body, &manifest); err != nil {
+			return fmt.Errorf("解析 manifest %s 失败: %w", desc.Digest, err)
+		}
+		for _, blob := range append([]Descriptor{manifest.Config}, manifest.Layers...) {
+			if blob.Digest == "" || len(blob.URLs) > 0 || seen[blob.Digest] {
+				continue
+			}
+			seen[blob.Digest] = true
+			blobs = append(blobs, blob)
+		}
+		return nil
+	}
+
+	walker := &indexWalker{
+		ctx:           ctx,
+		client:        c,
+		target:        source,
+		authorization: authorization,
+		visitor:       visitor,
+		limits:        c.getIndexLimits(),
+	}
+	if err := walker.walk(rootDesc, root, nil); err != nil {
+		return nil, nil, err
+	}
+	return manifests, blobs, nil
+}
+
+// checkBlob 检查 blob 是否已存在于目标仓库，用于试运行，返回 CopyBlobExisting 或 CopyBlobPending
+func (c *Client) checkBlob(ctx context.Context, target *registryTarget, authorization string, blob Descriptor) (string, error) {
+	exists, err := c.blobExists(ctx, target, authorization, blob.Digest)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return CopyBlobExisting, nil
+	}
+	return CopyBlobPending, nil
+}
+
+// copyBlob 将 blob 复制到目标仓库：已存在时跳过，可以挂载时通过跨仓库挂载复制，否则从源仓库下载后上传
+// 返回 blob 的处理方式: CopyBlobExisting、CopyBlobMounted 或 CopyBlobUploaded
+func (c *Client) copyBlob(ctx context.Context, source *registryTarget, srcAuthorization string, target *registryTarget, dstAuthorization, mountFrom string, blob Descriptor) (string, error) {
+	exists, err := c.blobExists(ctx, target, dstAuthorization, blob.Digest)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return CopyBlobExisting, nil
+	}
+
+	open := func() (io.Reader, error) {
+		return c.openBlob(ctx, source, srcAuthorization, blob.Digest)
+	}
+
+	if mountFrom != "" {
+		mounted, location, err := c.mountBlob(ctx, target, dstAuthorization, blob.Digest, mountFrom)
+		switch {
+		case err != nil:
+			c.logger.Debug("跨仓库挂载 blob 失败，改为上传", "repository", target.repository, "digest", blob.Digest, "error", err)
+		case mounted:
+			c.logger.Debug("blob 已通过跨仓库挂载复制", "repository", target.repository, "from", mountFrom, "digest", blob.Digest)
+			return CopyBlobMounted, nil
+		case c.blobBackend(target) == nil:
+			// registry 未完成挂载，直接使用其创建的上传会话
+			if err := c.completeUpload(ctx, target, dstAuthorization, location, blob, open); err != nil {
+				return "", err
+			}
+			return CopyBlobUploaded, nil
+		}
+	}
+
+	if err := c.storeBlob(ctx, target, dstAuthorization, blob, open); err != nil {
+		return "", err
+	}
+	return CopyBlobUploaded, nil
+}
+
+// openBlob 打开源仓库中 blob 的内容，返回的响应体由调用方关闭
+// 内容不读入内存，digest 由目标 registry 在上传时校验；响应体的读取不受 WithTimeout 限制，只受 ctx 控制
+func (c *Client) openBlob(ctx context.Context, target *registryTarget, authorization, digest string) (io.ReadCloser, error) {
+	resp, err := c.doRegistryRequest(withTransfer(ctx), http.MethodGet, target, "blobs/"+digest, authorization, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newResponseError("获取 blob 失败", resp)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,113 @@
+package registry
+
+import (
+	"context"
+	"sync"
+)
+
+// CredentialProvider 按需提供 registry 凭据，用于从 Vault、云厂商元数据服务或定期轮换的密钥中读取凭据，
+// 凭据更新后不需要重新创建 Client
+// registryKey 为查找凭据使用的 key：registry key（如 "dockerhub"）、未注册自定义源的域名，或 ImageSpec.CredentialKey
+// 没有该 registry 的凭据时返回 nil, nil
+// ctx 为发起认证的请求的 context，受调用方的 ctx 和 WithBatchImageTimeout 控制
+// 同一镜像的认证和请求只调用一次 GetCredential，批量任务中的每个镜像各调用一次，
+// 实现需要自行缓存凭据并保证并发安全
+type CredentialProvider interface {
+	GetCredential(ctx context.Context, registryKey string) (*RegistryCredential, error)
+}
+
+// CredentialProviderFunc 使用函数实现 CredentialProvider
+type CredentialProviderFunc func(ctx context.Context, registryKey string) (*RegistryCredential, error)
+
+// GetCredential 实现 CredentialProvider 接口
+func (f CredentialProviderFunc) GetCredential(ctx context.Context, registryKey string) (*RegistryCredential, error) {
+	return f(ctx, registryKey)
+}
+
+// WithCredentialProvider 设置凭据提供者，为 nil 时只使用 AddCredential 添加的凭据（默认）
+// 通过 AddCredential 添加的凭据优先，没有时才调用提供者
+// 提供者返回错误时输出 Warn 日志，按没有凭据处理
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithCredentialProvider(provider CredentialProvider) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.credentialProvider = provider
+	return c
+}
+
+// getCredentialProvider 返回设置的凭据提供者，未设置时返回 nil
+func (c *Client) getCredentialProvider() CredentialProvider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.credentialProvider
+}
+
+// credential 返回 credentialKey 的凭据，优先使用 AddCredential 添加的凭据，没有时从凭据提供者获取
+// ctx 携带 resolvedCredentials 时（见 withResolvedCredentials），提供者的结果保存在其中，相同 key 不再调用提供者
+func (c *Client) credential(ctx context.Context, credentialKey string) (*RegistryCredential, bool) {
+	c.mu.RLock()
+	cred, ok := c.credentials[credentialKey]
+	c.mu.RUnlock()
+	if ok {
+		return cred, true
+	}
+	if resolved, _ := ctx.Value(resolvedCredentialsKey{}).(*resolvedCredentials); resolved != nil {
+		return resolved.get(credentialKey, func() (*RegistryCredential, bool) {
+			return c.providedCredential(ctx, credentialKey)
+		})
+	}
+	return c.providedCredential(ctx, credentialKey)
+}
+
+// resolvedCredentials 保存从凭据提供者获取的凭据，在一个访问目标（或批量任务的子组）的所有认证步骤之间共享，
+// 避免每个认证步骤（token、Basic 认证、token 缓存 key 等）都调用一次提供者
+type resolvedCredentials struct {
+	mu      sync.Mutex
+	entries map[string]*RegistryCredential // 凭据 key -> 凭据，nil 表示没有凭据或获取失败
+}
+
+// resolvedCredentialsKey 是 ctx 中 resolvedCredentials 的 key
+type resolvedCredentialsKey struct{}
+
+// withResolvedCredentials 返回携带 resolved 的 ctx，其中的凭据查找共享 resolved 保存的结果
+func withResolvedCredentials(ctx context.Context, resolved *resolvedCredentials) context.Context {
+	if current, _ := ctx.Value(resolvedCredentialsKey{}).(*resolvedCredentials); current == resolved {
+		return ctx
+	}
+	return context.WithValue(ctx, resolvedCredentialsKey{}, resolved)
+}
+
+// get 返回 key 对应的凭据，未获取过时调用 fetch 并保存结果
+// 获取期间持有锁，并发的相同查找等待同一次调用
+func (r *resolvedCredentials) get(key string, fetch func() (*RegistryCredential, bool)) (*RegistryCredential, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cred, ok := r.entries[key]; ok {
+		return cred, cred != nil
+	}
+	cred, ok := fetch()
+	if !ok {
+		cred = nil
+	}
+	if r.entries == nil {
+		r.entries = make(map[string]*RegistryCredential)
+	}
+	r.entries[key] = cred
+	return cred, ok
+}
+
+// providedCredential 从凭据提供者获取凭据
+func (c *Client) providedCredential(ctx context.Context, registryKey string) (*RegistryCredential, bool) {
+	provider := c.getCredentialProvider()
+	if provider == nil || registryKey == "" {
+		return nil, false
+	}
+	cred, err := provider.GetCredential(ctx, registryKey)
+	if err != nil {
+		c.logger.Warn("从凭据提供者获取凭据失败，按没有凭据处理",
+			"registryKey", registryKey,
+			"error", err)
+		return nil, false
+	}
+	return cred, cred != nil
+}
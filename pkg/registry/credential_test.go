@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCredentialProviderCalledOncePerImage(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.users["robot"] = "secret"
+	image := reg.host() + "/app"
+	var specs []ImageSpec
+	for _, tag := range []string{"v1", "v2", "v3"} {
+		reg.putImage("app", tag, `{"architecture":"amd64","os":"linux"}`)
+		specs = append(specs, ImageSpec{Image: image, Tag: tag})
+	}
+
+	var calls atomic.Int32
+	provider := CredentialProviderFunc(func(ctx context.Context, registryKey string) (*RegistryCredential, error) {
+		calls.Add(1)
+		return &RegistryCredential{Username: "robot", Token: "secret"}, nil
+	})
+
+	// 认证（token 缓存 key、token 请求、Basic 认证）和请求共享同一次获取的凭据
+	client := reg.client().WithCredentialProvider(provider)
+	if _, _, err := client.GetManifestWithDigest(image, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("获取一个镜像调用凭据提供者 %d 次, 期望 1", n)
+	}
+
+	calls.Store(0)
+	for _, result := range reg.client().WithCredentialProvider(provider).GetManifestsWithOptions(context.Background(), specs, BatchOptions{}) {
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+	}
+	if n := calls.Load(); n != int32(len(specs)) {
+		t.Errorf("批量获取 %d 个镜像调用凭据提供者 %d 次, 期望每个镜像 1 次", len(specs), n)
+	}
+}
+
+func TestCredentialProviderUsesRequestContext(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+
+	canceled := make(chan bool, 1)
+	provider := CredentialProviderFunc(func(ctx context.Context, registryKey string) (*RegistryCredential, error) {
+		select {
+		case <-ctx.Done():
+			canceled <- true
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			canceled <- false
+			return nil, nil
+		}
+	})
+
+	// 卡住的凭据提供者随单个镜像的超时取消
+	client := reg.client().WithCredentialProvider(provider).WithBatchImageTimeout(100 * time.Millisecond)
+	specs := []ImageSpec{{Image: reg.host() + "/app", Tag: "v1"}}
+	client.GetManifestsWithOptions(context.Background(), specs, BatchOptions{})
+	if !<-canceled {
+		t.Error("凭据提供者收到的 ctx 未随镜像超时取消")
+	}
+}
@@ -0,0 +1,238 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RegistryDigests 表示一个 registry 中镜像的 digest
+type RegistryDigests struct {
+	Image     string            `json:"image"`               // 镜像名称，如 docker.io/library/nginx、harbor.internal/mirror/nginx
+	Tag       string            `json:"tag"`                 // 标签
+	Digest    string            `json:"digest,omitempty"`    // 根 manifest 的 digest（多架构镜像为索引的 digest）
+	Platforms map[string]string `json:"platforms,omitempty"` // 平台 -> 该平台镜像 manifest 的 digest
+	Error     error             `json:"-"`                   // 获取失败时的错误
+}
+
+// MarshalJSON 实现 json.Marshaler 接口，Error 输出为错误信息
+func (r RegistryDigests) MarshalJSON() ([]byte, error) {
+	type plain RegistryDigests
+	out := struct {
+		plain
+		Error string `json:"error,omitempty"`
+	}{plain: plain(r)}
+	if r.Error != nil {
+		out.Error = r.Error.Error()
+	}
+	return json.Marshal(out)
+}
+
+// PlatformComparison 表示一个平台在各 registry 之间的比较结果
+type PlatformComparison struct {
+	Platform string            `json:"platform"`          // 平台，如 linux/arm64/v8
+	Digests  map[string]string `json:"digests"`           // 镜像 -> 该平台 manifest 的 digest
+	Missing  []string          `json:"missing,omitempty"` // 缺少该平台的镜像（获取失败的镜像不计入）
+	Match    bool              `json:"match"`             // 所有获取成功的镜像中都存在该平台，且 digest 相同
+}
+
+// RegistryComparison 表示同一镜像在多个 registry 之间的一致性比较结果
+type RegistryComparison struct {
+	Results   []RegistryDigests    `json:"results"`   // 每个镜像的 digest，顺序与请求一致
+	Platforms []PlatformComparison `json:"platforms"` // 各平台的比较结果，按平台排序
+	Match     bool                 `json:"match"`     // 所有镜像的根 digest 相同（有镜像获取失败时为 false）
+}
+
+// Consistent 判断所有镜像都获取成功且根 digest 相同
+func (r *RegistryComparison) Consistent() bool {
+	return r.Match
+}
+
+// Drifted 返回 digest 不一致或缺失的平台
+func (r *RegistryComparison) Drifted() []PlatformComparison {
+	var drifted []PlatformComparison
+	for _, platform := range r.Platforms {
+		if !platform.Match {
+			drifted = append(drifted, platform)
+		}
+	}
+	return drifted
+}
+
+// WriteSummary 输出可读的比较结果
+func (r *RegistryComparison) WriteSummary(w io.Writer) error {
+	var b strings.Builder
+	for _, result := range r.Results {
+		if result.Error != nil {
+			fmt.Fprintf(&b, "✗ %s:%s: %v\n", result.Image, result.Tag, result.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "  %s:%s %s\n", result.Image, result.Tag, result.Digest)
+	}
+	if r.Match {
+		fmt.Fprintf(&b, "✓ %d 个 registry 中的镜像一致\n", len(r.Results))
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	drifted := r.Drifted()
+	if len(drifted) == 0 {
+		// 如重新生成了索引：根 digest 不同，但各平台的镜像相同
+		fmt.Fprintf(&b, "✗ 根 digest 不一致，%d 个平台的镜像相同\n", len(r.Platforms))
+	} else {
+		fmt.Fprintf(&b, "✗ 镜像不一致，%d/%d 个平台存在差异\n", len(drifted), len(r.Platforms))
+	}
+	for _, platform := range drifted {
+		fmt.Fprintf(&b, "  %s:\n", platform.Platform)
+		for _, result := range r.Results {
+			if result.Error != nil {
+				continue
+			}
+			digest, ok := platform.Digests[result.Image]
+			if !ok {
+				digest = "(缺失)"
+			}
+			fmt.Fprintf(&b, "    %s: %s\n", result.Image, digest)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// CompareRegistries 获取多个 registry 中的同一镜像（如 Docker Hub 和内部镜像仓库），比较根 digest 和每个平台的 digest
+// 用于发现镜像仓库与上游之间的漂移：只同步了部分平台的镜像根 digest 不同，但已同步平台的 digest 仍然一致
+// images 至少包含两个镜像，可以带标签（如 harbor.internal/mirror/nginx:1.27），未带标签时使用 tag；
+// tag 为空时使用各 registry 的默认标签
+// 单个镜像获取失败不会中止比较，错误记录在对应的 RegistryDigests.Error 中
+// 单平台镜像的平台从镜像配置中读取；索引中的 attestation manifest 不参与比较
+func (c *Client) CompareRegistries(images []string, tag string) (*RegistryComparison, error) {
+	return c.CompareRegistriesContext(context.Background(), images, tag)
+}
+
+// CompareRegistriesContext 与 CompareRegistries 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) CompareRegistriesContext(ctx context.Context, images []string, tag string) (*RegistryComparison, error) {
+	if len(images) < 2 {
+		return nil, fmt.Errorf("至少需要两个镜像才能比较")
+	}
+
+	results := make([]RegistryDigests, len(images))
+	var wg sync.WaitGroup
+	for i, image := range images {
+		results[i] = RegistryDigests{Image: image, Tag: tag}
+		if name, imageTag, ok := splitImageTag(image); ok {
+			results[i].Image, results[i].Tag = name, imageTag
+		}
+		wg.Add(1)
+		go func(result *RegistryDigests) {
+			defer wg.Done()
+			result.Error = c.fetchRegistryDigests(ctx, result)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	comparison := &RegistryComparison{Results: results, Match: true}
+	digests := make(map[string]map[string]string) // 平台 -> 镜像 -> digest
+	var succeeded []string
+	for _, result := range results {
+		if result.Error != nil || result.Digest != results[0].Digest {
+			comparison.Match = false
+		}
+		if result.Error != nil {
+			continue
+		}
+		succeeded = append(succeeded, result.Image)
+		for platform, digest := range result.Platforms {
+			if digests[platform] == nil {
+				digests[platform] = make(map[string]string)
+			}
+			digests[platform][result.Image] = digest
+		}
+	}
+
+	for platform, byImage := range digests {
+		platformComparison := PlatformComparison{Platform: platform, Digests: byImage, Match: true}
+		var first string
+		for _, image := range succeeded {
+			digest, ok := byImage[image]
+			if !ok {
+				platformComparison.Missing = append(platformComparison.Missing, image)
+				platformComparison.Match = false
+				continue
+			}
+			if first == "" {
+				first = digest
+			} else if digest != first {
+				platformComparison.Match = false
+			}
+		}
+		comparison.Platforms = append(comparison.Platforms, platformComparison)
+	}
+	sort.Slice(comparison.Platforms, func(i, j int) bool {
+		return comparison.Platforms[i].Platform < comparison.Platforms[j].Platform
+	})
+	return comparison, nil
+}
+
+// fetchRegistryDigests 获取镜像的根 digest 和各平台的 digest，结果写入 result
+func (c *Client) fetchRegistryDigests(ctx context.Context, result *RegistryDigests) error {
+	if err := c.checkImagePolicy(result.Image); err != nil {
+		return err
+	}
+	target, err := c.resolveTarget(result.Image)
+	if err != nil {
+		return err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return err
+	}
+	result.Tag = defaultTag(target.registryKey, result.Tag)
+	root, err := c.fetchManifest(ctx, target, authorization, result.Tag)
+	if newAuthorization, ok := c.reauthorize(ctx, target, err); ok {
+		authorization = newAuthorization
+		root, err = c.fetchManifest(ctx, target, authorization, result.Tag)
+	}
+	if err != nil {
+		return err
+	}
+	result.Digest = computeDigest(root.body)
+	result.Platforms = make(map[string]string)
+
+	if !IsIndexMediaType(root.mediaType) {
+		config, _, err := c.fetchImageConfig(ctx, target, authorization, root)
+		if err != nil {
+			return err
+		}
+		platform := Platform{OS: config.OS, Architecture: config.Architecture, Variant: config.Variant}
+		result.Platforms[platform.String()] = result.Digest
+		return nil
+	}
+
+	var index ImageIndex
+	if err := json.Unmarshal(root.body, &index); err != nil {
+		return fmt.Errorf("解析镜像索引失败: %w", err)
+	}
+	if err := c.getIndexLimits().checkChildren(result.Digest, &index); err != nil {
+		return err
+	}
+	for _, desc := range index.Manifests {
+		if comparablePlatform(desc) {
+			result.Platforms[desc.Platform.String()] = desc.Digest
+		}
+	}
+	return nil
+}
+
+// splitImageTag 拆分带标签的镜像名称，如 harbor.example.com:8443/team/app:v1 -> (harbor.example.com:8443/team/app, v1)
+// 镜像名称不带标签时返回 false
+func splitImageTag(image string) (string, string, bool) {
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return image, "", false
+	}
+	return image[:i], image[i+1:], true
+}
@@ -0,0 +1,271 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// 差异的类型
+const (
+	DiffAdded   = "added"   // 只存在于 B
+	DiffRemoved = "removed" // 只存在于 A
+	DiffChanged = "changed" // 两者都存在但值不同
+)
+
+// DiffImage 表示参与比较的镜像
+type DiffImage struct {
+	Image        string `json:"image"`                  // 镜像名称
+	Tag          string `json:"tag"`                    // 标签或 digest
+	Digest       string `json:"digest"`                 // 镜像 manifest digest（多架构镜像为所选平台的 manifest）
+	ConfigDigest string `json:"configDigest,omitempty"` // 配置 blob 的 digest
+}
+
+// ValueChange 表示键值对（环境变量、标签）的变化
+type ValueChange struct {
+	Key    string `json:"key"`
+	Change string `json:"change"`         // DiffAdded、DiffRemoved 或 DiffChanged
+	From   string `json:"from,omitempty"` // A 中的值，DiffAdded 时为空
+	To     string `json:"to,omitempty"`   // B 中的值，DiffRemoved 时为空
+}
+
+// ListChange 表示列表（Entrypoint、Cmd）的变化
+type ListChange struct {
+	From []string `json:"from"`
+	To   []string `json:"to"`
+}
+
+// StringChange 表示单个字符串字段的变化
+type StringChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// SetChange 表示集合（暴露端口）的变化
+type SetChange struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// ConfigDiff 表示两个镜像配置的差异，没有变化的字段为空
+type ConfigDiff struct {
+	A            DiffImage     `json:"a"`
+	B            DiffImage     `json:"b"`
+	Env          []ValueChange `json:"env,omitempty"`          // 环境变量的变化，按变量名排序
+	Labels       []ValueChange `json:"labels,omitempty"`       // 标签的变化，按标签名排序
+	Entrypoint   *ListChange   `json:"entrypoint,omitempty"`   // Entrypoint 的变化
+	Cmd          *ListChange   `json:"cmd,omitempty"`          // Cmd 的变化
+	ExposedPorts *SetChange    `json:"exposedPorts,omitempty"` // 暴露端口的变化，如 "8080/tcp"
+	User         *StringChange `json:"user,omitempty"`         // 运行用户的变化
+}
+
+// ManifestDiff 表示两个镜像 manifest 的层差异，大小均为 blob 的压缩大小（字节）
+type ManifestDiff struct {
+	A             DiffImage    `json:"a"`
+	B             DiffImage    `json:"b"`
+	Added         []Descriptor `json:"added"`         // 只存在于 B 的层，按 B 中的顺序
+	Removed       []Descriptor `json:"removed"`       // 只存在于 A 的层，按 A 中的顺序
+	Shared        []Descriptor `json:"shared"`        // 两者共有的层，按 A 中的顺序
+	SizeA         int64        `json:"sizeA"`         // A 的总大小（配置和全部层）
+	SizeB         int64        `json:"sizeB"`         // B 的总大小（配置和全部层）
+	SizeDelta     int64        `json:"sizeDelta"`     // SizeB - SizeA
+	AddedSize     int64        `json:"addedSize"`     // 新增层的总大小，即从 A 更新到 B 需要下载的层大小
+	RemovedSize   int64        `json:"removedSize"`   // 移除层的总大小
+	ConfigChanged bool         `json:"configChanged"` // 配置 blob 的 digest 是否不同
+}
+
+// Empty 判断两个配置在比较的字段上是否没有差异
+func (d *ConfigDiff) Empty() bool {
+	return len(d.Env) == 0 && len(d.Labels) == 0 && d.Entrypoint == nil && d.Cmd == nil && d.ExposedPorts == nil && d.User == nil
+}
+
+// DiffConfigs 比较两个镜像的配置，返回环境变量、标签、Entrypoint/Cmd、暴露端口和运行用户的差异
+// 用于发布审计（“除了层以外 v1.2 和 v1.3 还有什么变化”）；Tag 可以是标签或 digest
+// 多架构镜像按 ImageSpec.Platform、registry 默认平台、linux/amd64 的顺序选择平台
+func (c *Client) DiffConfigs(a, b ImageSpec) (*ConfigDiff, error) {
+	return c.DiffConfigsContext(context.Background(), a, b)
+}
+
+// DiffConfigsContext 与 DiffConfigs 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) DiffConfigsContext(ctx context.Context, a, b ImageSpec) (*ConfigDiff, error) {
+	var configs [2]ConfigResult
+	for i, spec := range []ImageSpec{a, b} {
+		if err := c.checkImagePolicy(spec.Image); err != nil {
+			return nil, err
+		}
+		configs[i] = ConfigResult{Image: spec.Image, Tag: spec.Tag}
+		if err := c.getConfig(ctx, spec, "", nil, &configs[i]); err != nil {
+			return nil, fmt.Errorf("获取 %s 的配置失败: %w", spec.Reference(), err)
+		}
+	}
+	from, to := configs[0].Config.Config, configs[1].Config.Config
+
+	diff := &ConfigDiff{
+		A:      DiffImage{Image: a.Image, Tag: a.Tag, Digest: configs[0].Digest, ConfigDigest: configs[0].ConfigDigest},
+		B:      DiffImage{Image: b.Image, Tag: b.Tag, Digest: configs[1].Digest, ConfigDigest: configs[1].ConfigDigest},
+		Env:    diffValues(envMap(from.Env), envMap(to.Env)),
+		Labels: diffValues(from.Labels, to.Labels),
+	}
+	if !slices.Equal(from.Entrypoint, to.Entrypoint) {
+		diff.Entrypoint = &ListChange{From: from.Entrypoint, To: to.Entrypoint}
+	}
+	if !slices.Equal(from.Cmd, to.Cmd) {
+		diff.Cmd = &ListChange{From: from.Cmd, To: to.Cmd}
+	}
+	if ports := diffSets(from.ExposedPorts, to.ExposedPorts); len(ports.Added) > 0 || len(ports.Removed) > 0 {
+		diff.ExposedPorts = ports
+	}
+	if from.User != to.User {
+		diff.User = &StringChange{From: from.User, To: to.User}
+	}
+	return diff, nil
+}
+
+// DiffManifests 比较两个镜像的 manifest，报告新增、移除和共有的层及其大小、总大小的变化，以及配置是否变化
+// 用于分析更新为什么需要下载大量数据；同一层在镜像中出现多次时按次数比较
+// Tag 可以是标签或 digest；多架构镜像按 ImageSpec.Platform、registry 默认平台、linux/amd64 的顺序选择平台
+func (c *Client) DiffManifests(a, b ImageSpec) (*ManifestDiff, error) {
+	return c.DiffManifestsContext(context.Background(), a, b)
+}
+
+// DiffManifestsContext 与 DiffManifests 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) DiffManifestsContext(ctx context.Context, a, b ImageSpec) (*ManifestDiff, error) {
+	var manifests [2]*ImageManifest
+	var images [2]DiffImage
+	for i, spec := range []ImageSpec{a, b} {
+		manifest, digest, err := c.fetchImageManifest(ctx, spec)
+		if err != nil {
+			return nil, fmt.Errorf("获取 %s 的 manifest 失败: %w", spec.Reference(), err)
+		}
+		manifests[i] = manifest
+		images[i] = DiffImage{Image: spec.Image, Tag: spec.Tag, Digest: digest, ConfigDigest: manifest.Config.Digest}
+	}
+	from, to := manifests[0], manifests[1]
+
+	diff := &ManifestDiff{
+		A:             images[0],
+		B:             images[1],
+		Added:         []Descriptor{},
+		Removed:       []Descriptor{},
+		Shared:        []Descriptor{},
+		SizeA:         from.Config.Size,
+		SizeB:         to.Config.Size,
+		ConfigChanged: from.Config.Digest != to.Config.Digest,
+	}
+	remaining := make(map[string]int)
+	for _, layer := range to.Layers {
+		remaining[layer.Digest]++
+		diff.SizeB += layer.Size
+	}
+	for _, layer := range from.Layers {
+		diff.SizeA += layer.Size
+		if remaining[layer.Digest] > 0 {
+			remaining[layer.Digest]--
+			diff.Shared = append(diff.Shared, layer)
+			continue
+		}
+		diff.Removed = append(diff.Removed, layer)
+		diff.RemovedSize += layer.Size
+	}
+	// B 中没有与 A 配对的层按出现顺序计为新增
+	for i := len(to.Layers) - 1; i >= 0; i-- {
+		layer := to.Layers[i]
+		if remaining[layer.Digest] > 0 {
+			remaining[layer.Digest]--
+			diff.Added = append(diff.Added, layer)
+			diff.AddedSize += layer.Size
+		}
+	}
+	slices.Reverse(diff.Added)
+	diff.SizeDelta = diff.SizeB - diff.SizeA
+	return diff, nil
+}
+
+// fetchImageManifest 获取镜像规格对应的单个平台的镜像 manifest，返回解析后的 manifest 和 digest
+func (c *Client) fetchImageManifest(ctx context.Context, spec ImageSpec) (*ImageManifest, string, error) {
+	if err := c.checkImagePolicy(spec.Image); err != nil {
+		return nil, "", err
+	}
+	var platform *Platform
+	if spec.Platform != "" {
+		p, err := ParsePlatform(spec.Platform)
+		if err != nil {
+			return nil, "", err
+		}
+		platform = &p
+	}
+	target, err := c.resolveSpec(spec)
+	if err != nil {
+		return nil, "", err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, "", err
+	}
+	fetched, err := c.fetchManifest(ctx, target, authorization, defaultTag(target.registryKey, spec.Tag))
+	if err != nil {
+		return nil, "", err
+	}
+	fetched, err = c.resolveImageManifest(ctx, target, authorization, fetched, platform)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var manifest ImageManifest
+	if err := json.Unmarshal(fetched.body, &manifest); err != nil {
+		return nil, "", fmt.Errorf("解析 manifest 失败: %w", err)
+	}
+	return &manifest, computeDigest(fetched.body), nil
+}
+
+// envMap 将 "KEY=VALUE" 形式的环境变量转换为映射
+func envMap(env []string) map[string]string {
+	values := make(map[string]string, len(env))
+	for _, variable := range env {
+		key, value, _ := strings.Cut(variable, "=")
+		values[key] = value
+	}
+	return values
+}
+
+// diffValues 比较两个键值映射，结果按键排序
+func diffValues(from, to map[string]string) []ValueChange {
+	var changes []ValueChange
+	for key, value := range from {
+		newValue, ok := to[key]
+		switch {
+		case !ok:
+			changes = append(changes, ValueChange{Key: key, Change: DiffRemoved, From: value})
+		case newValue != value:
+			changes = append(changes, ValueChange{Key: key, Change: DiffChanged, From: value, To: newValue})
+		}
+	}
+	for key, value := range to {
+		if _, ok := from[key]; !ok {
+			changes = append(changes, ValueChange{Key: key, Change: DiffAdded, To: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// diffSets 比较两个集合，结果排序
+func diffSets(from, to map[string]struct{}) *SetChange {
+	change := &SetChange{}
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			change.Removed = append(change.Removed, key)
+		}
+	}
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			change.Added = append(change.Added, key)
+		}
+	}
+	sort.Strings(change.Added)
+	sort.Strings(change.Removed)
+	return change
+}
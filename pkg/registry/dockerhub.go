@@ -0,0 +1,100 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dockerHubRateLimitImage Docker Hub 官方文档中用于查询拉取限额的镜像
+const dockerHubRateLimitImage = "ratelimitpreview/test"
+
+// DockerHubRateLimit 表示 Docker Hub 的拉取限额
+type DockerHubRateLimit struct {
+	Limit     int           `json:"limit"`     // 时间窗口内允许的拉取次数，-1 表示不受限制
+	Remaining int           `json:"remaining"` // 时间窗口内剩余的拉取次数，-1 表示不受限制
+	Window    time.Duration `json:"window"`    // 限额的时间窗口，如 6 小时
+	Source    string        `json:"source"`    // 限额的计数依据（匿名访问为 IP 地址，认证后为账号 ID）
+}
+
+// Limited 判断是否受拉取限额约束
+func (r *DockerHubRateLimit) Limited() bool {
+	return r.Limit >= 0
+}
+
+// GetDockerHubRateLimit 查询当前身份（匿名或已配置的 Docker Hub 凭据）在 Docker Hub 的剩余拉取次数
+// 按官方文档对 ratelimitpreview/test 发送 HEAD 请求，HEAD 请求不会消耗限额
+// 可在大批量获取前检查剩余次数是否足够
+func (c *Client) GetDockerHubRateLimit() (*DockerHubRateLimit, error) {
+	ctx := context.Background()
+
+	target, err := c.resolveTarget(dockerHubRateLimitImage)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doRegistryRequest(ctx, "HEAD", target, "manifests/latest", authorization, manifestAcceptHeader())
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &ResponseError{Message: "查询 Docker Hub 拉取限额失败", StatusCode: resp.StatusCode}
+	}
+
+	rateLimit := &DockerHubRateLimit{
+		Limit:     -1,
+		Remaining: -1,
+		Source:    resp.Header.Get("Docker-Ratelimit-Source"),
+	}
+
+	// 不受限制的账号不返回限额 header
+	if value := resp.Header.Get("Ratelimit-Limit"); value != "" {
+		rateLimit.Limit, rateLimit.Window, err = parseRateLimitHeader(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if value := resp.Header.Get("Ratelimit-Remaining"); value != "" {
+		rateLimit.Remaining, _, err = parseRateLimitHeader(value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.logger.Debug("Docker Hub 拉取限额",
+		"limit", rateLimit.Limit,
+		"remaining", rateLimit.Remaining,
+		"window", rateLimit.Window,
+		"source", rateLimit.Source)
+
+	return rateLimit, nil
+}
+
+// parseRateLimitHeader 解析 Docker Hub 的限额 header
+// 格式: "100;w=21600"，w 为时间窗口（秒）
+func parseRateLimitHeader(value string) (int, time.Duration, error) {
+	parts := strings.Split(value, ";")
+	count, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("无效的限额 header '%s': %w", value, err)
+	}
+
+	var window time.Duration
+	for _, part := range parts[1:] {
+		if seconds, ok := strings.CutPrefix(strings.TrimSpace(part), "w="); ok {
+			if n, err := strconv.Atoi(seconds); err == nil {
+				window = time.Duration(n) * time.Second
+			}
+		}
+	}
+	return count, window, nil
+}
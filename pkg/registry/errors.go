@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// 可通过 errors.Is 判断的错误类型
+// registry 返回对应状态码时，返回的错误（*ResponseError 或包装它的错误）与这些错误匹配
+var (
+	ErrUnauthorized     = errors.New("未授权")             // 401：凭据无效、token 过期或 scope 不足
+	ErrForbidden        = errors.New("禁止访问")            // 403：凭据有效但没有访问权限
+	ErrNotFound         = errors.New("镜像或标签不存在")        // 404：仓库、标签或 digest 不存在
+	ErrManifestTooLarge = errors.New("manifest 超过大小限制") // manifest 超过 MaxManifestSize
+)
+
+// MaxManifestSize manifest 的最大大小（4 MiB，与 Docker Distribution 的限制一致）
+// 超过时返回可通过 errors.Is(err, ErrManifestTooLarge) 判断的错误
+const MaxManifestSize = 4 << 20
+
+// ResponseError 表示 registry 或认证服务返回了非预期的 HTTP 状态码
+// 可通过 errors.As 获取状态码，用于区分认证失败、镜像不存在、限流等情况
+type ResponseError struct {
+	Message    string // 错误描述，如 "获取 manifest 失败"
+	StatusCode int    // HTTP 状态码
+	Body       string // 响应体（最多 64KB），可能为空
+
+	// RetryAfter 为 registry 通过 Retry-After 要求的等待时间，未提供时为 0
+	RetryAfter time.Duration
+
+	// Challenge 为 401 响应中的 WWW-Authenticate header，可用于重新认证
+	Challenge string
+}
+
+// Error 实现 error 接口
+func (e *ResponseError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s (状态码: %d)", e.Message, e.StatusCode)
+	}
+	return fmt.Sprintf("%s (状态码: %d): %s", e.Message, e.StatusCode, e.Body)
+}
+
+// Is 使 errors.Is 可以按状态码判断错误类型
+// 401、403、404、429 分别对应 ErrUnauthorized、ErrForbidden、ErrNotFound、ErrRateLimited
+// 注意：Docker Hub 对不存在的仓库返回 401 而不是 404
+func (e *ResponseError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// newResponseError 根据响应构造 ResponseError，会读取响应体
+func newResponseError(message string, resp *http.Response) *ResponseError {
+	return &ResponseError{
+		Message:    message,
+		StatusCode: resp.StatusCode,
+		Body:       readErrorBody(resp),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		Challenge:  resp.Header.Get("Www-Authenticate"),
+	}
+}
+
+// AuthError 表示获取认证信息（token 或 Basic 凭据）失败
+// 可通过 errors.As 获取 registry 和认证服务返回的状态码，Unwrap 返回底层错误
+type AuthError struct {
+	Message     string // 错误描述
+	RegistryKey string // registry key，未注册的自定义源为 "custom:<域名>"
+	StatusCode  int    // 认证服务返回的 HTTP 状态码，未收到响应时为 0
+	Err         error  // 底层错误
+}
+
+// Error 实现 error 接口
+func (e *AuthError) Error() string {
+	return e.Message + ": " + e.Err.Error()
+}
+
+// Unwrap 返回底层错误
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// newAuthError 包装认证阶段的错误，并从中提取状态码
+func newAuthError(message, registryKey string, err error) *AuthError {
+	return &AuthError{
+		Message:     message,
+		RegistryKey: registryKey,
+		StatusCode:  errorStatusCode(err),
+		Err:         err,
+	}
+}
+
+// errorStatusCode 返回错误中 registry 或认证服务的 HTTP 状态码，没有时返回 0
+func errorStatusCode(err error) int {
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode
+	}
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode
+	}
+	return 0
+}
+
+// statusError 表示从 JSON 还原的带状态码的错误
+// 错误信息原样保留，errors.Is 按状态码判断错误类型
+type statusError struct {
+	message    string
+	statusCode int
+}
+
+// Error 实现 error 接口
+func (e *statusError) Error() string {
+	return e.message
+}
+
+// Is 与 ResponseError 相同，按状态码判断错误类型
+func (e *statusError) Is(target error) bool {
+	return (&ResponseError{StatusCode: e.statusCode}).Is(target)
+}
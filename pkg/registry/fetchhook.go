@@ -0,0 +1,93 @@
+package registry
+
+import "context"
+
+// FetchHook 表示 manifest 获取钩子，用于在不修改批量流程的情况下扩展获取结果
+// 例如为结果注入内部资产 ID、去掉不需要的 manifest 内容、将错误替换为带有内部信息的错误
+// GetManifestWithDigest、GetManifestsWithDigest、GetManifestsWithOptions、GetManifestsByReference 和 GetManifestsStream 都会调用钩子；
+// 被访问策略拒绝、registry 不可用或批量任务中止等未开始获取的镜像不调用钩子
+// 并发获取时钩子会被并发调用，实现需要保证并发安全
+type FetchHook interface {
+	// BeforeFetch 在获取镜像前调用，返回错误时不获取该镜像，该错误作为结果的错误（随后调用 OnError）
+	BeforeFetch(ctx context.Context, spec ImageSpec) error
+	// AfterFetch 在获取成功后调用，可以修改结果，如设置 Metadata、清空 Manifest
+	AfterFetch(ctx context.Context, result *ManifestResult)
+	// OnError 在获取失败后调用，可以修改结果，如替换 Error 或设置 Metadata
+	OnError(ctx context.Context, result *ManifestResult)
+}
+
+// FetchHookFuncs 使用函数实现 FetchHook，未设置的函数会被忽略
+type FetchHookFuncs struct {
+	Before func(ctx context.Context, spec ImageSpec) error
+	After  func(ctx context.Context, result *ManifestResult)
+	Error  func(ctx context.Context, result *ManifestResult)
+}
+
+// BeforeFetch 实现 FetchHook 接口
+func (h FetchHookFuncs) BeforeFetch(ctx context.Context, spec ImageSpec) error {
+	if h.Before != nil {
+		return h.Before(ctx, spec)
+	}
+	return nil
+}
+
+// AfterFetch 实现 FetchHook 接口
+func (h FetchHookFuncs) AfterFetch(ctx context.Context, result *ManifestResult) {
+	if h.After != nil {
+		h.After(ctx, result)
+	}
+}
+
+// OnError 实现 FetchHook 接口
+func (h FetchHookFuncs) OnError(ctx context.Context, result *ManifestResult) {
+	if h.Error != nil {
+		h.Error(ctx, result)
+	}
+}
+
+// UseFetchHook 添加 manifest 获取钩子，多个钩子按添加顺序调用
+// 返回 Client 本身以支持链式调用
+func (c *Client) UseFetchHook(hook FetchHook) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fetchHooks = append(c.fetchHooks, hook)
+	return c
+}
+
+// getFetchHooks 返回已添加的 manifest 获取钩子
+func (c *Client) getFetchHooks() []FetchHook {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.fetchHooks
+}
+
+// fetchWithHooks 调用 fetch 获取镜像的 manifest，并在前后调用 manifest 获取钩子
+// 第一个 BeforeFetch 返回错误时不调用 fetch，后续钩子的 BeforeFetch 也不再调用
+func (c *Client) fetchWithHooks(ctx context.Context, spec ImageSpec, fetch func() ManifestResult) ManifestResult {
+	hooks := c.getFetchHooks()
+	if len(hooks) == 0 {
+		return fetch()
+	}
+
+	var result ManifestResult
+	var vetoErr error
+	for _, hook := range hooks {
+		if vetoErr = hook.BeforeFetch(ctx, spec); vetoErr != nil {
+			break
+		}
+	}
+	if vetoErr != nil {
+		result = ManifestResult{Image: spec.Image, Tag: spec.Tag, Error: vetoErr}
+	} else {
+		result = fetch()
+	}
+
+	for _, hook := range hooks {
+		if result.Error != nil {
+			hook.OnError(ctx, &result)
+		} else {
+			hook.AfterFetch(ctx, &result)
+		}
+	}
+	return result
+}
@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FileMatch 表示 FindFile 在镜像层中找到的路径
+type FileMatch struct {
+	Path       string     `json:"path"`       // 匹配的路径，不带开头的 "/"
+	Layer      Descriptor `json:"layer"`      // 所在层的描述符
+	LayerIndex int        `json:"layerIndex"` // 所在层在 manifest 中的位置，0 为最底层
+	Entry      LayerEntry `json:"entry"`      // 层中的条目；Type 为 LayerEntryWhiteout 时表示该层删除了此路径
+	Visible    bool       `json:"visible"`    // 是否出现在最终的文件系统中（没有被更新的层删除或覆盖）
+}
+
+// FindFile 在镜像的所有层中查找匹配 pathGlob 的路径，报告每个匹配所在的层
+// pathGlob 使用 path.Match 语法；包含 "/" 时匹配完整路径（开头的 "/" 可省略），否则只匹配文件名，如 "*.pem"
+// 从最新的层向最底层遍历并应用 whiteout 语义：被更新的层删除（.wh.<name>、opaque 目录）或覆盖的条目 Visible 为 false；
+// 删除匹配路径的 whiteout 条目也会返回，用于确定文件在哪一层被删除
+// 结果按层从新到旧、层内按 tar 中的顺序排列；多架构镜像按 registry 默认平台或 linux/amd64 选择
+// 需要下载镜像的全部层，层内容流式处理，不写入磁盘；带有 URLs 的外部层会被跳过
+func (c *Client) FindFile(image, tag, pathGlob string) ([]FileMatch, error) {
+	return c.FindFileContext(context.Background(), image, tag, pathGlob)
+}
+
+// FindFileContext 与 FindFile 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) FindFileContext(ctx context.Context, image, tag, pathGlob string) ([]FileMatch, error) {
+	pattern := strings.TrimPrefix(pathGlob, "/")
+	if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+		return nil, fmt.Errorf("无效的路径模式 '%s'", pathGlob)
+	}
+	matchName := !strings.Contains(pattern, "/")
+
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	fetched, err := c.fetchManifest(ctx, target, authorization, defaultTag(target.registryKey, tag))
+	if err != nil {
+		return nil, err
+	}
+	fetched, err = c.resolveImageManifest(ctx, target, authorization, fetched, nil)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ImageManifest
+	if err := json.Unmarshal(fetched.body, &manifest); err != nil {
+		return nil, fmt.Errorf("解析 manifest 失败: %w", err)
+	}
+
+	var matches []FileMatch
+	var overlay layerOverlay
+	for i := len(manifest.Layers) - 1; i >= 0; i-- {
+		layer := manifest.Layers[i]
+		if len(layer.URLs) > 0 {
+			c.logger.Warn("跳过外部层", "image", image, "digest", layer.Digest)
+			continue
+		}
+
+		var entries []LayerEntry
+		err := c.walkLayer(ctx, target, authorization, layer.Digest, func(entry LayerEntry) error {
+			entries = append(entries, entry)
+			name := entry.Path
+			if matchName {
+				name = path.Base(name)
+			}
+			if ok, _ := path.Match(pattern, name); ok && entry.Type != LayerEntryOpaque {
+				matches = append(matches, FileMatch{
+					Path:       entry.Path,
+					Layer:      layer,
+					LayerIndex: i,
+					Entry:      entry,
+					Visible:    entry.Type != LayerEntryWhiteout && overlay.visible(entry.Path),
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("读取层 %s 失败: %w", layer.Digest, err)
+		}
+		// 同一层中的 whiteout 只作用于更下层
+		overlay.add(entries)
+	}
+	return matches, nil
+}
+
+// layerOverlay 记录更新的层对下层路径的影响
+type layerOverlay struct {
+	present map[string]bool // 更新的层中存在的路径，下层的同名条目被覆盖
+	removed map[string]bool // 被删除或被非目录条目替换的路径，其下的全部路径都不可见
+	opaque  map[string]bool // opaque 目录，下层中其下的全部路径都不可见
+}
+
+// add 将一层的条目加入 overlay
+func (o *layerOverlay) add(entries []LayerEntry) {
+	if o.present == nil {
+		o.present, o.removed, o.opaque = make(map[string]bool), make(map[string]bool), make(map[string]bool)
+	}
+	for _, entry := range entries {
+		switch entry.Type {
+		case LayerEntryWhiteout:
+			o.removed[entry.Path] = true
+		case LayerEntryOpaque:
+			o.opaque[entry.Path] = true
+		case LayerEntryDir:
+			o.present[entry.Path] = true
+		default:
+			o.present[entry.Path] = true
+			o.removed[entry.Path] = true
+		}
+	}
+}
+
+// visible 判断下层中的路径在应用更新的层之后是否可见
+func (o *layerOverlay) visible(p string) bool {
+	if o.present[p] || o.removed[p] {
+		return false
+	}
+	for dir := path.Dir(p); ; dir = path.Dir(dir) {
+		if dir == "." || dir == "/" {
+			dir = ""
+		}
+		if o.removed[dir] && dir != "" || o.opaque[dir] {
+			return false
+		}
+		if dir == "" {
+			return true
+		}
+	}
+}
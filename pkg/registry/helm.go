@@ -0,0 +1,183 @@
+package registry
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Helm chart 的 OCI 媒体类型
+const (
+	MediaTypeHelmConfig       = "application/vnd.cncf.helm.config.v1+json"
+	MediaTypeHelmChartContent = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	MediaTypeHelmProvenance   = "application/vnd.cncf.helm.chart.provenance.v1.prov"
+	mediaTypeHelmLegacyChart  = "application/tar+gzip" // Helm 3.0 实验性 OCI 支持使用的层媒体类型
+)
+
+// MaxHelmChartSize chart 压缩包的最大大小（32 MiB）
+const MaxHelmChartSize = 32 << 20
+
+// ErrNotHelmChart 表示 artifact 不是 Helm chart，可通过 errors.Is 判断
+var ErrNotHelmChart = errors.New("artifact 不是 Helm chart")
+
+// HelmChart 表示 OCI registry 中的 Helm chart 及其 Chart.yaml 元数据
+type HelmChart struct {
+	Name        string      // chart 名称
+	Version     string      // chart 版本
+	AppVersion  string      // 应用版本，未设置时为空
+	APIVersion  string      // Chart.yaml 的 apiVersion，如 "v2"
+	Type        string      // chart 类型（application 或 library），未设置时为空
+	Description string      // chart 描述
+	KubeVersion string      // 支持的 Kubernetes 版本约束
+	Digest      string      // chart manifest 的 digest
+	Chart       Descriptor  // chart 压缩包层的描述符
+	Provenance  *Descriptor // helm package --sign 生成的 provenance 层，没有时为 nil
+}
+
+// IsHelmChart 判断 artifact 是否为 Helm chart（按 config 媒体类型或层媒体类型判断）
+func IsHelmChart(artifact *Artifact) bool {
+	if artifact.ArtifactType == MediaTypeHelmConfig || artifact.Config.MediaType == MediaTypeHelmConfig {
+		return true
+	}
+	for _, layer := range artifact.Layers {
+		if layer.MediaType == MediaTypeHelmChartContent {
+			return true
+		}
+	}
+	return false
+}
+
+// GetHelmChart 获取 OCI registry 中的 Helm chart，下载 chart 层并返回其中 Chart.yaml 的元数据
+// image 为 chart 仓库，如 "ghcr.io/org/charts/nginx"；tag 为 chart 版本或 digest
+// artifact 不是 Helm chart 时返回可通过 errors.Is(err, ErrNotHelmChart) 判断的错误
+func (c *Client) GetHelmChart(image, tag string) (*HelmChart, error) {
+	return c.GetHelmChartContext(context.Background(), image, tag)
+}
+
+// GetHelmChartContext 与 GetHelmChart 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) GetHelmChartContext(ctx context.Context, image, tag string) (*HelmChart, error) {
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	artifact, err := c.fetchArtifact(ctx, target, authorization, defaultTag(target.registryKey, tag))
+	if err != nil {
+		return nil, err
+	}
+	layer, ok := helmChartLayer(artifact.Layers)
+	if !ok || !IsHelmChart(artifact) {
+		return nil, fmt.Errorf("%w: %s@%s (artifactType: %s)", ErrNotHelmChart, image, artifact.Digest, artifact.ArtifactType)
+	}
+
+	content, err := c.fetchBlob(ctx, target, authorization, layer, MaxHelmChartSize)
+	if err != nil {
+		return nil, err
+	}
+	chartYAML, err := readChartYAML(content)
+	if err != nil {
+		return nil, fmt.Errorf("读取 chart %s 失败: %w", layer.Digest, err)
+	}
+
+	chart := parseChartYAML(chartYAML)
+	chart.Digest, chart.Chart = artifact.Digest, layer
+	for i := range artifact.Layers {
+		if artifact.Layers[i].MediaType == MediaTypeHelmProvenance {
+			chart.Provenance = &artifact.Layers[i]
+			break
+		}
+	}
+	return chart, nil
+}
+
+// helmChartLayer 返回 chart 压缩包所在的层
+func helmChartLayer(layers []Descriptor) (Descriptor, bool) {
+	for _, layer := range layers {
+		if layer.MediaType == MediaTypeHelmChartContent || layer.MediaType == mediaTypeHelmLegacyChart {
+			return layer, true
+		}
+	}
+	return Descriptor{}, false
+}
+
+// readChartYAML 从 chart 压缩包中读取顶层 chart 的 Chart.yaml（"<chart>/Chart.yaml"，不包括 charts/ 下的子 chart）
+func readChartYAML(content []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("解压 chart 失败: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("chart 中没有 Chart.yaml")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解压 chart 失败: %w", err)
+		}
+		dir, name, ok := strings.Cut(strings.TrimPrefix(header.Name, "./"), "/")
+		if !ok || dir == "" || name != "Chart.yaml" || header.Typeflag != tar.TypeReg {
+			continue
+		}
+		return io.ReadAll(io.LimitReader(tr, MaxHelmChartSize))
+	}
+}
+
+// parseChartYAML 解析 Chart.yaml 中的顶层标量字段
+// 只处理 Chart.yaml 中形如 "key: value" 的简单字段，忽略列表、映射和多行字符串
+func parseChartYAML(content []byte) *HelmChart {
+	chart := &HelmChart{}
+	fields := map[string]*string{
+		"name":        &chart.Name,
+		"version":     &chart.Version,
+		"appVersion":  &chart.AppVersion,
+		"apiVersion":  &chart.APIVersion,
+		"type":        &chart.Type,
+		"description": &chart.Description,
+		"kubeVersion": &chart.KubeVersion,
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '#' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		if field, ok := fields[strings.TrimSpace(key)]; ok {
+			*field = yamlScalar(value)
+		}
+	}
+	return chart
+}
+
+// yamlScalar 解析单行 YAML 标量：去除行尾注释和引号
+func yamlScalar(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
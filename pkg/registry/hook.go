@@ -0,0 +1,97 @@
+package registry
+
+import (
+	"net/http"
+	"time"
+)
+
+// RequestHook 表示 HTTP 请求钩子，可用于自定义指标、调试以及修改请求
+// 客户端发出的每个 HTTP 请求（包括认证请求、限流重试和 Basic 认证重试）都会调用钩子
+// 并发获取时钩子会被并发调用，实现需要保证并发安全
+type RequestHook interface {
+	// BeforeRequest 在请求发出前调用，可以修改请求（如添加 header）
+	BeforeRequest(req *http.Request)
+	// AfterResponse 在收到响应或请求失败后调用
+	AfterResponse(event *RequestEvent)
+}
+
+// RequestEvent 表示一次 HTTP 请求的结果
+type RequestEvent struct {
+	Method     string        // 请求方法
+	URL        string        // 请求地址
+	StatusCode int           // 响应状态码（请求失败时为 0）
+	Header     http.Header   // 响应 header（请求失败时为 nil）
+	Duration   time.Duration // 从发出请求到收到响应 header 的耗时
+	Err        error         // 请求错误（收到响应时为 nil）
+}
+
+// HookFuncs 使用函数实现 RequestHook，未设置的函数会被忽略
+type HookFuncs struct {
+	Before func(req *http.Request)
+	After  func(event *RequestEvent)
+}
+
+// BeforeRequest 实现 RequestHook 接口
+func (h HookFuncs) BeforeRequest(req *http.Request) {
+	if h.Before != nil {
+		h.Before(req)
+	}
+}
+
+// AfterResponse 实现 RequestHook 接口
+func (h HookFuncs) AfterResponse(event *RequestEvent) {
+	if h.After != nil {
+		h.After(event)
+	}
+}
+
+// Use 添加 HTTP 请求钩子，多个钩子按添加顺序调用
+// 返回 Client 本身以支持链式调用
+func (c *Client) Use(hook RequestHook) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hooks = append(c.hooks, hook)
+	return c
+}
+
+// getHooks 返回已添加的钩子
+func (c *Client) getHooks() []RequestHook {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hooks
+}
+
+// send 发送单个 HTTP 请求，并在前后调用钩子和 Metrics
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	client := c.httpClientFor(req)
+	hooks := c.getHooks()
+	metrics := c.getMetrics()
+	if len(hooks) == 0 && metrics == nil {
+		return client.Do(req)
+	}
+
+	for _, hook := range hooks {
+		hook.BeforeRequest(req)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	event := &RequestEvent{
+		Method:   req.Method,
+		URL:      req.URL.String(),
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	if resp != nil {
+		event.StatusCode = resp.StatusCode
+		event.Header = resp.Header
+	}
+
+	for _, hook := range hooks {
+		hook.AfterResponse(event)
+	}
+	if metrics != nil {
+		metrics.ObserveRequest(event)
+	}
+	return resp, err
+}
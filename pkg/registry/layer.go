@@ -0,0 +1,217 @@
+package registry
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// 层条目的类型
+const (
+	LayerEntryFile     = "file"
+	LayerEntryDir      = "dir"
+	LayerEntrySymlink  = "symlink"
+	LayerEntryHardlink = "hardlink"
+	LayerEntryChar     = "char"
+	LayerEntryBlock    = "block"
+	LayerEntryFIFO     = "fifo"
+	LayerEntryWhiteout = "whiteout" // 删除下层的文件或目录（.wh.<name>）
+	LayerEntryOpaque   = "opaque"   // 隐藏下层目录中的全部内容（.wh..wh..opq）
+)
+
+// whiteout 文件名前缀
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// errLayerStopped 表示层读取已停止，用于中止下载
+var errLayerStopped = errors.New("层读取已停止")
+
+// ErrZstdUnsupported 表示当前构建不支持读取 zstd 压缩的层（使用 nozap 构建标签时只支持 gzip）
+var ErrZstdUnsupported = errors.New("当前构建不支持 zstd 压缩的层 (nozap 构建只支持 gzip)")
+
+// 压缩格式的魔数
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// LayerEntry 表示层 tar 中的条目
+// whiteout 条目的 Path 为被删除的路径，opaque 条目的 Path 为被隐藏内容的目录
+type LayerEntry struct {
+	Path     string      `json:"path"`               // 条目路径，不带开头的 "./" 或 "/"
+	Type     string      `json:"type"`               // 条目类型，如 LayerEntryFile、LayerEntryWhiteout
+	Size     int64       `json:"size"`               // 文件大小（字节）
+	Mode     fs.FileMode `json:"mode"`               // 权限位以及 setuid、setgid、sticky 位
+	UID      int         `json:"uid"`                // 所有者 UID
+	GID      int         `json:"gid"`                // 所有者 GID
+	ModTime  time.Time   `json:"modTime"`            // 修改时间
+	Linkname string      `json:"linkname,omitempty"` // 符号链接或硬链接的目标
+}
+
+// ListLayer 下载层 blob 并列出其中的 tar 条目，不解压到磁盘
+// 根据内容的魔数识别 gzip、zstd 压缩或未压缩的 tar；内容流式处理，不读入内存，读完后校验 digest
+// whiteout 文件（.wh.<name>）列为 LayerEntryWhiteout 类型、路径为被删除的 <name>
+func (c *Client) ListLayer(image, digest string) ([]LayerEntry, error) {
+	return c.ListLayerContext(context.Background(), image, digest)
+}
+
+// ListLayerContext 与 ListLayer 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) ListLayerContext(ctx context.Context, image, digest string) ([]LayerEntry, error) {
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []LayerEntry
+	err = c.walkLayer(ctx, target, authorization, digest, func(entry LayerEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// walkLayer 流式下载层 blob，对其中的每个 tar 条目调用 fn
+// fn 返回错误时停止遍历并返回该错误；全部条目遍历完后读完剩余内容以校验 digest
+func (c *Client) walkLayer(ctx context.Context, target *registryTarget, authorization, digest string, fn func(LayerEntry) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.streamBlob(ctx, target, authorization, digest, pw)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	err := readLayer(pr, fn)
+	if err == nil {
+		// tar 结束标记之后可能还有填充，读完才能校验 digest
+		if _, err = io.Copy(io.Discard, pr); err == nil {
+			return <-done
+		}
+	}
+
+	// 提前停止或读取失败：中止下载，下载本身的错误（如 404）优先返回
+	pr.CloseWithError(errLayerStopped)
+	cancel()
+	if streamErr := <-done; streamErr != nil && !errors.Is(streamErr, errLayerStopped) && !errors.Is(streamErr, context.Canceled) {
+		return streamErr
+	}
+	return err
+}
+
+// readLayer 读取（可能压缩的）层 tar，对每个条目调用 fn
+func readLayer(r io.Reader, fn func(LayerEntry) error) error {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+
+	var content io.Reader = br
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("解压层失败: %w", err)
+		}
+		defer gz.Close()
+		content = gz
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := newZstdReader(br)
+		if err != nil {
+			return fmt.Errorf("解压层失败: %w", err)
+		}
+		defer zr.Close()
+		content = zr
+	}
+
+	tr := tar.NewReader(content)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取层 tar 失败: %w", err)
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		if err := fn(newLayerEntry(header)); err != nil {
+			return err
+		}
+	}
+	// 读完压缩流，以便校验压缩格式的尾部
+	if _, err := io.Copy(io.Discard, content); err != nil {
+		return fmt.Errorf("读取层失败: %w", err)
+	}
+	return nil
+}
+
+// newLayerEntry 根据 tar 头创建层条目
+func newLayerEntry(header *tar.Header) LayerEntry {
+	entry := LayerEntry{
+		Path:     strings.TrimPrefix(path.Clean("/"+header.Name), "/"),
+		Size:     header.Size,
+		Mode:     fs.FileMode(header.Mode).Perm(),
+		UID:      header.Uid,
+		GID:      header.Gid,
+		ModTime:  header.ModTime,
+		Linkname: header.Linkname,
+	}
+	if header.Mode&04000 != 0 {
+		entry.Mode |= fs.ModeSetuid
+	}
+	if header.Mode&02000 != 0 {
+		entry.Mode |= fs.ModeSetgid
+	}
+	if header.Mode&01000 != 0 {
+		entry.Mode |= fs.ModeSticky
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		entry.Type = LayerEntryDir
+	case tar.TypeSymlink:
+		entry.Type = LayerEntrySymlink
+	case tar.TypeLink:
+		entry.Type = LayerEntryHardlink
+	case tar.TypeChar:
+		entry.Type = LayerEntryChar
+	case tar.TypeBlock:
+		entry.Type = LayerEntryBlock
+	case tar.TypeFifo:
+		entry.Type = LayerEntryFIFO
+	default:
+		entry.Type = LayerEntryFile
+	}
+
+	dir, name := path.Split(entry.Path)
+	switch {
+	case name == whiteoutOpaque:
+		entry.Type, entry.Path = LayerEntryOpaque, strings.TrimSuffix(dir, "/")
+	case strings.HasPrefix(name, whiteoutPrefix):
+		entry.Type, entry.Path = LayerEntryWhiteout, dir+strings.TrimPrefix(name, whiteoutPrefix)
+	}
+	return entry
+}
@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+// testLayerTar 返回包含普通文件、目录、whiteout 和 opaque 条目的 tar
+func testLayerTar(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	entries := []*tar.Header{
+		{Name: "./etc/", Typeflag: tar.TypeDir, Mode: 0o755},
+		{Name: "./etc/hosts", Typeflag: tar.TypeReg, Mode: 0o644, Size: 5},
+		{Name: "usr/bin/.wh.old", Typeflag: tar.TypeReg, Mode: 0o644},
+		{Name: "var/cache/.wh..wh..opq", Typeflag: tar.TypeReg, Mode: 0o644},
+	}
+	for _, header := range entries {
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Size > 0 {
+			tw.Write([]byte("hello"))
+		}
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+// checkLayerEntries 读取层并检查条目
+func checkLayerEntries(t *testing.T, data []byte) {
+	t.Helper()
+	var got []LayerEntry
+	if err := readLayer(bytes.NewReader(data), func(entry LayerEntry) error {
+		got = append(got, entry)
+		return nil
+	}); err != nil {
+		t.Fatalf("readLayer: %v", err)
+	}
+	want := []struct{ path, typ string }{
+		{"etc", LayerEntryDir},
+		{"etc/hosts", LayerEntryFile},
+		{"usr/bin/old", LayerEntryWhiteout},
+		{"var/cache", LayerEntryOpaque},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("条目数 = %d, 期望 %d", len(got), len(want))
+	}
+	for i, w := range want {
+		if got[i].Path != w.path || got[i].Type != w.typ {
+			t.Errorf("条目 %d = %s (%s), 期望 %s (%s)", i, got[i].Path, got[i].Type, w.path, w.typ)
+		}
+	}
+	if got[1].Size != 5 {
+		t.Errorf("etc/hosts 大小 = %d, 期望 5", got[1].Size)
+	}
+}
+
+func TestReadLayerUncompressed(t *testing.T) {
+	checkLayerEntries(t, testLayerTar(t))
+}
+
+func TestReadLayerGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(testLayerTar(t))
+	gz.Close()
+	checkLayerEntries(t, buf.Bytes())
+}
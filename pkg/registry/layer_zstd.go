@@ -0,0 +1,18 @@
+//go:build !nozap
+
+package registry
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// newZstdReader 返回 zstd 解压 reader
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return zr.IOReadCloser(), nil
+}
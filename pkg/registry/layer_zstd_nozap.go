@@ -0,0 +1,10 @@
+//go:build nozap
+
+package registry
+
+import "io"
+
+// newZstdReader 使用 nozap 构建标签时不支持 zstd，核心包不依赖 github.com/klauspost/compress
+func newZstdReader(r io.Reader) (io.ReadCloser, error) {
+	return nil, ErrZstdUnsupported
+}
@@ -0,0 +1,17 @@
+//go:build nozap
+
+package registry
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestReadLayerZstdUnsupported(t *testing.T) {
+	data := append(append([]byte(nil), zstdMagic...), make([]byte, 16)...)
+	err := readLayer(bytes.NewReader(data), func(LayerEntry) error { return nil })
+	if !errors.Is(err, ErrZstdUnsupported) {
+		t.Errorf("err = %v, 期望 ErrZstdUnsupported", err)
+	}
+}
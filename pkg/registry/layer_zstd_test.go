@@ -0,0 +1,21 @@
+//go:build !nozap
+
+package registry
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestReadLayerZstd(t *testing.T) {
+	var buf bytes.Buffer
+	zw, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(testLayerTar(t))
+	zw.Close()
+	checkLayerEntries(t, buf.Bytes())
+}
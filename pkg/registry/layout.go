@@ -0,0 +1,355 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OCILayoutVersion 支持的 OCI 镜像布局版本（oci-layout 文件中的 imageLayoutVersion）
+const OCILayoutVersion = "1.0.0"
+
+// AnnotationRefName index.json 中记录 manifest 引用名（通常为标签）的注解
+const AnnotationRefName = "org.opencontainers.image.ref.name"
+
+// ImportOCILayout 将 OCI 镜像布局目录（oci-layout、index.json 和 blobs/）中的镜像推送为 image:tag
+// 按 index.json 中 org.opencontainers.image.ref.name 注解为 tag（或以 ":tag" 结尾）的 manifest 选择镜像；
+// 没有匹配的注解但 index.json 只有一个 manifest 时使用它；tag 也可以是 index.json 中 manifest 的 digest
+// tag 为空时 index.json 必须只有一个 manifest，并使用其引用名作为标签
+// 多架构索引的全部子 manifest 和引用的 blob 都从目录中读取；目标仓库中已存在的 blob 不会重复上传
+// 返回的 CopyResult 中 Source 为目录路径；只读模式下返回 ErrReadOnly
+func (c *Client) ImportOCILayout(dir, image, tag string) (*CopyResult, error) {
+	return c.ImportOCILayoutContext(context.Background(), dir, image, tag)
+}
+
+// ImportOCILayoutContext 与 ImportOCILayout 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) ImportOCILayoutContext(ctx context.Context, dir, image, tag string) (*CopyResult, error) {
+	result := &CopyResult{Source: dir}
+
+	root, tag, err := selectLayoutManifest(dir, tag)
+	if err != nil {
+		return result, err
+	}
+	result.Destination = imageReference(image, tag)
+
+	if err := c.checkImagePolicy(image); err != nil {
+		return result, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return result, err
+	}
+	if err := c.checkReadOnly(http.MethodPut, target, "manifests/"+tag); err != nil {
+		return result, err
+	}
+
+	manifests, blobs, err := collectLayout(dir, root, c.getIndexLimits())
+	if err != nil {
+		return result, err
+	}
+	result.Digest = root.Digest
+	result.Blobs = len(blobs)
+
+	authorization, err := c.authorizePush(ctx, target)
+	if err != nil {
+		return result, err
+	}
+	for _, blob := range blobs {
+		exists, err := c.blobExists(ctx, target, authorization, blob.Digest)
+		if err != nil {
+			return result, fmt.Errorf("上传 blob %s 失败: %w", blob.Digest, err)
+		}
+		if exists {
+			result.BlobsExisting++
+			continue
+		}
+		path, err := layoutBlobPath(dir, blob.Digest)
+		if err != nil {
+			return result, err
+		}
+		open := func() (io.Reader, error) {
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("读取 blob 失败: %w", err)
+			}
+			return file, nil
+		}
+		if err := c.storeBlob(ctx, target, authorization, blob, open); err != nil {
+			return result, fmt.Errorf("上传 blob %s 失败: %w", blob.Digest, err)
+		}
+		result.BlobsUploaded++
+		result.BytesUploaded += blob.Size
+	}
+
+	// 先推送子 manifest，再推送引用它们的索引
+	for i := len(manifests) - 1; i >= 0; i-- {
+		manifest := manifests[i]
+		reference := manifest.desc.Digest
+		if i == 0 {
+			reference = tag
+		}
+		if _, err := c.putManifest(ctx, target, authorization, reference, manifest.desc.MediaType, manifest.body); err != nil {
+			return result, fmt.Errorf("推送 manifest %s 失败: %w", manifest.desc.Digest, err)
+		}
+		result.Manifests++
+	}
+
+	c.logger.Debug("OCI 布局导入完成",
+		"source", dir,
+		"destination", result.Destination,
+		"digest", result.Digest,
+		"existing", result.BlobsExisting,
+		"uploaded", result.BlobsUploaded)
+	return result, nil
+}
+
+// selectLayoutManifest 检查布局版本，从 index.json 中选择要导入的 manifest，返回其描述符和推送使用的标签
+func selectLayoutManifest(dir, tag string) (Descriptor, string, error) {
+	index, err := readLayoutIndex(dir)
+	if err != nil {
+		return Descriptor{}, "", err
+	}
+
+	if tag == "" {
+		if len(index.Manifests) > 1 {
+			return Descriptor{}, "", fmt.Errorf("index.json 中有 %d 个 manifest，需要指定标签 (%s)", len(index.Manifests), layoutRefNames(index.Manifests))
+		}
+		desc := index.Manifests[0]
+		name := desc.Annotations[AnnotationRefName]
+		// 引用名可能是完整的镜像引用，如 docker.io/library/nginx:1.25
+		if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i:], "/") {
+			name = name[i+1:]
+		}
+		if name == "" || !validTag(name) {
+			return Descriptor{}, "", fmt.Errorf("manifest %s 没有可用作标签的引用名，需要指定标签", desc.Digest)
+		}
+		return desc, name, nil
+	}
+
+	for _, desc := range index.Manifests {
+		name := desc.Annotations[AnnotationRefName]
+		if desc.Digest == tag || name == tag || strings.HasSuffix(name, ":"+tag) {
+			return desc, tag, nil
+		}
+	}
+	if len(index.Manifests) == 1 && !strings.Contains(tag, ":") {
+		return index.Manifests[0], tag, nil
+	}
+	return Descriptor{}, "", fmt.Errorf("%w: index.json 中没有引用名为 '%s' 的 manifest (%s)", ErrNotFound, tag, layoutRefNames(index.Manifests))
+}
+
+// readLayoutIndex 检查布局版本并读取 index.json，index.json 中没有 manifest 时返回错误
+func readLayoutIndex(dir string) (*ImageIndex, error) {
+	var layout struct {
+		ImageLayoutVersion string `json:"imageLayoutVersion"`
+	}
+	if err := readLayoutJSON(filepath.Join(dir, "oci-layout"), &layout); err != nil {
+		return nil, err
+	}
+	if layout.ImageLayoutVersion != OCILayoutVersion {
+		return nil, fmt.Errorf("不支持的 OCI 布局版本 '%s'", layout.ImageLayoutVersion)
+	}
+	var index ImageIndex
+	if err := readLayoutJSON(filepath.Join(dir, "index.json"), &index); err != nil {
+		return nil, err
+	}
+	if len(index.Manifests) == 0 {
+		return nil, fmt.Errorf("%w: %s 中没有 manifest", ErrNotFound, filepath.Join(dir, "index.json"))
+	}
+	return &index, nil
+}
+
+// layoutRefNames 返回 index.json 中 manifest 的引用名列表，用于错误信息
+func layoutRefNames(manifests []Descriptor) string {
+	names := make([]string, 0, len(manifests))
+	for _, desc := range manifests {
+		if name := desc.Annotations[AnnotationRefName]; name != "" {
+			names = append(names, name)
+		} else {
+			names = append(names, desc.Digest)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// collectLayout 从布局目录中读取根 manifest 及其子 manifest，返回需要推送的 manifest 和引用的 blob
+// manifest 按遍历顺序返回，第一个为根节点，索引排在其子 manifest 之前
+func collectLayout(dir string, root Descriptor, limits IndexLimits) ([]copiedManifest, []Descriptor, error) {
+	return collectImage(root, limits, func(desc Descriptor) ([]byte, error) {
+		return readLayoutBlob(dir, desc, MaxManifestSize)
+	})
+}
+
+// collectImage 通过 readManifest 读取根 manifest 及其子 manifest，返回需要写入的 manifest 和引用的 blob
+// readManifest 负责校验内容；manifest 按遍历顺序返回，第一个为根节点，索引排在其子 manifest 之前
+func collectImage(root Descriptor, limits IndexLimits, readManifest func(Descriptor) ([]byte, error)) ([]copiedManifest, []Descriptor, error) {
+	var manifests []copiedManifest
+	var blobs []Descriptor
+	seen := make(map[string]bool)
+
+	var visit func(desc Descriptor, depth int) error
+	visit = func(desc Descriptor, depth int) error {
+		if seen[desc.Digest] {
+			return nil
+		}
+		if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+			return &IndexLimitError{Limit: "depth", Max: limits.MaxDepth, Actual: depth, Digest: desc.Digest}
+		}
+		seen[desc.Digest] = true
+		if limits.MaxTotal > 0 && len(seen) > limits.MaxTotal {
+			return &IndexLimitError{Limit: "total", Max: limits.MaxTotal, Actual: len(seen), Digest: root.Digest}
+		}
+
+		body, err := readManifest(desc)
+		if err != nil {
+			return err
+		}
+		manifests = append(manifests, copiedManifest{desc: desc, body: body})
+
+		if IsIndexMediaType(desc.MediaType) {
+			var index ImageIndex
+			if err := json.Unmarshal(body, &index); err != nil {
+				return fmt.Errorf("解析镜像索引 %s 失败: %w", desc.Digest, err)
+			}
+			if err := limits.checkChildren(desc.Digest, &index); err != nil {
+				return err
+			}
+			for _, child := range index.Manifests {
+				if err := visit(child, depth+1); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		var manifest ImageManifest
+		if err := json.Unmarshal(body, &manifest); err != nil {
+			return fmt.Errorf("解析 manifest %s 失败: %w", desc.Digest, err)
+		}
+		for _, blob := range append([]Descriptor{manifest.Config}, manifest.Layers...) {
+			if blob.Digest == "" || len(blob.URLs) > 0 || seen[blob.Digest] {
+				continue
+			}
+			seen[blob.Digest] = true
+			blobs = append(blobs, blob)
+		}
+		return nil
+	}
+
+	if err := visit(root, 0); err != nil {
+		return nil, nil, err
+	}
+	return manifests, blobs, nil
+}
+
+// layoutBlobPath 返回 blob 在布局目录中的路径（blobs/<algorithm>/<encoded>）
+func layoutBlobPath(dir, digest string) (string, error) {
+	algorithm, encoded, err := splitDigest(digest)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "blobs", algorithm, encoded), nil
+}
+
+// splitDigest 按 OCI 的 digest 语法（algorithm 为 [a-z0-9]+([+._-][a-z0-9]+)*，encoded 为 [a-zA-Z0-9=_-]+）
+// 拆分 digest，用于生成 blob 的文件路径；不符合语法的 digest（如包含路径分隔符或 ..）返回错误，
+// 避免读写布局目录之外的文件。sha256 的 encoded 必须为 64 位小写十六进制
+func splitDigest(digest string) (algorithm, encoded string, err error) {
+	algorithm, encoded, ok := strings.Cut(digest, ":")
+	if !ok || !validDigestAlgorithm(algorithm) || !validDigestEncoded(encoded) {
+		return "", "", fmt.Errorf("无效的 digest: %s", digest)
+	}
+	if algorithm == "sha256" {
+		if _, decodeErr := hex.DecodeString(encoded); decodeErr != nil || len(encoded) != sha256.Size*2 || strings.ToLower(encoded) != encoded {
+			return "", "", fmt.Errorf("无效的 digest: %s", digest)
+		}
+	}
+	return algorithm, encoded, nil
+}
+
+// checkWritableDigest 确认写入的 blob 的 digest 可以校验：目前只能计算 sha256，
+// 其他算法的 digest 返回错误，避免写入未经校验的内容
+func checkWritableDigest(digest string) error {
+	algorithm, _, err := splitDigest(digest)
+	if err != nil {
+		return err
+	}
+	if algorithm != "sha256" {
+		return fmt.Errorf("不支持写入 digest 算法为 %s 的 blob（无法校验）: %s", algorithm, digest)
+	}
+	return nil
+}
+
+// validDigestAlgorithm 判断 digest 的算法部分是否符合 [a-z0-9]+([+._-][a-z0-9]+)*
+func validDigestAlgorithm(algorithm string) bool {
+	separator := true // 开头和分隔符之后必须是字母或数字
+	for _, r := range algorithm {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			separator = false
+		case strings.ContainsRune("+._-", r) && !separator:
+			separator = true
+		default:
+			return false
+		}
+	}
+	return !separator
+}
+
+// validDigestEncoded 判断 digest 的编码部分是否符合 [a-zA-Z0-9=_-]+
+func validDigestEncoded(encoded string) bool {
+	if encoded == "" {
+		return false
+	}
+	for _, r := range encoded {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("=_-", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// readLayoutBlob 读取布局目录中的小 blob（manifest），校验大小和 digest
+func readLayoutBlob(dir string, desc Descriptor, maxSize int64) ([]byte, error) {
+	if desc.Size > maxSize {
+		return nil, fmt.Errorf("blob %s 超过大小限制 (%d > %d 字节)", desc.Digest, desc.Size, maxSize)
+	}
+	path, err := layoutBlobPath(dir, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 blob 失败: %w", err)
+	}
+	if int64(len(content)) != desc.Size {
+		return nil, fmt.Errorf("blob %s 大小不匹配: 期望 %d 字节，实际 %d 字节", desc.Digest, desc.Size, len(content))
+	}
+	if strings.HasPrefix(desc.Digest, "sha256:") && computeDigest(content) != desc.Digest {
+		return nil, fmt.Errorf("blob digest 不匹配: 期望 %s，实际 %s", desc.Digest, computeDigest(content))
+	}
+	return content, nil
+}
+
+// readLayoutJSON 读取并解析布局目录中的 JSON 文件
+func readLayoutJSON(path string, v any) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: 读取 OCI 布局失败: %w", ErrNotFound, err)
+	}
+	if err != nil {
+		return fmt.Errorf("读取 OCI 布局失败: %w", err)
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", path, err)
+	}
+	return nil
+}
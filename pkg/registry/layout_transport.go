@@ -0,0 +1,278 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ociTransport 是内置的 oci 传输方式，读写 OCI 镜像布局目录
+// 镜像引用中的名称为布局目录，标签为 index.json 中 org.opencontainers.image.ref.name 注解的引用名
+type ociTransport struct{}
+
+// OpenSource 实现 ImageTransport 接口，按 ImportOCILayout 的规则选择 manifest；tag 为空时 index.json 必须只有一个 manifest
+func (ociTransport) OpenSource(ctx context.Context, dir, tag string) (ImageSource, error) {
+	index, err := readLayoutIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	if tag == "" {
+		if len(index.Manifests) > 1 {
+			return nil, fmt.Errorf("index.json 中有 %d 个 manifest，需要指定引用名 (%s)", len(index.Manifests), layoutRefNames(index.Manifests))
+		}
+		return &ociSource{dir: dir, root: index.Manifests[0]}, nil
+	}
+	root, _, err := selectLayoutManifest(dir, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &ociSource{dir: dir, root: root}, nil
+}
+
+// OpenDestination 实现 ImageTransport 接口，目录不存在时在写入第一个 blob 时创建
+// tag 不为空时 index.json 中该引用名指向复制的镜像（替换之前指向其他 manifest 的同名条目）
+func (ociTransport) OpenDestination(ctx context.Context, dir, tag string) (ImageDestination, error) {
+	if dir == "" {
+		return nil, errors.New("OCI 布局目录不能为空")
+	}
+	return &ociDestination{dir: dir, tag: tag}, nil
+}
+
+// ociSource 表示 OCI 镜像布局目录中作为复制源的镜像
+type ociSource struct {
+	dir  string
+	root Descriptor
+}
+
+// Root 实现 ImageSource 接口
+func (s *ociSource) Root(ctx context.Context) (Descriptor, []byte, error) {
+	body, err := readLayoutBlob(s.dir, s.root, MaxManifestSize)
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+	mediaType := s.root.MediaType
+	if mediaType == "" {
+		mediaType = detectManifestMediaType("", body)
+	}
+	return Descriptor{MediaType: mediaType, Digest: s.root.Digest, Size: s.root.Size}, body, nil
+}
+
+// Manifest 实现 ImageSource 接口
+func (s *ociSource) Manifest(ctx context.Context, desc Descriptor) ([]byte, error) {
+	return readLayoutBlob(s.dir, desc, MaxManifestSize)
+}
+
+// Blob 实现 ImageSource 接口
+func (s *ociSource) Blob(ctx context.Context, desc Descriptor) (io.ReadCloser, error) {
+	path, err := layoutBlobPath(s.dir, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 blob 失败: %w", err)
+	}
+	return file, nil
+}
+
+// Close 实现 ImageSource 接口
+func (s *ociSource) Close() error {
+	return nil
+}
+
+// ociDestination 表示作为复制目标的 OCI 镜像布局目录
+type ociDestination struct {
+	dir         string
+	tag         string
+	initialized bool
+	root        *Descriptor
+}
+
+// SupportsIndex 实现 ImageDestination 接口
+func (d *ociDestination) SupportsIndex() bool {
+	return true
+}
+
+// HasBlob 实现 ImageDestination 接口，大小一致的 blob 文件视为已存在
+func (d *ociDestination) HasBlob(ctx context.Context, desc Descriptor) (bool, error) {
+	path, err := layoutBlobPath(d.dir, desc.Digest)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return info.Size() == desc.Size, nil
+}
+
+// PutBlob 实现 ImageDestination 接口
+func (d *ociDestination) PutBlob(ctx context.Context, desc Descriptor, content io.Reader) error {
+	if err := d.init(); err != nil {
+		return err
+	}
+	return writeLayoutBlob(d.dir, desc, content)
+}
+
+// PutManifest 实现 ImageDestination 接口
+func (d *ociDestination) PutManifest(ctx context.Context, desc Descriptor, body []byte, root bool) error {
+	if err := d.PutBlob(ctx, desc, bytes.NewReader(body)); err != nil {
+		return err
+	}
+	if root {
+		d.root = &Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size}
+	}
+	return nil
+}
+
+// Commit 实现 ImageDestination 接口，将根 manifest 写入 index.json
+func (d *ociDestination) Commit(ctx context.Context) error {
+	if d.root == nil {
+		return errors.New("没有写入根 manifest")
+	}
+	return updateLayoutIndex(d.dir, *d.root, d.tag)
+}
+
+// Close 实现 ImageDestination 接口，已写入的 blob 按内容寻址，未提交时保留也不影响布局
+func (d *ociDestination) Close() error {
+	return nil
+}
+
+// init 创建布局目录和 oci-layout 文件，目录已是 OCI 布局时检查版本
+func (d *ociDestination) init() error {
+	if d.initialized {
+		return nil
+	}
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return fmt.Errorf("创建 OCI 布局目录失败: %w", err)
+	}
+	var layout struct {
+		ImageLayoutVersion string `json:"imageLayoutVersion"`
+	}
+	path := filepath.Join(d.dir, "oci-layout")
+	err := readLayoutJSON(path, &layout)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		data, _ := json.Marshal(map[string]string{"imageLayoutVersion": OCILayoutVersion})
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return fmt.Errorf("写入 oci-layout 失败: %w", err)
+		}
+	case err != nil:
+		return err
+	case layout.ImageLayoutVersion != OCILayoutVersion:
+		return fmt.Errorf("不支持的 OCI 布局版本 '%s'", layout.ImageLayoutVersion)
+	}
+	d.initialized = true
+	return nil
+}
+
+// writeLayoutBlob 将 blob 写入布局目录，先写入临时文件，校验大小和 digest 后再重命名；只能写入 sha256 的 blob
+func writeLayoutBlob(dir string, desc Descriptor, content io.Reader) error {
+	if err := checkWritableDigest(desc.Digest); err != nil {
+		return err
+	}
+	path, err := layoutBlobPath(dir, desc.Digest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建 blob 目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("写入 blob 失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Chmod(0o644)
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入 blob %s 失败: %w", desc.Digest, err)
+	}
+	if n != desc.Size {
+		return fmt.Errorf("blob %s 大小不匹配: 期望 %d 字节，实际 %d 字节", desc.Digest, desc.Size, n)
+	}
+	if digest := "sha256:" + hex.EncodeToString(hash.Sum(nil)); digest != desc.Digest {
+		return fmt.Errorf("blob digest 不匹配: 期望 %s，实际 %s", desc.Digest, digest)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("写入 blob %s 失败: %w", desc.Digest, err)
+	}
+	return nil
+}
+
+// layoutIndexLocks 按布局目录串行化 index.json 的读取和写入，避免并发复制到同一目录（如 mirror 同步多个标签）时丢失条目
+// 只在进程内有效，多个进程同时写入同一目录时仍可能丢失条目
+var layoutIndexLocks sync.Map // 布局目录的绝对路径 -> *sync.Mutex
+
+// updateLayoutIndex 将 manifest 添加到布局目录的 index.json
+// tag 不为空时作为引用名注解，替换之前同名的条目；tag 为空时替换没有引用名的同一 manifest
+func updateLayoutIndex(dir string, desc Descriptor, tag string) error {
+	key, err := filepath.Abs(dir)
+	if err != nil {
+		key = dir
+	}
+	lock, _ := layoutIndexLocks.LoadOrStore(key, new(sync.Mutex))
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	path := filepath.Join(dir, "index.json")
+	index := ImageIndex{SchemaVersion: 2, MediaType: MediaTypeOCIIndex}
+	if err := readLayoutJSON(path, &index); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if index.MediaType == "" {
+		index.MediaType = MediaTypeOCIIndex
+	}
+
+	entry := desc
+	if tag != "" {
+		entry.Annotations = map[string]string{AnnotationRefName: tag}
+	}
+	manifests := make([]Descriptor, 0, len(index.Manifests)+1)
+	for _, existing := range index.Manifests {
+		name := existing.Annotations[AnnotationRefName]
+		if name == tag && (tag != "" || existing.Digest == desc.Digest) {
+			continue
+		}
+		manifests = append(manifests, existing)
+	}
+	index.Manifests = append(manifests, entry)
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "index.json.*")
+	if err != nil {
+		return fmt.Errorf("写入 index.json 失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	tmp.Chmod(0o644)
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入 index.json 失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入 index.json 失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("写入 index.json 失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 索引限制的默认值
+const (
+	DefaultMaxIndexDepth    = 8     // 默认最大嵌套深度
+	DefaultMaxIndexChildren = 1024  // 默认单个索引的最大子 manifest 数量
+	DefaultMaxIndexTotal    = 10000 // 默认一次遍历的最大描述符总数
+)
+
+// ErrIndexLimitExceeded 表示镜像索引超出了安全限制
+// 可通过 errors.Is(err, ErrIndexLimitExceeded) 判断，详细信息见 *IndexLimitError
+var ErrIndexLimitExceeded = errors.New("镜像索引超出限制")
+
+// IndexLimits 表示处理镜像索引时的安全限制
+// 用于防止异常或恶意的 registry 通过超深嵌套、超大索引拖垮批量任务
+// 零值字段使用默认值，负数表示不限制
+type IndexLimits struct {
+	MaxDepth    int // 最大嵌套深度，根索引的子节点深度为 1
+	MaxChildren int // 单个索引的最大子 manifest 数量
+	MaxTotal    int // IndexWalk 一次遍历的最大描述符总数
+}
+
+// withDefaults 返回填充了默认值的限制
+func (l IndexLimits) withDefaults() IndexLimits {
+	if l.MaxDepth == 0 {
+		l.MaxDepth = DefaultMaxIndexDepth
+	}
+	if l.MaxChildren == 0 {
+		l.MaxChildren = DefaultMaxIndexChildren
+	}
+	if l.MaxTotal == 0 {
+		l.MaxTotal = DefaultMaxIndexTotal
+	}
+	return l
+}
+
+// IndexLimitError 表示镜像索引超出限制的详细信息
+type IndexLimitError struct {
+	Limit  string // 超出的限制: "depth"、"children" 或 "total"
+	Max    int    // 限制值
+	Actual int    // 实际值
+	Digest string // 超出限制的索引 digest
+}
+
+// Error 实现 error 接口
+func (e *IndexLimitError) Error() string {
+	var name string
+	switch e.Limit {
+	case "depth":
+		name = "嵌套深度"
+	case "children":
+		name = "子 manifest 数量"
+	case "total":
+		name = "描述符总数"
+	default:
+		name = e.Limit
+	}
+	return fmt.Sprintf("镜像索引 %s 的%s超出限制 (%d > %d)", e.Digest, name, e.Actual, e.Max)
+}
+
+// Is 使 errors.Is(err, ErrIndexLimitExceeded) 返回 true
+func (e *IndexLimitError) Is(target error) bool {
+	return target == ErrIndexLimitExceeded
+}
+
+// WithIndexLimits 设置处理镜像索引时的安全限制
+// 影响 IndexWalk 以及按平台解析索引（默认平台）的行为
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithIndexLimits(limits IndexLimits) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexLimits = limits
+	return c
+}
+
+// getIndexLimits 返回填充了默认值的索引限制
+func (c *Client) getIndexLimits() IndexLimits {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.indexLimits.withDefaults()
+}
+
+// checkChildren 检查索引的子 manifest 数量
+func (l IndexLimits) checkChildren(digest string, index *ImageIndex) error {
+	if l.MaxChildren > 0 && len(index.Manifests) > l.MaxChildren {
+		return &IndexLimitError{Limit: "children", Max: l.MaxChildren, Actual: len(index.Manifests), Digest: digest}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package registry
+
+// Logger 是客户端使用的日志接口
+// 参数为交替的键值对，与 log/slog 的约定相同，*slog.Logger 可以直接满足该接口
+// 使用 zap 时可通过 NewZapLogger 适配
+type Logger interface {
+	Debug(msg string, keysAndValues ...any)
+	Info(msg string, keysAndValues ...any)
+	Warn(msg string, keysAndValues ...any)
+	Error(msg string, keysAndValues ...any)
+}
+
+// WithCustomLogger 为已存在的 Client 设置实现了 Logger 接口的 logger
+// 例如直接使用 *slog.Logger：client.WithCustomLogger(slog.Default())
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithCustomLogger(logger Logger) *Client {
+	if logger != nil {
+		c.logger = logger
+	}
+	return c
+}
+
+// nopLogger 不输出任何日志，作为客户端的默认 logger
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...any) {}
+func (nopLogger) Info(string, ...any)  {}
+func (nopLogger) Warn(string, ...any)  {}
+func (nopLogger) Error(string, ...any) {}
@@ -0,0 +1,26 @@
+//go:build nozap
+
+package registry
+
+import "log/slog"
+
+// 使用 nozap 构建标签时，客户端核心只依赖标准库（zstd 压缩的层不受支持），日志通过标准库 log/slog 输出：
+//
+//	go build -tags nozap ./...
+//
+// make check-nozap 检查这一点，新增导入时应保证该检查通过。
+
+// NewClientWithLogger 创建一个带自定义 logger 的 registry 客户端
+// logger: 自定义的 slog.Logger 实例
+func NewClientWithLogger(logger *slog.Logger) *Client {
+	return NewClient().WithLogger(logger)
+}
+
+// WithLogger 为已存在的 Client 设置 logger
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithLogger(logger *slog.Logger) *Client {
+	if logger != nil {
+		c.logger = logger
+	}
+	return c
+}
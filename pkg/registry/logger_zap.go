@@ -0,0 +1,35 @@
+//go:build !nozap
+
+package registry
+
+import "go.uber.org/zap"
+
+// zapLogger 将 zap.Logger 适配为 Logger
+type zapLogger struct {
+	sugar *zap.SugaredLogger
+}
+
+func (l zapLogger) Debug(msg string, keysAndValues ...any) { l.sugar.Debugw(msg, keysAndValues...) }
+func (l zapLogger) Info(msg string, keysAndValues ...any)  { l.sugar.Infow(msg, keysAndValues...) }
+func (l zapLogger) Warn(msg string, keysAndValues ...any)  { l.sugar.Warnw(msg, keysAndValues...) }
+func (l zapLogger) Error(msg string, keysAndValues ...any) { l.sugar.Errorw(msg, keysAndValues...) }
+
+// NewZapLogger 将 zap.Logger 适配为 Logger 接口
+func NewZapLogger(logger *zap.Logger) Logger {
+	return zapLogger{sugar: logger.Sugar()}
+}
+
+// NewClientWithLogger 创建一个带自定义 logger 的 registry 客户端
+// logger: 自定义的 zap.Logger 实例
+func NewClientWithLogger(logger *zap.Logger) *Client {
+	return NewClient().WithLogger(logger)
+}
+
+// WithLogger 为已存在的 Client 设置 logger
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithLogger(logger *zap.Logger) *Client {
+	if logger != nil {
+		c.logger = NewZapLogger(logger)
+	}
+	return c
+}
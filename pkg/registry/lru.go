@@ -0,0 +1,85 @@
+package registry
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache 是并发安全的 LRU 缓存，超过最大条目数时淘汰最久未使用的条目，条目可以设置过期时间
+// MemoryCache 和 MemoryManifestCache 共用这一个实现
+type lruCache[V any] struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	order   *list.List               // 最近使用的在前
+	entries map[string]*list.Element // key -> order 中的元素
+}
+
+// lruEntry 表示 lruCache 中的条目
+type lruEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time // 零值表示不过期
+}
+
+// newLRUCache 创建 LRU 缓存，maxEntries 为 0 或负数时使用默认值 DefaultManifestCacheSize
+func newLRUCache[V any](maxEntries int) *lruCache[V] {
+	if maxEntries <= 0 {
+		maxEntries = DefaultManifestCacheSize
+	}
+	return &lruCache[V]{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// get 返回 key 对应的值并将其标记为最近使用，不存在或已过期时返回 false（已过期的条目同时被删除）
+func (l *lruCache[V]) get(key string) (V, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	entry := elem.Value.(*lruEntry[V])
+	if !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+		var zero V
+		return zero, false
+	}
+	l.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// set 保存 key 对应的值，ttl 为 0 或负数表示不过期；超过最大条目数时淘汰最久未使用的条目
+func (l *lruCache[V]) set(key string, value V, ttl time.Duration) {
+	entry := &lruEntry[V]{key: key, value: value, expiresAt: expiresAt(ttl)}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.entries[key]; ok {
+		elem.Value = entry
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.entries[key] = l.order.PushFront(entry)
+	for l.order.Len() > l.maxEntries {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// delete 删除 key 对应的条目
+func (l *lruCache[V]) delete(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.entries[key]; ok {
+		l.order.Remove(elem)
+		delete(l.entries, key)
+	}
+}
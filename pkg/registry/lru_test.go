@@ -0,0 +1,58 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCacheEviction(t *testing.T) {
+	cache := newLRUCache[int](2)
+	cache.set("a", 1, 0)
+	cache.set("b", 2, 0)
+	// 读取 a 后 b 成为最久未使用的条目
+	if value, ok := cache.get("a"); !ok || value != 1 {
+		t.Errorf("get(a) = %d, %v, 期望 1", value, ok)
+	}
+	cache.set("c", 3, 0)
+	if _, ok := cache.get("b"); ok {
+		t.Error("超过最大条目数时应淘汰最久未使用的 b")
+	}
+	for key, want := range map[string]int{"a": 1, "c": 3} {
+		if value, ok := cache.get(key); !ok || value != want {
+			t.Errorf("get(%s) = %d, %v, 期望 %d", key, value, ok, want)
+		}
+	}
+
+	// 更新已有的条目不淘汰其他条目
+	cache.set("a", 10, 0)
+	if value, ok := cache.get("a"); !ok || value != 10 {
+		t.Errorf("get(a) = %d, %v, 期望 10", value, ok)
+	}
+	if _, ok := cache.get("c"); !ok {
+		t.Error("更新已有的条目时不应淘汰 c")
+	}
+
+	cache.delete("a")
+	if _, ok := cache.get("a"); ok {
+		t.Error("delete 之后 get 应未命中")
+	}
+}
+
+func TestLRUCacheExpiry(t *testing.T) {
+	cache := newLRUCache[string](0)
+	if cache.maxEntries != DefaultManifestCacheSize {
+		t.Errorf("maxEntries = %d, 期望默认值 %d", cache.maxEntries, DefaultManifestCacheSize)
+	}
+	cache.set("short", "v", time.Millisecond)
+	cache.set("forever", "v", 0)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.get("short"); ok {
+		t.Error("已过期的条目应未命中")
+	}
+	if _, ok := cache.entries["short"]; ok {
+		t.Error("已过期的条目应在读取时删除")
+	}
+	if _, ok := cache.get("forever"); !ok {
+		t.Error("ttl 为 0 的条目不应过期")
+	}
+}
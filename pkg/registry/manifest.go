@@ -1,129 +1,189 @@
 package registry
 
 import (
-	"fmt"
-	"io"
-	"net/http"
-	"strings"
-
-	"go.uber.org/zap"
+	"context"
+	"encoding/json"
+	"errors"
 )
 
 // GetManifestWithDigest 获取 manifest 并返回其 digest
 // digest 可以用于确保镜像的完整性
 func (c *Client) GetManifestWithDigest(image, tag string) (manifest string, digest string, err error) {
-	// 检测 registry key
-	registryKey := DetectRegistry(image)
-
-	// 检查是否为未注册的自定义 registry
-	isCustomUnregistered := false
-	var customDomain string
-	if len(registryKey) > 7 && registryKey[:7] == "custom:" {
-		isCustomUnregistered = true
-		customDomain = registryKey[7:] // 提取域名
-		c.logger.Debug("检测到未注册的自定义源", zap.String("domain", customDomain))
-	}
-
-	var config *RegistryConfig
-	var normalizedImage string
-	var token string
-
-	if isCustomUnregistered {
-		// 对于未注册的自定义源，使用 WWW-Authenticate 流程
-		// 构建 registry URL
-		registryURL := "https://" + customDomain
-
-		// 规范化镜像名称（移除域名前缀）
-		parts := strings.SplitN(image, "/", 2)
-		if len(parts) == 2 {
-			normalizedImage = parts[1]
-		} else {
-			normalizedImage = image
-		}
-
-		// 通过 WWW-Authenticate 获取 token
-		token, err = c.getAuthTokenViaWWWAuthenticate(registryURL, normalizedImage)
-		if err != nil {
-			return "", "", fmt.Errorf("通过 WWW-Authenticate 获取认证 token 失败: %w", err)
-		}
-
-		// 使用临时配置
-		config = &RegistryConfig{
-			RegistryURL: registryURL,
-		}
-	} else {
-		// 对于已注册的 registry，使用标准流程
-		var ok bool
-		config, ok = GetRegistry(registryKey)
-		if !ok {
-			return "", "", fmt.Errorf("未找到 registry 配置: %s", registryKey)
-		}
+	return c.GetManifestWithDigestContext(context.Background(), image, tag)
+}
 
-		// 规范化镜像名称
-		normalizedImage = NormalizeImageName(image, registryKey)
+// GetManifestWithDigestContext 与 GetManifestWithDigest 相同，但所有请求（包括认证）受 ctx 控制
+// 可通过 ctx 为单个镜像设置整体超时或取消请求
+func (c *Client) GetManifestWithDigestContext(ctx context.Context, image, tag string) (manifest string, digest string, err error) {
+	spec := ImageSpec{Image: image, Tag: tag}
+	result := c.fetchWithHooks(ctx, spec, func() ManifestResult {
+		result := ManifestResult{Image: image, Tag: tag}
+		result.Error = c.getManifest(ctx, spec, &result)
+		return result
+	})
+	return result.Manifest, result.Digest, result.Error
+}
 
-		// 获取认证 token
-		token, err = c.getAuthToken(image, registryKey)
-		if err != nil {
-			return "", "", fmt.Errorf("获取认证 token 失败: %w", err)
-		}
+// getManifest 获取 spec 对应的 manifest，结果写入 result
+// spec 设置了 CredentialKey 时使用对应的凭据，设置了 Platform 时解析为该平台的 manifest
+func (c *Client) getManifest(ctx context.Context, spec ImageSpec, result *ManifestResult) error {
+	// 检查镜像访问策略
+	if err := c.checkImagePolicy(spec.Image); err != nil {
+		return err
 	}
 
-	// 构建 manifest URL
-	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", config.RegistryURL, normalizedImage, tag)
-
-	c.logger.Debug("获取 manifest", zap.String("url", manifestURL))
-	// 创建请求
-	req, err := http.NewRequest("GET", manifestURL, nil)
+	// 解析访问目标（registry、仓库名称、凭据）
+	target, err := c.resolveSpec(spec)
 	if err != nil {
-		return "", "", fmt.Errorf("创建请求失败: %w", err)
+		return err
 	}
 
-	// 设置必要的 headers
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.list.v2+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.manifest.v1+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.index.v1+json")
-
-	// 发送请求
-	resp, err := c.httpClient.Do(req)
+	// 获取认证信息
+	authorization, err := c.authorize(ctx, target)
 	if err != nil {
-		return "", "", fmt.Errorf("请求失败: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
-
-	// 检查响应状态
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", "", fmt.Errorf("获取 manifest 失败 (状态码: %d): %s", resp.StatusCode, string(body))
+	if warning := target.anonymousWarning(); warning != "" {
+		result.Warnings = append(result.Warnings, warning)
 	}
 
-	// 获取 Docker-Content-Digest header
-	digest = resp.Header.Get("Docker-Content-Digest")
+	// 未指定标签时使用 registry 的默认标签
+	tag := defaultTag(target.registryKey, spec.Tag)
+
+	c.logger.Debug("获取 manifest",
+		"registry", target.registryURL,
+		"repository", target.repository,
+		"tag", tag)
 
-	// 读取响应体
-	body, err := io.ReadAll(resp.Body)
+	fetched, err := c.fetchManifest(ctx, target, authorization, tag)
+	if newAuthorization, ok := c.reauthorize(ctx, target, err); ok {
+		// token 过期或 scope 不足，使用重新获取的 token 重试一次
+		authorization = newAuthorization
+		fetched, err = c.fetchManifest(ctx, target, authorization, tag)
+	}
 	if err != nil {
-		return "", "", fmt.Errorf("读取响应失败: %w", err)
+		return err
 	}
 
-	return string(body), digest, nil
+	// 按指定平台或 registry 的默认平台策略解析索引
+	return c.resolveSpecPlatform(ctx, target, authorization, spec, fetched, result)
 }
 
 // ManifestResult 表示单个镜像的 manifest 获取结果
 type ManifestResult struct {
-	Image    string // 镜像名称
-	Tag      string // 镜像标签
-	Manifest string // Manifest JSON 字符串
-	Digest   string // Manifest digest
-	Error    error  // 错误信息（如果获取失败）
+	Image        string // 镜像名称
+	Tag          string // 镜像标签
+	Manifest     string // Manifest JSON 字符串（使用 WithSpillDir 落盘时为空）
+	ManifestPath string // Manifest 落盘文件路径（仅使用 WithSpillDir 时设置）
+	Digest       string // Manifest digest（由多架构索引解析时为所选平台的 manifest digest）
+	IndexDigest  string // 按平台解析多架构索引时为索引的 digest，不是索引时为空
+	Error        error  // 错误信息（如果获取失败）
+
+	// Warnings 为获取成功但需要注意的情况，如凭据被拒绝后回退为匿名访问（WithAnonymousFallback）
+	Warnings []string
+
+	// Metadata 为 FetchHook 添加的附加信息（如内部资产 ID），客户端本身不设置
+	Metadata map[string]string
+}
+
+// batchErr 实现 batchResult 接口
+func (r ManifestResult) batchErr() error {
+	return r.Error
+}
+
+// manifestResultJSON 是 ManifestResult 的 JSON 表示
+type manifestResultJSON struct {
+	Image        string            `json:"image"`
+	Tag          string            `json:"tag"`
+	Digest       string            `json:"digest,omitempty"`
+	IndexDigest  string            `json:"indexDigest,omitempty"`
+	Manifest     json.RawMessage   `json:"manifest,omitempty"`
+	ManifestPath string            `json:"manifestPath,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	StatusCode   int               `json:"statusCode,omitempty"`
+	Warnings     []string          `json:"warnings,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+}
+
+// MarshalJSON 实现 json.Marshaler 接口
+// Manifest 作为 JSON 对象内嵌输出（不是合法 JSON 时作为字符串），Error 输出为错误信息，
+// registry 返回了错误状态码时同时输出 statusCode，便于将批量结果交给其他工具处理
+func (r ManifestResult) MarshalJSON() ([]byte, error) {
+	out := manifestResultJSON{
+		Image:        r.Image,
+		Tag:          r.Tag,
+		Digest:       r.Digest,
+		IndexDigest:  r.IndexDigest,
+		ManifestPath: r.ManifestPath,
+		Warnings:     r.Warnings,
+		Metadata:     r.Metadata,
+	}
+	if r.Manifest != "" {
+		if json.Valid([]byte(r.Manifest)) {
+			out.Manifest = json.RawMessage(r.Manifest)
+		} else {
+			quoted, err := json.Marshal(r.Manifest)
+			if err != nil {
+				return nil, err
+			}
+			out.Manifest = quoted
+		}
+	}
+	if r.Error != nil {
+		out.Error = r.Error.Error()
+		out.StatusCode = errorStatusCode(r.Error)
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON 实现 json.Unmarshaler 接口
+// 带有 statusCode 的错误还原后可继续使用 errors.Is 判断错误类型（如 ErrNotFound）
+func (r *ManifestResult) UnmarshalJSON(data []byte) error {
+	var in manifestResultJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	*r = ManifestResult{
+		Image:        in.Image,
+		Tag:          in.Tag,
+		Digest:       in.Digest,
+		IndexDigest:  in.IndexDigest,
+		ManifestPath: in.ManifestPath,
+		Warnings:     in.Warnings,
+		Metadata:     in.Metadata,
+	}
+	if len(in.Manifest) > 0 {
+		var s string
+		if json.Unmarshal(in.Manifest, &s) == nil {
+			r.Manifest = s
+		} else {
+			r.Manifest = string(in.Manifest)
+		}
+	}
+	switch {
+	case in.StatusCode != 0:
+		r.Error = &statusError{message: in.Error, statusCode: in.StatusCode}
+	case in.Error != "":
+		r.Error = errors.New(in.Error)
+	}
+	return nil
 }
 
 // ImageSpec 表示镜像规格（名称+标签）
+// Tag 为空时使用 registry 配置的默认标签（未配置时为 latest）
 type ImageSpec struct {
 	Image string
 	Tag   string
+
+	// Platform 为镜像是多架构索引时选择的平台（如 linux/arm64），为空时使用 registry 的默认平台，
+	// 未配置默认平台时返回索引本身；结果的 Digest 为所选平台的 manifest digest，IndexDigest 为索引的 digest
+	Platform string
+
+	// CredentialKey 为获取该镜像使用的凭据 key（通过 AddCredential 添加），为空时使用镜像所在 registry 的凭据
+	// 用于同一 registry 上的镜像需要使用不同机器人账号的情况，如 AddCredential("harbor-team-a", ...)
+	// 批量认证时只有凭据相同的镜像共享 token
+	CredentialKey string
 }
 
 // GetManifestsWithDigest 批量获取多个镜像的 manifest 和 digest
@@ -137,25 +197,93 @@ type ImageSpec struct {
 //   - 每组限制最多 30 个镜像，超过则继续分组
 //   - 每组使用独立的批量认证 token
 func (c *Client) GetManifestsWithDigest(imageSpecs []ImageSpec, concurrency int, batchAuth bool, maxBatchSize *int) []ManifestResult {
-	if len(imageSpecs) == 0 {
-		return nil
+	opts := BatchOptions{Concurrency: concurrency, BatchAuth: batchAuth}
+	if maxBatchSize != nil {
+		opts.MaxBatchSize = *maxBatchSize
+	}
+	return runBatch(c, context.Background(), imageSpecs, opts, c.fetchSingleManifest, func(spec ImageSpec, err error) ManifestResult {
+		return ManifestResult{Image: spec.Image, Tag: spec.Tag, Error: err}
+	})
+}
+
+// GetManifestsWithOptions 与 GetManifestsWithDigest 相同，但通过 BatchOptions 设置批量选项，所有请求受 ctx 控制
+// 设置 opts.MaxFailures 时，失败数达到该值后中止整个批量任务，适用于任何失败都视为整体失败的流水线
+func (c *Client) GetManifestsWithOptions(ctx context.Context, imageSpecs []ImageSpec, opts BatchOptions) []ManifestResult {
+	return runBatch(c, ctx, imageSpecs, opts, c.fetchSingleManifest, func(spec ImageSpec, err error) ManifestResult {
+		return ManifestResult{Image: spec.Image, Tag: spec.Tag, Error: err}
+	})
+}
+
+// Reference 返回镜像规格的引用字符串 "image:tag"，Tag 为空时只有镜像名称
+func (s ImageSpec) Reference() string {
+	if s.Tag == "" {
+		return s.Image
 	}
+	return s.Image + ":" + s.Tag
+}
+
+// ManifestResults 表示按引用索引的批量获取结果
+type ManifestResults struct {
+	Results     []ManifestResult          // 结果，顺序与请求的镜像规格一致（包括重复的规格）
+	ByReference map[string]ManifestResult // 引用（请求时的 ImageSpec.Reference()，如 "nginx:1.27"）-> 结果
+}
 
-	// 第一步：分组
-	subGroups := c.groupImagesByRegistry(imageSpecs, maxBatchSize)
+// Get 返回镜像规格对应的结果，规格按请求时的原始名称和标签匹配
+func (r *ManifestResults) Get(image, tag string) (ManifestResult, bool) {
+	result, ok := r.ByReference[ImageSpec{Image: image, Tag: tag}.Reference()]
+	return result, ok
+}
 
-	// 第二步：为每个子组获取批量 token
-	if batchAuth {
-		c.acquireBatchTokens(subGroups)
+// GetManifestsByReference 与 GetManifestsWithOptions 相同，但同时返回按引用索引的结果，
+// 调用方对请求去重或重新排序后不需要按位置对应结果
+// 完全相同的镜像规格只获取一次；引用相同但 Platform 或 CredentialKey 不同的规格在 ByReference 中保留第一个的结果，
+// 可通过 Results 按位置获取
+func (c *Client) GetManifestsByReference(ctx context.Context, imageSpecs []ImageSpec, opts BatchOptions) *ManifestResults {
+	uniqueSpecs := make([]ImageSpec, 0, len(imageSpecs))
+	positions := make([]int, len(imageSpecs)) // 请求中的位置 -> uniqueSpecs 中的位置
+	seen := make(map[ImageSpec]int, len(imageSpecs))
+	for i, spec := range imageSpecs {
+		position, ok := seen[spec]
+		if !ok {
+			position = len(uniqueSpecs)
+			seen[spec] = position
+			uniqueSpecs = append(uniqueSpecs, spec)
+		}
+		positions[i] = position
 	}
 
-	// 第三步：获取 manifest
-	results := make([]ManifestResult, len(imageSpecs))
-	if concurrency <= 0 {
-		c.fetchManifestsSequentially(subGroups, results)
-	} else {
-		c.fetchManifestsConcurrently(subGroups, results, concurrency)
+	uniqueResults := c.GetManifestsWithOptions(ctx, uniqueSpecs, opts)
+	results := &ManifestResults{
+		Results:     make([]ManifestResult, len(imageSpecs)),
+		ByReference: make(map[string]ManifestResult, len(uniqueSpecs)),
 	}
+	for i, spec := range imageSpecs {
+		result := uniqueResults[positions[i]]
+		results.Results[i] = result
+		if _, ok := results.ByReference[spec.Reference()]; !ok {
+			results.ByReference[spec.Reference()] = result
+		}
+	}
+	return results
+}
 
+// GetManifestsStream 与 GetManifestsWithDigest 相同，但每个镜像完成后立即通过 channel 发送结果，
+// 不等待整个批量任务结束，适用于数百个镜像时尽早开始处理或显示进度
+// 结果按完成顺序发送（顺序执行时按分组后的顺序），通过 Image 和 Tag 对应请求；所有结果发送后 channel 关闭
+// ctx 取消后不再发起新的请求，未发送的结果被丢弃，channel 随后关闭
+func (c *Client) GetManifestsStream(ctx context.Context, imageSpecs []ImageSpec, opts BatchOptions) <-chan ManifestResult {
+	// Concurrency <= 0 时顺序执行，不需要缓冲
+	results := make(chan ManifestResult, max(opts.Concurrency, 0))
+	go func() {
+		defer close(results)
+		streamBatch(c, ctx, imageSpecs, opts, c.fetchSingleManifest, func(spec ImageSpec, err error) ManifestResult {
+			return ManifestResult{Image: spec.Image, Tag: spec.Tag, Error: err}
+		}, func(_ int, result ManifestResult) {
+			select {
+			case results <- result:
+			case <-ctx.Done():
+			}
+		})
+	}()
 	return results
 }
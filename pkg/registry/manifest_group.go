@@ -1,12 +1,11 @@
 package registry
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"sync"
-
-	"go.uber.org/zap"
+	"time"
 )
 
 // groupToken 表示一组镜像的批量认证 token
@@ -17,20 +16,23 @@ type groupToken struct {
 
 // subGroup 子组结构：包含具体的镜像列表和索引
 type subGroup struct {
-	registryKey string
-	specs       []ImageSpec
-	indices     []int
-	token       groupToken // 该子组的批量 token
+	registryKey   string
+	credentialKey string // 镜像指定的凭据 key，为空时使用 registry 的凭据
+	specs         []ImageSpec
+	indices       []int
+	token         groupToken // 该子组的批量 token
 }
 
 // registryGroup registry 初步分组结构
 type registryGroup struct {
-	registryKey string
-	specs       []ImageSpec
-	indices     []int
+	registryKey   string
+	credentialKey string
+	specs         []ImageSpec
+	indices       []int
 }
 
-// groupImagesByRegistry 按 registry key 和数量限制对镜像进行分组
+// groupImagesByRegistry 按 registry key、镜像指定的凭据和数量限制对镜像进行分组
+// 使用不同凭据的镜像不能共享批量 token，因此分在不同的组
 func (c *Client) groupImagesByRegistry(imageSpecs []ImageSpec, size *int) []*subGroup {
 	maxBatchSize := 30 // 每个批次的最大镜像数量
 	if size != nil {
@@ -45,32 +47,35 @@ func (c *Client) groupImagesByRegistry(imageSpecs []ImageSpec, size *int) []*sub
 
 	for i, spec := range imageSpecs {
 		registryKey := DetectRegistry(spec.Image)
-
-		if primaryGroups[registryKey] == nil {
-			primaryGroups[registryKey] = &registryGroup{
-				registryKey: registryKey,
-				specs:       []ImageSpec{},
-				indices:     []int{},
+		groupKey := registryKey + "|" + spec.CredentialKey
+
+		if primaryGroups[groupKey] == nil {
+			primaryGroups[groupKey] = &registryGroup{
+				registryKey:   registryKey,
+				credentialKey: spec.CredentialKey,
+				specs:         []ImageSpec{},
+				indices:       []int{},
 			}
 		}
-		primaryGroups[registryKey].specs = append(primaryGroups[registryKey].specs, spec)
-		primaryGroups[registryKey].indices = append(primaryGroups[registryKey].indices, i)
+		primaryGroups[groupKey].specs = append(primaryGroups[groupKey].specs, spec)
+		primaryGroups[groupKey].indices = append(primaryGroups[groupKey].indices, i)
 	}
 
 	// 第二步：对每个 registry 组按数量限制进行子分组
 	var subGroups []*subGroup
 
-	for registryKey, group := range primaryGroups {
+	for _, group := range primaryGroups {
+		registryKey := group.registryKey
 		totalImages := len(group.specs)
 
 		if totalImages > maxBatchSize {
 			// 超过限制，分成多个子组
 			numSubGroups := (totalImages + maxBatchSize - 1) / maxBatchSize
 			c.logger.Warn("镜像数量超过单批限制",
-				zap.String("registryKey", registryKey),
-				zap.Int("totalImages", totalImages),
-				zap.Int("maxBatchSize", maxBatchSize),
-				zap.Int("numSubGroups", numSubGroups))
+				"registryKey", registryKey,
+				"totalImages", totalImages,
+				"maxBatchSize", maxBatchSize,
+				"numSubGroups", numSubGroups)
 
 			for i := 0; i < totalImages; i += maxBatchSize {
 				end := i + maxBatchSize
@@ -79,17 +84,19 @@ func (c *Client) groupImagesByRegistry(imageSpecs []ImageSpec, size *int) []*sub
 				}
 
 				subGroups = append(subGroups, &subGroup{
-					registryKey: group.registryKey,
-					specs:       group.specs[i:end],
-					indices:     group.indices[i:end],
+					registryKey:   group.registryKey,
+					credentialKey: group.credentialKey,
+					specs:         group.specs[i:end],
+					indices:       group.indices[i:end],
 				})
 			}
 		} else {
 			// 不超过限制，直接作为一个子组
 			subGroups = append(subGroups, &subGroup{
-				registryKey: group.registryKey,
-				specs:       group.specs,
-				indices:     group.indices,
+				registryKey:   group.registryKey,
+				credentialKey: group.credentialKey,
+				specs:         group.specs,
+				indices:       group.indices,
 			})
 		}
 	}
@@ -104,11 +111,11 @@ func (c *Client) groupImagesByRegistry(imageSpecs []ImageSpec, size *int) []*sub
 func (c *Client) printGroupInfo(primaryGroups map[string]*registryGroup, subGroups []*subGroup) {
 	if len(primaryGroups) > 1 {
 		c.logger.Info("检测到多个 registry",
-			zap.Int("registryCount", len(primaryGroups)),
-			zap.Int("totalBatches", len(subGroups)))
+			"registryCount", len(primaryGroups),
+			"totalBatches", len(subGroups))
 	} else if len(subGroups) > 1 {
 		c.logger.Info("将分批处理",
-			zap.Int("batches", len(subGroups)))
+			"batches", len(subGroups))
 	}
 
 	for i, sg := range subGroups {
@@ -117,54 +124,332 @@ func (c *Client) printGroupInfo(primaryGroups map[string]*registryGroup, subGrou
 			registryName = config.Name
 		}
 		c.logger.Info("处理批次",
-			zap.Int("batchNumber", i+1),
-			zap.String("registry", registryName),
-			zap.Int("imageCount", len(sg.specs)))
+			"batchNumber", i+1,
+			"registry", registryName,
+			"imageCount", len(sg.specs))
 	}
 }
 
 // acquireBatchTokens 为每个子组获取批量认证 token
-func (c *Client) acquireBatchTokens(subGroups []*subGroup) {
+// 子组的认证 URL 超过 registry 的长度限制时拆分为多个子组，分别获取 token，返回拆分后的子组
+// 各子组的凭据从凭据提供者获取一次，在拆分和获取 token 之间共享
+func (c *Client) acquireBatchTokens(ctx context.Context, subGroups []*subGroup) []*subGroup {
+	ctx = withResolvedCredentials(ctx, &resolvedCredentials{})
+	var result []*subGroup
 	for _, sg := range subGroups {
-		if len(sg.specs) <= 1 {
-			continue // 单个镜像不需要批量认证
+		parts := c.splitForAuthURL(ctx, sg)
+		result = append(result, parts...)
+		for _, part := range parts {
+			c.acquireBatchToken(ctx, part)
 		}
+	}
+	return result
+}
+
+// splitForAuthURL 按认证 URL 的长度限制拆分子组，不需要拆分时返回只包含 sg 的切片
+func (c *Client) splitForAuthURL(ctx context.Context, sg *subGroup) []*subGroup {
+	config, ok := GetRegistry(sg.registryKey)
+	if !ok || len(sg.specs) <= 1 {
+		return []*subGroup{sg}
+	}
+
+	images := make([]string, len(sg.specs))
+	for i, spec := range sg.specs {
+		images[i] = spec.Image
+	}
+	chunks := c.splitScopesForAuthURL(ctx, config, imagePullScopes(images, sg.registryKey), sg.tokenCredentialKey())
+	if len(chunks) <= 1 {
+		return []*subGroup{sg}
+	}
+
+	c.logger.Info("认证 URL 超过长度限制，拆分为多个 token 请求",
+		"registry", sg.registryKey,
+		"imageCount", len(sg.specs),
+		"maxAuthURLLength", config.authURLLimit(),
+		"tokenRequests", len(chunks))
+	parts := make([]*subGroup, 0, len(chunks))
+	start := 0
+	for _, chunk := range chunks {
+		end := start + len(chunk)
+		parts = append(parts, &subGroup{
+			registryKey:   sg.registryKey,
+			credentialKey: sg.credentialKey,
+			specs:         sg.specs[start:end],
+			indices:       sg.indices[start:end],
+		})
+		start = end
+	}
+	return parts
+}
+
+// tokenCredentialKey 返回子组获取批量 token 使用的凭据 key，镜像指定了凭据时使用该凭据
+func (sg *subGroup) tokenCredentialKey() string {
+	if sg.credentialKey != "" {
+		return sg.credentialKey
+	}
+	return sg.registryKey
+}
+
+// acquireBatchToken 为子组获取批量认证 token
+func (c *Client) acquireBatchToken(ctx context.Context, sg *subGroup) {
+	if len(sg.specs) <= 1 {
+		return // 单个镜像不需要批量认证
+	}
+	if _, ok := c.staticToken(ctx, sg.tokenCredentialKey()); ok {
+		return // 预先获取的 bearer token 不需要批量认证
+	}
+
+	// 提取该子组的所有镜像名称
+	images := make([]string, len(sg.specs))
+	for i, spec := range sg.specs {
+		images[i] = spec.Image
+	}
+
+	// 获取批量 token
+	token, err := c.getAuthTokenForImages(ctx, images, sg.registryKey, sg.tokenCredentialKey())
+	if err == nil {
+		sg.token = groupToken{
+			registryKey: sg.registryKey,
+			token:       token,
+		}
+		c.logger.Info("已获取批量认证 token",
+			"imageCount", len(sg.specs))
+	} else if errors.Is(err, errNoTokenService) {
+		c.logger.Debug("registry 没有 token 认证服务，不使用批量认证",
+			"registry", sg.registryKey)
+	} else {
+		c.logger.Warn("批量认证失败，将单独认证",
+			"imageCount", len(sg.specs),
+			"error", err)
+	}
+}
 
-		// 提取该子组的所有镜像名称
-		images := make([]string, len(sg.specs))
-		for i, spec := range sg.specs {
-			images[i] = spec.Image
+// BatchOptions 表示批量获取的选项
+type BatchOptions struct {
+	Concurrency  int    // 并发数（0 表示顺序执行，> 0 表示并发执行）
+	BatchAuth    bool   // 是否使用批量认证（推荐，可以减少认证请求）
+	MaxBatchSize int    // 每批最多的镜像数量（0 使用默认值 30，最大 30）
+	Platform     string // GetConfigs 遇到多架构索引时选择的平台（如 linux/arm64），为空时使用 registry 默认平台，未配置时为 linux/amd64
+
+	// MaxFailures 为失败镜像数达到多少时中止整个批量任务：取消进行中的请求，不再获取剩余镜像，
+	// 这些镜像的错误可通过 errors.Is(err, ErrBatchAborted) 判断
+	// 1 表示遇到第一个错误即中止；0 表示不中止，始终获取所有镜像（默认）
+	MaxFailures int
+
+	// RetryFailed 为第一遍获取结束后重试失败镜像的次数，0 表示不重试（默认）
+	// 每次重试只获取仍然失败的镜像，每个镜像单独认证（不使用批量 token），重试的结果替换原来的结果
+	// 被访问策略拒绝、镜像不存在、批量任务中止等重试也不会成功的镜像不重试
+	// 对 GetManifestsWithOptions、GetManifestsByReference、GetConfigs 和 GetDigests 有效，GetManifestsStream 不重试
+	RetryFailed int
+
+	// RetryBackoff 为第一次重试前的等待时间，之后每次重试加倍；0 使用默认值 1 秒
+	RetryBackoff time.Duration
+}
+
+// defaultRetryBackoff BatchOptions.RetryBackoff 的默认值
+const defaultRetryBackoff = time.Second
+
+// ErrBatchAborted 表示批量任务因失败数达到 BatchOptions.MaxFailures 而中止，镜像未获取或获取被取消
+// 可通过 errors.Is(err, ErrBatchAborted) 判断
+var ErrBatchAborted = errors.New("批量任务已中止")
+
+// batchResult 是批量任务的结果类型，用于统计失败数
+type batchResult interface {
+	batchErr() error
+}
+
+// batchFetchFunc 获取单个镜像，ctx 为整个批量任务的 context
+type batchFetchFunc[T any] func(ctx context.Context, spec ImageSpec, token groupToken) T
+
+// runBatch 批量处理镜像，结果顺序与 imageSpecs 一致
+// 流程见 streamBatch
+func runBatch[T batchResult](c *Client, ctx context.Context, imageSpecs []ImageSpec, opts BatchOptions, fetch batchFetchFunc[T], reject func(ImageSpec, error) T) []T {
+	if len(imageSpecs) == 0 {
+		return nil
+	}
+	results := make([]T, len(imageSpecs))
+	streamBatch(c, ctx, imageSpecs, opts, fetch, reject, func(index int, result T) {
+		results[index] = result
+	})
+	if opts.RetryFailed > 0 {
+		retryFailed(c, ctx, imageSpecs, results, opts, fetch, reject)
+	}
+	return results
+}
+
+// retryFailed 重试 results 中失败的镜像，最多 opts.RetryFailed 次，重试的结果写回 results
+// 每次重试前按 opts.RetryBackoff 指数退避，重试时不使用批量 token；批量任务已中止时不重试
+func retryFailed[T batchResult](c *Client, ctx context.Context, imageSpecs []ImageSpec, results []T, opts BatchOptions, fetch batchFetchFunc[T], reject func(ImageSpec, error) T) {
+	for _, result := range results {
+		if errors.Is(result.batchErr(), ErrBatchAborted) {
+			return
 		}
+	}
+
+	retryOpts := opts
+	retryOpts.BatchAuth = false
+	retryOpts.MaxFailures = 0
+	backoff := opts.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
 
-		// 获取批量 token
-		token, err := c.GetAuthTokenForImages(images, sg.registryKey)
-		if err == nil {
-			sg.token = groupToken{
-				registryKey: sg.registryKey,
-				token:       token,
+	for attempt := 1; attempt <= opts.RetryFailed; attempt++ {
+		var retrySpecs []ImageSpec
+		var retryIndices []int
+		for i, result := range results {
+			if retryableBatchErr(result.batchErr()) {
+				retrySpecs = append(retrySpecs, imageSpecs[i])
+				retryIndices = append(retryIndices, i)
 			}
-			c.logger.Info("已获取批量认证 token",
-				zap.Int("imageCount", len(sg.specs)))
-		} else {
-			c.logger.Warn("批量认证失败，将单独认证",
-				zap.Int("imageCount", len(sg.specs)),
-				zap.Error(err))
 		}
+		if len(retrySpecs) == 0 {
+			return
+		}
+
+		c.logger.Info("重试失败的镜像",
+			"attempt", attempt,
+			"imageCount", len(retrySpecs),
+			"backoff", backoff)
+		if sleepContext(ctx, backoff) != nil {
+			return
+		}
+		backoff *= 2
+
+		streamBatch(c, ctx, retrySpecs, retryOpts, fetch, reject, func(index int, result T) {
+			results[retryIndices[index]] = result
+		})
+	}
+}
+
+// retryableBatchErr 判断失败的镜像是否值得重试
+// 访问策略、只读模式、离线模式、镜像不存在、超过大小限制、批量任务中止和调用方取消的错误重试也不会成功
+func retryableBatchErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, permanent := range []error{ErrImageNotAllowed, ErrReadOnly, ErrOffline, ErrNotFound, ErrManifestTooLarge, ErrBatchAborted, context.Canceled} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+// streamBatch 批量处理镜像的通用流程：按访问策略过滤、探测 registry、按 registry 分组、获取批量 token，
+// 然后对每个镜像调用 fetch，每个镜像完成时调用 emit（index 为镜像在 imageSpecs 中的位置）；
+// 被策略拒绝、registry 不可用或 ctx 取消前未开始获取的镜像调用 reject 生成结果
+// 并发获取时 emit 会在多个 goroutine 中调用，所有 emit 返回后 streamBatch 才返回
+// 设置了 opts.MaxFailures 时，失败数达到该值后取消 ctx，剩余镜像以 ErrBatchAborted 拒绝
+func streamBatch[T batchResult](c *Client, ctx context.Context, imageSpecs []ImageSpec, opts BatchOptions, fetch batchFetchFunc[T], reject func(ImageSpec, error) T, emit func(int, T)) {
+	if opts.MaxFailures > 0 {
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+		emit = abortOnFailures(opts.MaxFailures, cancel, emit)
+	}
+
+	// 第零步：按访问策略过滤，被拒绝的镜像不会发出任何请求
+	allowedSpecs := make([]ImageSpec, 0, len(imageSpecs))
+	allowedIndices := make([]int, 0, len(imageSpecs))
+	for i, spec := range imageSpecs {
+		spec.Tag = defaultTag(DetectRegistry(spec.Image), spec.Tag)
+		if err := c.checkImagePolicy(spec.Image); err != nil {
+			emit(i, reject(spec, err))
+			continue
+		}
+		allowedSpecs = append(allowedSpecs, spec)
+		allowedIndices = append(allowedIndices, i)
+	}
+	if len(allowedSpecs) == 0 {
+		return
+	}
+
+	// 探测 registry：无法访问的 registry 的镜像一次性标记为失败，不再逐个等待超时
+	if unavailable := c.probeRegistries(allowedSpecs); len(unavailable) > 0 {
+		reachableSpecs := allowedSpecs[:0:0]
+		reachableIndices := allowedIndices[:0:0]
+		for i, spec := range allowedSpecs {
+			if err, ok := unavailable[DetectRegistry(spec.Image)]; ok {
+				emit(allowedIndices[i], reject(spec, err))
+				c.availability.skip(err.Host, 1)
+				continue
+			}
+			reachableSpecs = append(reachableSpecs, spec)
+			reachableIndices = append(reachableIndices, allowedIndices[i])
+		}
+		allowedSpecs, allowedIndices = reachableSpecs, reachableIndices
+		if len(allowedSpecs) == 0 {
+			return
+		}
+	}
+
+	// 第一步：分组
+	var maxBatchSize *int
+	if opts.MaxBatchSize > 0 {
+		maxBatchSize = &opts.MaxBatchSize
+	}
+	subGroups := c.groupImagesByRegistry(allowedSpecs, maxBatchSize)
+
+	// 第二步：为每个子组获取批量 token（离线模式不需要认证），认证 URL 过长的子组会被拆分
+	if opts.BatchAuth && !c.Offline() {
+		subGroups = c.acquireBatchTokens(ctx, subGroups)
+	}
+	if metrics := c.getMetrics(); metrics != nil {
+		for _, sg := range subGroups {
+			metrics.ObserveBatch(sg.registryKey, len(sg.specs))
+		}
+	}
+
+	// 第三步：逐个获取，子组中的索引是 allowedSpecs 中的位置
+	fetchOne := func(index int, spec ImageSpec, token groupToken) {
+		if ctx.Err() != nil {
+			emit(allowedIndices[index], reject(spec, context.Cause(ctx)))
+			return
+		}
+		result := fetch(ctx, spec, token)
+		// 批量任务中止导致的失败（请求被取消）按中止报告，而不是报告为取消
+		if err := result.batchErr(); err != nil && errors.Is(err, context.Canceled) && errors.Is(context.Cause(ctx), ErrBatchAborted) {
+			result = reject(spec, context.Cause(ctx))
+		}
+		emit(allowedIndices[index], result)
+	}
+	if opts.Concurrency <= 0 {
+		fetchSequentially(subGroups, fetchOne)
+	} else {
+		fetchConcurrently(subGroups, opts.Concurrency, fetchOne)
 	}
 }
 
-// fetchManifestsSequentially 顺序获取所有 manifest
-func (c *Client) fetchManifestsSequentially(subGroups []*subGroup, results []ManifestResult) {
+// abortOnFailures 包装 emit，统计失败的结果，失败数达到 maxFailures 时以 ErrBatchAborted 取消批量任务
+// 中止后被拒绝的镜像（错误为 ErrBatchAborted）不计入失败数
+func abortOnFailures[T batchResult](maxFailures int, cancel context.CancelCauseFunc, emit func(int, T)) func(int, T) {
+	var mu sync.Mutex
+	failures := 0
+	return func(index int, result T) {
+		if err := result.batchErr(); err != nil && !errors.Is(err, ErrBatchAborted) {
+			mu.Lock()
+			failures++
+			if failures == maxFailures {
+				cancel(fmt.Errorf("%w: %d 个镜像获取失败，最近的错误: %v", ErrBatchAborted, failures, err))
+			}
+			mu.Unlock()
+		}
+		emit(index, result)
+	}
+}
+
+// fetchSequentially 顺序获取所有镜像
+func fetchSequentially(subGroups []*subGroup, fetchOne func(int, ImageSpec, groupToken)) {
 	for _, sg := range subGroups {
 		for idx, spec := range sg.specs {
-			originalIndex := sg.indices[idx]
-			results[originalIndex] = c.fetchSingleManifest(spec, sg.token)
+			fetchOne(sg.indices[idx], spec, sg.token)
 		}
 	}
 }
 
-// fetchManifestsConcurrently 并发获取所有 manifest
-func (c *Client) fetchManifestsConcurrently(subGroups []*subGroup, results []ManifestResult, concurrency int) {
+// fetchConcurrently 并发获取所有镜像
+func fetchConcurrently(subGroups []*subGroup, concurrency int, fetchOne func(int, ImageSpec, groupToken)) {
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, concurrency)
 
@@ -180,7 +465,7 @@ func (c *Client) fetchManifestsConcurrently(subGroups []*subGroup, results []Man
 				semaphore <- struct{}{} // 获取信号量
 				defer func() { <-semaphore }()
 
-				results[index] = c.fetchSingleManifest(imgSpec, tok)
+				fetchOne(index, imgSpec, tok)
 			}(originalIndex, spec, token)
 		}
 	}
@@ -188,84 +473,82 @@ func (c *Client) fetchManifestsConcurrently(subGroups []*subGroup, results []Man
 	wg.Wait()
 }
 
-// fetchSingleManifest 获取单个镜像的 manifest
-func (c *Client) fetchSingleManifest(spec ImageSpec, token groupToken) ManifestResult {
-	// 检查是否有批量 token
-	if token.token != "" {
-		// 使用批量 token
-		return c.getManifestWithBatchToken(spec, token.token, token.registryKey)
-	}
-
-	// 单独认证
-	manifest, digest, err := c.GetManifestWithDigest(spec.Image, spec.Tag)
-	return ManifestResult{
-		Image:    spec.Image,
-		Tag:      spec.Tag,
-		Manifest: manifest,
-		Digest:   digest,
-		Error:    err,
+// imageContext 返回批量任务中单个镜像使用的 context，parent 为整个批量任务的 context
+// 设置了单镜像超时时，该镜像的认证和所有请求共享同一个超时
+func (c *Client) imageContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if timeout := c.imageTimeout(); timeout > 0 {
+		return context.WithTimeout(parent, timeout)
 	}
+	return context.WithCancel(parent)
 }
 
-// getManifestWithBatchToken 使用已获取的批量 token 获取 manifest
-func (c *Client) getManifestWithBatchToken(spec ImageSpec, token string, registryKey string) ManifestResult {
-	result := ManifestResult{
-		Image: spec.Image,
-		Tag:   spec.Tag,
+// wrapImageTimeout 单个镜像超时时在错误中注明超时时间
+func (c *Client) wrapImageTimeout(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("获取镜像超时 (%s): %w", c.imageTimeout(), err)
 	}
+	return err
+}
 
-	// 获取 registry 配置
-	config, ok := GetRegistry(registryKey)
-	if !ok {
-		result.Error = fmt.Errorf("未找到 registry 配置: %s", registryKey)
+// fetchSingleManifest 获取单个镜像的 manifest
+func (c *Client) fetchSingleManifest(batchCtx context.Context, spec ImageSpec, token groupToken) ManifestResult {
+	ctx, cancel := c.imageContext(batchCtx)
+	defer cancel()
+
+	result := c.fetchWithHooks(ctx, spec, func() ManifestResult {
+		var result ManifestResult
+		if token.token != "" {
+			// 使用批量 token
+			result = c.getManifestWithBatchToken(ctx, spec, token.token)
+		} else {
+			// 单独认证
+			result = ManifestResult{Image: spec.Image, Tag: spec.Tag}
+			result.Error = c.getManifest(ctx, spec, &result)
+		}
+		result.Error = c.wrapImageTimeout(ctx, result.Error)
 		return result
-	}
-
-	// 规范化镜像名称
-	normalizedImage := NormalizeImageName(spec.Image, registryKey)
+	})
 
-	// 构建 manifest URL
-	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", config.RegistryURL, normalizedImage, spec.Tag)
-
-	// 创建请求
-	req, err := http.NewRequest("GET", manifestURL, nil)
-	if err != nil {
-		result.Error = fmt.Errorf("创建请求失败: %w", err)
-		return result
+	// 落盘模式：立即写入磁盘，避免所有 manifest 同时驻留内存
+	if dir := c.getSpillDir(); dir != "" && result.Error == nil {
+		if err := c.spillManifest(&result, dir); err != nil {
+			result.Manifest = ""
+			result.Error = err
+		}
 	}
+	return result
+}
 
-	// 设置必要的 headers
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/vnd.docker.distribution.manifest.v2+json")
-	req.Header.Add("Accept", "application/vnd.docker.distribution.manifest.list.v2+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.manifest.v1+json")
-	req.Header.Add("Accept", "application/vnd.oci.image.index.v1+json")
+// getManifestWithBatchToken 使用已获取的批量 token 获取 manifest
+func (c *Client) getManifestWithBatchToken(ctx context.Context, spec ImageSpec, token string) ManifestResult {
+	result := ManifestResult{
+		Image: spec.Image,
+		Tag:   spec.Tag,
+	}
 
-	// 发送请求
-	resp, err := c.httpClient.Do(req)
+	// 解析访问目标
+	target, err := c.resolveSpec(spec)
 	if err != nil {
-		result.Error = fmt.Errorf("请求失败: %w", err)
+		result.Error = err
 		return result
 	}
-	defer resp.Body.Close()
-
-	// 检查响应状态
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		result.Error = fmt.Errorf("获取 manifest 失败 (状态码: %d): %s", resp.StatusCode, string(body))
-		return result
+	authorization := "Bearer " + token
+
+	fetched, err := c.fetchManifest(ctx, target, authorization, spec.Tag)
+	if newAuthorization, ok := c.reauthorizeBatch(ctx, target, err); ok {
+		// 批量 token 过期或 scope 不足，使用单个镜像的 token 重试一次
+		authorization = newAuthorization
+		fetched, err = c.fetchManifest(ctx, target, authorization, spec.Tag)
+		if warning := target.anonymousWarning(); warning != "" {
+			result.Warnings = append(result.Warnings, warning)
+		}
 	}
-
-	// 获取 Docker-Content-Digest header
-	result.Digest = resp.Header.Get("Docker-Content-Digest")
-
-	// 读取响应体
-	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		result.Error = fmt.Errorf("读取响应失败: %w", err)
+		result.Error = err
 		return result
 	}
 
-	result.Manifest = string(body)
+	// 按指定平台或 registry 的默认平台策略解析索引
+	result.Error = c.resolveSpecPlatform(ctx, target, authorization, spec, fetched, &result)
 	return result
 }
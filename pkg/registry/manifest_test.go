@@ -0,0 +1,37 @@
+package registry
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGetManifestsStream(t *testing.T) {
+	reg := newTestRegistry(t)
+	image := reg.host() + "/app"
+	want := make(map[string]string)
+	for _, tag := range []string{"v1", "v2", "v3"} {
+		want[tag] = reg.putImage("app", tag, `{"architecture":"amd64","os":"linux"}`).Digest
+	}
+	specs := []ImageSpec{{Image: image, Tag: "v1"}, {Image: image, Tag: "v2"}, {Image: image, Tag: "v3"}, {Image: image, Tag: "missing"}}
+
+	// 负数的并发数与 0 相同，顺序执行
+	for _, concurrency := range []int{-1, 0, 3} {
+		var tags []string
+		for result := range reg.client().GetManifestsStream(context.Background(), specs, BatchOptions{Concurrency: concurrency, BatchAuth: true}) {
+			tags = append(tags, result.Tag)
+			switch {
+			case result.Tag == "missing":
+				if result.Error == nil {
+					t.Errorf("Concurrency=%d: 不存在的标签应返回错误", concurrency)
+				}
+			case result.Error != nil:
+				t.Errorf("Concurrency=%d: %s 错误: %v", concurrency, result.Tag, result.Error)
+			case result.Digest != want[result.Tag]:
+				t.Errorf("Concurrency=%d: %s digest = %s, 期望 %s", concurrency, result.Tag, result.Digest, want[result.Tag])
+			}
+		}
+		if len(tags) != 4 {
+			t.Errorf("Concurrency=%d: 收到 %v, 期望 4 个结果", concurrency, tags)
+		}
+	}
+}
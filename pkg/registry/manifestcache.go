@@ -0,0 +1,68 @@
+package registry
+
+// DefaultManifestCacheSize 内存 manifest 缓存默认的最大条目数
+const DefaultManifestCacheSize = 1000
+
+// CachedManifest 表示缓存的 manifest 响应
+type CachedManifest struct {
+	Body      []byte // manifest 原始内容
+	MediaType string // manifest 媒体类型
+	Digest    string // manifest digest
+	ETag      string // registry 返回的 ETag，下次请求时作为 If-None-Match 发送
+}
+
+// ManifestCache 缓存 manifest 响应，用于条件请求
+// key 由客户端根据 registry、仓库和引用（标签或 digest）生成
+// 实现需要保证并发安全
+type ManifestCache interface {
+	// Get 返回缓存的 manifest，不存在时返回 false
+	Get(key string) (*CachedManifest, bool)
+	// Set 缓存 manifest
+	Set(key string, manifest *CachedManifest)
+}
+
+// WithManifestCache 设置 manifest 缓存
+// 设置后获取 manifest 时会携带上次响应的 ETag（If-None-Match），registry 返回 304 时直接使用缓存的内容，
+// 适用于反复轮询相同镜像的场景；registry 仍会校验每个请求的认证信息
+// 镜像配置 blob 同时按 digest 缓存在其中，命中时不再请求 registry，供离线模式使用
+// 为 nil 时不缓存（默认）
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithManifestCache(cache ManifestCache) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.manifestCache = cache
+	return c
+}
+
+// getManifestCache 返回设置的 manifest 缓存，未设置时返回 nil
+func (c *Client) getManifestCache() ManifestCache {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.manifestCache
+}
+
+// manifestCacheKey 返回 manifest 缓存的 key
+func manifestCacheKey(target *registryTarget, reference string) string {
+	return "manifest|" + target.registryKey + "|" + target.repository + "|" + reference
+}
+
+// MemoryManifestCache 是进程内的 manifest 缓存，超过最大条目数时淘汰最久未使用的条目
+type MemoryManifestCache struct {
+	lru *lruCache[*CachedManifest]
+}
+
+// NewMemoryManifestCache 创建进程内的 manifest 缓存
+// maxEntries 为最大条目数，0 或负数使用默认值 DefaultManifestCacheSize
+func NewMemoryManifestCache(maxEntries int) *MemoryManifestCache {
+	return &MemoryManifestCache{lru: newLRUCache[*CachedManifest](maxEntries)}
+}
+
+// Get 实现 ManifestCache 接口
+func (m *MemoryManifestCache) Get(key string) (*CachedManifest, bool) {
+	return m.lru.get(key)
+}
+
+// Set 实现 ManifestCache 接口
+func (m *MemoryManifestCache) Set(key string, manifest *CachedManifest) {
+	m.lru.set(key, manifest, 0)
+}
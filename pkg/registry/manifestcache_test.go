@@ -0,0 +1,36 @@
+package registry
+
+import "testing"
+
+func TestManifestCacheNotModified(t *testing.T) {
+	reg := newTestRegistry(t)
+	root := reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	image := reg.host() + "/app"
+	client := reg.client().WithManifestCache(NewMemoryManifestCache(0))
+
+	first, _, err := client.GetManifestWithDigest(image, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二次请求携带 ETag，registry 返回 304，使用缓存的内容
+	manifest, digest, err := client.GetManifestWithDigest(image, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest != first || digest != root.Digest {
+		t.Errorf("缓存的 manifest digest = %s, 期望 %s", digest, root.Digest)
+	}
+	reg.mu.Lock()
+	notModified := reg.notModified
+	reg.mu.Unlock()
+	if notModified != 1 {
+		t.Errorf("registry 返回 304 %d 次, 期望 1", notModified)
+	}
+
+	// 标签指向新的 manifest 后 ETag 不匹配，返回新内容
+	updated := reg.putImage("app", "v1", `{"architecture":"arm64","os":"linux"}`)
+	if _, digest, err := client.GetManifestWithDigest(image, "v1"); err != nil || digest != updated.Digest {
+		t.Errorf("更新后的 digest = %s, %v, 期望 %s", digest, err, updated.Digest)
+	}
+}
@@ -0,0 +1,29 @@
+package registry
+
+// Metrics 接收客户端的运行指标，用于接入 Prometheus 等监控系统
+// 并发获取时会被并发调用，实现需要保证并发安全
+// Prometheus 实现见 pkg/metrics
+type Metrics interface {
+	// ObserveRequest 每个 HTTP 请求完成（收到响应或失败）后调用
+	ObserveRequest(event *RequestEvent)
+	// ObserveBatch 批量获取时每个批次调用一次，size 为批次中的镜像数量
+	ObserveBatch(registryKey string, size int)
+	// ObserveTokenCache 查询 token 缓存时调用，hit 表示是否命中
+	ObserveTokenCache(registryKey string, hit bool)
+}
+
+// WithMetrics 设置接收运行指标的 Metrics
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithMetrics(metrics Metrics) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = metrics
+	return c
+}
+
+// getMetrics 返回设置的 Metrics，未设置时返回 nil
+func (c *Client) getMetrics() Metrics {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.metrics
+}
@@ -0,0 +1,153 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Manifest 媒体类型常量
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+
+	// Docker v2 schema 1 已废弃，只在较旧的 registry 或很久以前推送的镜像中出现
+	MediaTypeDockerManifestSchema1       = "application/vnd.docker.distribution.manifest.v1+json"
+	MediaTypeDockerManifestSchema1Signed = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+)
+
+// Descriptor 表示 OCI 内容描述符
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	URLs         []string          `json:"urls,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	Platform     *Platform         `json:"platform,omitempty"`
+}
+
+// ImageManifest 表示单个镜像的 manifest（Docker v2 schema 2 或 OCI image manifest）
+type ImageManifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// ImageIndex 表示多架构镜像的索引（Docker manifest list 或 OCI image index）
+type ImageIndex struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Manifests     []Descriptor      `json:"manifests"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// IsIndexMediaType 判断媒体类型是否为索引（manifest list 或 OCI index）
+func IsIndexMediaType(mediaType string) bool {
+	return mediaType == MediaTypeDockerManifestList || mediaType == MediaTypeOCIIndex
+}
+
+// computeDigest 计算内容的 sha256 digest
+func computeDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// detectManifestMediaType 确定 manifest 的媒体类型
+// 优先使用响应的 Content-Type，无法确定时从内容中推断
+func detectManifestMediaType(contentType string, body []byte) string {
+	contentType, _, _ = strings.Cut(contentType, ";")
+	contentType = strings.TrimSpace(contentType)
+	switch contentType {
+	case MediaTypeDockerManifest, MediaTypeDockerManifestList, MediaTypeOCIManifest, MediaTypeOCIIndex,
+		MediaTypeDockerManifestSchema1, MediaTypeDockerManifestSchema1Signed:
+		return contentType
+	}
+
+	var probe struct {
+		SchemaVersion int               `json:"schemaVersion"`
+		MediaType     string            `json:"mediaType"`
+		Manifests     []json.RawMessage `json:"manifests"`
+		Signatures    []json.RawMessage `json:"signatures"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return contentType
+	}
+	if probe.MediaType != "" {
+		return probe.MediaType
+	}
+	// schema 1 没有 mediaType 字段，不能当作 OCI manifest
+	if probe.SchemaVersion == 1 {
+		if probe.Signatures != nil {
+			return MediaTypeDockerManifestSchema1Signed
+		}
+		return MediaTypeDockerManifestSchema1
+	}
+	// OCI 规范中 mediaType 字段是可选的，通过结构判断
+	if probe.Manifests != nil {
+		return MediaTypeOCIIndex
+	}
+	return MediaTypeOCIManifest
+}
+
+// Platform 表示镜像运行的平台
+type Platform struct {
+	Architecture string   `json:"architecture"`
+	OS           string   `json:"os"`
+	OSVersion    string   `json:"os.version,omitempty"`
+	OSFeatures   []string `json:"os.features,omitempty"`
+	Variant      string   `json:"variant,omitempty"`
+}
+
+// ParsePlatform 解析 "os/arch[/variant]" 格式的平台字符串，如 "linux/arm64/v8"
+func ParsePlatform(s string) (Platform, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Platform{}, fmt.Errorf("无效的平台格式 '%s'，应为 os/arch[/variant]", s)
+	}
+
+	platform := Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		platform.Variant = parts[2]
+	}
+	return platform, nil
+}
+
+// String 返回 "os/arch[/variant]" 格式的平台字符串
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Matches 判断 other 是否满足平台要求
+// 未指定 variant 时匹配任意 variant
+func (p Platform) Matches(other Platform) bool {
+	if p.OS != other.OS || p.Architecture != other.Architecture {
+		return false
+	}
+	return p.Variant == "" || p.Variant == other.Variant
+}
+
+// selectPlatformManifest 从索引中选择与平台匹配的子 manifest
+func selectPlatformManifest(index *ImageIndex, platform Platform) (*Descriptor, error) {
+	for i := range index.Manifests {
+		desc := &index.Manifests[i]
+		if desc.Platform != nil && platform.Matches(*desc.Platform) {
+			return desc, nil
+		}
+	}
+	return nil, fmt.Errorf("索引中没有平台 %s 的镜像", platform)
+}
@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOffline 表示客户端处于离线模式，拒绝了需要访问网络的请求
+// 可通过 errors.Is(err, ErrOffline) 判断；缓存未命中的错误同样与 ErrOffline 匹配
+var ErrOffline = errors.New("客户端处于离线模式")
+
+// ErrCacheMiss 表示离线模式下缓存中没有所需的内容
+// 可通过 errors.Is(err, ErrCacheMiss) 判断，errors.As 可获取 *CacheMissError
+var ErrCacheMiss = errors.New("缓存中不存在")
+
+// CacheMissError 表示离线模式下缓存中没有所需的 manifest 或镜像配置 blob
+type CacheMissError struct {
+	RegistryKey string // registry key，未注册的自定义源为 "custom:<域名>"
+	Repository  string // 仓库（规范化后的名称）
+	Reference   string // 标签或 digest；Config 为 true 时为配置 blob 的 digest
+	Config      bool   // 缓存中没有的是镜像配置 blob
+}
+
+// Error 实现 error 接口
+func (e *CacheMissError) Error() string {
+	if e.Config {
+		return fmt.Sprintf("离线模式下缓存中没有镜像配置 %s@%s (registry: %s)", e.Repository, e.Reference, e.RegistryKey)
+	}
+	return fmt.Sprintf("离线模式下缓存中没有 manifest %s:%s (registry: %s)", e.Repository, e.Reference, e.RegistryKey)
+}
+
+// Is 使 errors.Is 可以判断 ErrCacheMiss 和 ErrOffline
+func (e *CacheMissError) Is(target error) bool {
+	return target == ErrCacheMiss || target == ErrOffline
+}
+
+// WithOffline 设置离线模式
+// 离线模式下客户端不发送任何网络请求（包括认证），manifest、digest 和镜像配置（GetConfigs）查询只使用 manifest 缓存
+// （WithManifestCache 或 WithCache 设置，通常为 FileCache 等落盘缓存）中的内容，
+// 缓存未命中时返回可通过 errors.Is(err, ErrCacheMiss) 判断的 *CacheMissError；
+// 其他需要网络的操作（标签列表、其他 blob、推送等）返回 ErrOffline
+// 用于复现 CI 的结果，或在隔离网络中排查问题
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithOffline(offline bool) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offline = offline
+	return c
+}
+
+// Offline 判断客户端是否处于离线模式
+func (c *Client) Offline() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.offline
+}
+
+// cachedManifest 离线模式下从 manifest 缓存中读取 manifest，未命中时返回 *CacheMissError
+func (c *Client) cachedManifest(target *registryTarget, reference string) (*fetchedManifest, error) {
+	miss := &CacheMissError{RegistryKey: target.registryKey, Repository: target.repository, Reference: reference}
+	cache := c.getManifestCache()
+	if cache == nil {
+		return nil, miss
+	}
+	entry, ok := cache.Get(manifestCacheKey(target, reference))
+	if !ok {
+		return nil, miss
+	}
+	c.logger.Debug("离线模式，使用缓存的 manifest",
+		"repository", target.repository,
+		"reference", reference,
+		"digest", entry.Digest)
+	fetched := &fetchedManifest{body: entry.Body, mediaType: entry.MediaType, digest: entry.Digest}
+	if fetched.digest == "" {
+		fetched.digest = computeDigest(fetched.body)
+	}
+	return fetched, nil
+}
+
+// cachedConfig 离线模式下从 manifest 缓存中读取按 digest 缓存的镜像配置 blob，未命中时返回 *CacheMissError
+func (c *Client) cachedConfig(target *registryTarget, desc Descriptor) ([]byte, error) {
+	if body, ok := c.lookupConfig(desc); ok {
+		c.logger.Debug("离线模式，使用缓存的镜像配置",
+			"repository", target.repository,
+			"digest", desc.Digest)
+		return body, nil
+	}
+	return nil, &CacheMissError{RegistryKey: target.registryKey, Repository: target.repository, Reference: desc.Digest, Config: true}
+}
@@ -0,0 +1,78 @@
+package registry
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOfflineConfigFromCache(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux","config":{"Labels":{"version":"1"}}}`)
+	image := reg.host() + "/app"
+	spec := []ImageSpec{{Image: image, Tag: "v1"}}
+
+	dir := t.TempDir()
+	cache, err := NewFileCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	online := reg.client().WithCache(cache)
+	result := online.GetConfigs(spec, BatchOptions{})[0]
+	if result.Error != nil {
+		t.Fatalf("联网获取配置: %v", result.Error)
+	}
+
+	// 配置 blob 按 digest 缓存，再次获取时只请求 manifest
+	requests := reg.requestCount()
+	if result := online.GetConfigs(spec, BatchOptions{})[0]; result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	for _, request := range reg.requestsSince(requests) {
+		if request == "GET /v2/app/blobs/"+result.ConfigDigest {
+			t.Errorf("缓存的配置 blob 被再次请求")
+		}
+	}
+
+	// 离线模式从同一缓存目录读取 manifest 和配置，不发送任何请求
+	requests = reg.requestCount()
+	cache, _ = NewFileCache(dir)
+	offline := reg.client().WithCache(cache).WithOffline(true)
+	got := offline.GetConfigs(spec, BatchOptions{BatchAuth: true})[0]
+	if got.Error != nil {
+		t.Fatalf("离线获取配置: %v", got.Error)
+	}
+	if got.Config.Config.Labels["version"] != "1" || got.ConfigDigest != result.ConfigDigest {
+		t.Errorf("离线获取的配置 = %+v, 期望与联网时相同", got)
+	}
+	if n := reg.requestCount() - requests; n != 0 {
+		t.Errorf("离线模式发送了 %d 个请求", n)
+	}
+}
+
+func TestOfflineConfigCacheMiss(t *testing.T) {
+	reg := newTestRegistry(t)
+	reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	image := reg.host() + "/app"
+
+	// 只缓存了 manifest，配置 blob 未缓存
+	cache := NewMemoryCache(0)
+	if _, _, err := reg.client().WithCache(cache).GetManifestWithDigest(image, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	offline := reg.client().WithCache(cache).WithOffline(true)
+	err := offline.GetConfigs([]ImageSpec{{Image: image, Tag: "v1"}}, BatchOptions{})[0].Error
+
+	var miss *CacheMissError
+	if !errors.As(err, &miss) || !miss.Config {
+		t.Fatalf("错误 = %v, 期望缓存中没有镜像配置的 *CacheMissError", err)
+	}
+	if !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("errors.Is(err, ErrCacheMiss) = false")
+	}
+
+	// manifest 也未缓存时同样返回 CacheMissError
+	err = offline.GetConfigs([]ImageSpec{{Image: image, Tag: "v2"}}, BatchOptions{})[0].Error
+	if !errors.As(err, &miss) || miss.Config || miss.Reference != "v2" {
+		t.Errorf("错误 = %v, 期望缓存中没有 manifest v2 的 *CacheMissError", err)
+	}
+}
@@ -0,0 +1,119 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PingResult 表示 registry /v2/ 接口的探测结果
+type PingResult struct {
+	RegistryKey string        `json:"registryKey"`          // registry key，未注册的自定义源为 "custom:<域名>"
+	URL         string        `json:"url"`                  // registry 地址
+	Reachable   bool          `json:"reachable"`            // 是否收到了 502/503/504 以外的响应（包括 401）
+	StatusCode  int           `json:"statusCode,omitempty"` // /v2/ 的响应状态码，请求失败时为 0
+	APIVersion  string        `json:"apiVersion,omitempty"` // Docker-Distribution-API-Version header，如 "registry/2.0"
+	AuthScheme  string        `json:"authScheme,omitempty"` // 认证质询的类型（小写），如 "bearer"、"basic"；不需要认证时为空
+	Realm       string        `json:"realm,omitempty"`      // Bearer 质询中的 token 服务地址
+	Service     string        `json:"service,omitempty"`    // Bearer 质询中的 service
+	Latency     time.Duration `json:"latency"`              // 从发出请求到收到响应 header 的耗时
+}
+
+// Ping 访问 registry 的 /v2/ 接口，报告是否可以访问、API 版本、认证质询的类型和延迟
+// 可用于大批量任务开始前的预检，以及验证自定义 registry 的注册是否正确
+// registryKey 可以是 registry key（如 "dockerhub"、"custom:harbor.example.com"）或域名
+// registry 无法访问（连接失败、超时或返回 502/503/504）时同时返回结果和可通过 errors.Is(err, ErrRegistryUnavailable) 判断的错误
+func (c *Client) Ping(registryKey string) (*PingResult, error) {
+	return c.PingContext(context.Background(), registryKey)
+}
+
+// PingContext 与 Ping 相同，但请求受 ctx 控制
+func (c *Client) PingContext(ctx context.Context, registryKey string) (*PingResult, error) {
+	registryKey, registryURL, err := resolveRegistryURL(registryKey)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.ping(ctx, registryURL)
+	result.RegistryKey = registryKey
+	if err != nil {
+		return result, asUnavailable(registryURL, err)
+	}
+	return result, nil
+}
+
+// resolveRegistryURL 返回 registry key 或域名对应的 registry key 和地址
+func resolveRegistryURL(registryKey string) (string, string, error) {
+	if config, ok := GetRegistry(registryKey); ok {
+		return registryKey, config.RegistryURL, nil
+	}
+	domain := strings.TrimPrefix(registryKey, "custom:")
+	domain = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(domain, "https://"), "http://"), "/")
+	if domain == "" || strings.Contains(domain, "/") {
+		return "", "", fmt.Errorf("未找到 registry 配置: %s", registryKey)
+	}
+
+	// 已注册 registry 的域名
+	key := DetectRegistry(domain + "/")
+	if config, ok := GetRegistry(key); ok && extractDomain(config.RegistryURL) == domain {
+		return key, config.RegistryURL, nil
+	}
+	return "custom:" + domain, "https://" + domain, nil
+}
+
+// ping 访问 registry 的 /v2/ 接口
+// 请求失败或响应为 502/503/504 时返回错误，结果中仍包含已获得的信息
+func (c *Client) ping(ctx context.Context, registryURL string) (*PingResult, error) {
+	result := &PingResult{URL: registryURL}
+	req, err := http.NewRequestWithContext(ctx, "GET", registryURL+"/v2/", nil)
+	if err != nil {
+		return result, fmt.Errorf("创建探测请求失败: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := c.do(req)
+	result.Latency = time.Since(start)
+	if err != nil {
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	result.APIVersion = resp.Header.Get("Docker-Distribution-Api-Version")
+	if resp.StatusCode == http.StatusUnauthorized {
+		if challenge, err := parseChallenge(resp.Header.Get("Www-Authenticate")); err == nil {
+			result.AuthScheme = challenge.scheme
+			if challenge.scheme == "bearer" {
+				result.Realm, result.Service = challenge.params["realm"], challenge.params["service"]
+			}
+		}
+	}
+
+	if unavailableStatus(resp.StatusCode) {
+		return result, newResponseError("探测 registry 失败", resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	result.Reachable = true
+	return result, nil
+}
+
+// probeRegistry 访问 registry 的 /v2/ 接口，判断 registry 是否可以访问
+// 收到 502/503/504 以外的任何响应（包括 401）都视为可用
+func (c *Client) probeRegistry(ctx context.Context, registryURL string) *UnavailableError {
+	if _, err := c.ping(ctx, registryURL); err != nil {
+		return asUnavailable(registryURL, err)
+	}
+	return nil
+}
+
+// asUnavailable 将探测 registryURL 的错误转换为 *UnavailableError，已经是 *UnavailableError（如被熔断）时直接返回
+func asUnavailable(registryURL string, err error) *UnavailableError {
+	var unavailableErr *UnavailableError
+	if errors.As(err, &unavailableErr) {
+		return unavailableErr
+	}
+	return &UnavailableError{Host: extractDomain(registryURL), Err: err}
+}
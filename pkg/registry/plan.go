@@ -0,0 +1,402 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CopyPlanOptions 表示生成复制计划的选项
+type CopyPlanOptions struct {
+	Platforms   []string // 只复制指定平台（如 linux/amd64），为空时复制索引中的所有 manifest
+	Destination string   // 目标仓库前缀（如 registry.example.com/mirror），设置后检查目标中已存在的 blob
+	Bandwidth   int64    // 可用带宽（字节/秒），用于估算传输时间，0 表示不估算
+	Concurrency int      // 并发数（0 表示顺序执行）
+}
+
+// CopyPlan 表示批量复制镜像的计划，用于在执行大规模同步前评估传输量和限流风险
+// 可直接序列化为 JSON，或通过 WriteSummary 输出供人工审核的摘要
+type CopyPlan struct {
+	Images           []ImageCopyPlan     `json:"images"`
+	TotalBytes       int64               `json:"totalBytes"`    // 所有镜像 blob 的总大小（去重前）
+	UniqueBytes      int64               `json:"uniqueBytes"`   // 去重后的 blob 总大小
+	TransferBlobs    int                 `json:"transferBlobs"` // 去重并排除目标已存在的 blob 后需要传输的 blob 数量
+	TransferBytes    int64               `json:"transferBytes"` // 需要传输的字节数
+	Bandwidth        int64               `json:"bandwidth,omitempty"`
+	EstimatedSeconds float64             `json:"estimatedSeconds,omitempty"` // 按 Bandwidth 估算的传输时间
+	ManifestRequests map[string]int      `json:"manifestRequests"`           // 源 registry key -> 复制时需要获取的 manifest 数量
+	DockerHub        *DockerHubRateLimit `json:"dockerHubRateLimit,omitempty"`
+	RateLimitRisk    bool                `json:"rateLimitRisk"` // Docker Hub 剩余拉取次数不足以完成复制
+}
+
+// ImageCopyPlan 表示单个镜像的复制计划
+type ImageCopyPlan struct {
+	Image       string        `json:"image"`
+	Tag         string        `json:"tag"`
+	Digest      string        `json:"digest,omitempty"`
+	Destination string        `json:"destination,omitempty"` // 目标镜像（未设置 Destination 时为空）
+	Manifests   int           `json:"manifests"`             // 需要复制的 manifest 数量（包括索引）
+	Blobs       []PlannedBlob `json:"blobs,omitempty"`
+	Bytes       int64         `json:"bytes"` // 该镜像所有 blob 的总大小
+	Error       error         `json:"-"`     // 错误信息（如果获取失败）
+}
+
+// batchErr 实现 batchResult 接口
+func (p ImageCopyPlan) batchErr() error {
+	return p.Error
+}
+
+// PlannedBlob 表示复制计划中的 blob
+type PlannedBlob struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+	Exists    bool   `json:"exists"` // 目标中已存在（可跳过或跨仓库挂载）
+}
+
+// MarshalJSON 实现 json.Marshaler 接口，Error 输出为错误信息
+func (p ImageCopyPlan) MarshalJSON() ([]byte, error) {
+	type plain ImageCopyPlan
+	out := struct {
+		plain
+		Error string `json:"error,omitempty"`
+	}{plain: plain(p)}
+	if p.Error != nil {
+		out.Error = p.Error.Error()
+	}
+	return json.Marshal(out)
+}
+
+// EstimatedDuration 返回估算的传输时间，未设置带宽时为 0
+func (p *CopyPlan) EstimatedDuration() time.Duration {
+	return time.Duration(p.EstimatedSeconds * float64(time.Second))
+}
+
+// PlanCopy 生成批量复制镜像的计划，不会向目标写入任何内容
+// 统计每个镜像需要传输的 blob、去重后的总字节数、按带宽估算的时间，
+// 以及复制时每个源 registry 需要获取的 manifest 数量（Docker Hub 按 manifest 请求计入拉取限额）
+// 源镜像涉及 Docker Hub 时会查询当前的剩余拉取次数
+func (c *Client) PlanCopy(imageSpecs []ImageSpec, opts CopyPlanOptions) (*CopyPlan, error) {
+	platforms := make([]Platform, 0, len(opts.Platforms))
+	for _, value := range opts.Platforms {
+		platform, err := ParsePlatform(value)
+		if err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, platform)
+	}
+	destination := strings.TrimSuffix(opts.Destination, "/")
+
+	planner := &copyPlanner{
+		client:      c,
+		platforms:   platforms,
+		destination: destination,
+		existing:    make(map[string]bool),
+	}
+	batchOpts := BatchOptions{Concurrency: opts.Concurrency, BatchAuth: true}
+	images := runBatch(c, context.Background(), imageSpecs, batchOpts, planner.planImage, func(spec ImageSpec, err error) ImageCopyPlan {
+		return ImageCopyPlan{Image: spec.Image, Tag: spec.Tag, Error: err}
+	})
+
+	plan := &CopyPlan{
+		Images:           images,
+		Bandwidth:        opts.Bandwidth,
+		ManifestRequests: make(map[string]int),
+	}
+	unique := make(map[string]PlannedBlob)
+	for _, image := range images {
+		if image.Error != nil {
+			continue
+		}
+		plan.ManifestRequests[DetectRegistry(image.Image)] += image.Manifests
+		plan.TotalBytes += image.Bytes
+		for _, blob := range image.Blobs {
+			if existing, ok := unique[blob.Digest]; ok && existing.Exists {
+				continue
+			}
+			unique[blob.Digest] = blob
+		}
+	}
+	for _, blob := range unique {
+		plan.UniqueBytes += blob.Size
+		if !blob.Exists {
+			plan.TransferBlobs++
+			plan.TransferBytes += blob.Size
+		}
+	}
+	if opts.Bandwidth > 0 {
+		plan.EstimatedSeconds = float64(plan.TransferBytes) / float64(opts.Bandwidth)
+	}
+
+	// Docker Hub 按 manifest 请求计入拉取限额，检查剩余次数是否足够
+	if requests := plan.ManifestRequests[DockerHubKey]; requests > 0 {
+		rateLimit, err := c.GetDockerHubRateLimit()
+		if err != nil {
+			c.logger.Warn("查询 Docker Hub 拉取限额失败", "error", err)
+		} else {
+			plan.DockerHub = rateLimit
+			plan.RateLimitRisk = rateLimit.Limited() && rateLimit.Remaining < requests
+		}
+	}
+
+	return plan, nil
+}
+
+// copyPlanner 保存一次复制计划的状态
+type copyPlanner struct {
+	client      *Client
+	platforms   []Platform
+	destination string
+
+	mu       sync.Mutex
+	existing map[string]bool // 目标中已确认存在的 blob digest
+}
+
+// planImage 统计单个镜像需要复制的 manifest 和 blob
+func (p *copyPlanner) planImage(batchCtx context.Context, spec ImageSpec, token groupToken) ImageCopyPlan {
+	c := p.client
+	ctx, cancel := c.imageContext(batchCtx)
+	defer cancel()
+
+	plan := ImageCopyPlan{Image: spec.Image, Tag: spec.Tag}
+	repository, err := p.collect(ctx, spec, token.token, &plan)
+	if err != nil {
+		plan.Error = c.wrapImageTimeout(ctx, err)
+		return plan
+	}
+
+	if p.destination != "" {
+		destImage := p.destination + "/" + repository
+		plan.Destination = destImage + ":" + spec.Tag
+		p.checkDestination(ctx, destImage, &plan)
+	}
+	return plan
+}
+
+// collect 遍历镜像（包括多架构索引的子 manifest），收集需要复制的 blob，返回源仓库名称
+func (p *copyPlanner) collect(ctx context.Context, spec ImageSpec, batchToken string, plan *ImageCopyPlan) (string, error) {
+	c := p.client
+	target, err := c.resolveSpec(spec)
+	if err != nil {
+		return "", err
+	}
+
+	var authorization string
+	if batchToken != "" {
+		authorization = "Bearer " + batchToken
+	} else if authorization, err = c.authorize(ctx, target); err != nil {
+		return "", err
+	}
+
+	root, err := c.fetchManifest(ctx, target, authorization, spec.Tag)
+	if newAuthorization, ok := c.reauthorizer(batchToken)(ctx, target, err); ok {
+		authorization = newAuthorization
+		root, err = c.fetchManifest(ctx, target, authorization, spec.Tag)
+	}
+	if err != nil {
+		return "", err
+	}
+	if root.digest == "" {
+		root.digest = computeDigest(root.body)
+	}
+	plan.Digest = root.digest
+
+	seen := make(map[string]bool)
+	visitor := func(desc Descriptor, parents []Descriptor) error {
+		if len(parents) > 0 && !p.platformSelected(desc) {
+			return SkipIndex
+		}
+		plan.Manifests++
+		if IsIndexMediaType(desc.MediaType) {
+			return nil
+		}
+
+		body := root.body
+		if len(parents) > 0 {
+			child, err := c.fetchManifest(ctx, target, authorization, desc.Digest)
+			if err != nil {
+				return err
+			}
+			body = child.body
+		}
+
+		var manifest ImageManifest
+		if err := json.Unmarshal(body, &manifest); err != nil {
+			return fmt.Errorf("解析 manifest %s 失败: %w", desc.Digest, err)
+		}
+		for _, blob := range append([]Descriptor{manifest.Config}, manifest.Layers...) {
+			// 带有 URLs 的外部层（如 Windows 基础层）不会推送到 registry
+			if blob.Digest == "" || len(blob.URLs) > 0 || seen[blob.Digest] {
+				continue
+			}
+			seen[blob.Digest] = true
+			plan.Blobs = append(plan.Blobs, PlannedBlob{Digest: blob.Digest, MediaType: blob.MediaType, Size: blob.Size})
+			plan.Bytes += blob.Size
+		}
+		return nil
+	}
+
+	walker := &indexWalker{
+		ctx:           ctx,
+		client:        c,
+		target:        target,
+		authorization: authorization,
+		visitor:       visitor,
+		limits:        c.getIndexLimits(),
+	}
+	rootDesc := Descriptor{MediaType: root.mediaType, Digest: root.digest, Size: int64(len(root.body))}
+	return target.repository, walker.walk(rootDesc, root, nil)
+}
+
+// platformSelected 判断索引中的子 manifest 是否在要复制的平台范围内
+// 未指定平台时全部复制；指定平台时跳过没有平台信息的子 manifest（如构建证明）
+func (p *copyPlanner) platformSelected(desc Descriptor) bool {
+	if len(p.platforms) == 0 || IsIndexMediaType(desc.MediaType) {
+		return true
+	}
+	if desc.Platform == nil {
+		return false
+	}
+	for _, platform := range p.platforms {
+		if platform.Matches(*desc.Platform) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDestination 检查镜像的 blob 在目标仓库中是否已存在
+// 目标无法访问（如仓库尚未创建）时视为所有 blob 都需要传输
+func (p *copyPlanner) checkDestination(ctx context.Context, destImage string, plan *ImageCopyPlan) {
+	c := p.client
+	target, err := c.resolveTarget(destImage)
+	if err != nil {
+		c.logger.Warn("解析目标仓库失败", "destination", plan.Destination, "error", err)
+		return
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		c.logger.Warn("目标仓库认证失败，假设所有 blob 都需要传输", "destination", plan.Destination, "error", err)
+		return
+	}
+
+	for i := range plan.Blobs {
+		blob := &plan.Blobs[i]
+		if p.knownExisting(blob.Digest) {
+			blob.Exists = true
+			continue
+		}
+		exists, err := c.blobExists(ctx, target, authorization, blob.Digest)
+		if err != nil {
+			c.logger.Warn("检查目标 blob 失败", "destination", plan.Destination, "digest", blob.Digest, "error", err)
+			continue
+		}
+		if exists {
+			blob.Exists = true
+			p.markExisting(blob.Digest)
+		}
+	}
+}
+
+// knownExisting 判断 blob 是否已确认存在于目标 registry
+func (p *copyPlanner) knownExisting(digest string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.existing[digest]
+}
+
+// markExisting 记录目标 registry 中已存在的 blob
+func (p *copyPlanner) markExisting(digest string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.existing[digest] = true
+}
+
+// blobExists 通过 HEAD 请求检查仓库中是否存在 blob
+func (c *Client) blobExists(ctx context.Context, target *registryTarget, authorization, digest string) (bool, error) {
+	resp, err := c.doRegistryRequest(ctx, "HEAD", target, "blobs/"+digest, authorization, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, &ResponseError{Message: "检查 blob 失败", StatusCode: resp.StatusCode}
+	}
+}
+
+// WriteSummary 输出供人工审核的计划摘要
+func (p *CopyPlan) WriteSummary(w io.Writer) error {
+	var b strings.Builder
+
+	failed := 0
+	for _, image := range p.Images {
+		if image.Error != nil {
+			failed++
+			fmt.Fprintf(&b, "✗ %s:%s 失败: %v\n", image.Image, image.Tag, image.Error)
+			continue
+		}
+		transfer := 0
+		for _, blob := range image.Blobs {
+			if !blob.Exists {
+				transfer++
+			}
+		}
+		fmt.Fprintf(&b, "✓ %s:%s", image.Image, image.Tag)
+		if image.Destination != "" {
+			fmt.Fprintf(&b, " → %s", image.Destination)
+		}
+		fmt.Fprintf(&b, "\n  manifest: %d, blob: %d (需传输 %d), 大小: %s\n",
+			image.Manifests, len(image.Blobs), transfer, formatBytes(image.Bytes))
+	}
+
+	fmt.Fprintf(&b, "\n镜像: %d 个 (失败 %d 个)\n", len(p.Images), failed)
+	fmt.Fprintf(&b, "总大小: %s，去重后: %s\n", formatBytes(p.TotalBytes), formatBytes(p.UniqueBytes))
+	fmt.Fprintf(&b, "需要传输: %d 个 blob，%s\n", p.TransferBlobs, formatBytes(p.TransferBytes))
+	if p.Bandwidth > 0 {
+		fmt.Fprintf(&b, "预计耗时: %s (带宽 %s/s)\n", p.EstimatedDuration().Round(time.Second), formatBytes(p.Bandwidth))
+	}
+
+	keys := make([]string, 0, len(p.ManifestRequests))
+	for key := range p.ManifestRequests {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "manifest 请求: %s %d 次\n", key, p.ManifestRequests[key])
+	}
+	if p.DockerHub != nil && p.DockerHub.Limited() {
+		fmt.Fprintf(&b, "Docker Hub 剩余拉取次数: %d/%d\n", p.DockerHub.Remaining, p.DockerHub.Limit)
+	}
+	if p.RateLimitRisk {
+		fmt.Fprintf(&b, "⚠ Docker Hub 剩余拉取次数不足以完成复制\n")
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatBytes 将字节数格式化为易读的形式
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,117 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ErrImageNotAllowed 表示镜像被访问策略拒绝
+var ErrImageNotAllowed = errors.New("镜像被访问策略拒绝")
+
+// ImagePolicy 表示镜像访问策略（允许/拒绝列表）
+// 模式匹配规范化后的完整镜像名 "<registry 域名>/<仓库>"，例如：
+//   - docker.io/library/*   匹配 Docker Hub 官方镜像
+//   - ghcr.io/myorg/**      匹配 myorg 下任意层级的镜像
+//   - *.example.com/**      匹配 example.com 的所有子域名 registry
+//
+// 单个 * 不跨越 "/"，以 /** 结尾的模式匹配该前缀下的所有镜像
+type ImagePolicy struct {
+	Allow []string // 允许的镜像模式，为空表示全部允许
+	Deny  []string // 拒绝的镜像模式，优先级高于 Allow
+}
+
+// Validate 检查策略中的模式是否合法
+func (p *ImagePolicy) Validate() error {
+	for _, pattern := range append(append([]string{}, p.Allow...), p.Deny...) {
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil {
+			return fmt.Errorf("无效的镜像模式 '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Check 检查镜像是否允许访问，不允许时返回包装了 ErrImageNotAllowed 的错误
+func (p *ImagePolicy) Check(image string) error {
+	name := CanonicalImageName(image)
+
+	for _, pattern := range p.Deny {
+		if matchImagePattern(pattern, name) {
+			return fmt.Errorf("%w: %s 匹配拒绝规则 '%s'", ErrImageNotAllowed, name, pattern)
+		}
+	}
+
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range p.Allow {
+		if matchImagePattern(pattern, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s 不在允许列表中", ErrImageNotAllowed, name)
+}
+
+// matchImagePattern 判断镜像名是否匹配模式
+func matchImagePattern(pattern, name string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		// 依次检查镜像名的每一级前缀
+		parts := strings.Split(name, "/")
+		for i := 1; i < len(parts); i++ {
+			if matched, _ := path.Match(prefix, strings.Join(parts[:i], "/")); matched {
+				return true
+			}
+		}
+		return false
+	}
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+// CanonicalImageName 返回镜像的完整名称 "<registry 域名>/<仓库>"
+// 例如 nginx -> docker.io/library/nginx，ghcr.io/owner/repo -> ghcr.io/owner/repo
+func CanonicalImageName(image string) string {
+	registryKey := DetectRegistry(image)
+	return registryHost(registryKey) + "/" + NormalizeImageName(image, registryKey)
+}
+
+// registryHost 返回 registry key 对应的域名
+func registryHost(registryKey string) string {
+	if registryKey == DockerHubKey {
+		return "docker.io"
+	}
+	if domain, ok := strings.CutPrefix(registryKey, "custom:"); ok {
+		return domain
+	}
+	if config, ok := GetRegistry(registryKey); ok {
+		return extractDomain(config.RegistryURL)
+	}
+	return registryKey
+}
+
+// SetImagePolicy 设置镜像访问策略
+// 策略在发出任何上游请求之前检查，传入 nil 表示取消限制
+func (c *Client) SetImagePolicy(policy *ImagePolicy) error {
+	if policy != nil {
+		if err := policy.Validate(); err != nil {
+			return err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policy = policy
+	return nil
+}
+
+// checkImagePolicy 使用客户端的访问策略检查镜像
+func (c *Client) checkImagePolicy(image string) error {
+	c.mu.RLock()
+	policy := c.policy
+	c.mu.RUnlock()
+
+	if policy == nil {
+		return nil
+	}
+	return policy.Check(image)
+}
@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PrefetchTokens 预先获取 registry 上镜像的 pull token 并写入 token 缓存
+// 延迟敏感的服务可以在启动时预热认证，之后首次获取这些镜像时不再请求认证服务
+// registryKey 可以是 registry key（如 "dockerhub"）或未注册的自定义源（"custom:<域名>"），
+// images 为该 registry 上的镜像名称，可以带域名前缀（如 "nginx"、"ghcr.io/owner/repo"）
+// 所有镜像通过一次批量 token 请求获取（认证 URL 超过长度限制时拆分为多次），token 按镜像分别缓存
+// 未设置 token 缓存时自动使用 NewMemoryTokenCache；token 过期后按正常流程重新获取
+// registry 不需要认证、只支持 Basic 认证或使用预先获取的 bearer token 时没有需要预热的 token，返回 nil
+func (c *Client) PrefetchTokens(registryKey string, images []string) error {
+	return c.PrefetchTokensContext(context.Background(), registryKey, images)
+}
+
+// PrefetchTokensContext 与 PrefetchTokens 相同，但请求受 ctx 控制
+func (c *Client) PrefetchTokensContext(ctx context.Context, registryKey string, images []string) error {
+	if len(images) == 0 || c.Offline() {
+		return nil
+	}
+	ctx = withResolvedCredentials(ctx, &resolvedCredentials{})
+
+	// 凭据 key 与获取 manifest 时一致：未注册的自定义源按域名查找凭据
+	config, registered := GetRegistry(registryKey)
+	credentialKey, registryURL := registryKey, ""
+	if registered {
+		registryURL = config.RegistryURL
+	} else {
+		domain, custom := strings.CutPrefix(registryKey, "custom:")
+		if !custom {
+			return fmt.Errorf("未找到 registry 配置: %s", registryKey)
+		}
+		config, credentialKey, registryURL = nil, domain, "https://"+domain
+	}
+
+	for _, image := range images {
+		if err := c.checkImagePolicy(image); err != nil {
+			return err
+		}
+	}
+	if _, ok := c.staticToken(ctx, credentialKey); ok {
+		return nil // 预先获取的 bearer token 不需要预热
+	}
+	c.ensureTokenCache()
+
+	scopes := imagePullScopes(images, registryKey)
+	chunks := [][]string{scopes}
+	if config != nil {
+		chunks = c.splitScopesForAuthURL(ctx, config, scopes, credentialKey)
+	}
+	for _, chunk := range chunks {
+		info, err := c.getTokenInfoWithScopes(ctx, chunk, registryKey, credentialKey)
+		if errors.Is(err, errNoTokenService) {
+			c.logger.Debug("registry 没有 token 认证服务，不需要预热 token", "registry", registryKey)
+			return nil
+		}
+		if err != nil {
+			return newAuthError("预热 token 失败", registryKey, err)
+		}
+
+		// 批量 token 可以访问其中每个镜像，按单个镜像的 scope 分别缓存
+		for _, scope := range chunk {
+			key, err := c.tokenCacheKey(ctx, config, registryURL, credentialKey, []string{scope})
+			if err != nil {
+				return newAuthError("预热 token 失败", registryKey, err)
+			}
+			c.storeToken(key, info)
+		}
+	}
+
+	c.logger.Info("已预热认证 token",
+		"registry", registryKey,
+		"imageCount", len(images),
+		"tokenRequests", len(chunks))
+	return nil
+}
+
+// tokenCacheKey 返回获取 scopes 对应 token 时使用的缓存 key，与 getTokenInfoWithScopes 一致
+// config 为 nil 表示未注册的自定义源
+func (c *Client) tokenCacheKey(ctx context.Context, config *RegistryConfig, registryURL, credentialKey string, scopes []string) (string, error) {
+	if config != nil && config.AuthURL != "" {
+		return c.registryTokenKey(ctx, config.Key, credentialKey, scopes), nil
+	}
+	challenge, err := c.registryChallenge(ctx, registryURL)
+	if err != nil {
+		return "", err
+	}
+	realm, service, _, err := ParseWWWAuthenticate(challenge)
+	if err != nil {
+		return "", fmt.Errorf("解析 WWW-Authenticate 失败: %w", err)
+	}
+	return c.challengeTokenKey(ctx, realm, service, credentialKey, scopes), nil
+}
+
+// ensureTokenCache 未设置 token 缓存时使用进程内缓存
+func (c *Client) ensureTokenCache() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokenCache == nil {
+		c.tokenCache = NewMemoryTokenCache()
+	}
+}
@@ -0,0 +1,293 @@
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 证明的发现方式
+const (
+	AttestationSourceBuildKit  = "buildkit"  // BuildKit 写入镜像索引的证明 manifest
+	AttestationSourceReferrers = "referrers" // OCI referrers API 或标签方案
+	AttestationSourceCosign    = "cosign"    // cosign attest 推送的 "sha256-<hex>.att" 标签
+)
+
+// SLSAProvenancePrefix SLSA provenance 的 predicateType 前缀，如 https://slsa.dev/provenance/v1
+const SLSAProvenancePrefix = "https://slsa.dev/provenance/"
+
+// MaxAttestationSize 单个证明 blob 的最大大小（64 MiB）
+const MaxAttestationSize = 64 << 20
+
+// ErrNoProvenance 表示镜像没有附加 provenance 证明，可通过 errors.Is 判断
+var ErrNoProvenance = errors.New("镜像没有附加 provenance 证明")
+
+// BuildKit 证明 manifest 使用的注解
+const (
+	buildkitReferenceType   = "vnd.docker.reference.type"
+	buildkitReferenceDigest = "vnd.docker.reference.digest"
+	buildkitAttestationType = "attestation-manifest"
+	inTotoPredicateType     = "in-toto.io/predicate-type"
+	cosignPredicateType     = "predicateType"
+)
+
+// InTotoStatement 表示 in-toto 证明的 statement
+type InTotoStatement struct {
+	Type          string          `json:"_type"`
+	Subject       []InTotoSubject `json:"subject"`
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"` // 原始 predicate，如 SLSA provenance 的构建参数和材料
+}
+
+// InTotoSubject 表示 statement 证明的对象
+type InTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"` // 算法 -> 十六进制摘要，如 {"sha256": "..."}
+}
+
+// Attestation 表示附加到镜像的证明
+type Attestation struct {
+	Statement InTotoStatement // 解码后的 statement
+	Source    string          // 发现方式：AttestationSourceBuildKit、AttestationSourceReferrers 或 AttestationSourceCosign
+	Subject   string          // 证明所附加的 manifest digest（BuildKit 证明为对应平台的 manifest digest）
+	Platform  *Platform       // BuildKit 证明对应的平台，其他来源为 nil
+	Manifest  Descriptor      // 保存证明的 manifest 的描述符
+	Envelope  bool            // 证明是否包装在 DSSE 信封中（签名不会被验证）
+	Content   []byte          // 证明 blob 的原始内容
+}
+
+// GetProvenance 获取附加到镜像的 in-toto/SLSA provenance 证明，返回解码后的 statement
+// 查找以下位置，返回找到的全部证明：
+//   - 多架构镜像索引中 BuildKit 写入的证明 manifest（docker buildx build --provenance）
+//   - referrers API 或 OCI 规范标签方案中的 in-toto 证明（包括 DSSE 信封和 Sigstore bundle）
+//   - cosign attest 推送的 "sha256-<hex>.att" 标签
+//
+// 只返回 predicateType 以 SLSAProvenancePrefix 开头的证明；DSSE 信封的签名不会被验证
+// tag 可以是标签或 digest；没有 provenance 证明时返回可通过 errors.Is(err, ErrNoProvenance) 判断的错误
+func (c *Client) GetProvenance(image, tag string) ([]Attestation, error) {
+	return c.GetProvenanceContext(context.Background(), image, tag)
+}
+
+// GetProvenanceContext 与 GetProvenance 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) GetProvenanceContext(ctx context.Context, image, tag string) ([]Attestation, error) {
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	root, err := c.fetchManifest(ctx, target, authorization, defaultTag(target.registryKey, tag))
+	if err != nil {
+		return nil, err
+	}
+	subject := computeDigest(root.body)
+
+	finder := &attestationFinder{
+		client:        c,
+		target:        target,
+		authorization: authorization,
+		match:         isProvenance,
+	}
+	if IsIndexMediaType(root.mediaType) {
+		if err := finder.findBuildKit(ctx, root.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := finder.findReferrers(ctx, subject); err != nil {
+		return nil, err
+	}
+	if err := finder.findCosign(ctx, subject); err != nil {
+		return nil, err
+	}
+
+	if len(finder.found) == 0 {
+		return nil, fmt.Errorf("%w: %s@%s", ErrNoProvenance, image, subject)
+	}
+	return finder.found, nil
+}
+
+// isProvenance 判断 predicateType 是否为 SLSA provenance
+func isProvenance(predicateType string) bool {
+	return strings.HasPrefix(predicateType, SLSAProvenancePrefix)
+}
+
+// attestationFinder 保存一次证明查找的状态
+type attestationFinder struct {
+	client        *Client
+	target        *registryTarget
+	authorization string
+	match         func(predicateType string) bool // 需要的 predicateType
+	found         []Attestation
+}
+
+// findBuildKit 查找镜像索引中 BuildKit 写入的证明 manifest
+// 证明 manifest 的平台为 unknown/unknown，通过注解引用对应平台的 manifest
+func (f *attestationFinder) findBuildKit(ctx context.Context, indexBody []byte) error {
+	var index ImageIndex
+	if err := json.Unmarshal(indexBody, &index); err != nil {
+		return fmt.Errorf("解析镜像索引失败: %w", err)
+	}
+	platforms := make(map[string]*Platform)
+	for _, desc := range index.Manifests {
+		platforms[desc.Digest] = desc.Platform
+	}
+
+	for _, desc := range index.Manifests {
+		if desc.Annotations[buildkitReferenceType] != buildkitAttestationType {
+			continue
+		}
+		subject := desc.Annotations[buildkitReferenceDigest]
+		attestations, err := f.fetch(ctx, desc.Digest, inTotoPredicateType)
+		if err != nil {
+			return err
+		}
+		for _, attestation := range attestations {
+			attestation.Source, attestation.Subject, attestation.Platform = AttestationSourceBuildKit, subject, platforms[subject]
+			f.found = append(f.found, attestation)
+		}
+	}
+	return nil
+}
+
+// findReferrers 查找 referrers 中的 in-toto 证明
+func (f *attestationFinder) findReferrers(ctx context.Context, subject string) error {
+	referrers, err := f.client.listReferrers(ctx, f.target, f.authorization, subject)
+	if err != nil {
+		return err
+	}
+	for _, referrer := range referrers {
+		if !isAttestationType(referrer.ArtifactType) {
+			continue
+		}
+		attestations, err := f.fetch(ctx, referrer.Digest, "")
+		if err != nil {
+			return err
+		}
+		for _, attestation := range attestations {
+			attestation.Source, attestation.Subject = AttestationSourceReferrers, subject
+			f.found = append(f.found, attestation)
+		}
+	}
+	return nil
+}
+
+// findCosign 查找 cosign attest 推送的 "sha256-<hex>.att" 标签
+func (f *attestationFinder) findCosign(ctx context.Context, subject string) error {
+	attestations, err := f.fetch(ctx, referrersTag(subject)+".att", cosignPredicateType)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, attestation := range attestations {
+		attestation.Source, attestation.Subject = AttestationSourceCosign, subject
+		f.found = append(f.found, attestation)
+	}
+	return nil
+}
+
+// fetch 获取证明 manifest，下载并解码其中 predicateType 符合条件的证明
+// annotation 为层描述符上记录 predicateType 的注解，注解表明不符合条件时不下载该层
+func (f *attestationFinder) fetch(ctx context.Context, reference, annotation string) ([]Attestation, error) {
+	c := f.client
+	fetched, err := c.fetchManifest(ctx, f.target, f.authorization, reference)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ImageManifest
+	if err := json.Unmarshal(fetched.body, &manifest); err != nil {
+		return nil, fmt.Errorf("解析证明 manifest %s 失败: %w", reference, err)
+	}
+	manifestDesc := Descriptor{
+		MediaType:    fetched.mediaType,
+		Digest:       computeDigest(fetched.body),
+		Size:         int64(len(fetched.body)),
+		ArtifactType: manifest.ArtifactType,
+	}
+
+	var attestations []Attestation
+	for _, layer := range manifest.Layers {
+		if predicateType := layer.Annotations[annotation]; annotation != "" && predicateType != "" && !f.match(predicateType) {
+			continue
+		}
+		if !isAttestationType(layer.MediaType) && !isAttestationType(manifest.ArtifactType) {
+			continue
+		}
+
+		content, err := c.fetchBlob(ctx, f.target, f.authorization, layer, MaxAttestationSize)
+		if err != nil {
+			return nil, err
+		}
+		statement, envelope, err := decodeAttestation(content)
+		if err != nil {
+			c.logger.Warn("解码证明失败，已跳过", "repository", f.target.repository, "digest", layer.Digest, "error", err)
+			continue
+		}
+		if !f.match(statement.PredicateType) {
+			continue
+		}
+		attestations = append(attestations, Attestation{
+			Statement: *statement,
+			Manifest:  manifestDesc,
+			Envelope:  envelope,
+			Content:   content,
+		})
+	}
+	return attestations, nil
+}
+
+// isAttestationType 判断媒体类型或 artifactType 是否可能包含 in-toto 证明
+// 如 application/vnd.in-toto+json、application/vnd.dsse.envelope.v1+json、application/vnd.dev.sigstore.bundle.v0.3+json
+func isAttestationType(mediaType string) bool {
+	return strings.Contains(mediaType, "in-toto") || strings.Contains(mediaType, "dsse") || strings.Contains(mediaType, "sigstore.bundle")
+}
+
+// dsseEnvelope 表示 DSSE 信封
+type dsseEnvelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"` // base64 编码的 statement
+}
+
+// decodeAttestation 解码证明 blob，支持 in-toto statement、DSSE 信封和 Sigstore bundle
+// 返回 statement 以及是否来自 DSSE 信封
+func decodeAttestation(content []byte) (*InTotoStatement, bool, error) {
+	var doc struct {
+		InTotoStatement
+		dsseEnvelope
+		DSSEEnvelope *dsseEnvelope `json:"dsseEnvelope"` // Sigstore bundle
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, false, fmt.Errorf("解析证明失败: %w", err)
+	}
+	if doc.PredicateType != "" {
+		return &doc.InTotoStatement, false, nil
+	}
+
+	envelope := &doc.dsseEnvelope
+	if doc.DSSEEnvelope != nil {
+		envelope = doc.DSSEEnvelope
+	}
+	if envelope.Payload == "" {
+		return nil, false, fmt.Errorf("证明既不是 in-toto statement 也不是 DSSE 信封")
+	}
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		if payload, err = base64.URLEncoding.DecodeString(envelope.Payload); err != nil {
+			return nil, true, fmt.Errorf("解码 DSSE payload 失败: %w", err)
+		}
+	}
+	var statement InTotoStatement
+	if err := json.Unmarshal(payload, &statement); err != nil {
+		return nil, true, fmt.Errorf("解析 DSSE payload 失败: %w", err)
+	}
+	return &statement, true, nil
+}
@@ -0,0 +1,168 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AnnotationTitle 层描述符上记录文件名的注解（ORAS 推送文件时设置）
+const AnnotationTitle = "org.opencontainers.image.title"
+
+// Artifact 表示 OCI artifact 的 manifest
+type Artifact struct {
+	Image        string            // 镜像名称
+	Digest       string            // manifest digest
+	MediaType    string            // manifest 媒体类型
+	ArtifactType string            // artifact 类型；manifest 没有 artifactType 时为 config 的媒体类型（OCI 1.0 的约定）
+	Config       Descriptor        // config 描述符
+	Layers       []Descriptor      // 层（文件）描述符
+	Subject      *Descriptor       // 所附加的 manifest（可能为 nil）
+	Annotations  map[string]string // manifest 的注解
+}
+
+// PulledFile 表示下载到本地的 artifact 层
+type PulledFile struct {
+	Path       string     // 本地文件路径
+	Descriptor Descriptor // 层描述符
+}
+
+// GetArtifact 获取 OCI artifact 的 manifest（如 Helm chart、WASM 模块、策略包）
+// tag 可以是标签或 digest；artifact 为索引时返回错误，需要指定具体 manifest 的 digest
+func (c *Client) GetArtifact(image, tag string) (*Artifact, error) {
+	return c.GetArtifactContext(context.Background(), image, tag)
+}
+
+// GetArtifactContext 与 GetArtifact 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) GetArtifactContext(ctx context.Context, image, tag string) (*Artifact, error) {
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	return c.fetchArtifact(ctx, target, authorization, defaultTag(target.registryKey, tag))
+}
+
+// PullArtifact 获取 OCI artifact 并将其所有层下载到目录 dir，类似 oras pull
+// 文件名取自层的 org.opencontainers.image.title 注解，没有注解时使用 digest（如 sha256-<hex>）
+// 注解中的文件名不能是绝对路径或包含 ".."，否则返回错误；dir 不存在时自动创建，同名文件会被覆盖
+// 下载的内容按描述符校验大小和 digest，校验失败时不会留下文件；连接中断时与 GetBlob 一样续传
+func (c *Client) PullArtifact(image, tag, dir string) (*Artifact, []PulledFile, error) {
+	return c.PullArtifactContext(context.Background(), image, tag, dir)
+}
+
+// PullArtifactContext 与 PullArtifact 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) PullArtifactContext(ctx context.Context, image, tag, dir string) (*Artifact, []PulledFile, error) {
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, nil, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, nil, err
+	}
+	artifact, err := c.fetchArtifact(ctx, target, authorization, defaultTag(target.registryKey, tag))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 先确定所有文件名，避免下载到一半才发现注解无效
+	paths := make([]string, len(artifact.Layers))
+	for i, layer := range artifact.Layers {
+		name, err := artifactFileName(layer)
+		if err != nil {
+			return artifact, nil, err
+		}
+		paths[i] = filepath.Join(dir, name)
+	}
+
+	files := make([]PulledFile, 0, len(artifact.Layers))
+	for i, layer := range artifact.Layers {
+		if err := c.downloadBlob(ctx, target, authorization, layer, paths[i]); err != nil {
+			return artifact, files, err
+		}
+		files = append(files, PulledFile{Path: paths[i], Descriptor: layer})
+	}
+	return artifact, files, nil
+}
+
+// fetchArtifact 获取并解析 artifact 的 manifest
+func (c *Client) fetchArtifact(ctx context.Context, target *registryTarget, authorization, reference string) (*Artifact, error) {
+	fetched, err := c.fetchManifest(ctx, target, authorization, reference)
+	if err != nil {
+		return nil, err
+	}
+	if IsIndexMediaType(fetched.mediaType) {
+		return nil, fmt.Errorf("%s:%s 是索引 (%s)，请指定其中 manifest 的 digest", target.image, reference, fetched.mediaType)
+	}
+
+	var manifest ImageManifest
+	if err := json.Unmarshal(fetched.body, &manifest); err != nil {
+		return nil, fmt.Errorf("解析 artifact manifest 失败: %w", err)
+	}
+	artifact := &Artifact{
+		Image:        target.image,
+		Digest:       computeDigest(fetched.body),
+		MediaType:    fetched.mediaType,
+		ArtifactType: manifest.ArtifactType,
+		Config:       manifest.Config,
+		Layers:       manifest.Layers,
+		Subject:      manifest.Subject,
+		Annotations:  manifest.Annotations,
+	}
+	if artifact.ArtifactType == "" {
+		artifact.ArtifactType = manifest.Config.MediaType
+	}
+	return artifact, nil
+}
+
+// artifactFileName 返回层下载后的文件名
+func artifactFileName(layer Descriptor) (string, error) {
+	name := layer.Annotations[AnnotationTitle]
+	if name == "" {
+		return referrersTag(layer.Digest), nil
+	}
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("层 %s 的文件名 '%s' 无效：不能是绝对路径或包含 '..'", layer.Digest, name)
+	}
+	return name, nil
+}
+
+// downloadBlob 将 blob 流式下载到文件，校验大小和 digest 后原子替换目标文件
+func (c *Client) downloadBlob(ctx context.Context, target *registryTarget, authorization string, desc Descriptor, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	n, err := c.streamBlob(ctx, target, authorization, desc.Digest, tmp)
+	if err != nil {
+		return err
+	}
+	if n != desc.Size {
+		return fmt.Errorf("blob %s 大小不匹配: 期望 %d 字节，实际 %d 字节", desc.Digest, desc.Size, n)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,474 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BlobUpload 表示一次 blob 上传
+type BlobUpload struct {
+	RegistryKey string    // 目标 registry key，未注册的自定义源为 "custom:<域名>"
+	Repository  string    // 目标仓库（规范化后的名称）
+	Digest      string    // blob digest
+	Size        int64     // blob 大小
+	MediaType   string    // blob 媒体类型，可能为空
+	Content     io.Reader // blob 内容
+}
+
+// BlobBackend 是 blob 上传的扩展点，用于绕过 registry 直接写入其存储后端
+// 适用于使用对象存储（S3、GCS 等）且支持预签名上传的 registry，同一云内的镜像同步可以不经过 registry 中转
+// 上传完成后客户端会通过 HEAD 请求确认 registry 能够看到该 blob
+type BlobBackend interface {
+	// UploadBlob 将 blob 写入存储后端
+	// 返回 ErrBlobBackendSkipped 时改为通过 registry 的标准接口上传
+	UploadBlob(ctx context.Context, upload *BlobUpload) error
+}
+
+// ErrBlobBackendSkipped 由 BlobBackend 返回，表示该 blob 不由存储后端处理（如不支持的仓库），改为通过 registry 上传
+var ErrBlobBackendSkipped = errors.New("存储后端不处理该 blob")
+
+// WithBlobBackend 为 registry 设置 blob 上传的存储后端
+// registryKey 可以是 registry key（如 "dockerhub"、"custom:harbor.example.com"）或域名
+// backend 为 nil 时移除设置
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithBlobBackend(registryKey string, backend BlobBackend) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if backend == nil {
+		delete(c.blobBackends, registryKey)
+		return c
+	}
+	if c.blobBackends == nil {
+		c.blobBackends = make(map[string]BlobBackend)
+	}
+	c.blobBackends[registryKey] = backend
+	return c
+}
+
+// blobBackend 返回目标 registry 的存储后端，未设置时返回 nil
+// 先按 registry key 查找，再按域名查找
+func (c *Client) blobBackend(target *registryTarget) BlobBackend {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if backend, ok := c.blobBackends[target.registryKey]; ok {
+		return backend
+	}
+	return c.blobBackends[extractDomain(target.registryURL)]
+}
+
+// PresignFunc 为 blob 上传生成预签名的 PUT 地址及需要附加的 header（可以为 nil）
+// 返回 ErrBlobBackendSkipped 表示该 blob 改为通过 registry 上传
+type PresignFunc func(ctx context.Context, upload *BlobUpload) (uploadURL string, header http.Header, err error)
+
+// presignedBlobBackend 通过预签名地址上传 blob
+type presignedBlobBackend struct {
+	presign    PresignFunc
+	httpClient *http.Client
+}
+
+// NewPresignedBlobBackend 创建通过预签名地址上传 blob 的存储后端
+// presign 负责生成地址（如 S3 的 PresignPutObject 或 GCS 的 SignedURL），对象路径由调用方按 registry 的存储布局决定
+// httpClient 为 nil 时使用 http.DefaultClient
+func NewPresignedBlobBackend(presign PresignFunc, httpClient *http.Client) BlobBackend {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &presignedBlobBackend{presign: presign, httpClient: httpClient}
+}
+
+// UploadBlob 实现 BlobBackend 接口
+func (b *presignedBlobBackend) UploadBlob(ctx context.Context, upload *BlobUpload) error {
+	uploadURL, header, err := b.presign(ctx, upload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", uploadURL, upload.Content)
+	if err != nil {
+		return fmt.Errorf("创建上传请求失败: %w", err)
+	}
+	req.ContentLength = upload.Size
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("上传到存储后端失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newResponseError("上传到存储后端失败", resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// PushBlob 将 blob 上传到镜像所在的仓库，返回 blob 的描述符
+// 仓库中已存在相同 digest 的 blob 时不会重复上传
+func (c *Client) PushBlob(image string, content []byte, mediaType string) (Descriptor, error) {
+	return c.PushBlobContext(context.Background(), image, content, mediaType)
+}
+
+// PushBlobContext 与 PushBlob 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) PushBlobContext(ctx context.Context, image string, content []byte, mediaType string) (Descriptor, error) {
+	desc := Descriptor{MediaType: mediaType, Digest: computeDigest(content), Size: int64(len(content))}
+
+	if err := c.checkImagePolicy(image); err != nil {
+		return desc, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return desc, err
+	}
+	authorization, err := c.authorizePush(ctx, target)
+	if err != nil {
+		return desc, err
+	}
+	return desc, c.pushBlob(ctx, target, authorization, desc, content)
+}
+
+// PutManifest 将 manifest 推送到镜像所在的仓库，返回推送的 manifest 的描述符
+// tag 为目标标签，为空时按 manifest 的 digest 推送（如索引引用的子 manifest）；也可以是与 body 一致的 digest
+// mediaType 为空时按 body 的内容识别；body 原样发送，digest 按原始字节计算
+// manifest 引用的 blob 和子 manifest 需要已存在于仓库中，否则 registry 会拒绝
+// 认证时申请 pull,push 权限；只读模式下返回 ErrReadOnly
+func (c *Client) PutManifest(image, tag, mediaType string, body []byte) (Descriptor, error) {
+	return c.PutManifestContext(context.Background(), image, tag, mediaType, body)
+}
+
+// PutManifestContext 与 PutManifest 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) PutManifestContext(ctx context.Context, image, tag, mediaType string, body []byte) (Descriptor, error) {
+	desc := Descriptor{MediaType: mediaType, Digest: computeDigest(body), Size: int64(len(body))}
+	if desc.MediaType == "" {
+		desc.MediaType = detectManifestMediaType("", body)
+	}
+	switch {
+	case desc.MediaType == "":
+		return desc, fmt.Errorf("无法识别 manifest 的媒体类型，请指定 mediaType")
+	case len(body) > MaxManifestSize:
+		return desc, fmt.Errorf("%w (%d > %d 字节)", ErrManifestTooLarge, len(body), MaxManifestSize)
+	case !json.Valid(body):
+		return desc, fmt.Errorf("manifest 不是有效的 JSON")
+	}
+	reference := tag
+	if reference == "" {
+		reference = desc.Digest
+	} else if strings.Contains(reference, ":") && reference != desc.Digest {
+		return desc, fmt.Errorf("digest '%s' 与 manifest 内容不符 (%s)", reference, desc.Digest)
+	}
+
+	if err := c.checkImagePolicy(image); err != nil {
+		return desc, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return desc, err
+	}
+	authorization, err := c.authorizePush(ctx, target)
+	if err != nil {
+		return desc, err
+	}
+	header, err := c.putManifest(ctx, target, authorization, reference, desc.MediaType, body)
+	if err != nil {
+		return desc, err
+	}
+	if digest := header.Get("Docker-Content-Digest"); digest != "" && digest != desc.Digest {
+		c.logger.Warn("registry 返回的 manifest digest 与推送的内容不符",
+			"repository", target.repository,
+			"reference", reference,
+			"expected", desc.Digest,
+			"actual", digest)
+	}
+	return desc, nil
+}
+
+// blobOpener 打开 blob 内容，上传时可能被调用多次（如存储后端跳过后改为通过 registry 上传）
+// 返回的 Reader 实现了 io.Closer 时由调用方关闭
+type blobOpener func() (io.Reader, error)
+
+// bytesOpener 返回读取 content 的 blobOpener
+// 使用 *bytes.Reader 以便请求被限流后可以重新发送
+func bytesOpener(content []byte) blobOpener {
+	return func() (io.Reader, error) {
+		return bytes.NewReader(content), nil
+	}
+}
+
+// closeBlob 关闭 blobOpener 打开的内容
+func closeBlob(content io.Reader) {
+	if closer, ok := content.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// Tag 为镜像已有的 manifest 添加新标签，返回该 manifest 的描述符
+// existingDigestOrTag 为已有的 digest 或标签；按原始字节获取 manifest 后以 newTag 重新推送，不传输任何 blob，
+// 适用于将已验证的镜像提升为 prod 等标签的发布流程
+// 认证时申请 pull,push 权限；只读模式下返回 ErrReadOnly
+func (c *Client) Tag(image, existingDigestOrTag, newTag string) (Descriptor, error) {
+	return c.TagContext(context.Background(), image, existingDigestOrTag, newTag)
+}
+
+// TagContext 与 Tag 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) TagContext(ctx context.Context, image, existingDigestOrTag, newTag string) (Descriptor, error) {
+	var desc Descriptor
+	if !validTag(newTag) {
+		return desc, fmt.Errorf("无效的标签: '%s'", newTag)
+	}
+	if err := c.checkImagePolicy(image); err != nil {
+		return desc, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return desc, err
+	}
+	if err := c.checkReadOnly(http.MethodPut, target, "manifests/"+newTag); err != nil {
+		return desc, err
+	}
+	authorization, err := c.authorizePush(ctx, target)
+	if err != nil {
+		return desc, err
+	}
+
+	existing, err := c.fetchManifest(ctx, target, authorization, existingDigestOrTag)
+	if err != nil {
+		return desc, err
+	}
+	desc = Descriptor{MediaType: existing.mediaType, Digest: computeDigest(existing.body), Size: int64(len(existing.body))}
+	if strings.Contains(existingDigestOrTag, ":") && existingDigestOrTag != desc.Digest {
+		return desc, fmt.Errorf("registry 返回的 manifest 与 digest '%s' 不符 (%s)", existingDigestOrTag, desc.Digest)
+	}
+
+	if _, err := c.putManifest(ctx, target, authorization, newTag, desc.MediaType, existing.body); err != nil {
+		return desc, err
+	}
+	c.logger.Debug("已添加标签", "repository", target.repository, "tag", newTag, "digest", desc.Digest)
+	return desc, nil
+}
+
+// validTag 判断是否为有效的标签：以字母、数字或下划线开头，由字母、数字、'.'、'_'、'-' 组成，最长 128 个字符
+func validTag(tag string) bool {
+	if tag == "" || len(tag) > 128 || tag[0] == '.' || tag[0] == '-' {
+		return false
+	}
+	for _, r := range tag {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// pushBlob 上传 blob：已存在时跳过，否则调用 storeBlob 上传
+func (c *Client) pushBlob(ctx context.Context, target *registryTarget, authorization string, desc Descriptor, content []byte) error {
+	if err := c.checkReadOnly(http.MethodPut, target, "blobs/"+desc.Digest); err != nil {
+		return err
+	}
+
+	exists, err := c.blobExists(ctx, target, authorization, desc.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		c.logger.Debug("blob 已存在，跳过上传", "repository", target.repository, "digest", desc.Digest)
+		return nil
+	}
+	return c.storeBlob(ctx, target, authorization, desc, bytesOpener(content))
+}
+
+// storeBlob 上传目标仓库中不存在的 blob：设置了存储后端时直接写入后端，否则使用 registry 的上传接口
+func (c *Client) storeBlob(ctx context.Context, target *registryTarget, authorization string, desc Descriptor, open blobOpener) error {
+	if backend := c.blobBackend(target); backend != nil {
+		content, err := open()
+		if err != nil {
+			return err
+		}
+		err = backend.UploadBlob(ctx, &BlobUpload{
+			RegistryKey: target.registryKey,
+			Repository:  target.repository,
+			Digest:      desc.Digest,
+			Size:        desc.Size,
+			MediaType:   desc.MediaType,
+			Content:     content,
+		})
+		closeBlob(content)
+		switch {
+		case err == nil:
+			return c.confirmBackendBlob(ctx, target, authorization, desc.Digest)
+		case !errors.Is(err, ErrBlobBackendSkipped):
+			return fmt.Errorf("通过存储后端上传 blob %s 失败: %w", desc.Digest, err)
+		}
+	}
+
+	return c.uploadBlob(ctx, target, authorization, desc, open)
+}
+
+// confirmBackendBlob 确认通过存储后端上传的 blob 已能在 registry 中看到
+func (c *Client) confirmBackendBlob(ctx context.Context, target *registryTarget, authorization, digest string) error {
+	exists, err := c.blobExists(ctx, target, authorization, digest)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("blob %s 已写入存储后端，但 registry 中不存在，请检查存储路径", digest)
+	}
+	c.logger.Debug("blob 已通过存储后端上传", "repository", target.repository, "digest", digest)
+	return nil
+}
+
+// uploadBlob 通过 registry 的上传接口上传 blob：POST 创建上传会话，再 PUT 完整内容
+func (c *Client) uploadBlob(ctx context.Context, target *registryTarget, authorization string, desc Descriptor, open blobOpener) error {
+	resp, err := c.doRegistryRequest(ctx, http.MethodPost, target, "blobs/uploads/", authorization, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return newResponseError("创建 blob 上传会话失败", resp)
+	}
+
+	location, err := uploadLocation(resp)
+	if err != nil {
+		return err
+	}
+	return c.completeUpload(ctx, target, authorization, location, desc, open)
+}
+
+// mountBlob 通过跨仓库挂载（POST blobs/uploads/?mount=<digest>&from=<repository>）将同一 registry 中 fromRepository 的 blob 复制到目标仓库，不传输内容
+// registry 完成挂载时返回 true；registry 不支持挂载或无法访问源仓库时会改为创建上传会话，此时返回 false 和上传地址
+func (c *Client) mountBlob(ctx context.Context, target *registryTarget, authorization, digest, fromRepository string) (bool, *url.URL, error) {
+	query := url.Values{"mount": {digest}, "from": {fromRepository}}
+	resp, err := c.doRegistryRequest(ctx, http.MethodPost, target, "blobs/uploads/?"+query.Encode(), authorization, nil)
+	if err != nil {
+		return false, nil, err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		return true, nil, nil
+	case http.StatusAccepted:
+		location, err := uploadLocation(resp)
+		return false, location, err
+	default:
+		return false, nil, newResponseError("挂载 blob 失败", resp)
+	}
+}
+
+// uploadLocation 返回 registry 创建的上传会话地址
+func uploadLocation(resp *http.Response) (*url.URL, error) {
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return nil, fmt.Errorf("registry 未返回有效的上传地址: %q", resp.Header.Get("Location"))
+	}
+	return location, nil
+}
+
+// completeUpload 向上传会话地址 PUT blob 的完整内容并完成上传
+func (c *Client) completeUpload(ctx context.Context, target *registryTarget, authorization string, location *url.URL, desc Descriptor, open blobOpener) error {
+	query := location.Query()
+	query.Set("digest", desc.Digest)
+	location.RawQuery = query.Encode()
+
+	content, err := open()
+	if err != nil {
+		return err
+	}
+	defer closeBlob(content)
+	return c.putUpload(ctx, target, authorization, location, content, desc.Size)
+}
+
+// putUpload 向上传会话地址 PUT blob 内容
+// 上传地址可能不在仓库路径下，因此不经过 doRegistryRequest，只读检查由调用方完成
+// 上传地址可能指向其他主机（如 S3 或 CDN），此时不发送 registry 的认证信息，与 net/http 跨主机重定向时的处理相同
+func (c *Client) putUpload(ctx context.Context, target *registryTarget, authorization string, location *url.URL, content io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(withTransfer(ctx), http.MethodPut, location.String(), content)
+	if err != nil {
+		return fmt.Errorf("创建上传请求失败: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if authorization != "" && sameHost(location, target.registryURL) {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("上传 blob 失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return newResponseError("上传 blob 失败", resp)
+	}
+	return nil
+}
+
+// sameHost 判断 location 与 registryURL 是否为同一主机（忽略大小写和协议的默认端口）
+func sameHost(location *url.URL, registryURL string) bool {
+	registry, err := url.Parse(registryURL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(canonicalHost(location), canonicalHost(registry))
+}
+
+// canonicalHost 返回 URL 的主机，去掉协议的默认端口
+func canonicalHost(u *url.URL) string {
+	host, port := u.Hostname(), u.Port()
+	if port == "" || (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// pushScope 返回仓库 pull 和 push 权限的 scope
+func pushScope(repository string) string {
+	return fmt.Sprintf("repository:%s:pull,push", repository)
+}
+
+// authorizePush 获取向目标仓库推送（pull 和 push 权限）使用的 Authorization header
+func (c *Client) authorizePush(ctx context.Context, target *registryTarget) (string, error) {
+	return c.authorizePushFrom(ctx, target, "")
+}
+
+// authorizePushFrom 与 authorizePush 相同，fromRepository 不为空时同时申请同一 registry 中该仓库的 pull 权限，用于跨仓库挂载 blob
+func (c *Client) authorizePushFrom(ctx context.Context, target *registryTarget, fromRepository string) (string, error) {
+	ctx = target.context(ctx)
+	scopes := []string{pushScope(target.repository)}
+	if fromRepository != "" {
+		scopes = append(scopes, pullScope(fromRepository))
+	}
+
+	if target.challengeAuth {
+		// 未注册的自定义源和未配置 AuthURL 的 registry：按 /v2/ 的质询获取带 push 权限的 token
+		authorization, err := c.getAuthorizationViaWWWAuthenticate(ctx, target.registryKey, target.registryURL, scopes, target.credentialKey)
+		if err != nil {
+			return "", newAuthError("获取推送 token 失败", target.registryKey, err)
+		}
+		return authorization, nil
+	}
+
+	info, err := c.getTokenInfoWithScopes(ctx, scopes, target.registryKey, target.credentialKey)
+	if err == nil {
+		return "Bearer " + info.Token, nil
+	}
+
+	// token 服务不可用时，检查 registry 是否只支持 Basic 认证
+	challenge, probeErr := c.registryChallenge(ctx, target.registryURL)
+	basicAuth, hasCred := c.basicAuthHeader(ctx, target.credentialKey)
+	if probeErr != nil || !isBasicChallenge(challenge) || !hasCred {
+		return "", newAuthError("获取推送 token 失败", target.registryKey, err)
+	}
+	return basicAuth, nil
+}
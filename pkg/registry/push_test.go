@@ -0,0 +1,114 @@
+package registry
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// uploadRecorder 记录 blob 上传（PUT）请求收到的 Authorization header
+type uploadRecorder struct {
+	mu             sync.Mutex
+	authorizations []string
+}
+
+// handle 读取上传的内容，等待 delay 后完成上传
+func (u *uploadRecorder) handle(w http.ResponseWriter, req *http.Request, delay time.Duration) {
+	io.Copy(io.Discard, req.Body)
+	u.mu.Lock()
+	u.authorizations = append(u.authorizations, req.Header.Get("Authorization"))
+	u.mu.Unlock()
+	time.Sleep(delay)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// last 返回最后一次上传请求的 Authorization header
+func (u *uploadRecorder) last() string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.authorizations) == 0 {
+		return "<未上传>"
+	}
+	return u.authorizations[len(u.authorizations)-1]
+}
+
+func TestPushBlobUploadLocation(t *testing.T) {
+	var uploads uploadRecorder
+	storage := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		uploads.handle(w, req, 0)
+	}))
+	defer storage.Close()
+
+	reg := newTestRegistry(t)
+	var uploadURL string
+	var delay time.Duration
+	reg.handler = func(w http.ResponseWriter, req *http.Request) {
+		if _, _, ok := req.BasicAuth(); !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/blobs/uploads/"):
+			w.Header().Set("Location", uploadURL)
+			w.WriteHeader(http.StatusAccepted)
+		case req.Method == http.MethodPut:
+			uploads.handle(w, req, delay)
+		case req.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+	client := reg.client()
+	client.AddCredential(reg.host(), "robot", "secret")
+	image := reg.host() + "/app"
+
+	// 上传地址在 registry 上时发送认证信息
+	uploadURL = "/v2/app/blobs/uploads/1"
+	if _, err := client.PushBlob(image, []byte("same-host"), MediaTypeOCILayer); err != nil {
+		t.Fatal(err)
+	}
+	if got := uploads.last(); !strings.HasPrefix(got, "Basic ") {
+		t.Errorf("registry 上的上传 Authorization = %q, 期望 Basic 认证", got)
+	}
+
+	// 上传地址指向其他主机（如 S3）时不发送 registry 的凭据
+	uploadURL = storage.URL + "/bucket/upload?signature=x"
+	if _, err := client.PushBlob(image, []byte("other-host"), MediaTypeOCILayer); err != nil {
+		t.Fatal(err)
+	}
+	if got := uploads.last(); got != "" {
+		t.Errorf("其他主机上的上传 Authorization = %q, 期望不发送", got)
+	}
+
+	// 上传超过 WithTimeout 设置的总超时时仍然成功，只受 ctx 控制
+	uploadURL = "/v2/app/blobs/uploads/2"
+	delay = 300 * time.Millisecond
+	if _, err := client.WithTimeout(100*time.Millisecond).PushBlob(image, []byte("slow"), MediaTypeOCILayer); err != nil {
+		t.Errorf("较慢的上传失败: %v", err)
+	}
+}
+
+func TestSameHost(t *testing.T) {
+	tests := []struct {
+		location    string
+		registryURL string
+		want        bool
+	}{
+		{"https://ghcr.io/v2/app/blobs/uploads/1", "https://ghcr.io", true},
+		{"https://GHCR.io:443/v2/upload", "https://ghcr.io", true},
+		{"https://registry.test:5000/v2/upload", "https://registry.test:5000", true},
+		{"https://registry.test:5001/v2/upload", "https://registry.test:5000", false},
+		{"https://bucket.s3.amazonaws.com/upload", "https://ghcr.io", false},
+		{"https://evil.ghcr.io/upload", "https://ghcr.io", false},
+	}
+	for _, tt := range tests {
+		location, _ := http.NewRequest(http.MethodPut, tt.location, nil)
+		if got := sameHost(location.URL, tt.registryURL); got != tt.want {
+			t.Errorf("sameHost(%s, %s) = %v, 期望 %v", tt.location, tt.registryURL, got, tt.want)
+		}
+	}
+}
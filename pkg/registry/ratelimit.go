@@ -0,0 +1,168 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMaxRateLimitWait 被限流时默认最多等待的时间
+const DefaultMaxRateLimitWait = time.Minute
+
+// ErrRateLimited 表示请求被 registry 限流（429），且超过了最大等待时间或 registry 未提供 Retry-After
+// 可通过 errors.Is(err, ErrRateLimited) 判断
+var ErrRateLimited = errors.New("请求被 registry 限流")
+
+// WithMaxRateLimitWait 设置被限流（429）时单个请求最多等待的总时间
+// registry 返回 Retry-After 且等待时间不超过该值时，暂停对该域名的所有请求并在等待后重试；
+// 否则返回可通过 errors.Is(err, ErrRateLimited) 判断的错误
+// 0 使用默认值（1 分钟），负数表示不等待
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithMaxRateLimitWait(maxWait time.Duration) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxRateLimitWait = maxWait
+	return c
+}
+
+// rateLimitMaxWait 返回被限流时最多等待的时间
+func (c *Client) rateLimitMaxWait() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	switch {
+	case c.maxRateLimitWait == 0:
+		return DefaultMaxRateLimitWait
+	case c.maxRateLimitWait < 0:
+		return 0
+	default:
+		return c.maxRateLimitWait
+	}
+}
+
+// rateLimitPause 返回域名剩余的限流暂停时间
+func (c *Client) rateLimitPause(host string) time.Duration {
+	c.rateMu.Lock()
+	defer c.rateMu.Unlock()
+	until, ok := c.rateLimitedUntil[host]
+	if !ok {
+		return 0
+	}
+	wait := time.Until(until)
+	if wait <= 0 {
+		delete(c.rateLimitedUntil, host)
+		return 0
+	}
+	return wait
+}
+
+// pauseHost 暂停对域名的请求直到 wait 之后
+func (c *Client) pauseHost(host string, wait time.Duration) {
+	c.rateMu.Lock()
+	defer c.rateMu.Unlock()
+	if c.rateLimitedUntil == nil {
+		c.rateLimitedUntil = make(map[string]time.Time)
+	}
+	until := time.Now().Add(wait)
+	if until.After(c.rateLimitedUntil[host]) {
+		c.rateLimitedUntil[host] = until
+	}
+}
+
+// do 发送 HTTP 请求，处理 registry 的限流
+// 域名处于限流暂停期时先等待；响应为 429 且带有 Retry-After 时，暂停该域名的所有请求并在等待后重试
+// 超过最大等待时间时原样返回 429 响应，由调用方构造错误
+// 域名处于熔断期时直接返回 UnavailableError
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	maxWait := c.rateLimitMaxWait()
+	host := req.URL.Host
+	var waited time.Duration
+
+	if c.Offline() {
+		return nil, fmt.Errorf("%w: 拒绝 %s %s", ErrOffline, req.Method, req.URL.Redacted())
+	}
+	if err := c.availability.allow(host); err != nil {
+		return nil, err
+	}
+
+	for {
+		// 同一域名的其他请求触发了限流，等待暂停结束
+		if pause := c.rateLimitPause(host); pause > 0 && waited+pause <= maxWait {
+			if err := sleepContext(req.Context(), pause); err != nil {
+				return nil, err
+			}
+			waited += pause
+		}
+
+		resp, err := c.send(req)
+		c.availability.record(req, resp, err)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if retryAfter <= 0 {
+			return resp, nil
+		}
+		c.pauseHost(host, retryAfter)
+
+		if waited+retryAfter > maxWait || (req.Body != nil && req.GetBody == nil) {
+			c.logger.Warn("请求被 registry 限流，超过最大等待时间",
+				"host", host,
+				"retryAfter", retryAfter,
+				"maxWait", maxWait)
+			return resp, nil
+		}
+
+		c.logger.Warn("请求被 registry 限流，等待后重试",
+			"host", host,
+			"retryAfter", retryAfter)
+		resp.Body.Close()
+
+		retryReq := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			retryReq.Body = body
+		}
+		req = retryReq
+	}
+}
+
+// parseRetryAfter 解析 Retry-After header，支持秒数和 HTTP 日期两种格式
+// 无法解析时返回 0
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if wait := t.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// sleepContext 等待指定时间，ctx 被取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,37 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrReadOnly 表示客户端处于只读模式，拒绝了会修改 registry 的操作
+// 可通过 errors.Is(err, ErrReadOnly) 判断
+var ErrReadOnly = errors.New("客户端处于只读模式")
+
+// WithReadOnly 设置只读模式
+// 只读模式下客户端只会向 registry API 发送 GET 和 HEAD 请求，推送、删除、打标签等修改操作
+// 一律返回 ErrReadOnly，不受调用方参数影响；认证服务的请求不受限制
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithReadOnly(readOnly bool) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readOnly = readOnly
+	return c
+}
+
+// ReadOnly 判断客户端是否处于只读模式
+func (c *Client) ReadOnly() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.readOnly
+}
+
+// checkReadOnly 只读模式下拒绝 GET 和 HEAD 以外的 registry 请求
+func (c *Client) checkReadOnly(method string, target *registryTarget, path string) error {
+	if method == http.MethodGet || method == http.MethodHead || !c.ReadOnly() {
+		return nil
+	}
+	return fmt.Errorf("%w: 拒绝 %s %s/%s", ErrReadOnly, method, target.repository, path)
+}
@@ -0,0 +1,117 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RedactMode 表示报告中仓库名称的脱敏方式
+type RedactMode string
+
+// 脱敏方式
+const (
+	RedactNone RedactMode = "none" // 不脱敏
+	RedactHash RedactMode = "hash" // 替换为仓库名称的哈希，同一仓库在不同报告中保持一致，便于对照
+	RedactFull RedactMode = "full" // 替换为固定的 "redacted"
+)
+
+// redactedRepository RedactFull 使用的仓库名称
+const redactedRepository = "redacted"
+
+// ParseRedactMode 解析脱敏方式，支持 none（或空字符串）、hash 和 full
+func ParseRedactMode(value string) (RedactMode, error) {
+	switch mode := RedactMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "", RedactNone:
+		return RedactNone, nil
+	case RedactHash, RedactFull:
+		return mode, nil
+	}
+	return "", fmt.Errorf("不支持的脱敏方式 '%s'，应为 none、hash 或 full", value)
+}
+
+// Redactor 将报告中的仓库名称替换为哈希或固定值，registry 域名、标签和 digest 保持不变
+// 用于把包含内部仓库名称的结果分享给团队以外的人
+// nil 或 RedactNone 的 Redactor 不做任何替换
+type Redactor struct {
+	mode RedactMode
+	salt string
+}
+
+// NewRedactor 创建脱敏器
+// salt 参与 RedactHash 的哈希计算，防止通过常见仓库名称反查；为空时直接对仓库名称计算哈希
+func NewRedactor(mode RedactMode, salt string) *Redactor {
+	return &Redactor{mode: mode, salt: salt}
+}
+
+// enabled 判断是否需要脱敏
+func (r *Redactor) enabled() bool {
+	return r != nil && r.mode != RedactNone && r.mode != ""
+}
+
+// Repository 返回仓库名称（不包含 registry 域名）脱敏后的结果
+// RedactHash 返回 "repo-" 加 16 位哈希，如 team/app -> repo-3f2a9c1b0d4e5f67
+func (r *Redactor) Repository(repository string) string {
+	if !r.enabled() || repository == "" {
+		return repository
+	}
+	if r.mode == RedactFull {
+		return redactedRepository
+	}
+	sum := sha256.Sum256([]byte(r.salt + "\x00" + repository))
+	return "repo-" + hex.EncodeToString(sum[:8])
+}
+
+// Image 返回镜像名称脱敏后的结果 "<registry 域名>/<脱敏后的仓库>"，保留 registry 域名
+// 例如 harbor.example.com/team/app -> harbor.example.com/repo-3f2a9c1b0d4e5f67
+func (r *Redactor) Image(image string) string {
+	if !r.enabled() || image == "" {
+		return image
+	}
+	registryKey := DetectRegistry(image)
+	return registryHost(registryKey) + "/" + r.Repository(NormalizeImageName(image, registryKey))
+}
+
+// Text 将文本（如错误信息）中出现的镜像名称和仓库名称替换为脱敏后的结果
+// images 为文本可能涉及的镜像，原始名称、完整名称和仓库名称都会被替换
+func (r *Redactor) Text(text string, images ...string) string {
+	if !r.enabled() || text == "" || len(images) == 0 {
+		return text
+	}
+
+	replacements := make(map[string]string)
+	for _, image := range images {
+		if image == "" {
+			continue
+		}
+		registryKey := DetectRegistry(image)
+		repository := NormalizeImageName(image, registryKey)
+		redacted := r.Image(image)
+		replacements[CanonicalImageName(image)] = redacted
+		replacements[image] = redacted
+		replacements[repository] = r.Repository(repository)
+		// Docker Hub 官方镜像在错误信息中可能以不带 library/ 的形式出现
+		if short, ok := strings.CutPrefix(repository, "library/"); ok {
+			replacements[short] = r.Repository(repository)
+		}
+	}
+
+	// 较长的名称优先匹配，避免只替换完整名称的一部分
+	olds := make([]string, 0, len(replacements))
+	for old := range replacements {
+		olds = append(olds, old)
+	}
+	sort.Slice(olds, func(i, j int) bool {
+		if len(olds[i]) != len(olds[j]) {
+			return len(olds[i]) > len(olds[j])
+		}
+		return olds[i] < olds[j]
+	})
+	pairs := make([]string, 0, 2*len(olds))
+	for _, old := range olds {
+		pairs = append(pairs, old, replacements[old])
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
@@ -1,18 +1,33 @@
 package registry
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 	"sync"
 )
 
 // RegistryConfig 存储 registry 的配置信息
 type RegistryConfig struct {
-	Key         string // registry 的唯一标识符
-	Name        string // registry 的显示名称
-	RegistryURL string // registry API 地址
-	AuthURL     string // 认证服务地址
-	Service     string // 服务名称
+	Key         string `json:"key"`         // registry 的唯一标识符
+	Name        string `json:"name"`        // registry 的显示名称
+	RegistryURL string `json:"registryURL"` // registry API 地址
+	AuthURL     string `json:"authURL"`     // 认证服务地址
+	Service     string `json:"service"`     // 服务名称
+
+	// 认证服务参数：用于要求非默认参数的自建 token 服务
+	Audience    string            `json:"audience,omitempty"`    // 以 audience 参数发送给认证服务
+	TokenParams map[string]string `json:"tokenParams,omitempty"` // 额外的查询参数，如 account、client_id
+	TokenMethod string            `json:"tokenMethod,omitempty"` // 请求 token 的方式：GET（默认）或 POST（OAuth2 表单，没有 URL 长度限制）
+
+	// MaxAuthURLLength 以 GET 方式请求 token 时认证 URL 的最大长度，0 使用默认值 2048
+	// 批量认证的 scope 超过该长度时会自动拆分为多个 token 请求
+	MaxAuthURLLength int `json:"maxAuthURLLength,omitempty"`
+
+	// 默认策略：镜像规格未指定对应字段时使用
+	DefaultTag      string `json:"defaultTag,omitempty"`      // 默认标签，为空时使用 latest
+	DefaultPlatform string `json:"defaultPlatform,omitempty"` // 默认平台（如 linux/arm64），设置后索引会被解析为该平台的 manifest
 }
 
 // Registry key 常量
@@ -21,6 +36,12 @@ const (
 	GHCRKey      = "ghcr"
 )
 
+// Token 请求方式常量，用于 RegistryConfig.TokenMethod
+const (
+	TokenMethodGET  = "GET"  // 以查询参数请求 token，凭据以 Basic 认证发送
+	TokenMethodPOST = "POST" // 以 OAuth2 表单（grant_type、service、scope、client_id）请求 token
+)
+
 var (
 	// registries 存储所有已注册的 registry（包括内置和自定义）
 	registries = map[string]*RegistryConfig{
@@ -49,6 +70,10 @@ func DetectRegistry(image string) string {
 	if strings.HasPrefix(image, "ghcr.io/") {
 		return GHCRKey
 	}
+	// 带有 Docker Hub 域名的完整引用，如 docker.io/library/nginx
+	if _, ok := trimDockerHubDomain(image); ok {
+		return DockerHubKey
+	}
 
 	// 检查是否匹配其他自定义 registry
 	registryMu.RLock()
@@ -83,13 +108,27 @@ func DetectRegistry(image string) string {
 	return DockerHubKey
 }
 
+// dockerHubDomains 为镜像引用中表示 Docker Hub 的域名
+var dockerHubDomains = []string{"docker.io", "index.docker.io", "registry-1.docker.io"}
+
+// trimDockerHubDomain 去掉镜像名中的 Docker Hub 域名前缀，返回去掉后的名称和是否带有该前缀
+func trimDockerHubDomain(image string) (string, bool) {
+	for _, domain := range dockerHubDomains {
+		if name, ok := strings.CutPrefix(image, domain+"/"); ok {
+			return name, true
+		}
+	}
+	return image, false
+}
+
 // NormalizeImageName 规范化镜像名称
-// 对于 Docker Hub，如果没有 / 则添加 library/ 前缀
+// 对于 Docker Hub，移除 docker.io/ 等域名前缀，如果没有 / 则添加 library/ 前缀
 // 对于 GHCR，移除 ghcr.io/ 前缀
 // 对于自定义 registry，移除域名前缀
 func NormalizeImageName(image, registryKey string) string {
 	switch registryKey {
 	case DockerHubKey:
+		image, _ = trimDockerHubDomain(image)
 		// 如果镜像名中没有 /，说明是官方镜像，添加 library/ 前缀
 		if !strings.Contains(image, "/") {
 			return "library/" + image
@@ -134,6 +173,22 @@ func RegisterRegistry(key string, config RegistryConfig) error {
 	if config.Name == "" {
 		config.Name = key
 	}
+	config.TokenMethod = strings.ToUpper(config.TokenMethod)
+	if config.TokenMethod != "" && config.TokenMethod != TokenMethodGET && config.TokenMethod != TokenMethodPOST {
+		return fmt.Errorf("registry '%s' 的 tokenMethod 无效: %s，应为 GET 或 POST", key, config.TokenMethod)
+	}
+	if config.MaxAuthURLLength < 0 {
+		return fmt.Errorf("registry '%s' 的 maxAuthURLLength 不能为负数: %d", key, config.MaxAuthURLLength)
+	}
+
+	// 复制 map，避免调用方之后的修改影响已注册的配置
+	if config.TokenParams != nil {
+		params := make(map[string]string, len(config.TokenParams))
+		for k, v := range config.TokenParams {
+			params[k] = v
+		}
+		config.TokenParams = params
+	}
 
 	registries[key] = &config
 	return nil
@@ -179,3 +234,111 @@ func ListRegistries() map[string]*RegistryConfig {
 
 	return result
 }
+
+// SetRegistryDefaults 设置 registry 的默认标签和默认平台，内置 registry 同样适用
+// defaultPlatform 格式为 os/arch[/variant]，为空表示不解析索引
+func SetRegistryDefaults(key, defaultTag, defaultPlatform string) error {
+	if defaultPlatform != "" {
+		if _, err := ParsePlatform(defaultPlatform); err != nil {
+			return err
+		}
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	config, exists := registries[key]
+	if !exists {
+		return fmt.Errorf("registry key '%s' 未注册", key)
+	}
+
+	// 复制后替换，避免修改其他地方持有的配置
+	updated := *config
+	updated.DefaultTag = defaultTag
+	updated.DefaultPlatform = defaultPlatform
+	registries[key] = &updated
+	return nil
+}
+
+// defaultTag 返回镜像应使用的标签
+// tag 不为空时原样返回，否则使用 registry 配置的默认标签，未配置时为 latest
+func defaultTag(registryKey, tag string) string {
+	if tag != "" {
+		return tag
+	}
+	if config, ok := GetRegistry(registryKey); ok && config.DefaultTag != "" {
+		return config.DefaultTag
+	}
+	return "latest"
+}
+
+// defaultPlatform 返回 registry 配置的默认平台
+func defaultPlatform(registryKey string) (Platform, bool, error) {
+	config, ok := GetRegistry(registryKey)
+	if !ok || config.DefaultPlatform == "" {
+		return Platform{}, false, nil
+	}
+	platform, err := ParsePlatform(config.DefaultPlatform)
+	if err != nil {
+		return Platform{}, false, err
+	}
+	return platform, true, nil
+}
+
+// registriesFile 表示 registry 配置文件的内容
+type registriesFile struct {
+	Registries []RegistryConfig `json:"registries"`
+}
+
+// LoadRegistriesFile 从 JSON 配置文件加载 registry
+// 自定义 registry 会被注册；内置 registry（dockerhub、ghcr）只应用其默认策略
+//
+// 配置文件格式：
+//
+//	{
+//	  "registries": [
+//	    {
+//	      "key": "edge",
+//	      "name": "Edge Mirror",
+//	      "registryURL": "https://edge-registry.example.com",
+//	      "authURL": "https://edge-registry.example.com",
+//	      "service": "edge-registry.example.com",
+//	      "tokenParams": {"client_id": "docker-auth"},
+//	      "defaultTag": "stable",
+//	      "defaultPlatform": "linux/arm64"
+//	    },
+//	    {"key": "dockerhub", "defaultPlatform": "linux/amd64"}
+//	  ]
+//	}
+func LoadRegistriesFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取 registry 配置文件失败: %w", err)
+	}
+
+	var file registriesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("解析 registry 配置文件失败: %w", err)
+	}
+
+	for _, config := range file.Registries {
+		if config.DefaultPlatform != "" {
+			if _, err := ParsePlatform(config.DefaultPlatform); err != nil {
+				return fmt.Errorf("registry '%s' 配置错误: %w", config.Key, err)
+			}
+		}
+
+		if config.Key == DockerHubKey || config.Key == GHCRKey {
+			if err := SetRegistryDefaults(config.Key, config.DefaultTag, config.DefaultPlatform); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := RegisterRegistry(config.Key, config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
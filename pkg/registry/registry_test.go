@@ -0,0 +1,220 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testRegistry 是测试用的 registry，实现 manifest（带 ETag）、blob、标签列表以及 Bearer token 和 Basic 认证
+type testRegistry struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	manifests   map[string]testManifest // <仓库>@<digest> 和 <仓库>:<标签> -> manifest
+	blobs       map[string][]byte       // digest -> 内容
+	requests    []string                // 收到的请求，格式为 "<方法> <路径>"
+	token       string                  // /token 返回的 token，为空时不要求认证
+	basic       bool                    // 只支持 Basic 认证，不签发 token，要求 users 中的凭据
+	users       map[string]string       // 用户名 -> 密码；带凭据的 token 请求和 Basic 认证的请求必须匹配
+	handler     http.HandlerFunc        // 不为 nil 时替代默认的处理，用于模拟异常的响应
+	notModified int                     // 因 If-None-Match 匹配返回 304 的次数
+}
+
+// testManifest 表示 testRegistry 中的 manifest
+type testManifest struct {
+	mediaType string
+	body      []byte
+}
+
+// newTestRegistry 启动 TLS 测试 registry，要求 Bearer token 认证
+func newTestRegistry(t *testing.T) *testRegistry {
+	t.Helper()
+	r := &testRegistry{
+		manifests: make(map[string]testManifest),
+		blobs:     make(map[string][]byte),
+		token:     "test-token",
+		users:     make(map[string]string),
+	}
+	r.server = httptest.NewTLSServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+// host 返回 registry 的地址，用作镜像名的前缀
+func (r *testRegistry) host() string {
+	return extractDomain(r.server.URL)
+}
+
+// client 返回信任测试 registry 证书的客户端
+func (r *testRegistry) client() *Client {
+	client := NewClient()
+	client.httpClient.Transport = wrapTransport(r.server.Client().Transport)
+	return client
+}
+
+// putBlob 保存 blob，返回其描述符
+func (r *testRegistry) putBlob(mediaType string, content []byte) Descriptor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	digest := computeDigest(content)
+	r.blobs[digest] = content
+	return Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(content))}
+}
+
+// putManifest 保存 manifest，tag 不为空时同时作为标签，返回其描述符
+func (r *testRegistry) putManifest(repository, tag, mediaType string, v interface{}) Descriptor {
+	body, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	desc := Descriptor{MediaType: mediaType, Digest: computeDigest(body), Size: int64(len(body))}
+	r.manifests[repository+"@"+desc.Digest] = testManifest{mediaType: mediaType, body: body}
+	if tag != "" {
+		r.manifests[repository+":"+tag] = testManifest{mediaType: mediaType, body: body}
+	}
+	return desc
+}
+
+// putImage 保存单层镜像和配置，返回 manifest 的描述符
+func (r *testRegistry) putImage(repository, tag, config string) Descriptor {
+	manifest := ImageManifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIManifest,
+		Config:        r.putBlob(MediaTypeOCIConfig, []byte(config)),
+		Layers:        []Descriptor{r.putBlob(MediaTypeOCILayerGzip, []byte("layer-"+repository+":"+tag))},
+	}
+	return r.putManifest(repository, tag, MediaTypeOCIManifest, manifest)
+}
+
+// countRequests 返回第 n 个之后收到的请求中等于 request 的个数
+func (r *testRegistry) countRequests(n int, request string) int {
+	count := 0
+	for _, got := range r.requestsSince(n) {
+		if got == request {
+			count++
+		}
+	}
+	return count
+}
+
+// checkUser 判断请求的 Basic 认证是否与 users 中的凭据匹配，没有凭据时 present 为 false
+func (r *testRegistry) checkUser(req *http.Request) (present, ok bool) {
+	username, password, present := req.BasicAuth()
+	if !present {
+		return false, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	expected, exists := r.users[username]
+	return true, exists && expected == password
+}
+
+// requestCount 返回已收到的请求数
+func (r *testRegistry) requestCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// requestsSince 返回第 n 个之后收到的请求
+func (r *testRegistry) requestsSince(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.requests[n:]...)
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+	handler, token, basic := r.handler, r.token, r.basic
+	r.mu.Unlock()
+	if handler != nil {
+		handler(w, req)
+		return
+	}
+
+	switch {
+	case basic:
+		if _, ok := r.checkUser(req); !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	case req.URL.Path == "/token":
+		// 不带凭据时签发匿名 token，带凭据时凭据必须有效
+		if present, ok := r.checkUser(req); present && !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"errors":[{"code":"UNAUTHORIZED","message":"invalid credentials"}]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"token":%q,"expires_in":300}`, token)
+		return
+	case token != "" && req.Header.Get("Authorization") != "Bearer "+token:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="https://`+req.Host+`/token",service="test"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if path == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if repository, reference, ok := strings.Cut(path, "/manifests/"); ok {
+		key := repository + ":" + reference
+		if strings.HasPrefix(reference, "sha256:") {
+			key = repository + "@" + reference
+		}
+		manifest, ok := r.manifests[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[{"code":"MANIFEST_UNKNOWN","message":"manifest unknown"}]}`)
+			return
+		}
+		digest := computeDigest(manifest.body)
+		w.Header().Set("Content-Type", manifest.mediaType)
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Etag", `"`+digest+`"`)
+		if req.Header.Get("If-None-Match") == `"`+digest+`"` {
+			r.notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if req.Method != http.MethodHead {
+			w.Write(manifest.body)
+		}
+		return
+	}
+	if _, digest, ok := strings.Cut(path, "/blobs/"); ok {
+		content, ok := r.blobs[digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[{"code":"BLOB_UNKNOWN","message":"blob unknown"}]}`)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Write(content)
+		return
+	}
+	if repository, ok := strings.CutSuffix(path, "/tags/list"); ok {
+		tags := []string{}
+		for key := range r.manifests {
+			if tag, ok := strings.CutPrefix(key, repository+":"); ok {
+				tags = append(tags, tag)
+			}
+		}
+		sort.Strings(tags)
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": repository, "tags": tags})
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
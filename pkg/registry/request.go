@@ -0,0 +1,423 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// registryTarget 表示解析后的镜像访问目标
+type registryTarget struct {
+	image         string // 原始镜像名称
+	registryKey   string // registry key，未注册的自定义源为 "custom:<域名>"
+	registryURL   string // registry API 地址
+	repository    string // 规范化后的仓库名称
+	credentialKey string // 查找凭据使用的 key
+	challengeAuth bool   // 是否按 /v2/ 接口的质询认证（未注册的自定义源或未配置 AuthURL 的 registry）
+
+	credentials *resolvedCredentials // 从凭据提供者获取的凭据，该目标的所有认证步骤共享
+
+	rejectedCredential string // 被认证服务拒绝、已回退为匿名访问的凭据 key（仅 WithAnonymousFallback）
+}
+
+// resolveTarget 根据镜像名称解析访问目标
+func (c *Client) resolveTarget(image string) (*registryTarget, error) {
+	registryKey := DetectRegistry(image)
+
+	// 未注册的自定义源：直接使用域名访问，凭据按域名查找
+	if customDomain, ok := strings.CutPrefix(registryKey, "custom:"); ok {
+		c.logger.Debug("检测到未注册的自定义源", "domain", customDomain)
+
+		// 规范化镜像名称（移除域名前缀）
+		repository := image
+		if parts := strings.SplitN(image, "/", 2); len(parts) == 2 {
+			repository = parts[1]
+		}
+
+		return &registryTarget{
+			image:         image,
+			registryKey:   registryKey,
+			registryURL:   "https://" + customDomain,
+			repository:    repository,
+			credentialKey: customDomain,
+			challengeAuth: true,
+			credentials:   &resolvedCredentials{},
+		}, nil
+	}
+
+	config, ok := GetRegistry(registryKey)
+	if !ok {
+		return nil, fmt.Errorf("未找到 registry 配置: %s", registryKey)
+	}
+
+	return &registryTarget{
+		image:         image,
+		registryKey:   registryKey,
+		registryURL:   config.RegistryURL,
+		repository:    NormalizeImageName(image, registryKey),
+		credentialKey: registryKey,
+		challengeAuth: config.AuthURL == "",
+		credentials:   &resolvedCredentials{},
+	}, nil
+}
+
+// context 返回携带该目标已获取凭据的 ctx，同一目标的认证和请求只调用一次凭据提供者
+func (t *registryTarget) context(ctx context.Context) context.Context {
+	return withResolvedCredentials(ctx, t.credentials)
+}
+
+// resolveSpec 解析批量任务中镜像的访问目标，spec 设置了 CredentialKey 时使用该 key 查找凭据
+func (c *Client) resolveSpec(spec ImageSpec) (*registryTarget, error) {
+	target, err := c.resolveTarget(spec.Image)
+	if err != nil {
+		return nil, err
+	}
+	if spec.CredentialKey != "" {
+		target.credentialKey = spec.CredentialKey
+	}
+	return target, nil
+}
+
+// authorize 获取访问目标仓库（pull 权限）使用的 Authorization header
+func (c *Client) authorize(ctx context.Context, target *registryTarget) (string, error) {
+	if c.Offline() {
+		return "", nil // 离线模式只读取缓存，不需要认证
+	}
+	ctx = target.context(ctx)
+	if target.challengeAuth {
+		// 对于未注册的自定义源和未配置 AuthURL 的 registry，使用 WWW-Authenticate 流程
+		authorization, err := c.getAuthorizationViaWWWAuthenticate(ctx, target.registryKey, target.registryURL, []string{pullScope(target.repository)}, target.credentialKey)
+		if err != nil {
+			if anonymous, ok := c.authorizeAnonymously(ctx, target, err); ok {
+				return anonymous, nil
+			}
+			return "", newAuthError("通过 WWW-Authenticate 获取认证 token 失败", target.registryKey, err)
+		}
+		return authorization, nil
+	}
+
+	// 对于已注册的 registry，使用标准流程
+	token, err := c.getAuthToken(ctx, target.image, target.registryKey, target.credentialKey)
+	if err == nil {
+		return "Bearer " + token, nil
+	}
+
+	// 凭据被拒绝时按需回退为匿名访问
+	if anonymous, ok := c.authorizeAnonymously(ctx, target, err); ok {
+		return anonymous, nil
+	}
+
+	// token 服务不可用时，检查 registry 是否只支持 Basic 认证
+	challenge, probeErr := c.registryChallenge(ctx, target.registryURL)
+	basicAuth, hasCred := c.basicAuthHeader(ctx, target.credentialKey)
+	if probeErr != nil || !isBasicChallenge(challenge) || !hasCred {
+		return "", newAuthError("获取认证 token 失败", target.registryKey, err)
+	}
+	c.logger.Debug("registry 仅支持 Basic 认证", "registryKey", target.registryKey)
+	return basicAuth, nil
+}
+
+// reauthorize 请求因 token 过期或 scope 不足返回 401 时，按响应中的 Bearer 质询重新获取 token
+// err 不是带 Bearer 质询的 401 错误、使用预先获取的 bearer token，或重新获取失败时返回 false
+func (c *Client) reauthorize(ctx context.Context, target *registryTarget, err error) (string, bool) {
+	var respErr *ResponseError
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusUnauthorized {
+		return "", false
+	}
+	ctx = target.context(ctx)
+	if _, ok := c.staticToken(ctx, target.credentialKey); ok {
+		return "", false
+	}
+	if respErr.Challenge == "" || isBasicChallenge(respErr.Challenge) {
+		return "", false
+	}
+
+	c.logger.Debug("请求返回 401，按质询重新获取 token",
+		"image", target.image,
+		"challenge", respErr.Challenge)
+
+	authorization, authErr := c.getAuthorizationForChallenge(ctx, target.registryKey, respErr.Challenge, target.credentialKey, []string{pullScope(target.repository)}, false)
+	if authErr != nil {
+		c.logger.Debug("重新获取 token 失败", "image", target.image, "error", authErr)
+		return "", false
+	}
+	return authorization, true
+}
+
+// reauthorizeBatch 使用批量 token 的请求返回 401 时重新认证
+// 批量 token 在长时间运行的并发任务中可能中途过期，或不包含该镜像所需的 scope：
+// 优先按响应中的 Bearer 质询获取 token，没有可用的质询时按标准流程为该镜像单独认证
+func (c *Client) reauthorizeBatch(ctx context.Context, target *registryTarget, err error) (string, bool) {
+	if authorization, ok := c.reauthorize(ctx, target, err); ok {
+		return authorization, true
+	}
+	if errorStatusCode(err) != http.StatusUnauthorized {
+		return "", false
+	}
+
+	c.logger.Debug("批量 token 被拒绝，单独认证后重试", "image", target.image)
+	authorization, authErr := c.authorize(ctx, target)
+	if authErr != nil {
+		c.logger.Debug("单独认证失败", "image", target.image, "error", authErr)
+		return "", false
+	}
+	return authorization, true
+}
+
+// reauthorizer 返回请求返回 401 时使用的重新认证方法
+// 使用批量 token 时为 reauthorizeBatch，否则为 reauthorize
+func (c *Client) reauthorizer(batchToken string) func(context.Context, *registryTarget, error) (string, bool) {
+	if batchToken != "" {
+		return c.reauthorizeBatch
+	}
+	return c.reauthorize
+}
+
+// doRegistryRequest 向目标仓库发送请求
+// path 为仓库下的相对路径（如 "manifests/latest"、"tags/list"）
+// 只读模式下修改操作（GET 和 HEAD 以外的方法）返回 ErrReadOnly
+func (c *Client) doRegistryRequest(ctx context.Context, method string, target *registryTarget, path, authorization string, header http.Header) (*http.Response, error) {
+	return c.doRegistryRequestWithBody(ctx, method, target, path, authorization, header, nil)
+}
+
+// doRegistryRequestWithBody 与 doRegistryRequest 相同，body 不为 nil 时作为请求体发送
+func (c *Client) doRegistryRequestWithBody(ctx context.Context, method string, target *registryTarget, path, authorization string, header http.Header, body []byte) (*http.Response, error) {
+	if err := c.checkReadOnly(method, target, path); err != nil {
+		return nil, err
+	}
+
+	requestURL := fmt.Sprintf("%s/v2/%s/%s", target.registryURL, target.repository, path)
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(target.context(ctx), method, requestURL, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := c.doWithBasicFallback(req, target.credentialKey)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	return resp, nil
+}
+
+// fetchedManifest 表示从 registry 获取的 manifest
+type fetchedManifest struct {
+	body      []byte // manifest 原始内容
+	mediaType string // manifest 媒体类型
+	digest    string // Docker-Content-Digest
+}
+
+// fetchManifest 使用已获取的认证信息获取 manifest
+// reference 可以是标签或 digest
+// 设置了 manifest 缓存时使用 If-None-Match 条件请求，registry 返回 304 时使用缓存的内容
+// 离线模式下只读取缓存
+func (c *Client) fetchManifest(ctx context.Context, target *registryTarget, authorization, reference string) (*fetchedManifest, error) {
+	if c.Offline() {
+		return c.cachedManifest(target, reference)
+	}
+
+	header := manifestAcceptHeader()
+	cache := c.getManifestCache()
+	var cacheKey string
+	var cached *CachedManifest
+	if cache != nil {
+		cacheKey = manifestCacheKey(target, reference)
+		if entry, ok := cache.Get(cacheKey); ok && entry.ETag != "" {
+			cached = entry
+			header.Set("If-None-Match", entry.ETag)
+		}
+	}
+
+	resp, err := c.doRegistryRequest(ctx, "GET", target, "manifests/"+reference, authorization, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// manifest 未变化，使用缓存的内容
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		c.logger.Debug("manifest 未变化，使用缓存",
+			"repository", target.repository,
+			"reference", reference,
+			"digest", cached.Digest)
+		return &fetchedManifest{
+			body:      cached.Body,
+			mediaType: cached.MediaType,
+			digest:    cached.Digest,
+		}, nil
+	}
+
+	// 检查响应状态
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResponseError("获取 manifest 失败", resp)
+	}
+
+	// 读取响应体，限制大小以防止异常的 registry 耗尽内存
+	if resp.ContentLength > MaxManifestSize {
+		return nil, fmt.Errorf("%w (%d > %d 字节)", ErrManifestTooLarge, resp.ContentLength, MaxManifestSize)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxManifestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	if len(body) > MaxManifestSize {
+		return nil, fmt.Errorf("%w (超过 %d 字节)", ErrManifestTooLarge, MaxManifestSize)
+	}
+
+	fetched := &fetchedManifest{
+		body:      body,
+		mediaType: detectManifestMediaType(resp.Header.Get("Content-Type"), body),
+		digest:    resp.Header.Get("Docker-Content-Digest"),
+	}
+	if cache != nil {
+		// 没有 ETag 的响应同样缓存，供离线模式使用；按标签获取时同时按 digest 缓存
+		entry := &CachedManifest{
+			Body:      fetched.body,
+			MediaType: fetched.mediaType,
+			Digest:    fetched.digest,
+			ETag:      resp.Header.Get("Etag"),
+		}
+		cache.Set(cacheKey, entry)
+		if entry.Digest != "" && entry.Digest != reference {
+			cache.Set(manifestCacheKey(target, entry.Digest), entry)
+		}
+	}
+	return fetched, nil
+}
+
+// fetchBlob 获取 blob 内容并校验 digest
+// 超过 maxSize 字节时返回错误，避免异常的 registry 耗尽内存
+func (c *Client) fetchBlob(ctx context.Context, target *registryTarget, authorization string, desc Descriptor, maxSize int64) ([]byte, error) {
+	if desc.Size > maxSize {
+		return nil, fmt.Errorf("blob %s 超过大小限制 (%d > %d 字节)", desc.Digest, desc.Size, maxSize)
+	}
+
+	resp, err := c.doRegistryRequest(ctx, "GET", target, "blobs/"+desc.Digest, authorization, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResponseError("获取 blob 失败", resp)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("blob %s 超过大小限制 (%d 字节)", desc.Digest, maxSize)
+	}
+	if digest := computeDigest(body); strings.HasPrefix(desc.Digest, "sha256:") && digest != desc.Digest {
+		return nil, fmt.Errorf("blob digest 不匹配: 期望 %s，实际 %s", desc.Digest, digest)
+	}
+	return body, nil
+}
+
+// resolvePlatform 如果 manifest 为索引，获取其中与平台匹配的子 manifest
+// 不是索引时原样返回
+func (c *Client) resolvePlatform(ctx context.Context, target *registryTarget, authorization string, manifest *fetchedManifest, platform Platform) (*fetchedManifest, error) {
+	if !IsIndexMediaType(manifest.mediaType) {
+		return manifest, nil
+	}
+
+	var index ImageIndex
+	if err := json.Unmarshal(manifest.body, &index); err != nil {
+		return nil, fmt.Errorf("解析镜像索引失败: %w", err)
+	}
+	if err := c.getIndexLimits().checkChildren(manifest.digest, &index); err != nil {
+		return nil, err
+	}
+
+	desc, err := selectPlatformManifest(&index, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Debug("选择平台 manifest",
+		"platform", platform.String(),
+		"digest", desc.Digest)
+
+	child, err := c.fetchManifest(ctx, target, authorization, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	if child.digest == "" {
+		child.digest = desc.Digest
+	}
+	return child, nil
+}
+
+// applyRegistryDefaults 按 registry 的默认策略获取 manifest
+// 镜像所在 registry 配置了默认平台时，索引会被解析为该平台的 manifest
+func (c *Client) applyRegistryDefaults(ctx context.Context, target *registryTarget, authorization string, manifest *fetchedManifest) (*fetchedManifest, error) {
+	platform, ok, err := defaultPlatform(target.registryKey)
+	if err != nil || !ok {
+		return manifest, err
+	}
+	return c.resolvePlatform(ctx, target, authorization, manifest, platform)
+}
+
+// resolveSpecPlatform 按 spec 指定的平台解析索引，未指定时按 registry 的默认平台策略，结果写入 result
+// manifest 由索引解析而来时 result.IndexDigest 为索引的 digest
+func (c *Client) resolveSpecPlatform(ctx context.Context, target *registryTarget, authorization string, spec ImageSpec, manifest *fetchedManifest, result *ManifestResult) error {
+	resolved := manifest
+	if spec.Platform != "" {
+		platform, err := ParsePlatform(spec.Platform)
+		if err != nil {
+			return err
+		}
+		if resolved, err = c.resolvePlatform(ctx, target, authorization, manifest, platform); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if resolved, err = c.applyRegistryDefaults(ctx, target, authorization, manifest); err != nil {
+			return err
+		}
+	}
+
+	if resolved != manifest {
+		result.IndexDigest = manifest.digest
+	}
+	result.Manifest = string(resolved.body)
+	result.Digest = resolved.digest
+	return nil
+}
+
+// manifestAcceptTypes 获取 manifest 时接受的媒体类型
+var manifestAcceptTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// manifestAcceptHeader 返回获取 manifest 时使用的 Accept headers
+func manifestAcceptHeader() http.Header {
+	return http.Header{"Accept": append([]string(nil), manifestAcceptTypes...)}
+}
+
+// readErrorBody 读取错误响应体，用于构造错误信息
+func readErrorBody(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	return string(body)
+}
@@ -0,0 +1,193 @@
+package registry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SBOM 文档格式
+const (
+	SBOMFormatSPDX      = "spdx"
+	SBOMFormatCycloneDX = "cyclonedx"
+)
+
+// SBOM 的发现方式
+const (
+	SBOMSourceReferrers = "referrers" // OCI referrers API 或标签方案
+	SBOMSourceCosign    = "cosign"    // cosign attach sbom 推送的 "sha256-<hex>.sbom" 标签
+)
+
+// MaxSBOMSize SBOM 文档的最大大小（64 MiB）
+const MaxSBOMSize = 64 << 20
+
+// ErrNoSBOM 表示镜像没有附加 SBOM，可通过 errors.Is 判断
+var ErrNoSBOM = errors.New("镜像没有附加 SBOM")
+
+// SBOM 表示附加到镜像的 SBOM 文档
+type SBOM struct {
+	Format    string     // 文档格式：SBOMFormatSPDX 或 SBOMFormatCycloneDX
+	Version   string     // 文档的规范版本，如 "SPDX-2.3"、"1.5"；无法识别时为空
+	MediaType string     // SBOM blob 的媒体类型，如 "application/spdx+json"
+	Source    string     // 发现方式：SBOMSourceReferrers 或 SBOMSourceCosign
+	Subject   string     // SBOM 所附加的 manifest digest
+	Manifest  Descriptor // 保存 SBOM 的 artifact manifest 的描述符
+	Content   []byte     // SBOM 原始内容
+}
+
+// GetSBOM 获取附加到镜像的 SBOM 文档（SPDX 或 CycloneDX）
+// 先通过 referrers API（或 OCI 规范的标签方案）查找 artifactType 或 blob 媒体类型为 SPDX、CycloneDX 的 artifact，
+// 找不到时再查找 cosign attach sbom 推送的 "sha256-<hex>.sbom" 标签；有多个时返回第一个
+// tag 可以是标签或 digest；多架构镜像按索引的 digest 查找
+// 没有附加 SBOM 时返回可通过 errors.Is(err, ErrNoSBOM) 判断的错误
+func (c *Client) GetSBOM(image, tag string) (*SBOM, error) {
+	return c.GetSBOMContext(context.Background(), image, tag)
+}
+
+// GetSBOMContext 与 GetSBOM 相同，但所有请求（包括认证）受 ctx 控制
+func (c *Client) GetSBOMContext(ctx context.Context, image, tag string) (*SBOM, error) {
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := c.subjectDigest(ctx, target, authorization, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	referrers, err := c.listReferrers(ctx, target, authorization, subject)
+	if err != nil {
+		return nil, err
+	}
+	for _, referrer := range referrers {
+		// artifactType 明确不是 SBOM 的 artifact（如签名）不需要下载
+		if referrer.ArtifactType != "" && sbomFormat(referrer.ArtifactType) == "" {
+			continue
+		}
+		sbom, err := c.fetchSBOM(ctx, target, authorization, referrer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		if sbom != nil {
+			sbom.Source, sbom.Subject = SBOMSourceReferrers, subject
+			return sbom, nil
+		}
+	}
+
+	sbom, err := c.fetchSBOM(ctx, target, authorization, referrersTag(subject)+".sbom")
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s@%s", ErrNoSBOM, image, subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sbom == nil {
+		return nil, fmt.Errorf("%w: %s@%s", ErrNoSBOM, image, subject)
+	}
+	sbom.Source, sbom.Subject = SBOMSourceCosign, subject
+	return sbom, nil
+}
+
+// fetchSBOM 获取 artifact manifest，下载其中第一个 SBOM blob
+// manifest 中没有 SBOM 时返回 nil
+func (c *Client) fetchSBOM(ctx context.Context, target *registryTarget, authorization, reference string) (*SBOM, error) {
+	fetched, err := c.fetchManifest(ctx, target, authorization, reference)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ImageManifest
+	if err := json.Unmarshal(fetched.body, &manifest); err != nil {
+		return nil, fmt.Errorf("解析 artifact manifest %s 失败: %w", reference, err)
+	}
+
+	// artifactType 指明了格式时，blob 可能使用通用的媒体类型（如 application/json）
+	manifestFormat := sbomFormat(manifest.ArtifactType)
+	if manifestFormat == "" {
+		manifestFormat = sbomFormat(manifest.Config.MediaType)
+	}
+	for _, layer := range manifest.Layers {
+		format := sbomFormat(layer.MediaType)
+		if format == "" {
+			format = manifestFormat
+		}
+		if format == "" {
+			continue
+		}
+
+		content, err := c.fetchBlob(ctx, target, authorization, layer, MaxSBOMSize)
+		if err != nil {
+			return nil, err
+		}
+		return &SBOM{
+			Format:    format,
+			Version:   sbomVersion(format, content),
+			MediaType: layer.MediaType,
+			Manifest: Descriptor{
+				MediaType:    fetched.mediaType,
+				Digest:       computeDigest(fetched.body),
+				Size:         int64(len(fetched.body)),
+				ArtifactType: manifest.ArtifactType,
+			},
+			Content: content,
+		}, nil
+	}
+	return nil, nil
+}
+
+// sbomFormat 根据媒体类型或 artifactType 判断 SBOM 格式，不是 SBOM 时返回空字符串
+// 如 application/spdx+json、text/spdx、application/vnd.cyclonedx+json、application/vnd.cyclonedx+xml
+func sbomFormat(mediaType string) string {
+	mediaType = strings.ToLower(mediaType)
+	switch {
+	case strings.Contains(mediaType, "spdx"):
+		return SBOMFormatSPDX
+	case strings.Contains(mediaType, "cyclonedx"):
+		return SBOMFormatCycloneDX
+	}
+	return ""
+}
+
+// sbomVersion 从 SBOM 内容中解析规范版本，支持 JSON、SPDX tag-value 和 CycloneDX XML
+func sbomVersion(format string, content []byte) string {
+	var doc struct {
+		SPDXVersion string `json:"spdxVersion"`
+		SpecVersion string `json:"specVersion"`
+	}
+	if json.Unmarshal(content, &doc) == nil {
+		if format == SBOMFormatSPDX {
+			return doc.SPDXVersion
+		}
+		return doc.SpecVersion
+	}
+
+	if format == SBOMFormatSPDX {
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			if version, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "SPDXVersion:"); ok {
+				return strings.TrimSpace(version)
+			}
+		}
+		return ""
+	}
+
+	// CycloneDX XML 的版本在命名空间中，如 xmlns="http://cyclonedx.org/schema/bom/1.5"
+	const namespace = "cyclonedx.org/schema/bom/"
+	if i := bytes.Index(content, []byte(namespace)); i >= 0 {
+		rest := content[i+len(namespace):]
+		if end := bytes.IndexAny(rest, `"'`); end > 0 {
+			return string(rest[:end])
+		}
+	}
+	return ""
+}
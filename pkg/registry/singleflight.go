@@ -0,0 +1,75 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// flightCall 表示一次进行中的共享调用，done 关闭后 val 和 err 可读
+type flightCall struct {
+	done chan struct{}
+	val  any
+	err  error
+}
+
+// flightGroup 合并 key 相同的并发调用，与 golang.org/x/sync/singleflight 的 DoChan 相同，
+// 在包内实现以保持 nozap 构建下核心包不依赖第三方模块；零值可以直接使用
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do 在新的 goroutine 中执行 fn 并返回该调用；key 相同的调用正在进行时直接返回该调用，shared 为 true
+func (g *flightGroup) do(key string, fn func() (any, error)) (call *flightCall, shared bool) {
+	g.mu.Lock()
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		return call, true
+	}
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	call = &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	go func() {
+		defer func() {
+			g.mu.Lock()
+			delete(g.calls, key)
+			g.mu.Unlock()
+			close(call.done)
+		}()
+		call.val, call.err = fn()
+	}()
+	return call, false
+}
+
+// shareAuth 合并并发的相同认证请求，key 相同的请求同一时间只发送一次
+// 并发获取且回退到单独认证时，同一 registry 的多个 goroutine 不会各自请求相同的 token
+// 共享的请求不受单个调用方取消的影响（仍受 HTTP 客户端超时限制），每个调用方按自己的 ctx 返回
+func (c *Client) shareAuth(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	shared := context.WithoutCancel(ctx)
+	call, joined := c.authGroup.do(key, func() (any, error) {
+		return fn(shared)
+	})
+	if joined {
+		c.logger.Debug("合并并发的相同认证请求", "key", key)
+	}
+
+	select {
+	case <-call.done:
+		return call.val, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// scopeSetKey 返回与顺序无关的 scope 集合 key
+func scopeSetKey(scopes []string) string {
+	sorted := append([]string(nil), scopes...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, " ")
+}
@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShareAuthCollapsesConcurrentCalls(t *testing.T) {
+	client := NewClient()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (any, error) {
+		calls.Add(1)
+		<-release
+		return "token", nil
+	}
+
+	const callers = 8
+	var wg sync.WaitGroup
+	results := make([]any, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = client.shareAuth(context.Background(), "scope", fn)
+		}(i)
+	}
+	// 等待所有调用方加入同一个进行中的调用
+	deadline := time.Now().Add(time.Second)
+	for calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fn 调用次数 = %d, 期望 1", got)
+	}
+	for i, result := range results {
+		if result != "token" {
+			t.Errorf("调用方 %d 的结果 = %v, 期望 token", i, result)
+		}
+	}
+}
+
+func TestShareAuthCallerCancel(t *testing.T) {
+	client := NewClient()
+	release := make(chan struct{})
+	defer close(release)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.shareAuth(ctx, "scope", func(ctx context.Context) (any, error) {
+		<-release
+		return nil, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, 期望 context.Canceled", err)
+	}
+}
+
+func TestFlightGroupForgetsFinishedCalls(t *testing.T) {
+	var group flightGroup
+	for i := 0; i < 2; i++ {
+		call, shared := group.do("key", func() (any, error) { return i, nil })
+		if shared {
+			t.Fatalf("第 %d 次调用不应与已完成的调用共享", i)
+		}
+		<-call.done
+		if call.val != i {
+			t.Errorf("val = %v, 期望 %d", call.val, i)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package registry
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithSpillDir 设置批量获取时 manifest 内容的落盘目录，用于超大批量任务控制内存占用
+// 设置后 GetManifestsWithDigest 会在获取到每个 manifest 后立即将内容写入该目录，
+// 结果中只保留 digest 等元数据，ManifestResult.Manifest 为空，ManifestPath 为文件路径
+// 文件按内容的 sha256 命名，相同内容只保存一份；为空字符串时关闭落盘（默认）
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithSpillDir(dir string) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spillDir = dir
+	return c
+}
+
+// getSpillDir 返回 manifest 落盘目录
+func (c *Client) getSpillDir() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.spillDir
+}
+
+// spillManifest 将结果中的 manifest 内容写入落盘目录，并清空内存中的内容
+func (c *Client) spillManifest(result *ManifestResult, dir string) error {
+	body := []byte(result.Manifest)
+	name := strings.TrimPrefix(computeDigest(body), "sha256:") + ".json"
+	path := filepath.Join(dir, name)
+
+	// 内容寻址，文件已存在说明内容相同
+	if _, err := os.Stat(path); err != nil {
+		if err := writeFileAtomic(dir, path, body); err != nil {
+			return fmt.Errorf("写入 manifest 落盘文件失败: %w", err)
+		}
+	}
+
+	result.Manifest = ""
+	result.ManifestPath = path
+	return nil
+}
+
+// writeFileAtomic 先写入临时文件再重命名，避免并发或中断时留下不完整的文件
+func writeFileAtomic(dir, path string, data []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadManifest 返回结果中的 manifest 内容
+// 使用 WithSpillDir 落盘时从文件读取，否则直接返回 Manifest 字段
+func (r *ManifestResult) LoadManifest() (string, error) {
+	if r.ManifestPath == "" {
+		return r.Manifest, nil
+	}
+	data, err := os.ReadFile(r.ManifestPath)
+	if err != nil {
+		return "", fmt.Errorf("读取 manifest 落盘文件失败: %w", err)
+	}
+	return string(data), nil
+}
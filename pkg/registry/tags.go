@@ -0,0 +1,449 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tagsResponse 表示 /v2/<name>/tags/list 的响应
+type tagsResponse struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// ListTags 列出镜像的所有标签
+// 自动处理分页（Link header）
+func (c *Client) ListTags(image string) ([]string, error) {
+	ctx := context.Background()
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.listTags(ctx, target, authorization)
+}
+
+// listTags 使用已获取的认证信息列出标签
+func (c *Client) listTags(ctx context.Context, target *registryTarget, authorization string) ([]string, error) {
+	var tags []string
+	path := "tags/list"
+
+	for path != "" {
+		resp, err := c.doRegistryRequest(ctx, "GET", target, path, authorization, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			err := newResponseError("获取标签列表失败", resp)
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var page tagsResponse
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析标签列表失败: %w", err)
+		}
+		tags = append(tags, page.Tags...)
+
+		path = nextTagsPage(resp.Header.Get("Link"))
+	}
+
+	return tags, nil
+}
+
+// nextTagsPage 从 Link header 中解析下一页的相对路径
+// Link: </v2/library/nginx/tags/list?last=1.25&n=100>; rel="next"
+func nextTagsPage(link string) string {
+	if link == "" || !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+
+	start := strings.Index(link, "<")
+	end := strings.Index(link, ">")
+	if start < 0 || end <= start {
+		return ""
+	}
+
+	next, err := url.Parse(link[start+1 : end])
+	if err != nil {
+		return ""
+	}
+	return "tags/list?" + next.RawQuery
+}
+
+// GetDigest 通过 HEAD 请求获取镜像标签对应的 manifest digest，不下载 manifest 内容
+// 如果 registry 的 HEAD 响应中没有 Docker-Content-Digest，会退回 GET 并计算 sha256
+func (c *Client) GetDigest(image, tag string) (string, error) {
+	ctx := context.Background()
+	if err := c.checkImagePolicy(image); err != nil {
+		return "", err
+	}
+
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return "", err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return "", err
+	}
+
+	return c.headDigest(ctx, target, authorization, tag)
+}
+
+// headDigest 使用已获取的认证信息获取 digest
+// 离线模式下从 manifest 缓存中读取
+func (c *Client) headDigest(ctx context.Context, target *registryTarget, authorization, tag string) (string, error) {
+	if c.Offline() {
+		fetched, err := c.cachedManifest(target, tag)
+		if err != nil {
+			return "", err
+		}
+		return fetched.digest, nil
+	}
+
+	resp, err := c.doRegistryRequest(ctx, "HEAD", target, "manifests/"+tag, authorization, manifestAcceptHeader())
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := newResponseError("获取 digest 失败", resp)
+		resp.Body.Close()
+		return "", err
+	}
+	resp.Body.Close()
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	// 部分 registry 的 HEAD 响应不包含 digest，下载 manifest 后自行计算
+	resp, err = c.doRegistryRequest(ctx, "GET", target, "manifests/"+tag, authorization, manifestAcceptHeader())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", newResponseError("获取 manifest 失败", resp)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, resp.Body); err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// DigestResult 表示单个镜像的 digest 获取结果
+type DigestResult struct {
+	Image  string // 镜像名称
+	Tag    string // 镜像标签
+	Digest string // Manifest digest（多架构镜像为索引的 digest，指定 Platform 时为所选平台的 manifest digest）
+	Error  error  // 错误信息（如果获取失败）
+}
+
+// batchErr 实现 batchResult 接口
+func (r DigestResult) batchErr() error {
+	return r.Error
+}
+
+// Pinned 返回按 digest 固定的镜像引用 "image@digest"，获取失败时返回空字符串
+func (r DigestResult) Pinned() string {
+	if r.Error != nil || r.Digest == "" {
+		return ""
+	}
+	return r.Image + "@" + r.Digest
+}
+
+// GetDigests 批量获取多个镜像标签对应的 manifest digest，结果顺序与 imageSpecs 一致
+// 与 GetManifestsWithOptions 的流程相同（分组、批量认证、访问策略、registry 探测），
+// 但只发送 HEAD 请求，不下载 manifest 内容，适用于在 CI 中将镜像标签固定为 digest
+// 多架构镜像返回索引的 digest，不按 registry 的默认平台解析；spec 指定了 Platform 时下载索引并返回所选平台的 manifest digest
+func (c *Client) GetDigests(ctx context.Context, imageSpecs []ImageSpec, opts BatchOptions) []DigestResult {
+	return runBatch(c, ctx, imageSpecs, opts, c.fetchSingleDigest, func(spec ImageSpec, err error) DigestResult {
+		return DigestResult{Image: spec.Image, Tag: spec.Tag, Error: err}
+	})
+}
+
+// fetchSingleDigest 获取单个镜像的 digest，token 为空时单独认证
+func (c *Client) fetchSingleDigest(batchCtx context.Context, spec ImageSpec, token groupToken) DigestResult {
+	ctx, cancel := c.imageContext(batchCtx)
+	defer cancel()
+
+	result := DigestResult{Image: spec.Image, Tag: spec.Tag}
+	result.Digest, result.Error = c.getSpecDigest(ctx, spec, token.token)
+	result.Error = c.wrapImageTimeout(ctx, result.Error)
+	return result
+}
+
+// getSpecDigest 获取镜像规格对应的 digest
+func (c *Client) getSpecDigest(ctx context.Context, spec ImageSpec, token string) (string, error) {
+	target, err := c.resolveSpec(spec)
+	if err != nil {
+		return "", err
+	}
+	var authorization string
+	if token != "" {
+		authorization = "Bearer " + token
+	} else if authorization, err = c.authorize(ctx, target); err != nil {
+		return "", err
+	}
+	tag := defaultTag(target.registryKey, spec.Tag)
+	reauthorize := c.reauthorizer(token)
+
+	if spec.Platform == "" {
+		digest, err := c.headDigest(ctx, target, authorization, tag)
+		if newAuthorization, ok := reauthorize(ctx, target, err); ok {
+			digest, err = c.headDigest(ctx, target, newAuthorization, tag)
+		}
+		return digest, err
+	}
+
+	// 指定了平台：需要下载索引才能找到所选平台的 manifest
+	fetched, err := c.fetchManifest(ctx, target, authorization, tag)
+	if newAuthorization, ok := reauthorize(ctx, target, err); ok {
+		authorization = newAuthorization
+		fetched, err = c.fetchManifest(ctx, target, authorization, tag)
+	}
+	if err != nil {
+		return "", err
+	}
+	var result ManifestResult
+	if err := c.resolveSpecPlatform(ctx, target, authorization, spec, fetched, &result); err != nil {
+		return "", err
+	}
+	return result.Digest, nil
+}
+
+// TagAliasChain 表示通道标签（latest、stable、1、1.25 等）的解析结果
+type TagAliasChain struct {
+	Image   string   // 镜像名称
+	Tag     string   // 请求解析的标签
+	Digest  string   // 标签最终指向的 manifest digest
+	Aliases []string // 指向同一 digest 的标签链，从宽泛到具体排列，如 [latest 1 1.25 1.25.4]
+	Version string   // 指向同一 digest 的最具体的版本标签，如 1.25.4；未找到时为空
+}
+
+// String 返回便于展示的别名链，如 "latest == 1 == 1.25 == 1.25.4"
+func (a *TagAliasChain) String() string {
+	return strings.Join(a.Aliases, " == ")
+}
+
+// maxAliasCandidates 找到第一个匹配的版本标签之前，最多检查的候选标签数量
+const maxAliasCandidates = 30
+
+// ResolveTagAlias 解析通道标签，返回指向同一 digest 的完整别名链
+// 例如 nginx:latest 的结果可能是 "latest == 1 == 1.25 == 1.25.4"，可用于升级报告
+//
+// 候选标签按版本号从新到旧依次检查：
+//   - 请求的标签本身是版本号（如 1.25）时，只检查更具体的版本（如 1.25.x）
+//   - 带后缀的标签（如 1.25-alpine、alpine）只匹配相同后缀的版本
+//   - 找到第一个匹配后，只再检查其前缀版本（如 1、1.25），避免大量请求
+func (c *Client) ResolveTagAlias(image, tag string) (*TagAliasChain, error) {
+	ctx := context.Background()
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, err := c.headDigest(ctx, target, authorization, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := c.listTags(ctx, target, authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := &TagAliasChain{
+		Image:   image,
+		Tag:     tag,
+		Digest:  digest,
+		Aliases: []string{tag},
+	}
+
+	var matched []TagVersion
+	checked := 0
+	for _, candidate := range aliasCandidates(tag, tags) {
+		// 已有匹配时只检查其前缀版本
+		if len(matched) > 0 && !candidate.isPrefixOf(matched[0]) {
+			continue
+		}
+		if len(matched) == 0 {
+			if checked >= maxAliasCandidates {
+				break
+			}
+			checked++
+		}
+
+		candidateDigest, err := c.headDigest(ctx, target, authorization, candidate.Tag)
+		if err != nil {
+			c.logger.Debug("检查候选标签失败",
+				"tag", candidate.Tag,
+				"error", err)
+			continue
+		}
+		if candidateDigest == digest {
+			matched = append(matched, candidate)
+		}
+	}
+
+	// 从宽泛到具体排列
+	sort.Slice(matched, func(i, j int) bool {
+		return len(matched[i].Numbers) < len(matched[j].Numbers)
+	})
+	for _, m := range matched {
+		chain.Aliases = append(chain.Aliases, m.Tag)
+	}
+	if len(matched) > 0 {
+		chain.Version = matched[len(matched)-1].Tag
+	}
+
+	return chain, nil
+}
+
+// TagVersion 表示解析为版本号的标签
+type TagVersion struct {
+	Tag     string // 原始标签
+	Numbers []int  // 版本号各部分，如 1.25.4 -> [1 25 4]
+	Suffix  string // 后缀，如 1.25.4-alpine -> alpine
+}
+
+// Compare 比较两个版本标签，v 较新时返回 1，较旧时返回 -1，相同时返回 0
+// 依次比较版本号各部分；前缀相同时更具体的版本较新（如 1.25.4 新于 1.25），
+// 版本号相同时不带后缀的标签较新（如 1.25.4 新于 1.25.4-alpine），后缀不同时按字典序比较
+func (v TagVersion) Compare(other TagVersion) int {
+	for k := 0; k < len(v.Numbers) && k < len(other.Numbers); k++ {
+		if v.Numbers[k] != other.Numbers[k] {
+			return cmpInt(v.Numbers[k], other.Numbers[k])
+		}
+	}
+	if len(v.Numbers) != len(other.Numbers) {
+		return cmpInt(len(v.Numbers), len(other.Numbers))
+	}
+	switch {
+	case v.Suffix == other.Suffix:
+		return 0
+	case v.Suffix == "":
+		return 1
+	case other.Suffix == "":
+		return -1
+	}
+	return strings.Compare(v.Suffix, other.Suffix)
+}
+
+// cmpInt 比较两个整数，a 较大时返回 1，较小时返回 -1，相等时返回 0
+func cmpInt(a, b int) int {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	}
+	return 0
+}
+
+// isPrefixOf 判断 v 是否为 other 的前缀版本（如 1.25 是 1.25.4 的前缀）
+func (v TagVersion) isPrefixOf(other TagVersion) bool {
+	if v.Suffix != other.Suffix || len(v.Numbers) >= len(other.Numbers) {
+		return false
+	}
+	for i, n := range v.Numbers {
+		if other.Numbers[i] != n {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTagVersion 将标签解析为版本号，格式为 [v]X[.Y[.Z...]][-后缀]，如 1.25、v2.0.1、1.25.4-alpine
+// 标签不是版本号（如 latest、alpine）时返回 false
+func ParseTagVersion(tag string) (TagVersion, bool) {
+	version, suffix, _ := strings.Cut(strings.TrimPrefix(tag, "v"), "-")
+	if version == "" {
+		return TagVersion{}, false
+	}
+
+	parts := strings.Split(version, ".")
+	numbers := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return TagVersion{}, false
+		}
+		numbers = append(numbers, n)
+	}
+
+	return TagVersion{Tag: tag, Numbers: numbers, Suffix: suffix}, true
+}
+
+// aliasCandidates 返回可能与 tag 指向同一 digest 的版本标签，按版本从新到旧排列
+func aliasCandidates(tag string, tags []string) []TagVersion {
+	requested, isVersion := ParseTagVersion(tag)
+
+	var candidates, suffixed []TagVersion
+	for _, t := range tags {
+		if t == tag {
+			continue
+		}
+		v, ok := ParseTagVersion(t)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case isVersion:
+			// 版本标签只匹配更具体的版本
+			if requested.isPrefixOf(v) {
+				candidates = append(candidates, v)
+			}
+		case v.Suffix == tag:
+			// 变体通道标签（如 alpine）匹配相同后缀的版本（如 1.25.4-alpine）
+			suffixed = append(suffixed, v)
+		case v.Suffix == "":
+			candidates = append(candidates, v)
+		}
+	}
+	if len(suffixed) > 0 {
+		candidates = suffixed
+	}
+
+	// 候选标签的后缀相同，按版本号从新到旧排列，前缀相同时更具体的版本优先
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Compare(candidates[j]) > 0
+	})
+
+	return candidates
+}
@@ -0,0 +1,125 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// defaultRequestTimeout 单个 HTTP 请求的默认超时
+const defaultRequestTimeout = 30 * time.Second
+
+// WithTimeout 设置单个 HTTP 请求的总超时（包括连接、TLS 握手、等待响应和读取响应体）
+// 默认 30 秒，0 表示不限制
+// 不限制 blob 内容的下载和上传（GetBlob、PullImage、ListLayer、复制和推送），大的层可能需要更长时间，这些请求只受 ctx 控制
+// 可以在 Client 使用中调用，进行中的请求仍使用之前的超时
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithTimeout(timeout time.Duration) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	client := *c.httpClient
+	client.Timeout = timeout
+	c.httpClient = &client
+	return c
+}
+
+// getHTTPClient 返回当前的 HTTP 客户端
+// httpClient 设置后不再修改，WithTimeout 等方法替换为修改后的副本，返回的客户端可以在不加锁的情况下使用
+func (c *Client) getHTTPClient() *http.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.httpClient
+}
+
+// transferKey 是标记 blob 内容传输请求的 context key
+type transferKey struct{}
+
+// withTransfer 标记 ctx 中的请求传输 blob 内容，send 对其使用不设置总超时的 transferClient
+func withTransfer(ctx context.Context) context.Context {
+	return context.WithValue(ctx, transferKey{}, true)
+}
+
+// httpClientFor 返回发送 req 使用的 HTTP 客户端：blob 内容传输的请求使用 transferClient，其他请求使用 httpClient
+func (c *Client) httpClientFor(req *http.Request) *http.Client {
+	if transfer, _ := req.Context().Value(transferKey{}).(bool); transfer {
+		return c.transferClient()
+	}
+	return c.getHTTPClient()
+}
+
+// transferClient 返回传输 blob 内容使用的 HTTP 客户端，大 blob 的传输可能超过 WithTimeout 设置的总超时，不设置总超时（由 ctx 控制）
+func (c *Client) transferClient() *http.Client {
+	client := *c.getHTTPClient()
+	client.Timeout = 0
+	return &client
+}
+
+// WithTLSHandshakeTimeout 设置 TLS 握手超时，0 表示不限制
+// 仅在使用 *http.Transport 时生效
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithTLSHandshakeTimeout(timeout time.Duration) *Client {
+	c.configureTransport("TLSHandshakeTimeout", func(t *http.Transport) {
+		t.TLSHandshakeTimeout = timeout
+	})
+	return c
+}
+
+// WithResponseHeaderTimeout 设置发送请求后等待响应头的超时，0 表示不限制
+// 可用于快速发现卡住的 registry，而不影响大响应体的读取
+// 仅在使用 *http.Transport 时生效
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithResponseHeaderTimeout(timeout time.Duration) *Client {
+	c.configureTransport("ResponseHeaderTimeout", func(t *http.Transport) {
+		t.ResponseHeaderTimeout = timeout
+	})
+	return c
+}
+
+// WithBatchImageTimeout 设置批量获取时单个镜像的超时，0 表示不限制（默认）
+// 超时覆盖该镜像的认证、manifest 获取和平台解析等所有请求，
+// 避免一个缓慢的 registry 耗尽整个批量任务的时间
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithBatchImageTimeout(timeout time.Duration) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batchImageTimeout = timeout
+	return c
+}
+
+// imageTimeout 返回批量获取时单个镜像的超时
+func (c *Client) imageTimeout() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.batchImageTimeout
+}
+
+// configureTransport 修改 transport 的连接参数
+// 自定义 RoundTripper 无法修改，记录警告后忽略
+func (c *Client) configureTransport(option string, configure func(*http.Transport)) {
+	transport, ok := c.getHTTPClient().Transport.(*registryTransport)
+	if !ok {
+		c.logger.Warn("自定义 RoundTripper 不支持该超时配置，已忽略", "option", option)
+		return
+	}
+	transport.configure(configure)
+}
+
+// configure 修改 base transport 以及所有按域名配置的 transport
+// 使用修改后的副本替换原 transport，避免与进行中的请求产生数据竞争
+// 之后通过 updateHost 创建的 transport 会从 base 继承这些设置
+func (t *registryTransport) configure(configure func(*http.Transport)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	base := t.base.Clone()
+	configure(base)
+	t.base.CloseIdleConnections()
+	t.base = base
+
+	for _, host := range t.hosts {
+		transport := host.transport.Clone()
+		configure(transport)
+		host.transport.CloseIdleConnections()
+		host.transport = transport
+	}
+}
@@ -0,0 +1,216 @@
+package registry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// TLSOptions 表示单个 registry 的 TLS 配置
+type TLSOptions struct {
+	CACertPEM     []byte // 额外信任的 CA 证书（PEM 格式），在系统根证书基础上追加
+	ClientCertPEM []byte // 客户端证书（PEM 格式），用于双向 TLS 认证
+	ClientKeyPEM  []byte // 客户端证书私钥（PEM 格式）
+
+	// InsecureSkipVerify 跳过证书校验，仅用于使用自签名证书的测试环境
+	InsecureSkipVerify bool
+}
+
+// buildTLSConfig 基于 base 构建应用了 TLS 选项的 tls.Config
+func (o *TLSOptions) buildTLSConfig(base *tls.Config) (*tls.Config, error) {
+	var config *tls.Config
+	if base != nil {
+		config = base.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+
+	if len(o.CACertPEM) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(o.CACertPEM) {
+			return nil, fmt.Errorf("CA 证书中没有有效的 PEM 证书")
+		}
+		config.RootCAs = pool
+	}
+
+	if o.InsecureSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+
+	if len(o.ClientCertPEM) > 0 || len(o.ClientKeyPEM) > 0 {
+		cert, err := tls.X509KeyPair(o.ClientCertPEM, o.ClientKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// hostTLS 表示某个域名的 TLS 选项及对应的 transport
+type hostTLS struct {
+	options   TLSOptions
+	transport *http.Transport
+}
+
+// registryTransport 根据请求的目标域名选择 transport
+// 未单独配置 TLS 的域名使用 base transport
+type registryTransport struct {
+	base  *http.Transport
+	mu    sync.RWMutex
+	hosts map[string]*hostTLS // 域名 -> TLS 配置
+}
+
+// newRegistryTransport 创建按域名路由的 transport
+func newRegistryTransport(base *http.Transport) *registryTransport {
+	return &registryTransport{
+		base:  base,
+		hosts: make(map[string]*hostTLS),
+	}
+}
+
+// RoundTrip 实现 http.RoundTripper 接口
+func (t *registryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	transport := t.base
+	if host, ok := t.hosts[req.URL.Host]; ok {
+		transport = host.transport
+	}
+	t.mu.RUnlock()
+
+	return transport.RoundTrip(req)
+}
+
+// updateHost 修改指定域名的 TLS 选项并重建其 transport
+func (t *registryTransport) updateHost(host string, update func(*TLSOptions)) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var options TLSOptions
+	if existing, ok := t.hosts[host]; ok {
+		options = existing.options
+	}
+	update(&options)
+
+	tlsConfig, err := options.buildTLSConfig(t.base.TLSClientConfig)
+	if err != nil {
+		return err
+	}
+
+	transport := t.base.Clone()
+	transport.TLSClientConfig = tlsConfig
+	t.hosts[host] = &hostTLS{
+		options:   options,
+		transport: transport,
+	}
+	return nil
+}
+
+// registryTLSHosts 返回 registry key 对应的需要配置 TLS 的域名
+// 已注册的 registry 包括 registry 地址和认证服务地址
+// 未注册的 key 视为域名本身（与未注册自定义源按域名查找凭据的方式一致）
+func registryTLSHosts(registryKey string) []string {
+	config, ok := GetRegistry(registryKey)
+	if !ok {
+		return []string{registryKey}
+	}
+
+	hosts := []string{extractDomain(config.RegistryURL)}
+	if config.AuthURL != "" {
+		if authHost := extractDomain(config.AuthURL); authHost != hosts[0] {
+			hosts = append(hosts, authHost)
+		}
+	}
+	return hosts
+}
+
+// updateRegistryTLS 修改 registry 对应所有域名的 TLS 选项
+func (c *Client) updateRegistryTLS(registryKey string, update func(*TLSOptions)) error {
+	transport, ok := c.getHTTPClient().Transport.(*registryTransport)
+	if !ok {
+		return fmt.Errorf("自定义 RoundTripper 不支持按 registry 配置 TLS，请使用 *http.Transport")
+	}
+
+	for _, host := range registryTLSHosts(registryKey) {
+		if err := transport.updateHost(host, update); err != nil {
+			return fmt.Errorf("配置 %s 的 TLS 失败: %w", host, err)
+		}
+	}
+	return nil
+}
+
+// SetRegistryCACert 为指定 registry 设置额外信任的 CA 证书（PEM 格式）
+// registryKey: 已注册的 registry key，或未注册自定义源的域名（如 "registry.example.com:5000"）
+// 仅影响该 registry 的请求，其他 registry 仍使用系统根证书
+func (c *Client) SetRegistryCACert(registryKey string, pemData []byte) error {
+	// 提前校验证书，避免部分域名配置成功
+	if _, err := (&TLSOptions{CACertPEM: pemData}).buildTLSConfig(nil); err != nil {
+		return err
+	}
+
+	return c.updateRegistryTLS(registryKey, func(o *TLSOptions) {
+		o.CACertPEM = pemData
+	})
+}
+
+// SetRegistryCACertFile 从文件读取 CA 证书并为指定 registry 设置
+func (c *Client) SetRegistryCACertFile(registryKey, caFile string) error {
+	pemData, err := os.ReadFile(caFile)
+	if err != nil {
+		return fmt.Errorf("读取 CA 证书文件失败: %w", err)
+	}
+	return c.SetRegistryCACert(registryKey, pemData)
+}
+
+// SetRegistryClientCert 为指定 registry 设置客户端证书，用于要求双向 TLS（mTLS）的 registry
+// registryKey: 已注册的 registry key，或未注册自定义源的域名
+// certPEM、keyPEM: PEM 格式的证书和私钥
+func (c *Client) SetRegistryClientCert(registryKey string, certPEM, keyPEM []byte) error {
+	// 提前校验证书，避免部分域名配置成功
+	if _, err := (&TLSOptions{ClientCertPEM: certPEM, ClientKeyPEM: keyPEM}).buildTLSConfig(nil); err != nil {
+		return err
+	}
+
+	return c.updateRegistryTLS(registryKey, func(o *TLSOptions) {
+		o.ClientCertPEM = certPEM
+		o.ClientKeyPEM = keyPEM
+	})
+}
+
+// SetRegistryClientCertFile 从文件读取客户端证书和私钥并为指定 registry 设置
+func (c *Client) SetRegistryClientCertFile(registryKey, certFile, keyFile string) error {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return fmt.Errorf("读取客户端证书文件失败: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("读取客户端私钥文件失败: %w", err)
+	}
+	return c.SetRegistryClientCert(registryKey, certPEM, keyPEM)
+}
+
+// SetRegistryInsecureSkipVerify 为指定 registry 开启或关闭 TLS 证书校验跳过
+// 只影响该 registry 的域名，其他 registry 仍正常校验证书
+// 仅用于使用自签名证书的测试环境，开启时会输出警告日志
+func (c *Client) SetRegistryInsecureSkipVerify(registryKey string, insecure bool) error {
+	if err := c.updateRegistryTLS(registryKey, func(o *TLSOptions) {
+		o.InsecureSkipVerify = insecure
+	}); err != nil {
+		return err
+	}
+
+	if insecure {
+		c.logger.Warn("已跳过 registry 的 TLS 证书校验，连接可能被中间人攻击，请勿在生产环境使用",
+			"registryKey", registryKey,
+			"hosts", registryTLSHosts(registryKey))
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package registry
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistryTLSPerHost(t *testing.T) {
+	// httptest 的 TLS 服务使用同一个自签名证书，为一个 registry 信任它不影响另一个
+	regA := newTestRegistry(t)
+	regB := newTestRegistry(t)
+	regA.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	regB.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: regA.server.Certificate().Raw})
+
+	client := NewClient()
+	if _, err := client.GetDigest(regA.host()+"/app", "v1"); err == nil {
+		t.Fatal("未配置 CA 证书时应返回证书错误")
+	}
+	if err := client.SetRegistryCACert(regA.host(), caCert); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetDigest(regA.host()+"/app", "v1"); err != nil {
+		t.Errorf("配置 CA 证书后获取失败: %v", err)
+	}
+	if _, err := client.GetDigest(regB.host()+"/app", "v1"); err == nil {
+		t.Error("未配置 CA 证书的 registry 应返回证书错误")
+	}
+
+	if err := client.SetRegistryInsecureSkipVerify(regB.host(), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetDigest(regB.host()+"/app", "v1"); err != nil {
+		t.Errorf("跳过证书校验后获取失败: %v", err)
+	}
+	if err := client.SetRegistryCACert(regB.host(), []byte("not a certificate")); err == nil {
+		t.Error("无效的 CA 证书应返回错误")
+	}
+}
+
+func TestRegistryClientCert(t *testing.T) {
+	// 自签名的客户端证书，同时作为服务端信任的客户端 CA
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "docker-auth-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	// 使用 testRegistry 的处理，但要求并校验客户端证书
+	reg := newTestRegistry(t)
+	reg.putImage("app", "v1", `{"architecture":"amd64","os":"linux"}`)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(certificate)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(reg.serve))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0) // 未提供客户端证书时的握手错误
+	server.StartTLS()
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	client := NewClient()
+	if err := client.SetRegistryCACert(host, serverCA); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetDigest(host+"/app", "v1"); err == nil {
+		t.Fatal("未配置客户端证书时应返回错误")
+	}
+	if err := client.SetRegistryClientCert(host, certPEM, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetDigest(host+"/app", "v1"); err != nil {
+		t.Errorf("配置客户端证书后获取失败: %v", err)
+	}
+	if err := client.SetRegistryClientCert(host, certPEM, []byte("not a key")); err == nil {
+		t.Error("无效的客户端私钥应返回错误")
+	}
+}
@@ -0,0 +1,121 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTokenExpiry 认证响应未返回 expires_in 时使用的默认有效期
+// Docker Registry token 规范规定默认值为 60 秒
+const defaultTokenExpiry = 60 * time.Second
+
+// tokenResponse 表示认证服务器返回的 token 响应
+// expires_in 和 issued_at 使用原始 JSON 解析，以兼容不规范的 token 服务
+type tokenResponse struct {
+	Token        string          `json:"token"`
+	AccessToken  string          `json:"access_token"`
+	RefreshToken string          `json:"refresh_token"`
+	ExpiresIn    json.RawMessage `json:"expires_in"`
+	IssuedAt     json.RawMessage `json:"issued_at"`
+}
+
+// TokenInfo 表示认证服务签发的 bearer token 及其元数据
+type TokenInfo struct {
+	Token        string        // bearer token
+	RefreshToken string        // refresh token（仅部分 token 服务返回）
+	ExpiresIn    time.Duration // 规范化后的有效期，未返回时为默认的 60 秒
+	IssuedAt     time.Time     // 签发时间，未返回或无法解析时为本地获取时间
+}
+
+// ExpiresAt 返回 token 的过期时间
+func (t *TokenInfo) ExpiresAt() time.Time {
+	return t.IssuedAt.Add(t.ExpiresIn)
+}
+
+// Expired 判断 token 在 skew 时间之后是否已经过期
+// skew 用于预留请求耗时，避免 token 在使用过程中过期
+func (t *TokenInfo) Expired(skew time.Duration) bool {
+	return !time.Now().Add(skew).Before(t.ExpiresAt())
+}
+
+// parseTokenResponse 解析认证服务的 token 响应
+// 兼容以下不规范的情况：
+//   - 额外的未知字段
+//   - expires_in 为字符串（如 "300"）或浮点数
+//   - 缺少 expires_in 或 issued_at
+//   - 只返回 access_token 而没有 token
+func parseTokenResponse(body []byte) (*TokenInfo, error) {
+	var resp tokenResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("解析认证响应失败: %w", err)
+	}
+
+	// 优先使用 token 字段，如果没有则使用 access_token
+	token := resp.Token
+	if token == "" {
+		token = resp.AccessToken
+	}
+	if token == "" {
+		return nil, fmt.Errorf("认证响应中没有找到 token")
+	}
+
+	info := &TokenInfo{
+		Token:        token,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    defaultTokenExpiry,
+		IssuedAt:     time.Now(),
+	}
+
+	if seconds, ok := parseJSONNumber(resp.ExpiresIn); ok && seconds > 0 {
+		info.ExpiresIn = time.Duration(seconds * float64(time.Second))
+	}
+
+	if issuedAt, ok := parseJSONTime(resp.IssuedAt); ok {
+		// 签发时间明显晚于本地时间说明时钟不同步，此时使用本地时间
+		if !issuedAt.After(info.IssuedAt.Add(time.Minute)) {
+			info.IssuedAt = issuedAt
+		}
+	}
+
+	return info, nil
+}
+
+// parseJSONNumber 解析数字或数字字符串形式的 JSON 值
+func parseJSONNumber(raw json.RawMessage) (float64, bool) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return 0, false
+	}
+
+	var number float64
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return number, true
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil {
+		return 0, false
+	}
+	number, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
+
+// parseJSONTime 解析 RFC 3339 字符串形式的 JSON 时间
+func parseJSONTime(raw json.RawMessage) (time.Time, bool) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err != nil || text == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, text)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
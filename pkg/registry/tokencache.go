@@ -0,0 +1,237 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// tokenCacheSkew 从缓存取出的 token 至少还要有效的时间，预留请求耗时
+const tokenCacheSkew = 10 * time.Second
+
+// TokenCache 缓存认证服务签发的 bearer token
+// key 由客户端根据 registry、scope 集合和凭据指纹生成，不包含明文凭据
+// 实现需要保证并发安全
+type TokenCache interface {
+	// Get 返回缓存的 token，不存在时返回 false
+	Get(key string) (*TokenInfo, bool)
+	// Set 缓存 token
+	Set(key string, info *TokenInfo)
+}
+
+// WithTokenCache 设置 token 缓存，相同 registry、scope 和凭据的认证会复用未过期的 token
+// 为 nil 时不缓存（默认）
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithTokenCache(cache TokenCache) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokenCache = cache
+	return c
+}
+
+// getTokenCache 返回设置的 token 缓存，未设置时返回 nil
+func (c *Client) getTokenCache() TokenCache {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.tokenCache
+}
+
+// cachedToken 从 token 缓存中查找即将使用的 token，并记录命中情况
+// label 用于 Metrics 的 registry 标签
+func (c *Client) cachedToken(key, label string) (*TokenInfo, bool) {
+	cache := c.getTokenCache()
+	if cache == nil {
+		return nil, false
+	}
+	info, ok := cache.Get(key)
+	hit := ok && !info.Expired(tokenCacheSkew)
+	if metrics := c.getMetrics(); metrics != nil {
+		metrics.ObserveTokenCache(label, hit)
+	}
+	if !hit {
+		return nil, false
+	}
+	c.logger.Debug("使用缓存的 token", "registry", label, "expiresAt", info.ExpiresAt())
+	return info, true
+}
+
+// storeToken 将新获取的 token 写入缓存
+func (c *Client) storeToken(key string, info *TokenInfo) {
+	if cache := c.getTokenCache(); cache != nil && !info.Expired(tokenCacheSkew) {
+		cache.Set(key, info)
+	}
+}
+
+// registryTokenKey 返回向已注册 registry 的认证服务获取的 token 的缓存 key
+func (c *Client) registryTokenKey(ctx context.Context, registryKey, credentialKey string, scopes []string) string {
+	return "token|" + registryKey + "|" + c.credentialFingerprint(ctx, credentialKey) + "|" + scopeSetKey(scopes)
+}
+
+// challengeTokenKey 返回按 Bearer 质询获取的 token 的缓存 key
+func (c *Client) challengeTokenKey(ctx context.Context, realm, service, credentialKey string, scopes []string) string {
+	return "challenge|" + credentialKey + "|" + c.credentialFingerprint(ctx, credentialKey) + "|" + realm + "|" + service + "|" + scopeSetKey(scopes)
+}
+
+// credentialFingerprint 返回凭据的指纹，用于区分不同凭据获取的 token
+// 未配置凭据时返回空字符串
+func (c *Client) credentialFingerprint(ctx context.Context, credentialKey string) string {
+	cred, ok := c.credential(ctx, credentialKey)
+	if !ok || cred.Username == "" || cred.Token == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(cred.Username + ":" + cred.Token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// MemoryTokenCache 是进程内的 token 缓存
+type MemoryTokenCache struct {
+	mu      sync.Mutex
+	entries map[string]*TokenInfo
+}
+
+// NewMemoryTokenCache 创建进程内的 token 缓存，适用于长期运行的服务
+func NewMemoryTokenCache() *MemoryTokenCache {
+	return &MemoryTokenCache{entries: make(map[string]*TokenInfo)}
+}
+
+// Get 实现 TokenCache 接口
+func (m *MemoryTokenCache) Get(key string) (*TokenInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, ok := m.entries[key]
+	if ok && info.Expired(0) {
+		delete(m.entries, key)
+		return nil, false
+	}
+	return info, ok
+}
+
+// Set 实现 TokenCache 接口
+func (m *MemoryTokenCache) Set(key string, info *TokenInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = info
+}
+
+// FileTokenCache 将 token 持久化到磁盘的缓存，用于短时间内多次执行命令行工具时复用 token
+// 文件权限为 0600；写入时与文件中的现有内容合并，并清理已过期的 token
+type FileTokenCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]fileTokenEntry
+	loaded  bool
+}
+
+// fileTokenEntry 表示缓存文件中的一个 token
+type fileTokenEntry struct {
+	Token     string    `json:"token"`
+	IssuedAt  time.Time `json:"issuedAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// DefaultTokenCachePath 返回默认的 token 缓存文件路径
+// Linux 上为 ~/.cache/docker-manifest/tokens.json
+func DefaultTokenCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("获取缓存目录失败: %w", err)
+	}
+	return filepath.Join(dir, "docker-manifest", "tokens.json"), nil
+}
+
+// NewFileTokenCache 创建持久化到 path 的 token 缓存，path 为空时使用 DefaultTokenCachePath
+func NewFileTokenCache(path string) (*FileTokenCache, error) {
+	if path == "" {
+		var err error
+		if path, err = DefaultTokenCachePath(); err != nil {
+			return nil, err
+		}
+	}
+	return &FileTokenCache{path: path}, nil
+}
+
+// Path 返回缓存文件路径
+func (f *FileTokenCache) Path() string {
+	return f.path
+}
+
+// Get 实现 TokenCache 接口
+func (f *FileTokenCache) Get(key string) (*TokenInfo, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.loaded {
+		f.entries, _ = f.read()
+		f.loaded = true
+	}
+	entry, ok := f.entries[key]
+	if !ok || !time.Now().Before(entry.ExpiresAt) {
+		return nil, false
+	}
+	return &TokenInfo{
+		Token:     entry.Token,
+		IssuedAt:  entry.IssuedAt,
+		ExpiresIn: entry.ExpiresAt.Sub(entry.IssuedAt),
+	}, true
+}
+
+// Set 实现 TokenCache 接口
+// 写入失败（如目录不可写）时忽略，不影响认证流程
+func (f *FileTokenCache) Set(key string, info *TokenInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// 重新读取文件，合并其他进程写入的 token
+	entries, _ := f.read()
+	if entries == nil {
+		entries = make(map[string]fileTokenEntry)
+	}
+	entries[key] = fileTokenEntry{
+		Token:     info.Token,
+		IssuedAt:  info.IssuedAt,
+		ExpiresAt: info.ExpiresAt(),
+	}
+	now := time.Now()
+	for k, entry := range entries {
+		if !now.Before(entry.ExpiresAt) {
+			delete(entries, k)
+		}
+	}
+	f.entries = entries
+	f.loaded = true
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+	dir := filepath.Dir(f.path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return
+	}
+	writeFileAtomic(dir, f.path, data)
+}
+
+// read 读取缓存文件，文件不存在时返回空的缓存
+func (f *FileTokenCache) read() (map[string]fileTokenEntry, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(map[string]fileTokenEntry), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := make(map[string]fileTokenEntry)
+	if err := json.Unmarshal(data, &entries); err != nil {
+		// 文件损坏时丢弃，下次写入时覆盖
+		return make(map[string]fileTokenEntry), nil
+	}
+	return entries, nil
+}
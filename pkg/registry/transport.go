@@ -0,0 +1,471 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 传输方式，用作 CopyImage 和 ParseImageReference 中镜像引用的前缀（与 skopeo 的 transport 对应）
+const (
+	TransportRegistry      = "registry"       // registry 中的镜像，如 registry:ghcr.io/org/app:v1；不带前缀的引用都是 registry 中的镜像
+	TransportOCI           = "oci"            // OCI 镜像布局目录，如 oci:/data/layout:v1
+	TransportDockerArchive = "docker-archive" // docker save 格式的 tar 文件，如 docker-archive:/tmp/nginx.tar:nginx:1.27
+	TransportDockerDaemon  = "docker-daemon"  // Docker daemon 中的镜像，需要注册 pkg/dockerd 的 Daemon，如 docker-daemon:nginx:1.27
+	TransportContainerd    = "containerd"     // containerd 中的镜像，需要注册 pkg/containerd 的 Store，如 containerd:docker.io/library/nginx:1.27
+)
+
+// ErrUnknownTransport 表示镜像引用使用了未注册的传输方式
+var ErrUnknownTransport = errors.New("未注册的传输方式")
+
+// ImageReference 表示带传输方式前缀的镜像引用，由 ParseImageReference 解析
+type ImageReference struct {
+	Transport string // 传输方式，不带前缀时为 TransportRegistry
+	// Name 为 registry、docker-daemon 和 containerd 中的镜像名；oci 为布局目录；docker-archive 为 tar 文件路径
+	Name string
+	// Tag 为标签或 digest；oci 为 index.json 中的引用名；docker-archive 为归档中的镜像引用（如 nginx:1.27）；未指定时为空
+	Tag string
+}
+
+// ParseImageReference 解析带传输方式前缀的镜像引用：
+//
+//	nginx:1.27、registry:ghcr.io/org/app@sha256:...、docker://nginx   registry 中的镜像
+//	oci:/data/layout、oci:/data/layout:v1                             OCI 镜像布局目录，可以带引用名
+//	docker-archive:/tmp/nginx.tar、docker-archive:/tmp/nginx.tar:nginx:1.27  docker save 格式的 tar 文件，可以带镜像引用
+//	docker-daemon:nginx:1.27                                          Docker daemon 中的镜像
+//	containerd:docker.io/library/nginx:1.27                           containerd 中的镜像
+//
+// 前缀之后紧跟端口号时（如 registry:5000/app）视为 registry 地址而不是传输方式
+func ParseImageReference(ref string) (ImageReference, error) {
+	transport, rest := splitTransport(ref)
+	reference := ImageReference{Transport: transport}
+	switch transport {
+	case TransportOCI, TransportDockerArchive:
+		// 路径之后的第一个冒号分隔引用
+		reference.Name, reference.Tag, _ = strings.Cut(rest, ":")
+	default:
+		if name, digest, ok := strings.Cut(rest, "@"); ok {
+			reference.Name, reference.Tag = name, digest
+		} else {
+			reference.Name, reference.Tag, _ = splitImageTag(rest)
+		}
+	}
+	if reference.Name == "" {
+		return reference, fmt.Errorf("无效的镜像引用 '%s'", ref)
+	}
+	return reference, nil
+}
+
+// String 返回带传输方式前缀的镜像引用，registry 中的镜像不带前缀
+func (r ImageReference) String() string {
+	var prefix string
+	if r.Transport != TransportRegistry {
+		prefix = r.Transport + ":"
+	}
+	switch {
+	case r.Tag == "":
+		return prefix + r.Name
+	case r.Transport == TransportOCI || r.Transport == TransportDockerArchive:
+		return prefix + r.Name + ":" + r.Tag
+	default:
+		return prefix + imageReference(r.Name, r.Tag)
+	}
+}
+
+// splitTransport 拆分镜像引用的传输方式前缀，没有前缀时返回 TransportRegistry 和原始引用
+func splitTransport(ref string) (string, string) {
+	if rest, ok := strings.CutPrefix(ref, "docker://"); ok {
+		return TransportRegistry, rest
+	}
+	scheme, rest, ok := strings.Cut(ref, ":")
+	if !ok || isPort(rest) {
+		return TransportRegistry, ref
+	}
+	switch scheme {
+	case TransportRegistry, TransportOCI, TransportDockerArchive, TransportDockerDaemon, TransportContainerd:
+		return scheme, rest
+	}
+	return TransportRegistry, ref
+}
+
+// isPort 判断冒号之后的内容是否以端口号开头（如 "5000/app"）
+func isPort(rest string) bool {
+	port, _, _ := strings.Cut(rest, "/")
+	if port == "" {
+		return false
+	}
+	for _, r := range port {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ImageTransport 表示 registry 之外的镜像存储，通过 WithImageTransport 注册后可以作为 CopyImage 的源和目标
+// oci 和 docker-archive 为内置实现；pkg/dockerd 的 Daemon 和 pkg/containerd 的 Store 分别实现了 docker-daemon 和 containerd
+type ImageTransport interface {
+	// OpenSource 打开 name 中 tag 指向的镜像用于读取，镜像不存在时返回可通过 errors.Is(err, ErrNotFound) 判断的错误
+	OpenSource(ctx context.Context, name, tag string) (ImageSource, error)
+	// OpenDestination 打开 name 用于写入，tag 为写入完成后指向根 manifest 的标签
+	// 在写入第一个 blob 之前不应修改存储，试运行时只调用 HasBlob
+	OpenDestination(ctx context.Context, name, tag string) (ImageDestination, error)
+}
+
+// ImageSource 表示复制的源
+type ImageSource interface {
+	// Root 返回镜像的根 manifest（索引或单个 manifest）的描述符和内容
+	Root(ctx context.Context) (Descriptor, []byte, error)
+	// Manifest 返回索引引用的子 manifest 的内容，调用方校验大小和 digest
+	Manifest(ctx context.Context, desc Descriptor) ([]byte, error)
+	// Blob 打开 blob 的内容，由调用方关闭
+	Blob(ctx context.Context, desc Descriptor) (io.ReadCloser, error)
+	// Close 释放源占用的资源（如临时文件）
+	Close() error
+}
+
+// ImageDestination 表示复制的目标
+// 复制时先写入全部 blob，再从子 manifest 到根 manifest 依次写入，最后调用 Commit
+type ImageDestination interface {
+	// SupportsIndex 返回目标能否保存多架构索引，不能时（如 docker-archive）需要在复制时指定平台
+	SupportsIndex() bool
+	// HasBlob 返回目标中是否已存在该 blob，已存在的 blob 不会重复写入
+	HasBlob(ctx context.Context, desc Descriptor) (bool, error)
+	// PutBlob 写入 blob，实现应校验内容的大小和 digest
+	PutBlob(ctx context.Context, desc Descriptor, content io.Reader) error
+	// PutManifest 写入 manifest，root 为 true 时为根 manifest，Commit 后标签指向它
+	PutManifest(ctx context.Context, desc Descriptor, body []byte, root bool) error
+	// Commit 完成写入（如更新 index.json、导入 Docker daemon），未调用 Commit 时 Close 应丢弃未完成的写入
+	Commit(ctx context.Context) error
+	// Close 释放目标占用的资源
+	Close() error
+}
+
+// ImageDigestResolver 可以由 ImageTransport 实现，不读取镜像内容直接返回 name:tag 的 manifest digest，用于同步时比较源和目标
+// 无法确定 digest 时返回空字符串，镜像不存在时返回可通过 errors.Is(err, ErrNotFound) 判断的错误
+type ImageDigestResolver interface {
+	ResolveDigest(ctx context.Context, name, tag string) (string, error)
+}
+
+// WithImageTransport 注册传输方式 scheme 的实现，之后 CopyImage 的源和目标可以使用 "scheme:" 前缀
+// scheme 通常为 TransportDockerDaemon 或 TransportContainerd，也可以替换内置的 oci 和 docker-archive：
+//
+//	daemon, _ := dockerd.New(dockerd.Options{})
+//	client.WithImageTransport(registry.TransportDockerDaemon, daemon)
+//
+// transport 为 nil 时移除注册
+// 返回 Client 本身以支持链式调用
+func (c *Client) WithImageTransport(scheme string, transport ImageTransport) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if transport == nil {
+		delete(c.imageTransports, scheme)
+		return c
+	}
+	if c.imageTransports == nil {
+		c.imageTransports = make(map[string]ImageTransport)
+	}
+	c.imageTransports[scheme] = transport
+	return c
+}
+
+// imageTransport 返回传输方式的实现：优先使用注册的实现，oci 和 docker-archive 未注册时使用内置实现
+func (c *Client) imageTransport(scheme string) (ImageTransport, error) {
+	c.mu.RLock()
+	transport, ok := c.imageTransports[scheme]
+	c.mu.RUnlock()
+	if ok {
+		return transport, nil
+	}
+	switch scheme {
+	case TransportOCI:
+		return ociTransport{}, nil
+	case TransportDockerArchive:
+		return dockerArchiveTransport{}, nil
+	case TransportDockerDaemon, TransportContainerd:
+		return nil, fmt.Errorf("%w '%s'，需要先通过 WithImageTransport 注册", ErrUnknownTransport, scheme)
+	}
+	return nil, fmt.Errorf("%w '%s'", ErrUnknownTransport, scheme)
+}
+
+// openImageSource 打开镜像引用对应的源
+func (c *Client) openImageSource(ctx context.Context, ref ImageReference) (ImageSource, error) {
+	if ref.Transport == TransportRegistry {
+		source, err := c.openRegistrySource(ctx, ref.Name, ref.Tag)
+		if err != nil {
+			return nil, err
+		}
+		return source, nil
+	}
+	transport, err := c.imageTransport(ref.Transport)
+	if err != nil {
+		return nil, err
+	}
+	return transport.OpenSource(ctx, ref.Name, ref.Tag)
+}
+
+// openImageDestination 打开镜像引用对应的目标，dryRun 为 true 时 registry 目标只需要 pull 权限
+func (c *Client) openImageDestination(ctx context.Context, ref ImageReference, dryRun bool) (ImageDestination, error) {
+	if ref.Transport == TransportRegistry {
+		destination, err := c.openRegistryDestination(ctx, ref.Name, ref.Tag, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		return destination, nil
+	}
+	transport, err := c.imageTransport(ref.Transport)
+	if err != nil {
+		return nil, err
+	}
+	return transport.OpenDestination(ctx, ref.Name, ref.Tag)
+}
+
+// ResolveImageDigest 返回镜像引用（可以带传输方式前缀）指向的根 manifest digest，用于比较不同存储中的镜像
+// 传输方式实现了 ImageDigestResolver 时直接查询，否则打开镜像读取根 manifest；无法确定 digest 时返回空字符串
+// 镜像不存在时返回可通过 errors.Is(err, ErrNotFound) 判断的错误
+func (c *Client) ResolveImageDigest(ctx context.Context, ref ImageReference) (string, error) {
+	if ref.Transport != TransportRegistry {
+		transport, err := c.imageTransport(ref.Transport)
+		if err != nil {
+			return "", err
+		}
+		if resolver, ok := transport.(ImageDigestResolver); ok {
+			return resolver.ResolveDigest(ctx, ref.Name, ref.Tag)
+		}
+	}
+	source, err := c.openImageSource(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	root, _, err := source.Root(ctx)
+	if err != nil {
+		return "", err
+	}
+	return root.Digest, nil
+}
+
+// CopyImageReference 与 CopyImageWithOptions 相同，但源和目标为镜像引用，可以是 registry、OCI 布局目录、docker-archive、
+// Docker daemon 或 containerd 中的镜像，见 ParseImageReference
+//
+//	src, _ := registry.ParseImageReference("nginx:1.27")
+//	dst, _ := registry.ParseImageReference("oci:/data/layout:1.27")
+//	result, err := client.CopyImageReference(ctx, src, dst, registry.CopyOptions{})
+//
+// 源和目标都是 registry 时与 CopyImageWithOptions 完全相同（包括跨仓库挂载）；
+// 否则逐个 blob 从源读取后写入目标，目标中已存在的 blob 跳过，CopyResult 中挂载数始终为 0
+// docker-archive 和 docker-daemon 只能保存单个平台的镜像，源为多架构镜像且未指定 opts.Platform 时选择源 registry 配置的默认平台（未配置时为 linux/amd64）
+// docker-archive 源中的镜像只有各层的 tar，复制时生成新的 OCI manifest，digest 与原始 registry 中的不同
+func (c *Client) CopyImageReference(ctx context.Context, src, dst ImageReference, opts CopyOptions) (*CopyResult, error) {
+	if src.Transport == TransportRegistry && dst.Transport == TransportRegistry {
+		if src.Tag == "" {
+			src.Tag = defaultTag(DetectRegistry(src.Name), "")
+		}
+		return c.CopyImageWithOptions(ctx, src.Name, src.Tag, dst.Name, dst.Tag, opts)
+	}
+	return c.copyBetweenTransports(ctx, src, dst, opts)
+}
+
+// copyBetweenTransports 通过 ImageSource 和 ImageDestination 复制镜像，用于源或目标不是 registry 的情况
+func (c *Client) copyBetweenTransports(ctx context.Context, src, dst ImageReference, opts CopyOptions) (*CopyResult, error) {
+	dst.Tag = destinationTag(src, dst)
+	result := &CopyResult{Source: src.String(), Destination: dst.String(), DryRun: opts.DryRun}
+
+	source, err := c.openImageSource(ctx, src)
+	if err != nil {
+		return result, err
+	}
+	defer source.Close()
+	root, body, err := source.Root(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	// registry 之外的目标在选择平台前打开，不能保存索引时（docker-archive、docker-daemon）默认选择单个平台；
+	// registry 目标的标签可能取决于所选 manifest 的 digest，在选择平台后打开
+	var destination ImageDestination
+	if dst.Transport != TransportRegistry {
+		if destination, err = c.openImageDestination(ctx, dst, opts.DryRun); err != nil {
+			return result, err
+		}
+		defer destination.Close()
+		if opts.Platform == "" && IsIndexMediaType(root.MediaType) && !destination.SupportsIndex() {
+			opts.Platform = singlePlatform(src)
+			c.logger.Debug("目标只能保存单个平台的镜像，使用默认平台", "destination", result.Destination, "platform", opts.Platform)
+		}
+	}
+	if opts.Platform != "" && IsIndexMediaType(root.MediaType) {
+		if root, body, err = c.selectSourcePlatform(ctx, source, root, body, opts.Platform); err != nil {
+			return result, err
+		}
+	}
+	if dst.Tag == "" && dst.Transport == TransportRegistry {
+		dst.Tag = root.Digest
+		result.Destination = dst.String()
+	}
+
+	readManifest := func(desc Descriptor) ([]byte, error) {
+		if desc.Digest == root.Digest {
+			return body, nil
+		}
+		content, err := source.Manifest(ctx, desc)
+		if err != nil {
+			return nil, err
+		}
+		return content, checkContent(desc, content)
+	}
+	manifests, blobs, err := collectImage(root, c.getIndexLimits(), readManifest)
+	if err != nil {
+		return result, err
+	}
+	result.Digest = root.Digest
+	result.Blobs = len(blobs)
+	if strings.Contains(dst.Tag, ":") && dst.Transport == TransportRegistry && dst.Tag != result.Digest {
+		return result, fmt.Errorf("digest '%s' 与源镜像的 manifest 不符 (%s)", dst.Tag, result.Digest)
+	}
+
+	if destination == nil {
+		if destination, err = c.openImageDestination(ctx, dst, opts.DryRun); err != nil {
+			return result, err
+		}
+		defer destination.Close()
+	}
+	if IsIndexMediaType(root.MediaType) && !destination.SupportsIndex() {
+		return result, fmt.Errorf("%s 只能保存单个平台的镜像，%s 为多架构镜像，需要指定平台", dst.Transport, result.Source)
+	}
+
+	for i, blob := range blobs {
+		action, err := copyTransportBlob(ctx, source, destination, blob, opts.DryRun)
+		if err != nil {
+			return result, fmt.Errorf("复制 blob %s 失败: %w", blob.Digest, err)
+		}
+		result.addBlob(action, blob.Size)
+		if opts.Progress != nil {
+			opts.Progress(CopyProgress{Blob: blob, Action: action, Done: i + 1, Total: len(blobs)})
+		}
+	}
+	if opts.DryRun {
+		result.Manifests = len(manifests)
+		return result, nil
+	}
+
+	// 先写入子 manifest，再写入引用它们的索引
+	for i := len(manifests) - 1; i >= 0; i-- {
+		manifest := manifests[i]
+		if err := destination.PutManifest(ctx, manifest.desc, manifest.body, i == 0); err != nil {
+			return result, fmt.Errorf("写入 manifest %s 失败: %w", manifest.desc.Digest, err)
+		}
+		result.Manifests++
+	}
+	if err := destination.Commit(ctx); err != nil {
+		return result, err
+	}
+
+	c.logger.Debug("镜像复制完成",
+		"source", result.Source,
+		"destination", result.Destination,
+		"digest", result.Digest,
+		"existing", result.BlobsExisting,
+		"uploaded", result.BlobsUploaded)
+	return result, nil
+}
+
+// destinationTag 返回目标未指定标签时使用的标签：
+// docker-archive 目标使用源镜像的 name:tag 作为归档中的镜像引用，其他目标使用源镜像的标签（源为 docker-archive 时为其镜像引用中的标签）
+// 源没有可用的标签时返回空字符串，registry 目标随后按 digest 推送
+func destinationTag(src, dst ImageReference) string {
+	if dst.Tag != "" || src.Tag == "" {
+		return dst.Tag
+	}
+	switch {
+	case dst.Transport == TransportDockerArchive:
+		if src.Transport == TransportOCI || src.Transport == TransportDockerArchive || strings.Contains(src.Tag, ":") {
+			return ""
+		}
+		return src.Name + ":" + src.Tag
+	case src.Transport == TransportDockerArchive:
+		_, tag, _ := splitImageTag(src.Tag)
+		return tag
+	default:
+		return src.Tag
+	}
+}
+
+// singlePlatform 返回复制到只能保存单个平台的目标时默认选择的平台：源 registry 配置的默认平台，未配置时为 linux/amd64
+func singlePlatform(src ImageReference) string {
+	if src.Transport == TransportRegistry {
+		if config, ok := GetRegistry(DetectRegistry(src.Name)); ok && config.DefaultPlatform != "" {
+			return config.DefaultPlatform
+		}
+	}
+	return "linux/amd64"
+}
+
+// selectSourcePlatform 从源的索引中选择平台对应的 manifest
+func (c *Client) selectSourcePlatform(ctx context.Context, source ImageSource, root Descriptor, body []byte, platformName string) (Descriptor, []byte, error) {
+	platform, err := ParsePlatform(platformName)
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+	var index ImageIndex
+	if err := json.Unmarshal(body, &index); err != nil {
+		return Descriptor{}, nil, fmt.Errorf("解析镜像索引失败: %w", err)
+	}
+	if err := c.getIndexLimits().checkChildren(root.Digest, &index); err != nil {
+		return Descriptor{}, nil, err
+	}
+	desc, err := selectPlatformManifest(&index, platform)
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+	content, err := source.Manifest(ctx, *desc)
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+	if err := checkContent(*desc, content); err != nil {
+		return Descriptor{}, nil, err
+	}
+	return Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size}, content, nil
+}
+
+// copyTransportBlob 将 blob 从源复制到目标，返回处理方式: CopyBlobExisting、CopyBlobUploaded 或试运行时的 CopyBlobPending
+func copyTransportBlob(ctx context.Context, source ImageSource, destination ImageDestination, blob Descriptor, dryRun bool) (string, error) {
+	exists, err := destination.HasBlob(ctx, blob)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case exists:
+		return CopyBlobExisting, nil
+	case dryRun:
+		return CopyBlobPending, nil
+	}
+
+	// 目标为 registry 时传入可以重复打开的内容，存储后端跳过后改为通过 registry 上传时重新读取源
+	if target, ok := destination.(*registryDestination); ok {
+		open := func() (io.Reader, error) {
+			return source.Blob(ctx, blob)
+		}
+		return CopyBlobUploaded, target.storeBlob(ctx, blob, open)
+	}
+	content, err := source.Blob(ctx, blob)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+	return CopyBlobUploaded, destination.PutBlob(ctx, blob, content)
+}
+
+// checkContent 校验 manifest 的大小和 digest（只校验 sha256）
+func checkContent(desc Descriptor, content []byte) error {
+	if desc.Size > 0 && int64(len(content)) != desc.Size {
+		return fmt.Errorf("manifest %s 大小不匹配: 期望 %d 字节，实际 %d 字节", desc.Digest, desc.Size, len(content))
+	}
+	if strings.HasPrefix(desc.Digest, "sha256:") && computeDigest(content) != desc.Digest {
+		return fmt.Errorf("manifest digest 不匹配: 期望 %s，实际 %s", desc.Digest, computeDigest(content))
+	}
+	return nil
+}
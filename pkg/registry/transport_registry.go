@@ -0,0 +1,143 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// registrySource 表示 registry 中作为复制源的镜像
+type registrySource struct {
+	c             *Client
+	target        *registryTarget
+	authorization string
+	reference     string
+}
+
+// openRegistrySource 打开 registry 中的镜像用于读取，reference 为空时使用 registry 配置的默认标签
+func (c *Client) openRegistrySource(ctx context.Context, image, reference string) (*registrySource, error) {
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	return &registrySource{c: c, target: target, authorization: authorization, reference: defaultTag(target.registryKey, reference)}, nil
+}
+
+// Root 实现 ImageSource 接口
+func (s *registrySource) Root(ctx context.Context) (Descriptor, []byte, error) {
+	root, err := s.c.fetchManifest(ctx, s.target, s.authorization, s.reference)
+	if newAuthorization, ok := s.c.reauthorize(ctx, s.target, err); ok {
+		s.authorization = newAuthorization
+		root, err = s.c.fetchManifest(ctx, s.target, s.authorization, s.reference)
+	}
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+	return Descriptor{MediaType: root.mediaType, Digest: computeDigest(root.body), Size: int64(len(root.body))}, root.body, nil
+}
+
+// Manifest 实现 ImageSource 接口
+func (s *registrySource) Manifest(ctx context.Context, desc Descriptor) ([]byte, error) {
+	manifest, err := s.c.fetchManifest(ctx, s.target, s.authorization, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return manifest.body, nil
+}
+
+// Blob 实现 ImageSource 接口
+func (s *registrySource) Blob(ctx context.Context, desc Descriptor) (io.ReadCloser, error) {
+	return s.c.openBlob(ctx, s.target, s.authorization, desc.Digest)
+}
+
+// Close 实现 ImageSource 接口
+func (s *registrySource) Close() error {
+	return nil
+}
+
+// registryDestination 表示 registry 中作为复制目标的仓库
+type registryDestination struct {
+	c             *Client
+	target        *registryTarget
+	authorization string
+	tag           string
+}
+
+// openRegistryDestination 打开 registry 中的仓库用于写入；dryRun 为 true 时只获取 pull 权限，只读模式下同样可用
+func (c *Client) openRegistryDestination(ctx context.Context, image, tag string, dryRun bool) (*registryDestination, error) {
+	if err := c.checkImagePolicy(image); err != nil {
+		return nil, err
+	}
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return nil, err
+	}
+	var authorization string
+	if dryRun {
+		authorization, err = c.authorize(ctx, target)
+	} else {
+		if err := c.checkReadOnly(http.MethodPut, target, "manifests/"+tag); err != nil {
+			return nil, err
+		}
+		authorization, err = c.authorizePush(ctx, target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &registryDestination{c: c, target: target, authorization: authorization, tag: tag}, nil
+}
+
+// SupportsIndex 实现 ImageDestination 接口
+func (d *registryDestination) SupportsIndex() bool {
+	return true
+}
+
+// HasBlob 实现 ImageDestination 接口
+func (d *registryDestination) HasBlob(ctx context.Context, desc Descriptor) (bool, error) {
+	return d.c.blobExists(ctx, d.target, d.authorization, desc.Digest)
+}
+
+// PutBlob 实现 ImageDestination 接口，内容只能读取一次，存储后端跳过该 blob 时返回错误
+func (d *registryDestination) PutBlob(ctx context.Context, desc Descriptor, content io.Reader) error {
+	opened := false
+	return d.storeBlob(ctx, desc, func() (io.Reader, error) {
+		if opened {
+			return nil, fmt.Errorf("blob %s 的内容已被读取，无法重新上传", desc.Digest)
+		}
+		opened = true
+		return content, nil
+	})
+}
+
+// storeBlob 上传 blob，open 可能被调用多次
+func (d *registryDestination) storeBlob(ctx context.Context, desc Descriptor, open blobOpener) error {
+	return d.c.storeBlob(ctx, d.target, d.authorization, desc, open)
+}
+
+// PutManifest 实现 ImageDestination 接口，根 manifest 按标签推送，其他按 digest 推送
+func (d *registryDestination) PutManifest(ctx context.Context, desc Descriptor, body []byte, root bool) error {
+	reference := desc.Digest
+	if root {
+		reference = d.tag
+	}
+	_, err := d.c.putManifest(ctx, d.target, d.authorization, reference, desc.MediaType, body)
+	return err
+}
+
+// Commit 实现 ImageDestination 接口，推送根 manifest 后复制即完成
+func (d *registryDestination) Commit(ctx context.Context) error {
+	return nil
+}
+
+// Close 实现 ImageDestination 接口
+func (d *registryDestination) Close() error {
+	return nil
+}
@@ -0,0 +1,353 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want ImageReference
+	}{
+		{"nginx:1.27", ImageReference{TransportRegistry, "nginx", "1.27"}},
+		{"nginx", ImageReference{TransportRegistry, "nginx", ""}},
+		{"registry:ghcr.io/org/app@sha256:abc", ImageReference{TransportRegistry, "ghcr.io/org/app", "sha256:abc"}},
+		{"docker://nginx:1.27", ImageReference{TransportRegistry, "nginx", "1.27"}},
+		{"registry:5000/app:v1", ImageReference{TransportRegistry, "registry:5000/app", "v1"}},
+		{"oci:/data/layout", ImageReference{TransportOCI, "/data/layout", ""}},
+		{"oci:/data/layout:v1", ImageReference{TransportOCI, "/data/layout", "v1"}},
+		{"docker-archive:/tmp/nginx.tar:nginx:1.27", ImageReference{TransportDockerArchive, "/tmp/nginx.tar", "nginx:1.27"}},
+		{"docker-daemon:nginx:1.27", ImageReference{TransportDockerDaemon, "nginx", "1.27"}},
+		{"containerd:docker.io/library/nginx@sha256:abc", ImageReference{TransportContainerd, "docker.io/library/nginx", "sha256:abc"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseImageReference(tt.ref)
+		if err != nil {
+			t.Errorf("ParseImageReference(%q) 错误: %v", tt.ref, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseImageReference(%q) = %+v, 期望 %+v", tt.ref, got, tt.want)
+		}
+	}
+	for _, ref := range []string{"oci:", "docker-daemon:"} {
+		if _, err := ParseImageReference(ref); err == nil {
+			t.Errorf("ParseImageReference(%q) 应返回错误", ref)
+		}
+	}
+}
+
+func TestImageReferenceString(t *testing.T) {
+	for _, ref := range []string{"nginx:1.27", "oci:/data/layout:v1", "docker-archive:/tmp/a.tar:nginx:1.27", "containerd:docker.io/library/nginx:1.27"} {
+		parsed, err := ParseImageReference(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := parsed.String(); got != ref {
+			t.Errorf("String() = %s, 期望 %s", got, ref)
+		}
+	}
+}
+
+func TestUnregisteredTransport(t *testing.T) {
+	src, _ := ParseImageReference("docker-daemon:nginx:1.27")
+	dst, _ := ParseImageReference("oci:" + t.TempDir())
+	_, err := NewClient().CopyImageReference(context.Background(), src, dst, CopyOptions{})
+	if !errors.Is(err, ErrUnknownTransport) {
+		t.Errorf("错误 = %v, 期望 ErrUnknownTransport", err)
+	}
+}
+
+// testLayoutImage 在 OCI 布局目录中写入单层镜像，返回其 manifest 的描述符
+func testLayoutImage(t *testing.T, dir, tag string) Descriptor {
+	t.Helper()
+	ctx := context.Background()
+	destination, err := ociTransport{}.OpenDestination(ctx, dir, tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destination.Close()
+	put := func(mediaType string, content []byte) Descriptor {
+		desc := Descriptor{MediaType: mediaType, Digest: computeDigest(content), Size: int64(len(content))}
+		if err := destination.PutBlob(ctx, desc, strings.NewReader(string(content))); err != nil {
+			t.Fatal(err)
+		}
+		return desc
+	}
+	manifest := ImageManifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIManifest,
+		Config:        put(MediaTypeOCIConfig, []byte(`{"architecture":"amd64","os":"linux"}`)),
+		Layers:        []Descriptor{put(MediaTypeOCILayer, testLayerTar(t))},
+	}
+	body, _ := json.Marshal(manifest)
+	root := Descriptor{MediaType: MediaTypeOCIManifest, Digest: computeDigest(body), Size: int64(len(body))}
+	if err := destination.PutManifest(ctx, root, body, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := destination.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestCopyOCIToOCI(t *testing.T) {
+	src := t.TempDir()
+	root := testLayoutImage(t, src, "v1")
+	dst := filepath.Join(t.TempDir(), "layout")
+
+	client := NewClient()
+	result, err := client.CopyImageReference(context.Background(),
+		ImageReference{TransportOCI, src, "v1"}, ImageReference{TransportOCI, dst, ""}, CopyOptions{})
+	if err != nil {
+		t.Fatalf("CopyImageReference: %v", err)
+	}
+	if result.Digest != root.Digest || result.BlobsUploaded != 2 || result.Manifests != 1 {
+		t.Errorf("结果 = %+v, 期望 digest %s、上传 2 个 blob、1 个 manifest", result, root.Digest)
+	}
+	// 目标未指定引用名时使用源的引用名
+	desc, _, err := selectLayoutManifest(dst, "v1")
+	if err != nil {
+		t.Fatalf("目标中没有 v1: %v", err)
+	}
+	if desc.Digest != root.Digest {
+		t.Errorf("v1 = %s, 期望 %s", desc.Digest, root.Digest)
+	}
+
+	// 再次复制时 blob 已存在，index.json 中的同名条目被替换
+	result, err = client.CopyImageReference(context.Background(),
+		ImageReference{TransportOCI, src, "v1"}, ImageReference{TransportOCI, dst, "v1"}, CopyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BlobsExisting != 2 {
+		t.Errorf("已存在的 blob = %d, 期望 2", result.BlobsExisting)
+	}
+	index, err := readLayoutIndex(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 1 {
+		t.Errorf("index.json 中有 %d 个 manifest, 期望 1", len(index.Manifests))
+	}
+}
+
+func TestCopyDockerArchiveRoundTrip(t *testing.T) {
+	layout := t.TempDir()
+	root := testLayoutImage(t, layout, "v1")
+	archive := filepath.Join(t.TempDir(), "app.tar")
+	ctx := context.Background()
+	client := NewClient()
+
+	if _, err := client.CopyImageReference(ctx,
+		ImageReference{TransportOCI, layout, "v1"}, ImageReference{TransportDockerArchive, archive, "example.com/app:v1"}, CopyOptions{}); err != nil {
+		t.Fatalf("复制到 docker-archive: %v", err)
+	}
+	if _, err := os.Stat(archive); err != nil {
+		t.Fatalf("docker-archive 未创建: %v", err)
+	}
+	if matches, _ := filepath.Glob(archive + ".*.tmp"); len(matches) > 0 {
+		t.Errorf("临时文件未删除: %v", matches)
+	}
+
+	for _, reference := range []string{"", "example.com/app:v1", "v1"} {
+		source, err := NewDockerArchiveSource(archive, reference)
+		if err != nil {
+			t.Fatalf("NewDockerArchiveSource(%q): %v", reference, err)
+		}
+		source.Close()
+	}
+	if _, err := NewDockerArchiveSource(archive, "other:v1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("不存在的镜像错误 = %v, 期望 ErrNotFound", err)
+	}
+
+	// 归档中的层未压缩，生成的 manifest 与源相同
+	back := t.TempDir()
+	result, err := client.CopyImageReference(ctx,
+		ImageReference{TransportDockerArchive, archive, "example.com/app:v1"}, ImageReference{TransportOCI, back, ""}, CopyOptions{})
+	if err != nil {
+		t.Fatalf("从 docker-archive 复制: %v", err)
+	}
+	if result.Digest != root.Digest {
+		t.Errorf("digest = %s, 期望 %s", result.Digest, root.Digest)
+	}
+	if _, _, err := selectLayoutManifest(back, "v1"); err != nil {
+		t.Errorf("目标中没有 v1: %v", err)
+	}
+}
+
+func TestDockerArchiveRejectsIndex(t *testing.T) {
+	destination, err := NewDockerArchiveDestination(filepath.Join(t.TempDir(), "a.tar"), "app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destination.Close()
+	if destination.SupportsIndex() {
+		t.Error("SupportsIndex() = true, 期望 false")
+	}
+	if err := destination.PutManifest(context.Background(), Descriptor{MediaType: MediaTypeOCIIndex}, []byte(`{}`), true); err == nil {
+		t.Error("写入索引应返回错误")
+	}
+	if _, err := NewDockerArchiveDestination("a.tar", "app@sha256:abc"); err == nil {
+		t.Error("digest 引用应返回错误")
+	}
+}
+
+func TestLayoutBlobCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	desc := Descriptor{MediaType: MediaTypeOCILayer, Digest: computeDigest([]byte("hello")), Size: 5}
+	if err := writeLayoutBlob(dir, desc, strings.NewReader("world")); err == nil {
+		t.Error("digest 不匹配时应返回错误")
+	}
+	if err := writeLayoutBlob(dir, desc, strings.NewReader("hi")); err == nil {
+		t.Error("大小不匹配时应返回错误")
+	}
+	if err := writeLayoutBlob(dir, Descriptor{Digest: "sha256:../../etc", Size: 0}, strings.NewReader("")); err == nil {
+		t.Error("无效的 digest 应返回错误")
+	}
+
+	// 无法校验的 digest 算法返回错误，不写入文件
+	sha512Digest := "sha512:" + strings.Repeat("cd", 64)
+	if err := writeLayoutBlob(dir, Descriptor{Digest: sha512Digest, Size: 5}, strings.NewReader("world")); err == nil {
+		t.Error("sha512 的 digest 应返回错误")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "blobs", "sha512")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("sha512 的 blob 目录不应被创建: %v", err)
+	}
+}
+
+func TestDockerArchiveBlobUnverifiable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.tar")
+	destination, err := NewDockerArchiveDestination(path, "app:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destination.Close()
+	ctx := context.Background()
+
+	sha512Digest := "sha512:" + strings.Repeat("cd", 64)
+	if err := destination.PutBlob(ctx, Descriptor{Digest: sha512Digest, Size: 5}, strings.NewReader("world")); err == nil {
+		t.Error("sha512 的 digest 应返回错误")
+	}
+	if ok, _ := destination.HasBlob(ctx, Descriptor{Digest: sha512Digest, Size: 5}); ok {
+		t.Error("未校验的 blob 不应视为已写入")
+	}
+	desc := Descriptor{MediaType: MediaTypeOCILayer, Digest: computeDigest([]byte("hello")), Size: 5}
+	if err := destination.PutBlob(ctx, desc, strings.NewReader("world")); err == nil {
+		t.Error("digest 不匹配时应返回错误")
+	}
+}
+
+func TestLayoutBlobPath(t *testing.T) {
+	dir := t.TempDir()
+	sha256Hex := strings.Repeat("ab", 32)
+	valid := []struct {
+		digest string
+		want   string
+	}{
+		{"sha256:" + sha256Hex, filepath.Join(dir, "blobs", "sha256", sha256Hex)},
+		{"sha512:" + strings.Repeat("cd", 64), filepath.Join(dir, "blobs", "sha512", strings.Repeat("cd", 64))},
+		{"sha256+b64u:LCa0a2j_xo_5m0U8HTBBNBNCLXBkg7-g-YpeiGJm564=", filepath.Join(dir, "blobs", "sha256+b64u", "LCa0a2j_xo_5m0U8HTBBNBNCLXBkg7-g-YpeiGJm564=")},
+	}
+	for _, tt := range valid {
+		got, err := layoutBlobPath(dir, tt.digest)
+		if err != nil {
+			t.Errorf("layoutBlobPath(%q) 错误: %v", tt.digest, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("layoutBlobPath(%q) = %s, 期望 %s", tt.digest, got, tt.want)
+		}
+	}
+
+	invalid := []string{
+		"sha256:../../etc/passwd",
+		"../sha256:" + sha256Hex,
+		"sha256:" + sha256Hex[:62] + "/x",
+		`sha256:` + sha256Hex[:62] + `\x`,
+		"sha256:..",
+		"sha256:",
+		":" + sha256Hex,
+		sha256Hex,
+		"sha256:" + strings.ToUpper(sha256Hex),
+		"sha256:abc",
+		"sha256:" + strings.Repeat("zz", 32),
+		"SHA256:" + sha256Hex,
+		".:abc",
+		"a..b:abc",
+		"sha256-:abc",
+		"sha512:abc.def",
+	}
+	for _, digest := range invalid {
+		if got, err := layoutBlobPath(dir, digest); err == nil {
+			t.Errorf("layoutBlobPath(%q) = %s, 期望返回错误", digest, got)
+		}
+		if got, err := archiveBlobName(digest); err == nil {
+			t.Errorf("archiveBlobName(%q) = %s, 期望返回错误", digest, got)
+		}
+	}
+}
+
+func TestCopyIndexToDockerArchiveSelectsPlatform(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	destination, err := ociTransport{}.OpenDestination(ctx, dir, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(mediaType string, content []byte) Descriptor {
+		desc := Descriptor{MediaType: mediaType, Digest: computeDigest(content), Size: int64(len(content))}
+		if err := destination.PutBlob(ctx, desc, strings.NewReader(string(content))); err != nil {
+			t.Fatal(err)
+		}
+		return desc
+	}
+	index := ImageIndex{SchemaVersion: 2, MediaType: MediaTypeOCIIndex}
+	children := make(map[string]string)
+	for _, arch := range []string{"arm64", "amd64"} {
+		manifest := ImageManifest{
+			SchemaVersion: 2,
+			MediaType:     MediaTypeOCIManifest,
+			Config:        put(MediaTypeOCIConfig, []byte(`{"architecture":"`+arch+`","os":"linux"}`)),
+			Layers:        []Descriptor{put(MediaTypeOCILayer, []byte("layer-"+arch))},
+		}
+		body, _ := json.Marshal(manifest)
+		desc := put(MediaTypeOCIManifest, body)
+		desc.Platform = &Platform{OS: "linux", Architecture: arch}
+		index.Manifests = append(index.Manifests, desc)
+		children[arch] = desc.Digest
+	}
+	body, _ := json.Marshal(index)
+	root := Descriptor{MediaType: MediaTypeOCIIndex, Digest: computeDigest(body), Size: int64(len(body))}
+	if err := destination.PutManifest(ctx, root, body, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := destination.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "app.tar")
+	result, err := NewClient().CopyImageReference(ctx,
+		ImageReference{TransportOCI, dir, "v1"}, ImageReference{TransportDockerArchive, archive, "app:v1"}, CopyOptions{})
+	if err != nil {
+		t.Fatalf("CopyImageReference: %v", err)
+	}
+	if result.Digest != children["amd64"] {
+		t.Errorf("digest = %s, 期望 linux/amd64 的 manifest %s", result.Digest, children["amd64"])
+	}
+
+	result, err = NewClient().CopyImageReference(ctx,
+		ImageReference{TransportOCI, dir, "v1"}, ImageReference{TransportDockerArchive, archive, "app:v1"}, CopyOptions{Platform: "linux/arm64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Digest != children["arm64"] {
+		t.Errorf("digest = %s, 期望 linux/arm64 的 manifest %s", result.Digest, children["arm64"])
+	}
+}
@@ -0,0 +1,76 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+)
+
+// 本地镜像的更新状态
+const (
+	UpdateStatusUpToDate  = "up_to_date"       // 本地 digest 与远程一致
+	UpdateStatusAvailable = "update_available" // 远程标签已指向新的 digest
+	UpdateStatusUnknown   = "unknown"          // 本地镜像没有记录 registry digest（如本地构建或 docker load 导入），无法比较
+	UpdateStatusFailed    = "failed"           // 获取远程 digest 失败
+)
+
+// LocalImage 表示本地（Docker daemon、containerd 等）存在的镜像标签
+type LocalImage struct {
+	Image   string   `json:"image"`             // 镜像名称，如 nginx、ghcr.io/org/app
+	Tag     string   `json:"tag"`               // 标签
+	ID      string   `json:"id,omitempty"`      // 本地镜像 ID
+	Digests []string `json:"digests,omitempty"` // 本地记录的该仓库的 manifest digest（拉取时的索引或 manifest digest），可能有多个
+}
+
+// ImageUpdate 表示本地镜像与远程标签的比较结果
+type ImageUpdate struct {
+	LocalImage
+	RemoteDigest string `json:"remoteDigest,omitempty"` // 远程标签当前指向的 digest
+	Status       string `json:"status"`                 // UpdateStatusUpToDate、UpdateStatusAvailable、UpdateStatusUnknown 或 UpdateStatusFailed
+	Error        error  `json:"-"`                      // 获取远程 digest 失败时的错误
+}
+
+// MarshalJSON 实现 json.Marshaler 接口，Error 输出为错误信息
+func (u ImageUpdate) MarshalJSON() ([]byte, error) {
+	type plain ImageUpdate
+	out := struct {
+		plain
+		Error string `json:"error,omitempty"`
+	}{plain: plain(u)}
+	if u.Error != nil {
+		out.Error = u.Error.Error()
+	}
+	return json.Marshal(out)
+}
+
+// CheckUpdates 批量获取本地镜像标签的远程 digest，与本地记录的 digest 比较，报告哪些镜像有可用的更新
+// 只发送 HEAD 请求，不拉取镜像；流程与 GetDigests 相同，opts.Platform 不生效（本地记录的是拉取时的索引 digest）
+// 本地镜像没有记录 digest 时不访问 registry，状态为 UpdateStatusUnknown；结果顺序与 images 一致
+func (c *Client) CheckUpdates(ctx context.Context, images []LocalImage, opts BatchOptions) []ImageUpdate {
+	updates := make([]ImageUpdate, len(images))
+	var specs []ImageSpec
+	var indexes []int
+	for i, image := range images {
+		updates[i] = ImageUpdate{LocalImage: image, Status: UpdateStatusUnknown}
+		if len(image.Digests) > 0 {
+			specs = append(specs, ImageSpec{Image: image.Image, Tag: image.Tag})
+			indexes = append(indexes, i)
+		}
+	}
+	if len(specs) == 0 {
+		return updates
+	}
+
+	for i, result := range c.GetDigests(ctx, specs, opts) {
+		update := &updates[indexes[i]]
+		switch {
+		case result.Error != nil:
+			update.Status, update.Error = UpdateStatusFailed, result.Error
+		case slices.Contains(update.Digests, result.Digest):
+			update.Status, update.RemoteDigest = UpdateStatusUpToDate, result.Digest
+		default:
+			update.Status, update.RemoteDigest = UpdateStatusAvailable, result.Digest
+		}
+	}
+	return updates
+}
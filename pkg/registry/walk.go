@@ -0,0 +1,127 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SkipIndex 可由 IndexVisitor 返回，表示不再展开当前索引的子节点
+// 对非索引描述符返回时没有效果
+var SkipIndex = errors.New("跳过当前索引")
+
+// IndexVisitor 是 IndexWalk 对每个描述符调用的访问函数
+// desc: 当前描述符；parents: 从根节点到父节点的描述符路径（根节点为空）
+// 返回 SkipIndex 跳过当前索引的子节点，返回其他错误会终止遍历
+type IndexVisitor func(desc Descriptor, parents []Descriptor) error
+
+// IndexWalk 从 image:ref 开始递归遍历索引，对每个描述符调用 visitor
+// ref 可以是标签或 digest
+// 支持多层嵌套的索引（index → index → manifest），子 manifest 只访问描述符，不下载内容
+// 遍历顺序为深度优先，与索引中的顺序一致
+// 嵌套深度、子 manifest 数量和描述符总数受 WithIndexLimits 限制，超出时返回 *IndexLimitError
+func (c *Client) IndexWalk(image, ref string, visitor IndexVisitor) error {
+	ctx := context.Background()
+	if err := c.checkImagePolicy(image); err != nil {
+		return err
+	}
+
+	target, err := c.resolveTarget(image)
+	if err != nil {
+		return err
+	}
+	authorization, err := c.authorize(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	root, err := c.fetchManifest(ctx, target, authorization, ref)
+	if err != nil {
+		return err
+	}
+
+	rootDesc := Descriptor{
+		MediaType: root.mediaType,
+		Digest:    root.digest,
+		Size:      int64(len(root.body)),
+	}
+	if rootDesc.Digest == "" {
+		rootDesc.Digest = computeDigest(root.body)
+	}
+
+	walker := &indexWalker{
+		ctx:           ctx,
+		client:        c,
+		target:        target,
+		authorization: authorization,
+		visitor:       visitor,
+		limits:        c.getIndexLimits(),
+	}
+	return walker.walk(rootDesc, root, nil)
+}
+
+// indexWalker 保存一次遍历的状态
+type indexWalker struct {
+	ctx           context.Context
+	client        *Client
+	target        *registryTarget
+	authorization string
+	visitor       IndexVisitor
+	limits        IndexLimits
+	visited       int // 已访问的描述符数量
+}
+
+// walk 访问描述符，如果是索引则继续展开其子节点
+// manifest 为该描述符已获取的内容，为 nil 时按需获取
+func (w *indexWalker) walk(desc Descriptor, manifest *fetchedManifest, parents []Descriptor) error {
+	w.visited++
+	if w.limits.MaxTotal > 0 && w.visited > w.limits.MaxTotal {
+		return &IndexLimitError{Limit: "total", Max: w.limits.MaxTotal, Actual: w.visited, Digest: parents[0].Digest}
+	}
+
+	err := w.visitor(desc, parents)
+	if errors.Is(err, SkipIndex) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !IsIndexMediaType(desc.MediaType) {
+		return nil
+	}
+
+	// 防止异常的 registry 返回循环引用或超深嵌套
+	if w.limits.MaxDepth > 0 && len(parents) >= w.limits.MaxDepth {
+		return &IndexLimitError{Limit: "depth", Max: w.limits.MaxDepth, Actual: len(parents) + 1, Digest: desc.Digest}
+	}
+	for _, parent := range parents {
+		if parent.Digest == desc.Digest {
+			return fmt.Errorf("索引存在循环引用: %s", desc.Digest)
+		}
+	}
+
+	if manifest == nil {
+		manifest, err = w.client.fetchManifest(w.ctx, w.target, w.authorization, desc.Digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	var index ImageIndex
+	if err := json.Unmarshal(manifest.body, &index); err != nil {
+		return fmt.Errorf("解析镜像索引 %s 失败: %w", desc.Digest, err)
+	}
+	if err := w.limits.checkChildren(desc.Digest, &index); err != nil {
+		return err
+	}
+
+	childParents := append(append([]Descriptor(nil), parents...), desc)
+	for _, child := range index.Manifests {
+		if err := w.walk(child, nil, childParents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
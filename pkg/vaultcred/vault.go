@@ -0,0 +1,396 @@
+// Package vaultcred 提供从 HashiCorp Vault KV 读取 registry 凭据的 registry.CredentialProvider 实现
+// 适用于禁止通过环境变量或命令行传递 registry token 的组织
+//
+// 使用方式：
+//
+//	provider, err := vaultcred.New(vaultcred.Options{
+//		Addr:       "https://vault.example.com:8200",
+//		PathPrefix: "ci/registries/",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	client := registry.NewClient().WithCredentialProvider(provider)
+//
+// 凭据 "dockerhub" 从 secret/data/ci/registries/dockerhub 读取，secret 中包含 username 和 token（或 password）字段
+// 通过 Vault HTTP API 直接通信，不依赖 Vault SDK
+package vaultcred
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker-make/docker-mainifest/pkg/registry"
+	"golang.org/x/sync/singleflight"
+)
+
+// 默认配置
+const (
+	DefaultMount           = "secret"
+	DefaultKVVersion       = 2
+	DefaultRefreshInterval = 5 * time.Minute
+	DefaultErrorBackoff    = 30 * time.Second
+	DefaultTimeout         = 10 * time.Second
+	DefaultUsernameField   = "username"
+	DefaultTokenField      = "token"
+)
+
+// Options 表示 Vault 连接和凭据路径配置
+type Options struct {
+	Addr      string // Vault 地址，如 https://vault.example.com:8200；为空时使用环境变量 VAULT_ADDR
+	Token     string // Vault token；为空时依次使用 TokenFile、环境变量 VAULT_TOKEN 和 ~/.vault-token
+	TokenFile string // 保存 Vault token 的文件（如 Vault Agent 的 sink 文件），每次请求前重新读取
+	Namespace string // Vault Enterprise 命名空间；为空时使用环境变量 VAULT_NAMESPACE
+
+	Mount      string            // KV 引擎的挂载路径，为空时使用 DefaultMount
+	KVVersion  int               // KV 引擎版本（1 或 2），0 使用 DefaultKVVersion
+	Paths      map[string]string // 凭据 key -> secret 路径（相对于 Mount），优先于 PathPrefix
+	PathPrefix string            // 未在 Paths 中列出的凭据 key 从 PathPrefix+key 读取；为空时只读取 Paths 中的 key
+
+	UsernameField string // 用户名字段，为空时使用 DefaultUsernameField
+	TokenField    string // token 字段，为空时使用 DefaultTokenField，secret 中没有该字段时使用 password 字段
+
+	RefreshInterval time.Duration // 凭据的缓存时间，之后重新读取 Vault（secret 的租约更短时以租约为准），0 使用 DefaultRefreshInterval
+	ErrorBackoff    time.Duration // 读取失败后等待多久再重新读取 Vault，期间直接返回上次的错误（或上次读取的凭据），0 使用 DefaultErrorBackoff
+	RenewToken      bool          // 是否在 Vault token 有效期过半时自动续期（auth/token/renew-self）
+	Timeout         time.Duration // 读取一个凭据（包括续期 token）的超时，0 使用 DefaultTimeout
+	HTTPClient      *http.Client  // 访问 Vault 使用的 HTTP 客户端，为 nil 时使用超时为 DefaultTimeout 的客户端
+}
+
+// Provider 从 Vault KV 读取 registry 凭据，实现 registry.CredentialProvider 接口
+// 读取的凭据在 RefreshInterval 内复用；重新读取失败时继续使用上次读取的凭据，错误可通过 OnError 记录
+// 读取失败后 ErrorBackoff 内不再访问 Vault，Vault 不可用或拒绝访问时每个 key 只产生一次请求和一个错误
+type Provider struct {
+	opts Options
+
+	// OnError 在读取凭据或续期 token 失败、但仍可使用缓存的凭据时调用，可用于记录日志；为 nil 时忽略错误
+	OnError func(err error)
+
+	mu      sync.Mutex
+	entries map[string]*entry // 凭据 key -> 读取结果
+	renewAt time.Time         // 下次续期 Vault token 的时间
+	group   singleflight.Group
+}
+
+// entry 表示一次读取的结果，cred 和 err 都为 nil 表示 Vault 中没有该 secret
+type entry struct {
+	cred      *registry.RegistryCredential
+	err       error // 读取失败且没有可用的凭据时的错误，在 expiresAt 之前直接返回
+	expiresAt time.Time
+}
+
+// 编译期检查 Provider 实现了 registry.CredentialProvider 接口
+var _ registry.CredentialProvider = (*Provider)(nil)
+
+// New 创建 Vault 凭据提供者，凭据在第一次使用时读取
+func New(opts Options) (*Provider, error) {
+	if opts.Addr == "" {
+		opts.Addr = os.Getenv("VAULT_ADDR")
+	}
+	if opts.Addr == "" {
+		return nil, errors.New("vault: 未指定地址 (Addr 或 VAULT_ADDR)")
+	}
+	opts.Addr = strings.TrimSuffix(opts.Addr, "/")
+	if opts.Namespace == "" {
+		opts.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if opts.Mount == "" {
+		opts.Mount = DefaultMount
+	}
+	opts.Mount = strings.Trim(opts.Mount, "/")
+	if opts.KVVersion == 0 {
+		opts.KVVersion = DefaultKVVersion
+	}
+	if opts.KVVersion != 1 && opts.KVVersion != 2 {
+		return nil, fmt.Errorf("vault: 不支持的 KV 版本 %d，应为 1 或 2", opts.KVVersion)
+	}
+	if len(opts.Paths) == 0 && opts.PathPrefix == "" {
+		return nil, errors.New("vault: 必须指定 Paths 或 PathPrefix")
+	}
+	if opts.UsernameField == "" {
+		opts.UsernameField = DefaultUsernameField
+	}
+	if opts.TokenField == "" {
+		opts.TokenField = DefaultTokenField
+	}
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = DefaultRefreshInterval
+	}
+	if opts.ErrorBackoff <= 0 {
+		opts.ErrorBackoff = DefaultErrorBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	p := &Provider{opts: opts, entries: make(map[string]*entry)}
+	if _, err := p.token(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetCredential 实现 registry.CredentialProvider 接口
+// 没有为 registryKey 配置路径或 Vault 中没有该 secret 时返回 nil, nil
+// 读取失败的结果缓存 ErrorBackoff，批量操作中每次认证都会查询凭据，不缓存会在 Vault 故障期间反复请求 Vault
+// 并发调用共享的读取受 Options.Timeout 限制，ctx 只决定调用方等待多久；取消和超时不缓存
+func (p *Provider) GetCredential(ctx context.Context, registryKey string) (*registry.RegistryCredential, error) {
+	path, ok := p.path(registryKey)
+	if !ok {
+		return nil, nil
+	}
+
+	p.mu.Lock()
+	cached, ok := p.entries[registryKey]
+	p.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.cred, cached.err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// 同一 key 的并发调用共享一次读取，读取不使用某一个调用方的 ctx，避免一个调用方取消或超时使其他调用方都失败
+	flight := p.group.DoChan(registryKey, func() (any, error) {
+		readCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.opts.Timeout)
+		defer cancel()
+		p.renewToken(readCtx)
+		read, err := p.read(readCtx, path)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+				// 超时不一定说明 Vault 不可用，不缓存
+				return nil, err
+			}
+			read = &entry{err: err, expiresAt: time.Now().Add(p.opts.ErrorBackoff)}
+			if ok && cached.err == nil {
+				// Vault 暂时不可用时继续使用上次读取的凭据
+				p.reportError(err)
+				read = &entry{cred: cached.cred, expiresAt: read.expiresAt}
+			}
+		}
+		p.mu.Lock()
+		p.entries[registryKey] = read
+		p.mu.Unlock()
+		return read, nil
+	})
+	select {
+	case result := <-flight:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		read := result.Val.(*entry)
+		return read.cred, read.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Invalidate 清除缓存的凭据，下次使用时重新读取 Vault；registryKey 为空时清除所有凭据
+// 可在 registry 拒绝凭据（如凭据已在 Vault 中轮换）时调用
+func (p *Provider) Invalidate(registryKey string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if registryKey == "" {
+		p.entries = make(map[string]*entry)
+		return
+	}
+	delete(p.entries, registryKey)
+}
+
+// path 返回凭据 key 对应的 secret 路径
+func (p *Provider) path(registryKey string) (string, bool) {
+	if path, ok := p.opts.Paths[registryKey]; ok {
+		return strings.Trim(path, "/"), true
+	}
+	if p.opts.PathPrefix == "" || registryKey == "" {
+		return "", false
+	}
+	return strings.Trim(p.opts.PathPrefix, "/") + "/" + registryKey, true
+}
+
+// secretResponse 表示 Vault 读取 secret 的响应
+type secretResponse struct {
+	LeaseDuration int             `json:"lease_duration"`
+	Data          json.RawMessage `json:"data"`
+	Auth          *struct {
+		LeaseDuration int `json:"lease_duration"`
+	} `json:"auth"`
+}
+
+// read 从 Vault 读取 secret 并解析为凭据
+func (p *Provider) read(ctx context.Context, path string) (*entry, error) {
+	apiPath := p.opts.Mount + "/" + escapePath(path)
+	if p.opts.KVVersion == 2 {
+		apiPath = p.opts.Mount + "/data/" + escapePath(path)
+	}
+	resp, status, err := p.do(ctx, "GET", apiPath)
+	if status == http.StatusNotFound {
+		return &entry{expiresAt: time.Now().Add(p.opts.RefreshInterval)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vault: 读取 %s 失败: %w", apiPath, err)
+	}
+
+	data := resp.Data
+	if p.opts.KVVersion == 2 {
+		var v2 struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &v2); err != nil {
+			return nil, fmt.Errorf("vault: 解析 %s 失败: %w", apiPath, err)
+		}
+		data = v2.Data
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("vault: 解析 %s 失败: %w", apiPath, err)
+	}
+
+	username, _ := fields[p.opts.UsernameField].(string)
+	token, _ := fields[p.opts.TokenField].(string)
+	if token == "" {
+		token, _ = fields["password"].(string)
+	}
+	if username == "" || token == "" {
+		return nil, fmt.Errorf("vault: %s 中缺少 %s 或 %s 字段", apiPath, p.opts.UsernameField, p.opts.TokenField)
+	}
+
+	ttl := p.opts.RefreshInterval
+	if lease := time.Duration(resp.LeaseDuration) * time.Second; lease > 0 && lease < ttl {
+		ttl = lease
+	}
+	return &entry{
+		cred:      &registry.RegistryCredential{Username: username, Token: token},
+		expiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// renewToken 启用了 RenewToken 且 Vault token 有效期已过半时续期 token
+// 失败时通过 OnError 记录，下次读取凭据时重试
+func (p *Provider) renewToken(ctx context.Context) {
+	if !p.opts.RenewToken {
+		return
+	}
+	p.mu.Lock()
+	due := !time.Now().Before(p.renewAt)
+	p.mu.Unlock()
+	if !due {
+		return
+	}
+
+	resp, _, err := p.do(ctx, "POST", "auth/token/renew-self")
+	if err != nil {
+		p.reportError(fmt.Errorf("vault: 续期 token 失败: %w", err))
+		return
+	}
+	// 不会过期的 token（如 root token）按刷新间隔检查
+	next := p.opts.RefreshInterval
+	if resp.Auth != nil && resp.Auth.LeaseDuration > 0 {
+		next = time.Duration(resp.Auth.LeaseDuration) * time.Second / 2
+	}
+	p.mu.Lock()
+	p.renewAt = time.Now().Add(next)
+	p.mu.Unlock()
+}
+
+// do 发送 Vault API 请求，返回解析后的响应和状态码
+func (p *Provider) do(ctx context.Context, method, apiPath string) (*secretResponse, int, error) {
+	token, err := p.token()
+	if err != nil {
+		return nil, 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.opts.Addr+"/v1/"+apiPath, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	req.Header.Set("X-Vault-Request", "true")
+	if p.opts.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.opts.Namespace)
+	}
+
+	resp, err := p.opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, fmt.Errorf("状态码 %d: %s", resp.StatusCode, vaultErrors(body))
+	}
+	var result secretResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("解析响应失败: %w", err)
+	}
+	return &result, resp.StatusCode, nil
+}
+
+// token 返回访问 Vault 使用的 token
+func (p *Provider) token() (string, error) {
+	if p.opts.Token != "" {
+		return p.opts.Token, nil
+	}
+	if p.opts.TokenFile != "" {
+		data, err := os.ReadFile(p.opts.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("vault: 读取 token 文件失败: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if data, err := os.ReadFile(filepath.Join(home, ".vault-token")); err == nil {
+			if token := strings.TrimSpace(string(data)); token != "" {
+				return token, nil
+			}
+		}
+	}
+	return "", errors.New("vault: 未找到 token (Token、TokenFile、VAULT_TOKEN 或 ~/.vault-token)")
+}
+
+// reportError 调用 OnError 记录错误
+func (p *Provider) reportError(err error) {
+	if p.OnError != nil {
+		p.OnError(err)
+	}
+}
+
+// vaultErrors 返回 Vault 错误响应中的错误信息，无法解析时返回原始内容
+func vaultErrors(body []byte) string {
+	var resp struct {
+		Errors []string `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil && len(resp.Errors) > 0 {
+		return strings.Join(resp.Errors, "; ")
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// escapePath 对 secret 路径的每一段进行转义
+func escapePath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
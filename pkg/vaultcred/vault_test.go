@@ -0,0 +1,167 @@
+package vaultcred
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestVault 启动模拟的 Vault，status 为 200 时返回 ci/registries/dockerhub 的凭据，否则返回该状态码
+func newTestVault(t *testing.T, status *atomic.Int32, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if code := int(status.Load()); code != http.StatusOK {
+			w.WriteHeader(code)
+			fmt.Fprint(w, `{"errors":["permission denied"]}`)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/ci/registries/dockerhub" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[]}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"data":{"username":"u","token":"t"}}}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGetCredentialCachesError(t *testing.T) {
+	var status, requests atomic.Int32
+	status.Store(http.StatusForbidden)
+	srv := newTestVault(t, &status, &requests)
+	p, err := New(Options{Addr: srv.URL, Token: "vt", PathPrefix: "ci/registries/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if cred, err := p.GetCredential(context.Background(), "dockerhub"); err == nil || cred != nil {
+			t.Fatalf("GetCredential = %v, %v, 期望错误", cred, err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Vault 请求次数 = %d, 期望 1", n)
+	}
+
+	// Invalidate 后立即重试
+	status.Store(http.StatusOK)
+	p.Invalidate("dockerhub")
+	cred, err := p.GetCredential(context.Background(), "dockerhub")
+	if err != nil || cred == nil || cred.Username != "u" {
+		t.Fatalf("GetCredential = %v, %v, 期望 Vault 中的凭据", cred, err)
+	}
+}
+
+func TestGetCredentialStaleDuringBackoff(t *testing.T) {
+	var status, requests atomic.Int32
+	status.Store(http.StatusOK)
+	srv := newTestVault(t, &status, &requests)
+	p, err := New(Options{Addr: srv.URL, Token: "vt", PathPrefix: "ci/registries/", RefreshInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reported atomic.Int32
+	p.OnError = func(error) { reported.Add(1) }
+
+	if _, err := p.GetCredential(context.Background(), "dockerhub"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	status.Store(http.StatusServiceUnavailable)
+	for i := 0; i < 3; i++ {
+		cred, err := p.GetCredential(context.Background(), "dockerhub")
+		if err != nil || cred == nil || cred.Token != "t" {
+			t.Fatalf("GetCredential = %v, %v, 期望上次读取的凭据", cred, err)
+		}
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Vault 请求次数 = %d, 期望 2", n)
+	}
+	if n := reported.Load(); n != 1 {
+		t.Errorf("OnError 调用次数 = %d, 期望 1", n)
+	}
+}
+
+func TestGetCredentialCanceledNotCached(t *testing.T) {
+	var status, requests atomic.Int32
+	status.Store(http.StatusOK)
+	srv := newTestVault(t, &status, &requests)
+	p, err := New(Options{Addr: srv.URL, Token: "vt", PathPrefix: "ci/registries/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.GetCredential(ctx, "dockerhub"); err == nil {
+		t.Fatal("GetCredential 使用已取消的 ctx 时期望错误")
+	}
+	cred, err := p.GetCredential(context.Background(), "dockerhub")
+	if err != nil || cred == nil {
+		t.Fatalf("GetCredential = %v, %v, 期望 Vault 中的凭据", cred, err)
+	}
+}
+
+func TestGetCredentialLeaderCanceled(t *testing.T) {
+	arrived := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		arrived <- struct{}{}
+		<-release
+		fmt.Fprint(w, `{"data":{"data":{"username":"u","token":"t"}}}`)
+	}))
+	t.Cleanup(srv.Close)
+	p, err := New(Options{Addr: srv.URL, Token: "vt", PathPrefix: "ci/registries/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一个调用方发起读取，第二个调用方等待同一次读取
+	ctx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		_, err := p.GetCredential(ctx, "dockerhub")
+		leader <- err
+	}()
+	<-arrived
+	type result struct {
+		username string
+		err      error
+	}
+	waiter := make(chan result, 1)
+	go func() {
+		cred, err := p.GetCredential(context.Background(), "dockerhub")
+		if cred == nil {
+			waiter <- result{err: err}
+			return
+		}
+		waiter <- result{cred.Username, err}
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// 第一个调用方取消后立即返回，共享的读取继续进行
+	cancel()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Fatalf("取消的调用方错误 = %v, 期望 context.Canceled", err)
+	}
+	close(release)
+	if got := <-waiter; got.err != nil || got.username != "u" {
+		t.Fatalf("等待的调用方 = %q, %v, 期望 Vault 中的凭据", got.username, got.err)
+	}
+	cred, err := p.GetCredential(context.Background(), "dockerhub")
+	if err != nil || cred == nil || cred.Username != "u" {
+		t.Fatalf("GetCredential = %v, %v, 期望缓存的凭据", cred, err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Vault 请求次数 = %d, 期望 1", n)
+	}
+}